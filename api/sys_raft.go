@@ -2,11 +2,14 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/mitchellh/mapstructure"
 )
 
 // RaftJoinResponse represents the response of the raft join API
@@ -27,6 +30,39 @@ type RaftJoinRequest struct {
 	NonVoter         bool   `json:"non_voter"`
 }
 
+// AutopilotConfig is the configuration of autopilot, which tracks the health
+// of the servers in the raft cluster.
+type AutopilotConfig struct {
+	CleanupDeadServers             bool   `json:"cleanup_dead_servers" mapstructure:"cleanup_dead_servers"`
+	LastContactThreshold           string `json:"last_contact_threshold" mapstructure:"last_contact_threshold"`
+	DeadServerLastContactThreshold string `json:"dead_server_last_contact_threshold" mapstructure:"dead_server_last_contact_threshold"`
+	MaxTrailingLogs                uint64 `json:"max_trailing_logs" mapstructure:"max_trailing_logs"`
+	MinQuorum                      uint   `json:"min_quorum" mapstructure:"min_quorum"`
+	ServerStabilizationTime        string `json:"server_stabilization_time" mapstructure:"server_stabilization_time"`
+}
+
+// AutopilotState represents the response of the raft autopilot state API
+type AutopilotState struct {
+	Healthy          bool                        `mapstructure:"healthy"`
+	FailureTolerance int                         `mapstructure:"failure_tolerance"`
+	Leader           string                      `mapstructure:"leader"`
+	Voters           []string                    `mapstructure:"voters"`
+	Servers          map[string]*AutopilotServer `mapstructure:"servers"`
+}
+
+// AutopilotServer represents the state of a server in the response of the
+// raft autopilot state API
+type AutopilotServer struct {
+	ID          string `mapstructure:"id"`
+	Address     string `mapstructure:"address"`
+	Status      string `mapstructure:"status"`
+	NodeStatus  string `mapstructure:"node_status"`
+	Healthy     bool   `mapstructure:"healthy"`
+	LastContact string `mapstructure:"last_contact"`
+	LastIndex   uint64 `mapstructure:"last_index"`
+	StableSince string `mapstructure:"stable_since"`
+}
+
 // RaftJoin adds the node from which this call is invoked from to the raft
 // cluster represented by the leader address in the parameter.
 func (c *Sys) RaftJoin(opts *RaftJoinRequest) (*RaftJoinResponse, error) {
@@ -160,3 +196,122 @@ func (c *Sys) RaftSnapshotRestore(snapReader io.Reader, force bool) error {
 
 	return nil
 }
+
+// RaftSnapshotRestoreMountsResponse represents the response of the raft
+// snapshot mounts restore API
+type RaftSnapshotRestoreMountsResponse struct {
+	// Keys is the number of keys restored for each mount.
+	Keys map[string]int `mapstructure:"keys"`
+}
+
+// RaftSnapshotRestoreMounts reads the snapshot from the io.Reader and restores
+// the data of the given mounts from it, leaving the rest of the cluster
+// untouched.
+func (c *Sys) RaftSnapshotRestoreMounts(snapReader io.Reader, mounts []string) (*RaftSnapshotRestoreMountsResponse, error) {
+	r := c.c.NewRequest("POST", "/v1/sys/storage/raft/snapshot-restore-mounts")
+	r.Params.Set("mounts", strings.Join(mounts, ","))
+
+	r.Body = snapReader
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+	resp, err := c.c.RawRequestWithContext(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	secret, err := ParseSecret(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("data from server response is empty")
+	}
+
+	var result RaftSnapshotRestoreMountsResponse
+	err = mapstructure.Decode(secret.Data, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, err
+}
+
+// RaftAutopilotState returns the state of the raft cluster as seen by
+// autopilot.
+func (c *Sys) RaftAutopilotState() (*AutopilotState, error) {
+	r := c.c.NewRequest("GET", "/v1/sys/storage/raft/autopilot/state")
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+	resp, err := c.c.RawRequestWithContext(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	secret, err := ParseSecret(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("data from server response is empty")
+	}
+
+	var result AutopilotState
+	err = mapstructure.Decode(secret.Data, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, err
+}
+
+// RaftAutopilotConfiguration returns the configuration of autopilot.
+func (c *Sys) RaftAutopilotConfiguration() (*AutopilotConfig, error) {
+	r := c.c.NewRequest("GET", "/v1/sys/storage/raft/autopilot/configuration")
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+	resp, err := c.c.RawRequestWithContext(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	secret, err := ParseSecret(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("data from server response is empty")
+	}
+
+	var result AutopilotConfig
+	err = mapstructure.Decode(secret.Data, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, err
+}
+
+// PutRaftAutopilotConfiguration replaces the configuration of autopilot.
+func (c *Sys) PutRaftAutopilotConfiguration(opts *AutopilotConfig) error {
+	r := c.c.NewRequest("POST", "/v1/sys/storage/raft/autopilot/configuration")
+
+	if err := r.SetJSONBody(opts); err != nil {
+		return err
+	}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+	resp, err := c.c.RawRequestWithContext(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/hashicorp/vault/sdk/helper/consts"
+)
+
+// TransitStreamInput holds the parameters of a streaming encrypt or decrypt
+// request to the transit secrets engine.
+type TransitStreamInput struct {
+	// Mount is the path the transit secrets engine is mounted at. Defaults
+	// to "transit".
+	Mount string
+
+	// Name is the name of the transit key.
+	Name string
+
+	// Context is the key derivation context, required for derived keys.
+	Context []byte
+
+	// KeyVersion is the version of the key used to wrap the data key when
+	// encrypting. Zero uses the latest version.
+	KeyVersion int
+
+	// ChunkSize is the number of plaintext bytes sealed per chunk when
+	// encrypting. Zero uses the server default.
+	ChunkSize int
+}
+
+// TransitEncryptStream encrypts everything read from plaintext with a new data
+// key protected by the named transit key, writing the resulting framed
+// ciphertext to ciphertext as it is received.
+func (c *Logical) TransitEncryptStream(ctx context.Context, input *TransitStreamInput, plaintext io.Reader, ciphertext io.Writer) error {
+	return c.transitStream(ctx, "encrypt", input, plaintext, ciphertext)
+}
+
+// TransitDecryptStream decrypts a framed ciphertext produced by
+// TransitEncryptStream, writing the plaintext to plaintext as it is received.
+// If an error is returned, any plaintext written must be discarded as the
+// stream failed to authenticate.
+func (c *Logical) TransitDecryptStream(ctx context.Context, input *TransitStreamInput, ciphertext io.Reader, plaintext io.Writer) error {
+	return c.transitStream(ctx, "decrypt", input, ciphertext, plaintext)
+}
+
+func (c *Logical) transitStream(ctx context.Context, op string, input *TransitStreamInput, in io.Reader, out io.Writer) error {
+	if input == nil || input.Name == "" {
+		return errors.New("missing key name")
+	}
+	mount := input.Mount
+	if mount == "" {
+		mount = "transit"
+	}
+
+	r := c.c.NewRequest("POST", fmt.Sprintf("/v1/%s/stream/%s/%s", mount, op, input.Name))
+	if len(input.Context) > 0 {
+		r.Params.Set("context", base64.StdEncoding.EncodeToString(input.Context))
+	}
+	if input.KeyVersion != 0 {
+		r.Params.Set("key_version", strconv.Itoa(input.KeyVersion))
+	}
+	if input.ChunkSize != 0 {
+		r.Params.Set("chunk_size", strconv.Itoa(input.ChunkSize))
+	}
+	r.URL.RawQuery = r.Params.Encode()
+
+	// The body is streamed rather than handed to RawRequestWithContext, which
+	// reads it into memory to be able to retry the request
+	req, err := http.NewRequest(http.MethodPost, r.URL.RequestURI(), ioutil.NopCloser(in))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+
+	req.URL.User = r.URL.User
+	req.URL.Scheme = r.URL.Scheme
+	req.URL.Host = r.URL.Host
+	req.Host = r.URL.Host
+
+	if r.Headers != nil {
+		for header, vals := range r.Headers {
+			for _, val := range vals {
+				req.Header.Add(header, val)
+			}
+		}
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	if len(r.ClientToken) != 0 {
+		req.Header.Set(consts.AuthHeaderName, r.ClientToken)
+	}
+
+	if len(r.MFAHeaderVals) != 0 {
+		for _, mfaHeaderVal := range r.MFAHeaderVals {
+			req.Header.Add("X-Vault-MFA", mfaHeaderVal)
+		}
+	}
+
+	if r.PolicyOverride {
+		req.Header.Set("X-Vault-Policy-Override", "true")
+	}
+
+	resp, err := c.c.config.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// The request body has been consumed, so redirects cannot be followed
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		return fmt.Errorf("unexpected redirect to %q; streaming requests must be sent to the active node", resp.Header.Get("Location"))
+	}
+
+	result := &Response{Response: resp}
+	if err := result.Error(); err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		return err
+	}
+
+	// Errors occurring after the server started responding are reported in
+	// a trailer, which is only available once the body has been read
+	if streamErr := resp.Trailer.Get(consts.StreamErrorTrailerName); streamErr != "" {
+		return fmt.Errorf("stream %s failed: %s", op, streamErr)
+	}
+
+	return nil
+}
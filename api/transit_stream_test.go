@@ -0,0 +1,75 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/helper/consts"
+)
+
+func TestLogical_TransitStream(t *testing.T) {
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Content-Type") != "application/octet-stream" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+
+		switch req.URL.Path {
+		case "/v1/transit/stream/encrypt/files":
+			if req.URL.Query().Get("chunk_size") != "1024" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(strings.ToUpper(string(body))))
+		case "/v1/transit/stream/decrypt/files":
+			w.Header().Set("Trailer", consts.StreamErrorTrailerName)
+			w.Write(body)
+			w.Header().Set(consts.StreamErrorTrailerName, "invalid stream: message authentication failed")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":["no handler for route"]}`))
+		}
+	}
+
+	config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+	defer ln.Close()
+
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := new(bytes.Buffer)
+	err = client.Logical().TransitEncryptStream(context.Background(), &TransitStreamInput{
+		Name:      "files",
+		ChunkSize: 1024,
+	}, strings.NewReader("plaintext"), out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "PLAINTEXT" {
+		t.Fatalf("bad output: %q", out.String())
+	}
+
+	// Errors reported in the trailer are returned
+	err = client.Logical().TransitDecryptStream(context.Background(), &TransitStreamInput{
+		Name: "files",
+	}, strings.NewReader("ciphertext"), ioutil.Discard)
+	if err == nil || !strings.Contains(err.Error(), "message authentication failed") {
+		t.Fatalf("expected stream error, got %v", err)
+	}
+
+	// Error responses are returned
+	err = client.Logical().TransitDecryptStream(context.Background(), &TransitStreamInput{
+		Mount: "other",
+		Name:  "files",
+	}, strings.NewReader("ciphertext"), ioutil.Discard)
+	if respErr, ok := err.(*ResponseError); !ok || respErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
@@ -0,0 +1,568 @@
+package pki
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const (
+	configACMEPath = "config/acme"
+
+	// ACME state is kept per account; orders and authorizations are stored
+	// beneath their account so that ownership is implied by the path.
+	acmeAccountPrefix    = "acme/accounts/"
+	acmeThumbprintPrefix = "acme/account-thumbprints/"
+	acmeCertPrefix       = "acme/certs/"
+	acmeNoncePrefix      = "acme/nonces/"
+
+	acmeNonceTTL = 30 * time.Minute
+	acmeAuthzTTL = 24 * time.Hour
+
+	acmeJSONContentType        = "application/json"
+	acmeProblemContentType     = "application/problem+json"
+	acmeCertificateContentType = "application/pem-certificate-chain"
+
+	acmeStatusPending     = "pending"
+	acmeStatusReady       = "ready"
+	acmeStatusProcessing  = "processing"
+	acmeStatusValid       = "valid"
+	acmeStatusInvalid     = "invalid"
+	acmeStatusDeactivated = "deactivated"
+)
+
+// acmeSignatureAlgorithms are the JWS algorithms accepted from clients.
+// RFC 8555 forbids "none" and MAC-based algorithms.
+var acmeSignatureAlgorithms = []string{
+	string(jose.RS256),
+	string(jose.ES256),
+	string(jose.ES384),
+	string(jose.ES512),
+	string(jose.EdDSA),
+}
+
+// acmeConfigEntry controls which roles may be used through ACME. It is
+// stored at config/acme.
+type acmeConfigEntry struct {
+	Enabled      bool     `json:"enabled"`
+	AllowedRoles []string `json:"allowed_roles"`
+	DefaultRole  string   `json:"default_role"`
+}
+
+type acmeAccount struct {
+	ID          string          `json:"id"`
+	Key         json.RawMessage `json:"key"`
+	Thumbprint  string          `json:"thumbprint"`
+	Contact     []string        `json:"contact"`
+	Status      string          `json:"status"`
+	Role        string          `json:"role"`
+	CreatedTime time.Time       `json:"created_time"`
+}
+
+type acmeIdentifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type acmeOrder struct {
+	ID                string           `json:"id"`
+	AccountID         string           `json:"account_id"`
+	Status            string           `json:"status"`
+	Expires           time.Time        `json:"expires"`
+	Identifiers       []acmeIdentifier `json:"identifiers"`
+	AuthorizationIDs  []string         `json:"authorization_ids"`
+	CertificateSerial string           `json:"certificate_serial"`
+	Certificate       string           `json:"certificate"`
+}
+
+type acmeChallenge struct {
+	Type      string     `json:"type"`
+	Token     string     `json:"token"`
+	Status    string     `json:"status"`
+	Validated time.Time  `json:"validated"`
+	Error     *acmeError `json:"error,omitempty"`
+}
+
+type acmeAuthorization struct {
+	ID         string           `json:"id"`
+	AccountID  string           `json:"account_id"`
+	Identifier acmeIdentifier   `json:"identifier"`
+	Status     string           `json:"status"`
+	Expires    time.Time        `json:"expires"`
+	Wildcard   bool             `json:"wildcard"`
+	Challenges []*acmeChallenge `json:"challenges"`
+}
+
+// acmeError is an RFC 7807 problem document using the ACME error types
+type acmeError struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+	Status int    `json:"status,omitempty"`
+}
+
+func (e *acmeError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Type, e.Detail)
+}
+
+func newACMEError(errType string, status int, format string, args ...interface{}) *acmeError {
+	return &acmeError{
+		Type:   "urn:ietf:params:acme:error:" + errType,
+		Detail: fmt.Sprintf(format, args...),
+		Status: status,
+	}
+}
+
+// acmeContext describes the directory a request was made against
+type acmeContext struct {
+	// baseURL is the external URL of the directory, without a trailing
+	// slash
+	baseURL  string
+	roleName string
+	role     *roleEntry
+}
+
+// acmeRequest is a verified JWS request body
+type acmeRequest struct {
+	payload []byte
+	jwk     *jose.JSONWebKey
+	account *acmeAccount
+}
+
+// acmeNonceStore tracks the nonces handed out to clients. Nonces are kept in
+// storage so that they remain valid after a leadership change; they are only
+// issued and consumed by the active node, to which standbys forward ACME
+// requests. Each nonce encodes the minute it expires in, and is stored
+// beneath it so that expired nonces can be dropped without reading them.
+type acmeNonceStore struct {
+	locks []*locksutil.LockEntry
+}
+
+func newACMENonceStore() *acmeNonceStore {
+	return &acmeNonceStore{
+		locks: locksutil.CreateLocks(),
+	}
+}
+
+func (n *acmeNonceStore) issue(ctx context.Context, s logical.Storage) (string, error) {
+	expiry := time.Now().Add(acmeNonceTTL).Truncate(time.Minute).Add(time.Minute)
+
+	raw := make([]byte, 24)
+	binary.BigEndian.PutUint64(raw, uint64(expiry.Unix()))
+	if _, err := rand.Read(raw[8:]); err != nil {
+		return "", err
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(raw)
+
+	if err := s.Put(ctx, &logical.StorageEntry{
+		Key:   acmeNonceKey(expiry.Unix(), nonce),
+		Value: []byte{},
+	}); err != nil {
+		return "", err
+	}
+
+	return nonce, nil
+}
+
+// consume returns whether the nonce was valid, and invalidates it
+func (n *acmeNonceStore) consume(ctx context.Context, s logical.Storage, nonce string) (bool, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(nonce)
+	if err != nil || len(raw) != 24 {
+		return false, nil
+	}
+	expiry := int64(binary.BigEndian.Uint64(raw))
+	if time.Now().Unix() >= expiry {
+		return false, nil
+	}
+
+	lock := locksutil.LockForKey(n.locks, nonce)
+	lock.Lock()
+	defer lock.Unlock()
+
+	key := acmeNonceKey(expiry, nonce)
+	entry, err := s.Get(ctx, key)
+	if err != nil || entry == nil {
+		return false, err
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// tidy removes the nonces that have expired without being used
+func (n *acmeNonceStore) tidy(ctx context.Context, s logical.Storage) error {
+	buckets, err := s.List(ctx, acmeNoncePrefix)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	for _, bucket := range buckets {
+		expiry, err := strconv.ParseInt(strings.TrimSuffix(bucket, "/"), 10, 64)
+		if err != nil || expiry > now {
+			continue
+		}
+		if err := logical.ClearView(ctx, logical.NewStorageView(s, acmeNoncePrefix+bucket)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func acmeNonceKey(expiry int64, nonce string) string {
+	return acmeNoncePrefix + strconv.FormatInt(expiry, 10) + "/" + nonce
+}
+
+// addACMEFields adds the fields shared by all ACME endpoints: the optional
+// role naming the directory, and the members of a flattened JWS.
+func addACMEFields(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["role"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `The role whose ACME directory is used.`,
+	}
+	fields["protected"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `JWS protected header.`,
+	}
+	fields["payload"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `JWS payload.`,
+	}
+	fields["signature"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `JWS signature.`,
+	}
+	return fields
+}
+
+// acmePattern returns a pattern matching the given endpoint within both the
+// default directory and role-specific directories
+func acmePattern(endpoint string) string {
+	return "acme/(roles/" + framework.GenericNameRegex("role") + "/)?" + endpoint
+}
+
+func getACMEConfig(ctx context.Context, s logical.Storage) (*acmeConfigEntry, error) {
+	entry, err := s.Get(ctx, configACMEPath)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &acmeConfigEntry{
+		AllowedRoles: []string{"*"},
+	}
+	if entry == nil {
+		return config, nil
+	}
+
+	if err := entry.DecodeJSON(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// acmeContextFor resolves the directory of the request, checking that ACME
+// is enabled and the role is allowed to be used with it
+func (b *backend) acmeContextFor(ctx context.Context, req *logical.Request, data *framework.FieldData) (*acmeContext, *acmeError) {
+	config, err := getACMEConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, newACMEError("serverInternal", http.StatusInternalServerError, "error reading ACME configuration")
+	}
+	if !config.Enabled {
+		return nil, newACMEError("serverInternal", http.StatusNotFound, "ACME is not enabled on this mount")
+	}
+
+	urlConfig, err := getURLConfig(ctx, req)
+	if err != nil {
+		return nil, newACMEError("serverInternal", http.StatusInternalServerError, "error reading URL configuration")
+	}
+	if urlConfig == nil || urlConfig.BaseURL == "" {
+		return nil, newACMEError("serverInternal", http.StatusInternalServerError, "base_url must be set in config/urls to use ACME")
+	}
+
+	roleName := data.Get("role").(string)
+	directory := "acme"
+	if roleName != "" {
+		directory = "acme/roles/" + roleName
+	} else {
+		roleName = config.DefaultRole
+		if roleName == "" {
+			return nil, newACMEError("serverInternal", http.StatusNotFound, "no default role is configured for ACME; use a role-specific directory")
+		}
+	}
+
+	if !strutil.StrListContains(config.AllowedRoles, "*") && !strutil.StrListContains(config.AllowedRoles, roleName) {
+		return nil, newACMEError("unauthorized", http.StatusForbidden, "role %q may not be used with ACME", roleName)
+	}
+
+	role, err := b.getRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, newACMEError("serverInternal", http.StatusInternalServerError, "error reading role")
+	}
+	if role == nil {
+		return nil, newACMEError("serverInternal", http.StatusNotFound, "unknown role %q", roleName)
+	}
+
+	return &acmeContext{
+		baseURL:  strings.TrimSuffix(urlConfig.BaseURL, "/") + "/" + directory,
+		roleName: roleName,
+		role:     role,
+	}, nil
+}
+
+// acmeVerify parses and verifies the JWS of a request. Requests signed with
+// an embedded key are only accepted if allowJWK is set; all others must
+// reference an existing account of the directory via "kid".
+func (b *backend) acmeVerify(ctx context.Context, req *logical.Request, data *framework.FieldData, actx *acmeContext, allowJWK bool) (*acmeRequest, *acmeError) {
+	flattened, err := json.Marshal(map[string]string{
+		"protected": data.Get("protected").(string),
+		"payload":   data.Get("payload").(string),
+		"signature": data.Get("signature").(string),
+	})
+	if err != nil {
+		return nil, newACMEError("malformed", http.StatusBadRequest, "unable to read JWS")
+	}
+
+	jws, err := jose.ParseSigned(string(flattened))
+	if err != nil {
+		return nil, newACMEError("malformed", http.StatusBadRequest, "unable to parse JWS: %v", err)
+	}
+	if len(jws.Signatures) != 1 {
+		return nil, newACMEError("malformed", http.StatusBadRequest, "JWS must have exactly one signature")
+	}
+	header := jws.Signatures[0].Protected
+
+	if !strutil.StrListContains(acmeSignatureAlgorithms, header.Algorithm) {
+		return nil, newACMEError("badSignatureAlgorithm", http.StatusBadRequest, "unsupported signature algorithm %q", header.Algorithm)
+	}
+
+	valid, err := b.acmeNonces.consume(ctx, req.Storage, header.Nonce)
+	if err != nil {
+		return nil, newACMEError("serverInternal", http.StatusInternalServerError, "error checking nonce")
+	}
+	if !valid {
+		return nil, newACMEError("badNonce", http.StatusBadRequest, "invalid or expired nonce")
+	}
+
+	expectedURL := actx.baseURL + "/" + acmeEndpoint(req.Path)
+	if reqURL, _ := header.ExtraHeaders[jose.HeaderKey("url")].(string); reqURL != expectedURL {
+		return nil, newACMEError("unauthorized", http.StatusUnauthorized, "JWS url %q does not match request URL %q", reqURL, expectedURL)
+	}
+
+	result := &acmeRequest{}
+	var verificationKey *jose.JSONWebKey
+	switch {
+	case header.JSONWebKey != nil && header.KeyID != "":
+		return nil, newACMEError("malformed", http.StatusBadRequest, "JWS must not contain both jwk and kid")
+
+	case header.JSONWebKey != nil:
+		if !allowJWK {
+			return nil, newACMEError("malformed", http.StatusBadRequest, "this endpoint requires a kid")
+		}
+		if !header.JSONWebKey.Valid() || !header.JSONWebKey.IsPublic() {
+			return nil, newACMEError("badPublicKey", http.StatusBadRequest, "jwk must be a valid public key")
+		}
+		verificationKey = header.JSONWebKey
+		result.jwk = header.JSONWebKey
+
+	case header.KeyID != "":
+		accountPrefix := actx.baseURL + "/account/"
+		if !strings.HasPrefix(header.KeyID, accountPrefix) {
+			return nil, newACMEError("accountDoesNotExist", http.StatusBadRequest, "kid does not belong to this directory")
+		}
+		account, err := getACMEAccount(ctx, req.Storage, strings.TrimPrefix(header.KeyID, accountPrefix))
+		if err != nil {
+			return nil, newACMEError("serverInternal", http.StatusInternalServerError, "error reading account")
+		}
+		if account == nil || account.Role != actx.roleName {
+			return nil, newACMEError("accountDoesNotExist", http.StatusBadRequest, "account does not exist")
+		}
+		if account.Status != acmeStatusValid {
+			return nil, newACMEError("unauthorized", http.StatusUnauthorized, "account is %s", account.Status)
+		}
+
+		verificationKey = &jose.JSONWebKey{}
+		if err := verificationKey.UnmarshalJSON(account.Key); err != nil {
+			return nil, newACMEError("serverInternal", http.StatusInternalServerError, "error decoding account key")
+		}
+		result.account = account
+
+	default:
+		return nil, newACMEError("malformed", http.StatusBadRequest, "JWS must contain a jwk or kid")
+	}
+
+	payload, err := jws.Verify(verificationKey)
+	if err != nil {
+		return nil, newACMEError("malformed", http.StatusBadRequest, "JWS signature verification failed")
+	}
+	result.payload = payload
+
+	return result, nil
+}
+
+// acmeEndpoint returns the request path relative to its directory
+func acmeEndpoint(path string) string {
+	path = strings.TrimPrefix(path, "acme/")
+	if strings.HasPrefix(path, "roles/") {
+		parts := strings.SplitN(path, "/", 3)
+		if len(parts) == 3 {
+			return parts[2]
+		}
+	}
+	return path
+}
+
+// acmeResponse builds a raw response carrying a fresh nonce, as required of
+// every ACME response
+func (b *backend) acmeResponse(ctx context.Context, req *logical.Request, actx *acmeContext, status int, contentType string, body []byte, location string) (*logical.Response, error) {
+	nonce, err := b.acmeNonces.issue(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPStatusCode:      status,
+			logical.HTTPRawCacheControl: "no-store",
+		},
+		Headers: map[string][]string{
+			"Replay-Nonce": []string{nonce},
+		},
+	}
+	if contentType != "" {
+		resp.Data[logical.HTTPContentType] = contentType
+		resp.Data[logical.HTTPRawBody] = body
+	}
+	if actx != nil {
+		resp.Headers["Link"] = []string{fmt.Sprintf("<%s/directory>;rel=\"index\"", actx.baseURL)}
+	}
+	if location != "" {
+		resp.Headers["Location"] = []string{location}
+	}
+
+	return resp, nil
+}
+
+func (b *backend) acmeJSONResponse(ctx context.Context, req *logical.Request, actx *acmeContext, status int, body interface{}, location string) (*logical.Response, error) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return b.acmeResponse(ctx, req, actx, status, acmeJSONContentType, encoded, location)
+}
+
+func (b *backend) acmeErrorResponse(ctx context.Context, req *logical.Request, actx *acmeContext, acmeErr *acmeError) (*logical.Response, error) {
+	encoded, err := json.Marshal(acmeErr)
+	if err != nil {
+		return nil, err
+	}
+	return b.acmeResponse(ctx, req, actx, acmeErr.Status, acmeProblemContentType, encoded, "")
+}
+
+func getACMEAccount(ctx context.Context, s logical.Storage, id string) (*acmeAccount, error) {
+	if id == "" || strings.Contains(id, "/") {
+		return nil, nil
+	}
+
+	entry, err := s.Get(ctx, acmeAccountPrefix+id)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var account acmeAccount
+	if err := entry.DecodeJSON(&account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func writeACMEAccount(ctx context.Context, s logical.Storage, account *acmeAccount) error {
+	entry, err := logical.StorageEntryJSON(acmeAccountPrefix+account.ID, account)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+func getACMEOrder(ctx context.Context, s logical.Storage, accountID, id string) (*acmeOrder, error) {
+	entry, err := s.Get(ctx, acmeAccountPrefix+accountID+"/orders/"+id)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var order acmeOrder
+	if err := entry.DecodeJSON(&order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func writeACMEOrder(ctx context.Context, s logical.Storage, order *acmeOrder) error {
+	entry, err := logical.StorageEntryJSON(acmeAccountPrefix+order.AccountID+"/orders/"+order.ID, order)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+func getACMEAuthorization(ctx context.Context, s logical.Storage, accountID, id string) (*acmeAuthorization, error) {
+	entry, err := s.Get(ctx, acmeAccountPrefix+accountID+"/authorizations/"+id)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var authz acmeAuthorization
+	if err := entry.DecodeJSON(&authz); err != nil {
+		return nil, err
+	}
+	return &authz, nil
+}
+
+func writeACMEAuthorization(ctx context.Context, s logical.Storage, authz *acmeAuthorization) error {
+	entry, err := logical.StorageEntryJSON(acmeAccountPrefix+authz.AccountID+"/authorizations/"+authz.ID, authz)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// acmeThumbprint returns the RFC 7638 thumbprint of a key, as used in key
+// authorizations
+func acmeThumbprint(key *jose.JSONWebKey) (string, error) {
+	thumbprint, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
+
+func acmeRandomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
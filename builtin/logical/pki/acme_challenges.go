@@ -0,0 +1,118 @@
+package pki
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	cleanhttp "github.com/hashicorp/go-cleanhttp"
+)
+
+const (
+	acmeChallengeHTTP01 = "http-01"
+	acmeChallengeDNS01  = "dns-01"
+
+	acmeChallengeTimeout = 10 * time.Second
+
+	// maxACMEChallengeResponseSize bounds the body read from http-01
+	// challenge responders; a key authorization is under 100 bytes.
+	maxACMEChallengeResponseSize = 1024
+)
+
+// acmeChallengeTypes returns the challenge types offered for an
+// identifier. Wildcards can only be proven through DNS.
+func acmeChallengeTypes(identifier acmeIdentifier, wildcard bool) []string {
+	switch {
+	case identifier.Type == "ip":
+		return []string{acmeChallengeHTTP01}
+	case wildcard:
+		return []string{acmeChallengeDNS01}
+	default:
+		return []string{acmeChallengeHTTP01, acmeChallengeDNS01}
+	}
+}
+
+// validateACMEChallenge checks that the client has provisioned the key
+// authorization for the challenge
+func (b *backend) validateACMEChallenge(ctx context.Context, authz *acmeAuthorization, challenge *acmeChallenge, thumbprint string) *acmeError {
+	keyAuthorization := challenge.Token + "." + thumbprint
+
+	ctx, cancel := context.WithTimeout(ctx, acmeChallengeTimeout)
+	defer cancel()
+
+	switch challenge.Type {
+	case acmeChallengeHTTP01:
+		return b.validateACMEHTTP01(ctx, authz.Identifier, challenge.Token, keyAuthorization)
+	case acmeChallengeDNS01:
+		return validateACMEDNS01(ctx, authz.Identifier, keyAuthorization)
+	default:
+		return newACMEError("malformed", http.StatusBadRequest, "unsupported challenge type %q", challenge.Type)
+	}
+}
+
+func (b *backend) validateACMEHTTP01(ctx context.Context, identifier acmeIdentifier, token, keyAuthorization string) *acmeError {
+	host := identifier.Value
+	if b.acmeHTTPChallengePort != 80 {
+		host = net.JoinHostPort(host, strconv.Itoa(b.acmeHTTPChallengePort))
+	} else if identifier.Type == "ip" && strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	challengeURL := fmt.Sprintf("http://%s/.well-known/acme-challenge/%s", host, token)
+
+	httpReq, err := http.NewRequest(http.MethodGet, challengeURL, nil)
+	if err != nil {
+		return newACMEError("malformed", http.StatusBadRequest, "unable to build challenge request: %v", err)
+	}
+
+	client := cleanhttp.DefaultClient()
+	resp, err := client.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return newACMEError("connection", http.StatusBadRequest, "error fetching %s: %v", challengeURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newACMEError("incorrectResponse", http.StatusForbidden, "%s returned status %d", challengeURL, resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxACMEChallengeResponseSize))
+	if err != nil {
+		return newACMEError("connection", http.StatusBadRequest, "error reading %s: %v", challengeURL, err)
+	}
+	if strings.TrimSpace(string(body)) != keyAuthorization {
+		return newACMEError("incorrectResponse", http.StatusForbidden, "%s did not return the expected key authorization", challengeURL)
+	}
+
+	return nil
+}
+
+func validateACMEDNS01(ctx context.Context, identifier acmeIdentifier, keyAuthorization string) *acmeError {
+	if identifier.Type != "dns" {
+		return newACMEError("malformed", http.StatusBadRequest, "dns-01 challenges are only valid for dns identifiers")
+	}
+
+	digest := sha256.Sum256([]byte(keyAuthorization))
+	expected := base64.RawURLEncoding.EncodeToString(digest[:])
+
+	name := "_acme-challenge." + strings.TrimPrefix(identifier.Value, "*.")
+	records, err := net.DefaultResolver.LookupTXT(ctx, name)
+	if err != nil {
+		return newACMEError("dns", http.StatusBadRequest, "error looking up TXT records of %s: %v", name, err)
+	}
+
+	for _, record := range records {
+		if strings.TrimSpace(record) == expected {
+			return nil
+		}
+	}
+
+	return newACMEError("incorrectResponse", http.StatusForbidden, "no TXT record of %s contained the expected value", name)
+}
@@ -6,7 +6,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/hashicorp/errwrap"
+	multierror "github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -33,12 +37,23 @@ func Backend(conf *logical.BackendConfig) *backend {
 				"ca",
 				"crl/pem",
 				"crl",
+				"crl/delta",
+				"crl/delta/pem",
+				"ca/issuer/*",
+				"crl/issuer/*",
+				"ocsp",
+				"ocsp/*",
+				"acme/*",
 			},
 
 			LocalStorage: []string{
 				"revoked/",
 				"crl",
+				"delta-crl",
 				"certs/",
+				"role-certs/",
+				"acme/",
+				"tidy/",
 			},
 
 			Root: []string{
@@ -47,7 +62,8 @@ func Backend(conf *logical.BackendConfig) *backend {
 			},
 
 			SealWrapStorage: []string{
-				"config/ca_bundle",
+				legacyCertBundlePath,
+				keyPrefix,
 			},
 		},
 
@@ -70,23 +86,62 @@ func Backend(conf *logical.BackendConfig) *backend {
 			pathFetchCA(&b),
 			pathFetchCAChain(&b),
 			pathFetchCRL(&b),
+			pathFetchDeltaCRL(&b),
 			pathFetchCRLViaCertPath(&b),
 			pathFetchValid(&b),
 			pathFetchListCerts(&b),
 			pathRevoke(&b),
+			pathRevokeRole(&b),
 			pathTidy(&b),
+			pathTidyStatus(&b),
+			pathConfigAutoTidy(&b),
+			pathListIssuers(&b),
+			pathIssuer(&b),
+			pathCrossSignIssuer(&b),
+			pathImportIssuer(&b),
+			pathIssuerGenerateRoot(&b),
+			pathIssuerGenerateIntermediate(&b),
+			pathConfigIssuers(&b),
+			pathFetchIssuerCA(&b),
+			pathFetchIssuerCRL(&b),
+			pathFetchIssuerViaCertPath(&b),
+			pathListKeys(&b),
+			pathKey(&b),
+			pathOCSPGet(&b),
+			pathOCSPPost(&b),
+			pathConfigACME(&b),
+			pathACMEDirectory(&b),
+			pathACMENewNonce(&b),
+			pathACMENewAccount(&b),
+			pathACMEAccount(&b),
+			pathACMEAccountOrders(&b),
+			pathACMENewOrder(&b),
+			pathACMEOrder(&b),
+			pathACMEOrderFinalize(&b),
+			pathACMEOrderCert(&b),
+			pathACMEAuthorization(&b),
+			pathACMEChallenge(&b),
+			pathACMERevoke(&b),
 		},
 
 		Secrets: []*framework.Secret{
 			secretCerts(&b),
 		},
 
-		BackendType: logical.TypeLogical,
+		InitializeFunc: b.initialize,
+		PeriodicFunc:   b.periodicFunc,
+		Clean:          b.stopACMEValidations,
+		BackendType:    logical.TypeLogical,
 	}
 
 	b.crlLifetime = time.Hour * 72
 	b.tidyCASGuard = new(uint32)
 	b.storage = conf.StorageView
+	b.acmeNonces = newACMENonceStore()
+	b.acmeLocks = locksutil.CreateLocks()
+	b.acmeValidations = make(map[string]struct{})
+	b.acmeValidationCtx, b.acmeValidationCancel = context.WithCancel(context.Background())
+	b.acmeHTTPChallengePort = 80
 
 	return &b
 }
@@ -98,6 +153,66 @@ type backend struct {
 	crlLifetime       time.Duration
 	revokeStorageLock sync.RWMutex
 	tidyCASGuard      *uint32
+
+	tidyStatusLock sync.RWMutex
+	tidyStatus     *tidyStatus
+
+	acmeNonces *acmeNonceStore
+	// acmeLocks serialize the requests of each ACME account
+	acmeLocks []*locksutil.LockEntry
+
+	// acmeValidations holds the challenges being validated by this node;
+	// validations run until the backend is cleaned up
+	acmeValidationsLock  sync.Mutex
+	acmeValidations      map[string]struct{}
+	acmeValidationCtx    context.Context
+	acmeValidationCancel context.CancelFunc
+	acmeValidationWG     sync.WaitGroup
+	// acmeHTTPChallengePort is the port http-01 challenges are validated
+	// against; it is only changed by tests
+	acmeHTTPChallengePort int
+}
+
+func (b *backend) initialize(ctx context.Context, req *logical.InitializationRequest) error {
+	// On standbys and secondaries the migration is performed by the active
+	// node of the primary cluster and replicated to us
+	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby | consts.ReplicationDRSecondary) {
+		return nil
+	}
+	if !b.System().LocalMount() && b.System().ReplicationState().HasState(consts.ReplicationPerformanceSecondary) {
+		return nil
+	}
+
+	migrated, err := migrateLegacyBundle(ctx, req.Storage)
+	if err != nil {
+		b.Logger().Error("error migrating legacy CA bundle", "error", err)
+		return err
+	}
+	if migrated {
+		b.Logger().Info("migrated legacy CA bundle to issuer storage")
+	}
+
+	return nil
+}
+
+func (b *backend) periodicFunc(ctx context.Context, req *logical.Request) error {
+	// CRLs and certificates are kept in local storage, so every cluster
+	// maintains its own; only standbys have nothing to do
+	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby | consts.ReplicationDRSecondary) {
+		return nil
+	}
+
+	var result error
+	if err := b.rebuildCRLsIfNeeded(ctx, req); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("error rebuilding CRLs: {{err}}", err))
+	}
+	if err := b.tidyIfScheduled(ctx, req); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("error starting automatic tidy: {{err}}", err))
+	}
+	if err := b.acmeNonces.tidy(ctx, req.Storage); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("error removing expired ACME nonces: {{err}}", err))
+	}
+	return result
 }
 
 const backendHelp = `
@@ -9,6 +9,7 @@ import (
 	"crypto/x509/pkix"
 	"encoding/asn1"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/pem"
 	"fmt"
 	"net"
@@ -44,6 +45,29 @@ var (
 	hostnameRegex                = regexp.MustCompile(`^(\*\.)?(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\-]*[a-zA-Z0-9])\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\-]*[A-Za-z0-9])\.?$`)
 	oidExtensionBasicConstraints = []int{2, 5, 29, 19}
 	oidExtensionSubjectAltName   = []int{2, 5, 29, 17}
+
+	oidExtensionExtendedKeyUsage    = asn1.ObjectIdentifier{2, 5, 29, 37}
+	oidExtensionCertificatePolicies = asn1.ObjectIdentifier{2, 5, 29, 32}
+
+	// managedExtensionOIDs are the extensions Vault sets itself from the
+	// role and the issuer; they can't be passed through from a CSR
+	managedExtensionOIDs = []asn1.ObjectIdentifier{
+		{2, 5, 29, 14},                     // Subject Key Identifier
+		{2, 5, 29, 15},                     // Key Usage
+		{2, 5, 29, 17},                     // Subject Alternative Name
+		{2, 5, 29, 19},                     // Basic Constraints
+		{2, 5, 29, 30},                     // Name Constraints
+		{2, 5, 29, 31},                     // CRL Distribution Points
+		{2, 5, 29, 32},                     // Certificate Policies
+		{2, 5, 29, 35},                     // Authority Key Identifier
+		{2, 5, 29, 37},                     // Extended Key Usage
+		{1, 3, 6, 1, 5, 5, 7, 1, 1},        // Authority Information Access
+		{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}, // Certificate Transparency precertificate poison
+	}
+
+	// nameRegex matches the names accepted for issuers and keys; it is the
+	// same shape as framework.GenericNameRegex.
+	nameRegex = regexp.MustCompile(`^\w(([\w-.]+)?\w)?$`)
 )
 
 func oidInExtensions(oid asn1.ObjectIdentifier, extensions []pkix.Extension) bool {
@@ -67,47 +91,19 @@ func getFormat(data *framework.FieldData) string {
 	return format
 }
 
-// Fetches the CA info. Unlike other certificates, the CA info is stored
-// in the backend as a CertBundle, because we are storing its private key
-func fetchCAInfo(ctx context.Context, req *logical.Request) (*certutil.CAInfoBundle, error) {
-	bundleEntry, err := req.Storage.Get(ctx, "config/ca_bundle")
+// Fetches the CA info for the referenced issuer. Unlike other certificates,
+// the CA info is stored in the backend along with its private key, which is
+// kept in a separate key entry.
+func fetchCAInfo(ctx context.Context, req *logical.Request, issuerRef string) (*certutil.CAInfoBundle, error) {
+	issuerID, err := resolveIssuerReference(ctx, req.Storage, issuerRef)
 	if err != nil {
-		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch local CA certificate/key: %v", err)}
-	}
-	if bundleEntry == nil {
-		return nil, errutil.UserError{Err: "backend must be configured with a CA certificate/key"}
-	}
-
-	var bundle certutil.CertBundle
-	if err := bundleEntry.DecodeJSON(&bundle); err != nil {
-		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to decode local CA certificate/key: %v", err)}
-	}
-
-	parsedBundle, err := bundle.ToParsedCertBundle()
-	if err != nil {
-		return nil, errutil.InternalError{Err: err.Error()}
-	}
-
-	if parsedBundle.Certificate == nil {
-		return nil, errutil.InternalError{Err: "stored CA information not able to be parsed"}
-	}
-
-	caInfo := &certutil.CAInfoBundle{*parsedBundle, nil}
-
-	entries, err := getURLs(ctx, req)
-	if err != nil {
-		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch URL information: %v", err)}
-	}
-	if entries == nil {
-		entries = &certutil.URLEntries{
-			IssuingCertificates:   []string{},
-			CRLDistributionPoints: []string{},
-			OCSPServers:           []string{},
+		if _, ok := err.(errutil.UserError); ok {
+			return nil, errutil.UserError{Err: fmt.Sprintf("backend must be configured with a CA certificate/key: %v", err)}
 		}
+		return nil, err
 	}
-	caInfo.URLs = entries
 
-	return caInfo, nil
+	return fetchCAInfoByIssuerID(ctx, req, issuerID)
 }
 
 // Allows fetching certificates from the backend; it handles the slightly
@@ -130,6 +126,8 @@ func fetchCertBySerial(ctx context.Context, req *logical.Request, prefix, serial
 		path = "ca"
 	case serial == "crl":
 		path = "crl"
+	case serial == "delta-crl":
+		path = "delta-crl"
 	default:
 		legacyPath = "certs/" + colonSerial
 		path = "certs/" + hyphenSerial
@@ -423,6 +421,154 @@ func parseOtherSANs(others []string) (map[string][]string, error) {
 	return result, nil
 }
 
+// parseAllowedCSRExtensions parses a role's allowed CSR extensions. Each is
+// either an OID, allowing any value, or of the form <oid>;<type>:<value>
+// where <type> is UTF8/UTF-8, matching a UTF8String value against a glob, or
+// HEX, matching the hex-encoded DER value exactly. The result maps OIDs to
+// their constraints, with "*" allowing any value.
+func parseAllowedCSRExtensions(allowed []string) (map[string][]string, error) {
+	result := map[string][]string{}
+	for _, entry := range allowed {
+		splitEntry := strings.SplitN(entry, ";", 2)
+		oid, err := certutil.StringToOid(splitEntry[0])
+		if err != nil {
+			return nil, fmt.Errorf("%q could not be parsed as a valid oid", splitEntry[0])
+		}
+		if oidInList(oid, managedExtensionOIDs) {
+			return nil, fmt.Errorf("extension %s is set by Vault and cannot be passed through from a CSR", oid)
+		}
+
+		constraint := "*"
+		if len(splitEntry) == 2 {
+			splitType := strings.SplitN(splitEntry[1], ":", 2)
+			if len(splitType) != 2 {
+				return nil, fmt.Errorf("expected a colon in allowed extension %q", entry)
+			}
+			switch {
+			case strings.EqualFold(splitType[0], "utf8"), strings.EqualFold(splitType[0], "utf-8"):
+				constraint = "utf8:" + splitType[1]
+			case strings.EqualFold(splitType[0], "hex"):
+				if _, err := hex.DecodeString(splitType[1]); err != nil {
+					return nil, fmt.Errorf("invalid hex value in allowed extension %q", entry)
+				}
+				constraint = "hex:" + strings.ToLower(splitType[1])
+			default:
+				return nil, fmt.Errorf("only utf8 and hex values are supported; found non-supported type in allowed extension %q", entry)
+			}
+		}
+		result[oid.String()] = append(result[oid.String()], constraint)
+	}
+
+	return result, nil
+}
+
+// csrExtensionAllowed checks an extension's value against the constraints
+// returned by parseAllowedCSRExtensions
+func csrExtensionAllowed(ext pkix.Extension, constraints []string) bool {
+	for _, constraint := range constraints {
+		switch {
+		case constraint == "*":
+			return true
+		case strings.HasPrefix(constraint, "utf8:"):
+			var value string
+			rest, err := asn1.UnmarshalWithParams(ext.Value, &value, "utf8")
+			if err == nil && len(rest) == 0 && glob.Glob(strings.TrimPrefix(constraint, "utf8:"), value) {
+				return true
+			}
+		case strings.HasPrefix(constraint, "hex:"):
+			if hex.EncodeToString(ext.Value) == strings.TrimPrefix(constraint, "hex:") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// policyInformation is the ASN.1 structure of a certificate policy, from RFC
+// 5280 section 4.2.1.4
+type policyInformation struct {
+	Policy     asn1.ObjectIdentifier
+	Qualifiers asn1.RawValue `asn1:"optional"`
+}
+
+// csrPassthroughValues returns the extensions, extended key usage OIDs and
+// policy identifiers of a CSR that the role allows into the certificate.
+// Categories the role has no allow-list for are ignored as they always
+// were; once it has one, any value requested outside of it is an error.
+func csrPassthroughValues(data *inputBundle, csr *x509.CertificateRequest) ([]pkix.Extension, []string, []string, error) {
+	var extensions []pkix.Extension
+	var extKeyUsageOIDs, policyIdentifiers []string
+
+	allowedExtensions, err := parseAllowedCSRExtensions(data.role.AllowedCSRExtensions)
+	if err != nil {
+		return nil, nil, nil, errwrap.Wrapf("error parsing role's allowed CSR extensions: {{err}}", err)
+	}
+
+	for _, ext := range csr.Extensions {
+		switch {
+		case ext.Id.Equal(oidExtensionExtendedKeyUsage):
+			if len(data.role.AllowedCSRExtKeyUsageOIDs) == 0 {
+				continue
+			}
+			var oids []asn1.ObjectIdentifier
+			if rest, err := asn1.Unmarshal(ext.Value, &oids); err != nil || len(rest) != 0 {
+				return nil, nil, nil, errutil.UserError{Err: "could not parse the extended key usage extension of the CSR"}
+			}
+			for _, oid := range oids {
+				if !strutil.StrListContains(data.role.AllowedCSRExtKeyUsageOIDs, "*") && !strutil.StrListContains(data.role.AllowedCSRExtKeyUsageOIDs, oid.String()) {
+					return nil, nil, nil, errutil.UserError{Err: fmt.Sprintf("extended key usage %s not allowed by this role", oid)}
+				}
+				if !strutil.StrListContains(data.role.ExtKeyUsageOIDs, oid.String()) {
+					extKeyUsageOIDs = append(extKeyUsageOIDs, oid.String())
+				}
+			}
+
+		case ext.Id.Equal(oidExtensionCertificatePolicies):
+			if len(data.role.AllowedCSRPolicyIdentifiers) == 0 {
+				continue
+			}
+			var policies []policyInformation
+			if rest, err := asn1.Unmarshal(ext.Value, &policies); err != nil || len(rest) != 0 {
+				return nil, nil, nil, errutil.UserError{Err: "could not parse the certificate policies extension of the CSR"}
+			}
+			for _, policy := range policies {
+				if !strutil.StrListContains(data.role.AllowedCSRPolicyIdentifiers, "*") && !strutil.StrListContains(data.role.AllowedCSRPolicyIdentifiers, policy.Policy.String()) {
+					return nil, nil, nil, errutil.UserError{Err: fmt.Sprintf("policy identifier %s not allowed by this role", policy.Policy)}
+				}
+				if !strutil.StrListContains(data.role.PolicyIdentifiers, policy.Policy.String()) {
+					policyIdentifiers = append(policyIdentifiers, policy.Policy.String())
+				}
+			}
+
+		case oidInList(ext.Id, managedExtensionOIDs):
+
+		default:
+			if len(allowedExtensions) == 0 {
+				continue
+			}
+			constraints, ok := allowedExtensions[ext.Id.String()]
+			if !ok {
+				return nil, nil, nil, errutil.UserError{Err: fmt.Sprintf("extension %s not allowed by this role", ext.Id)}
+			}
+			if !csrExtensionAllowed(ext, constraints) {
+				return nil, nil, nil, errutil.UserError{Err: fmt.Sprintf("value of extension %s not allowed by this role", ext.Id)}
+			}
+			extensions = append(extensions, ext)
+		}
+	}
+
+	return extensions, extKeyUsageOIDs, policyIdentifiers, nil
+}
+
+func oidInList(oid asn1.ObjectIdentifier, oids []asn1.ObjectIdentifier) bool {
+	for _, other := range oids {
+		if oid.Equal(other) {
+			return true
+		}
+	}
+	return false
+}
+
 func validateSerialNumber(data *inputBundle, serialNumber string) string {
 	valid := false
 	if len(data.role.AllowedSerialNumbers) > 0 {
@@ -1068,6 +1214,16 @@ func generateCreationBundle(b *backend, data *inputBundle, caSign *certutil.CAIn
 		CSR:           csr,
 	}
 
+	if csr != nil {
+		extensions, extKeyUsageOIDs, policyIdentifiers, err := csrPassthroughValues(data, csr)
+		if err != nil {
+			return nil, err
+		}
+		creation.Params.ExtraExtensions = extensions
+		creation.Params.ExtKeyUsageOIDs = append(append([]string{}, creation.Params.ExtKeyUsageOIDs...), extKeyUsageOIDs...)
+		creation.Params.PolicyIdentifiers = append(append([]string{}, creation.Params.PolicyIdentifiers...), policyIdentifiers...)
+	}
+
 	// Don't deal with URLs or max path length if it's self-signed, as these
 	// normally come from the signing bundle
 	if caSign == nil {
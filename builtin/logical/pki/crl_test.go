@@ -1,8 +1,12 @@
 package pki
 
 import (
+	"context"
 	"crypto/x509"
+	"encoding/asn1"
+	"math/big"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/vault/api"
 	vaulthttp "github.com/hashicorp/vault/http"
@@ -122,3 +126,294 @@ func TestBackend_CRL_EnableDisable(t *testing.T) {
 	toggle(false)
 	test(6)
 }
+
+func TestBackend_CRL_DeltaAndAutoRebuild(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s %s: err: %v resp: %#v", op, path, err, resp)
+		}
+		return resp
+	}
+
+	fetchCRL := func(path string) *x509.RevocationList {
+		t.Helper()
+		resp := request(logical.ReadOperation, path, nil)
+		crl, err := x509.ParseRevocationList(resp.Data[logical.HTTPRawBody].([]byte))
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		return crl
+	}
+
+	deltaBaseNumber := func(crl *x509.RevocationList) *big.Int {
+		t.Helper()
+		for _, ext := range crl.Extensions {
+			if ext.Id.Equal(oidExtensionDeltaCRLIndicator) {
+				if !ext.Critical {
+					t.Fatal("delta CRL indicator must be critical")
+				}
+				var number *big.Int
+				if _, err := asn1.Unmarshal(ext.Value, &number); err != nil {
+					t.Fatal(err)
+				}
+				return number
+			}
+		}
+		t.Fatal("delta CRL indicator not found")
+		return nil
+	}
+
+	periodic := func() {
+		t.Helper()
+		if err := b.periodicFunc(context.Background(), &logical.Request{Storage: storage}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	request(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "root.example.com",
+		"ttl":         "720h",
+	})
+	request(logical.UpdateOperation, "config/urls", map[string]interface{}{
+		"base_url": "https://vault.example.com:8200/v1/pki",
+	})
+	request(logical.UpdateOperation, "roles/example", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+	})
+	var serials []string
+	for i := 0; i < 3; i++ {
+		resp := request(logical.UpdateOperation, "issue/example", map[string]interface{}{
+			"common_name": "leaf.example.com",
+			"ttl":         "1h",
+		})
+		serials = append(serials, resp.Data["serial_number"].(string))
+	}
+
+	// Delta CRLs and skipping rebuilds need the CRL to be rebuilt on a
+	// schedule
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/crl",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"enable_delta": true,
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error enabling delta CRLs without auto_rebuild, got: err: %v resp: %#v", err, resp)
+	}
+
+	request(logical.UpdateOperation, "config/crl", map[string]interface{}{
+		"auto_rebuild": true,
+		"enable_delta": true,
+	})
+
+	base := fetchCRL("crl")
+	if len(base.RevokedCertificates) != 0 {
+		t.Fatalf("expected empty base CRL, got %d entries", len(base.RevokedCertificates))
+	}
+	var freshest bool
+	for _, ext := range base.Extensions {
+		freshest = freshest || ext.Id.Equal(oidExtensionFreshestCRL)
+	}
+	if !freshest {
+		t.Fatal("expected freshest CRL extension on the base CRL")
+	}
+
+	// A revocation only rebuilds the delta CRL
+	request(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": serials[0],
+	})
+	if crl := fetchCRL("crl"); crl.Number.Cmp(base.Number) != 0 || len(crl.RevokedCertificates) != 0 {
+		t.Fatalf("expected base CRL to be unchanged, got number %v with %d entries", crl.Number, len(crl.RevokedCertificates))
+	}
+	delta := fetchCRL("crl/delta")
+	if len(delta.RevokedCertificates) != 1 || deltaBaseNumber(delta).Cmp(base.Number) != 0 || delta.Number.Cmp(base.Number) <= 0 {
+		t.Fatalf("unexpected delta CRL: number %v with %d entries", delta.Number, len(delta.RevokedCertificates))
+	}
+	if issuerDelta := fetchCRL("crl/issuer/default/delta"); issuerDelta.Number.Cmp(delta.Number) != 0 {
+		t.Fatalf("expected issuer delta CRL number %v, got %v", delta.Number, issuerDelta.Number)
+	}
+
+	// With rebuilds skipped, the revocation waits for the schedule
+	request(logical.UpdateOperation, "config/crl", map[string]interface{}{
+		"skip_rebuild_on_revoke": true,
+		"delta_rebuild_interval": "1ns",
+	})
+	base = fetchCRL("crl")
+	request(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": serials[1],
+	})
+	if delta := fetchCRL("crl/delta"); len(delta.RevokedCertificates) != 0 {
+		t.Fatalf("expected delta CRL to be unchanged, got %d entries", len(delta.RevokedCertificates))
+	}
+	periodic()
+	delta = fetchCRL("crl/delta")
+	if len(delta.RevokedCertificates) != 1 || deltaBaseNumber(delta).Cmp(base.Number) != 0 {
+		t.Fatalf("unexpected delta CRL after periodic rebuild: %d entries", len(delta.RevokedCertificates))
+	}
+
+	// A full rebuild folds the delta into the base CRL
+	request(logical.ReadOperation, "crl/rotate", nil)
+	base = fetchCRL("crl")
+	if len(base.RevokedCertificates) != 2 {
+		t.Fatalf("expected 2 entries on the base CRL, got %d", len(base.RevokedCertificates))
+	}
+	delta = fetchCRL("crl/delta")
+	if len(delta.RevokedCertificates) != 0 || deltaBaseNumber(delta).Cmp(base.Number) != 0 {
+		t.Fatalf("unexpected delta CRL after full rebuild: %d entries", len(delta.RevokedCertificates))
+	}
+
+	// Revocations after the full rebuild are picked up by the schedule,
+	// whatever the number of revocations the previous delta carried
+	request(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": serials[2],
+	})
+	periodic()
+	delta = fetchCRL("crl/delta")
+	if len(delta.RevokedCertificates) != 1 || deltaBaseNumber(delta).Cmp(base.Number) != 0 {
+		t.Fatalf("unexpected delta CRL after revoking past a full rebuild: %d entries", len(delta.RevokedCertificates))
+	}
+
+	// The base CRL is rebuilt once it enters the grace period
+	request(logical.UpdateOperation, "config/crl", map[string]interface{}{
+		"expiry":                    "2s",
+		"auto_rebuild_grace_period": "1s",
+	})
+	request(logical.ReadOperation, "crl/rotate", nil)
+	base = fetchCRL("crl")
+	periodic()
+	if crl := fetchCRL("crl"); crl.Number.Cmp(base.Number) != 0 {
+		t.Fatalf("expected base CRL to be unchanged before the grace period, got number %v", crl.Number)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	periodic()
+	if crl := fetchCRL("crl"); crl.Number.Cmp(base.Number) <= 0 {
+		t.Fatalf("expected base CRL to be rebuilt, got number %v", crl.Number)
+	}
+
+	// Turning off delta CRLs removes them
+	request(logical.UpdateOperation, "config/crl", map[string]interface{}{
+		"enable_delta":           false,
+		"skip_rebuild_on_revoke": false,
+	})
+	resp = request(logical.ReadOperation, "crl/delta", nil)
+	if resp.Data[logical.HTTPStatusCode] != 204 {
+		t.Fatalf("expected no delta CRL, got %#v", resp.Data)
+	}
+}
+
+func TestPki_RevokeByCertificateAndRole(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s %s: err: %v resp: %#v", op, path, err, resp)
+		}
+		return resp
+	}
+
+	request(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "root.example.com",
+		"ttl":         "720h",
+	})
+	for _, role := range []string{"web", "ephemeral"} {
+		request(logical.UpdateOperation, "roles/"+role, map[string]interface{}{
+			"allowed_domains":  "example.com",
+			"allow_subdomains": true,
+			"no_store":         role == "ephemeral",
+			"ttl":              "1h",
+		})
+	}
+	issue := func(role string) (string, string) {
+		t.Helper()
+		resp := request(logical.UpdateOperation, "issue/"+role, map[string]interface{}{
+			"common_name": role + ".example.com",
+		})
+		return resp.Data["serial_number"].(string), resp.Data["certificate"].(string)
+	}
+
+	webSerialA, webCertA := issue("web")
+	webSerialB, _ := issue("web")
+	ephemeralSerial, ephemeralCert := issue("ephemeral")
+
+	// Certificates which were never stored can be revoked by their contents
+	request(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"certificate": ephemeralCert,
+	})
+	if !crlContainsSerial(t, b, storage, "crl", ephemeralSerial) {
+		t.Fatalf("expected %s on the CRL", ephemeralSerial)
+	}
+
+	otherBackend, otherStorage := createBackendWithStorage(t)
+	resp, err := otherBackend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "root/generate/internal",
+		Storage:   otherStorage,
+		Data: map[string]interface{}{
+			"common_name": "web.example.com",
+			"ttl":         "1h",
+		},
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "revoke",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"certificate": resp.Data["certificate"],
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error revoking a certificate of another mount, got err: %v resp: %#v", err, resp)
+	}
+
+	resp = request(logical.UpdateOperation, "revoke/role/web", map[string]interface{}{
+		"dry_run": true,
+	})
+	if serials := resp.Data["serial_numbers"].([]string); len(serials) != 2 {
+		t.Fatalf("expected two certificates of role web, got %v", serials)
+	}
+	if crlContainsSerial(t, b, storage, "crl", webSerialA) {
+		t.Fatalf("dry run must not revoke %s", webSerialA)
+	}
+
+	request(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"certificate": webCertA,
+	})
+	resp = request(logical.UpdateOperation, "revoke/role/web", nil)
+	if serials := resp.Data["serial_numbers"].([]string); len(serials) != 1 || serials[0] != webSerialB {
+		t.Fatalf("expected only %s to be revoked, got %v", webSerialB, serials)
+	}
+	for _, serial := range []string{webSerialA, webSerialB, ephemeralSerial} {
+		if !crlContainsSerial(t, b, storage, "crl", serial) {
+			t.Fatalf("expected %s on the CRL", serial)
+		}
+	}
+
+	resp = request(logical.UpdateOperation, "revoke/role/web", map[string]interface{}{
+		"dry_run": true,
+	})
+	if serials := resp.Data["serial_numbers"].([]string); len(serials) != 0 {
+		t.Fatalf("expected no certificates left to revoke, got %v", serials)
+	}
+}
@@ -5,13 +5,14 @@ import (
 	"crypto/rand"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/asn1"
 	"errors"
 	"fmt"
+	"math/big"
 	"strings"
 	"time"
 
 	"github.com/hashicorp/errwrap"
-	"github.com/hashicorp/vault/sdk/helper/certutil"
 	"github.com/hashicorp/vault/sdk/helper/errutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
@@ -20,10 +21,31 @@ type revocationInfo struct {
 	CertificateBytes  []byte    `json:"certificate_bytes"`
 	RevocationTime    int64     `json:"revocation_time"`
 	RevocationTimeUTC time.Time `json:"revocation_time_utc"`
+	CertificateIssuer string    `json:"issuer_id"`
 }
 
 // Revokes a cert, and tries to be smart about error recovery
 func revokeCert(ctx context.Context, b *backend, req *logical.Request, serial string, fromLease bool) (*logical.Response, error) {
+	resp, err := storeRevocation(ctx, b, req, serial, fromLease)
+	if err != nil || resp == nil || resp.IsError() {
+		return resp, err
+	}
+
+	crlErr := b.crlAfterRevoke(ctx, req, serial)
+	switch crlErr.(type) {
+	case errutil.UserError:
+		return logical.ErrorResponse(fmt.Sprintf("Error during CRL building: %s", crlErr)), nil
+	case errutil.InternalError:
+		return nil, errwrap.Wrapf("error encountered during CRL building: {{err}}", crlErr)
+	}
+
+	return resp, nil
+}
+
+// storeRevocation records the revocation of a cert without updating the
+// CRLs, returning the revocation time. A nil response means that there was
+// nothing to revoke.
+func storeRevocation(ctx context.Context, b *backend, req *logical.Request, serial string, fromLease bool) (*logical.Response, error) {
 	// As this backend is self-contained and this function does not hook into
 	// third parties to manage users or resources, if the mount is tainted,
 	// revocation doesn't matter anyways -- the CRL that would be written will
@@ -33,7 +55,7 @@ func revokeCert(ctx context.Context, b *backend, req *logical.Request, serial st
 		return nil, nil
 	}
 
-	signingBundle, caErr := fetchCAInfo(ctx, req)
+	signingBundle, caErr := fetchCAInfo(ctx, req, defaultRef)
 	switch caErr.(type) {
 	case errutil.UserError:
 		return logical.ErrorResponse(fmt.Sprintf("could not fetch the CA certificate: %s", caErr)), nil
@@ -44,8 +66,18 @@ func revokeCert(ctx context.Context, b *backend, req *logical.Request, serial st
 		return nil, errors.New("CA info not found")
 	}
 	colonSerial := strings.Replace(strings.ToLower(serial), "-", ":", -1)
-	if colonSerial == certutil.GetHexFormatted(signingBundle.Certificate.SerialNumber.Bytes(), ":") {
-		return logical.ErrorResponse("adding CA to CRL is not allowed"), nil
+	issuerIDs, err := listIssuers(ctx, req.Storage)
+	if err != nil {
+		return nil, errwrap.Wrapf("error listing issuers: {{err}}", err)
+	}
+	for _, issuerID := range issuerIDs {
+		issuer, err := fetchIssuerByID(ctx, req.Storage, issuerID)
+		if err != nil {
+			return nil, err
+		}
+		if colonSerial == issuer.SerialNumber {
+			return logical.ErrorResponse("adding CA to CRL is not allowed"), nil
+		}
 	}
 
 	alreadyRevoked := false
@@ -110,6 +142,11 @@ func revokeCert(ctx context.Context, b *backend, req *logical.Request, serial st
 			return nil, nil
 		}
 
+		revInfo.CertificateIssuer, err = findIssuerForCert(ctx, req.Storage, cert)
+		if err != nil {
+			return nil, errwrap.Wrapf("error finding issuer of certificate: {{err}}", err)
+		}
+
 		currTime := time.Now()
 		revInfo.CertificateBytes = certEntry.Value
 		revInfo.RevocationTime = currTime.Unix()
@@ -127,14 +164,6 @@ func revokeCert(ctx context.Context, b *backend, req *logical.Request, serial st
 
 	}
 
-	crlErr := buildCRL(ctx, b, req, false)
-	switch crlErr.(type) {
-	case errutil.UserError:
-		return logical.ErrorResponse(fmt.Sprintf("Error during CRL building: %s", crlErr)), nil
-	case errutil.InternalError:
-		return nil, errwrap.Wrapf("error encountered during CRL building: {{err}}", crlErr)
-	}
-
 	resp := &logical.Response{
 		Data: map[string]interface{}{
 			"revocation_time": revInfo.RevocationTime,
@@ -146,8 +175,95 @@ func revokeCert(ctx context.Context, b *backend, req *logical.Request, serial st
 	return resp, nil
 }
 
-// Builds a CRL by going through the list of revoked certificates and building
-// a new CRL with the stored revocation times and serial numbers.
+// crlAfterRevoke updates the CRLs after certificates have been revoked. By
+// default the full CRLs are rebuilt; when they are rebuilt on a schedule
+// instead, the revocations are recorded so the next delta CRL can carry them.
+func (b *backend) crlAfterRevoke(ctx context.Context, req *logical.Request, serials ...string) error {
+	crlInfo, err := b.CRL(ctx, req.Storage)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error fetching CRL config information: %s", err)}
+	}
+
+	if crlInfo == nil || crlInfo.Disable || !crlInfo.AutoRebuild || (!crlInfo.EnableDelta && !crlInfo.SkipRebuildOnRevoke) {
+		return buildCRL(ctx, b, req, false)
+	}
+
+	for _, serial := range serials {
+		err = req.Storage.Put(ctx, &logical.StorageEntry{
+			Key: deltaWALPrefix + normalizeSerial(serial),
+		})
+		if err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("error recording revocation for the delta CRL: %s", err)}
+		}
+	}
+
+	// Bump the generation so that the next scheduled rebuild picks up the
+	// revocations, even if the number of recorded ones did not change
+	state, err := getCRLState(ctx, req.Storage)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error fetching CRL state: %s", err)}
+	}
+	state.WALGeneration++
+	if err := setCRLState(ctx, req.Storage, state); err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error storing CRL state: %s", err)}
+	}
+
+	if crlInfo.EnableDelta && !crlInfo.SkipRebuildOnRevoke {
+		return buildDeltaCRL(ctx, b, req)
+	}
+	return nil
+}
+
+// crlState tracks the CRL numbers and build times shared by the CRLs of all
+// issuers of the mount
+type crlState struct {
+	// Number is the last CRL number used; base and delta CRLs share the
+	// sequence as required by RFC 5280.
+	Number int64 `json:"number"`
+
+	// BaseNumber is the CRL number of the current base CRLs
+	BaseNumber int64 `json:"base_number"`
+
+	LastRebuild time.Time `json:"last_rebuild"`
+	NextUpdate  time.Time `json:"next_update"`
+
+	LastDeltaRebuild time.Time `json:"last_delta_rebuild"`
+
+	// WALGeneration is incremented whenever revocations are recorded for the
+	// delta CRLs, and DeltaGeneration is the generation the current delta
+	// CRLs were built from
+	WALGeneration   uint64 `json:"wal_generation"`
+	DeltaGeneration uint64 `json:"delta_generation"`
+}
+
+func getCRLState(ctx context.Context, s logical.Storage) (*crlState, error) {
+	entry, err := s.Get(ctx, crlStatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &crlState{}
+	if entry == nil {
+		return state, nil
+	}
+	if err := entry.DecodeJSON(state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func setCRLState(ctx context.Context, s logical.Storage, state *crlState) error {
+	entry, err := logical.StorageEntryJSON(crlStatePath, state)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// Builds a CRL for every issuer with a key by going through the list of
+// revoked certificates and building new CRLs with the stored revocation times
+// and serial numbers. If delta CRLs are enabled, they are reset to be empty
+// relative to the new base CRLs.
 func buildCRL(ctx context.Context, b *backend, req *logical.Request, forceNew bool) error {
 	crlInfo, err := b.CRL(ctx, req.Storage)
 	if err != nil {
@@ -155,17 +271,23 @@ func buildCRL(ctx context.Context, b *backend, req *logical.Request, forceNew bo
 	}
 
 	crlLifetime := b.crlLifetime
-	var revokedCerts []pkix.RevokedCertificate
-	var revInfo revocationInfo
-	var revokedSerials []string
+	var revokedSerials, walSerials []string
+	revokedByIssuer := map[string][]pkix.RevokedCertificate{}
+
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error fetching issuers config: %s", err)}
+	}
+
+	issuers, err := fetchIssuersForCRL(ctx, req)
+	if err != nil {
+		return err
+	}
 
 	if crlInfo != nil {
-		if crlInfo.Expiry != "" {
-			crlDur, err := time.ParseDuration(crlInfo.Expiry)
-			if err != nil {
-				return errutil.InternalError{Err: fmt.Sprintf("error parsing CRL duration of %s", crlInfo.Expiry)}
-			}
-			crlLifetime = crlDur
+		crlLifetime, err = crlInfo.lifetime(crlLifetime)
+		if err != nil {
+			return errutil.InternalError{Err: err.Error()}
 		}
 
 		if crlInfo.Disable {
@@ -176,70 +298,432 @@ func buildCRL(ctx context.Context, b *backend, req *logical.Request, forceNew bo
 		}
 	}
 
+	// Revocations recorded for the delta CRLs are covered by the new base
+	// CRLs; list them before the revoked certificates so none are lost
+	walSerials, err = req.Storage.List(ctx, deltaWALPrefix)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error fetching list of revocations for the delta CRL: %s", err)}
+	}
+
 	revokedSerials, err = req.Storage.List(ctx, "revoked/")
 	if err != nil {
 		return errutil.InternalError{Err: fmt.Sprintf("error fetching list of revoked certs: %s", err)}
 	}
 
 	for _, serial := range revokedSerials {
-		revokedEntry, err := req.Storage.Get(ctx, "revoked/"+serial)
+		issuerID, revokedCert, err := fetchRevokedCertForCRL(ctx, req, serial, issuers, config.DefaultIssuerID)
 		if err != nil {
-			return errutil.InternalError{Err: fmt.Sprintf("unable to fetch revoked cert with serial %s: %s", serial, err)}
+			return err
 		}
-		if revokedEntry == nil {
+		if revokedCert == nil {
 			return errutil.InternalError{Err: fmt.Sprintf("revoked certificate entry for serial %s is nil", serial)}
 		}
-		if revokedEntry.Value == nil || len(revokedEntry.Value) == 0 {
-			// TODO: In this case, remove it and continue? How likely is this to
-			// happen? Alternately, could skip it entirely, or could implement a
-			// delete function so that there is a way to remove these
-			return errutil.InternalError{Err: fmt.Sprintf("found revoked serial but actual certificate is empty")}
+		revokedByIssuer[issuerID] = append(revokedByIssuer[issuerID], *revokedCert)
+	}
+
+WRITE:
+	if config.DefaultIssuerID == "" {
+		return errutil.UserError{Err: "could not fetch the CA certificate: no default issuer is configured"}
+	}
+
+	state, err := getCRLState(ctx, req.Storage)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error fetching CRL state: %s", err)}
+	}
+	state.Number++
+
+	urlConfig, err := getURLConfig(ctx, req)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error fetching URL config: %s", err)}
+	}
+	enableDelta := crlInfo != nil && crlInfo.EnableDelta && !crlInfo.Disable
+
+	now := time.Now()
+	for issuerID, issuer := range issuers {
+		if issuer.KeyID == "" {
+			continue
 		}
 
-		err = revokedEntry.DecodeJSON(&revInfo)
+		var extensions []pkix.Extension
+		if enableDelta && urlConfig != nil && urlConfig.BaseURL != "" {
+			freshestCRL, err := freshestCRLExtension(strings.TrimSuffix(urlConfig.BaseURL, "/") + "/crl/issuer/" + issuerID + "/delta")
+			if err != nil {
+				return errutil.InternalError{Err: fmt.Sprintf("error creating freshest CRL extension: %s", err)}
+			}
+			extensions = append(extensions, freshestCRL)
+		}
+
+		crlBytes, err := signIssuerCRL(ctx, req, issuers, issuerID, revokedByIssuer, state.Number, now, now.Add(crlLifetime), extensions)
 		if err != nil {
-			return errutil.InternalError{Err: fmt.Sprintf("error decoding revocation entry for serial %s: %s", serial, err)}
+			return err
 		}
 
-		revokedCert, err := x509.ParseCertificate(revInfo.CertificateBytes)
+		err = req.Storage.Put(ctx, &logical.StorageEntry{
+			Key:   issuerCRLPrefix + issuerID,
+			Value: crlBytes,
+		})
 		if err != nil {
-			return errutil.InternalError{Err: fmt.Sprintf("unable to parse stored revoked certificate with serial %s: %s", serial, err)}
+			return errutil.InternalError{Err: fmt.Sprintf("error storing CRL: %s", err)}
 		}
 
-		// NOTE: We have to change this to UTC time because the CRL standard
-		// mandates it but Go will happily encode the CRL without this.
-		newRevCert := pkix.RevokedCertificate{
-			SerialNumber: revokedCert.SerialNumber,
+		// The default issuer's CRL is also served from the legacy location
+		if issuerID == config.DefaultIssuerID {
+			err = req.Storage.Put(ctx, &logical.StorageEntry{
+				Key:   "crl",
+				Value: crlBytes,
+			})
+			if err != nil {
+				return errutil.InternalError{Err: fmt.Sprintf("error storing CRL: %s", err)}
+			}
 		}
-		if !revInfo.RevocationTimeUTC.IsZero() {
-			newRevCert.RevocationTime = revInfo.RevocationTimeUTC
-		} else {
-			newRevCert.RevocationTime = time.Unix(revInfo.RevocationTime, 0).UTC()
+	}
+
+	state.BaseNumber = state.Number
+	state.DeltaGeneration = state.WALGeneration
+	state.LastRebuild = now
+	state.NextUpdate = now.Add(crlLifetime)
+	if err := setCRLState(ctx, req.Storage, state); err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error storing CRL state: %s", err)}
+	}
+
+	for _, serial := range walSerials {
+		if err := req.Storage.Delete(ctx, deltaWALPrefix+serial); err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("error clearing revocations for the delta CRL: %s", err)}
 		}
-		revokedCerts = append(revokedCerts, newRevCert)
 	}
 
-WRITE:
-	signingBundle, caErr := fetchCAInfo(ctx, req)
+	if enableDelta {
+		return buildDeltaCRL(ctx, b, req)
+	}
+	return clearDeltaCRLs(ctx, req, issuers)
+}
+
+// buildDeltaCRL builds a delta CRL for every issuer with a key, holding the
+// certificates revoked since the current base CRLs were built
+func buildDeltaCRL(ctx context.Context, b *backend, req *logical.Request) error {
+	crlInfo, err := b.CRL(ctx, req.Storage)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error fetching CRL config information: %s", err)}
+	}
+	if crlInfo == nil || !crlInfo.EnableDelta || crlInfo.Disable {
+		return nil
+	}
+
+	state, err := getCRLState(ctx, req.Storage)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error fetching CRL state: %s", err)}
+	}
+	if state.BaseNumber == 0 {
+		// A delta CRL is meaningless without a base to refer to
+		return buildCRL(ctx, b, req, false)
+	}
+
+	crlLifetime, err := crlInfo.lifetime(b.crlLifetime)
+	if err != nil {
+		return errutil.InternalError{Err: err.Error()}
+	}
+
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error fetching issuers config: %s", err)}
+	}
+	if config.DefaultIssuerID == "" {
+		return errutil.UserError{Err: "could not fetch the CA certificate: no default issuer is configured"}
+	}
+
+	issuers, err := fetchIssuersForCRL(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	walSerials, err := req.Storage.List(ctx, deltaWALPrefix)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error fetching list of revocations for the delta CRL: %s", err)}
+	}
+
+	revokedByIssuer := map[string][]pkix.RevokedCertificate{}
+	for _, serial := range walSerials {
+		issuerID, revokedCert, err := fetchRevokedCertForCRL(ctx, req, serial, issuers, config.DefaultIssuerID)
+		if err != nil {
+			return err
+		}
+		if revokedCert == nil {
+			// Tidied away since it was revoked
+			continue
+		}
+		revokedByIssuer[issuerID] = append(revokedByIssuer[issuerID], *revokedCert)
+	}
+
+	indicator, err := asn1.Marshal(big.NewInt(state.BaseNumber))
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error creating delta CRL indicator extension: %s", err)}
+	}
+	extensions := []pkix.Extension{
+		{
+			Id:       oidExtensionDeltaCRLIndicator,
+			Critical: true,
+			Value:    indicator,
+		},
+	}
+
+	state.Number++
+	now := time.Now()
+	for issuerID, issuer := range issuers {
+		if issuer.KeyID == "" {
+			continue
+		}
+
+		crlBytes, err := signIssuerCRL(ctx, req, issuers, issuerID, revokedByIssuer, state.Number, now, now.Add(crlLifetime), extensions)
+		if err != nil {
+			return err
+		}
+
+		err = req.Storage.Put(ctx, &logical.StorageEntry{
+			Key:   deltaCRLPrefix + issuerID,
+			Value: crlBytes,
+		})
+		if err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("error storing delta CRL: %s", err)}
+		}
+
+		if issuerID == config.DefaultIssuerID {
+			err = req.Storage.Put(ctx, &logical.StorageEntry{
+				Key:   "delta-crl",
+				Value: crlBytes,
+			})
+			if err != nil {
+				return errutil.InternalError{Err: fmt.Sprintf("error storing delta CRL: %s", err)}
+			}
+		}
+	}
+
+	state.LastDeltaRebuild = now
+	state.DeltaGeneration = state.WALGeneration
+	if err := setCRLState(ctx, req.Storage, state); err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error storing CRL state: %s", err)}
+	}
+
+	return nil
+}
+
+// clearDeltaCRLs removes delta CRLs left over from when they were enabled,
+// as they no longer relate to the current base CRLs
+func clearDeltaCRLs(ctx context.Context, req *logical.Request, issuers map[string]*issuerEntry) error {
+	for issuerID := range issuers {
+		if err := req.Storage.Delete(ctx, deltaCRLPrefix+issuerID); err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("error removing delta CRL: %s", err)}
+		}
+	}
+	if err := req.Storage.Delete(ctx, "delta-crl"); err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error removing delta CRL: %s", err)}
+	}
+	return nil
+}
+
+// rebuildCRLsIfNeeded is run periodically when CRLs are rebuilt
+// automatically. It rebuilds the base CRLs when they are about to expire,
+// and the delta CRLs when revocations have been recorded since they were
+// last built.
+func (b *backend) rebuildCRLsIfNeeded(ctx context.Context, req *logical.Request) error {
+	crlInfo, err := b.CRL(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+	if crlInfo == nil || !crlInfo.AutoRebuild || crlInfo.Disable {
+		return nil
+	}
+
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+	if config.DefaultIssuerID == "" {
+		return nil
+	}
+
+	gracePeriod, err := time.ParseDuration(crlInfo.AutoRebuildGracePeriod)
+	if err != nil {
+		return errwrap.Wrapf("error parsing CRL auto rebuild grace period: {{err}}", err)
+	}
+
+	b.revokeStorageLock.Lock()
+	defer b.revokeStorageLock.Unlock()
+
+	state, err := getCRLState(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if now.After(state.NextUpdate.Add(-gracePeriod)) {
+		b.Logger().Debug("rebuilding CRLs before they expire", "next_update", state.NextUpdate)
+		return buildCRL(ctx, b, req, false)
+	}
+
+	if !crlInfo.EnableDelta {
+		return nil
+	}
+	deltaInterval, err := time.ParseDuration(crlInfo.DeltaRebuildInterval)
+	if err != nil {
+		return errwrap.Wrapf("error parsing delta CRL rebuild interval: {{err}}", err)
+	}
+	if now.Before(state.LastDeltaRebuild.Add(deltaInterval)) {
+		return nil
+	}
+
+	if state.DeltaGeneration == state.WALGeneration {
+		return nil
+	}
+	return buildDeltaCRL(ctx, b, req)
+}
+
+func fetchIssuersForCRL(ctx context.Context, req *logical.Request) (map[string]*issuerEntry, error) {
+	issuerIDs, err := listIssuers(ctx, req.Storage)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("error listing issuers: %s", err)}
+	}
+
+	issuers := map[string]*issuerEntry{}
+	for _, issuerID := range issuerIDs {
+		issuer, err := fetchIssuerByID(ctx, req.Storage, issuerID)
+		if err != nil {
+			return nil, err
+		}
+		issuers[issuerID] = issuer
+	}
+	return issuers, nil
+}
+
+// fetchRevokedCertForCRL reads the revocation entry of a serial, returning
+// the issuer it belongs to and its CRL entry. A nil entry is returned if the
+// serial is not revoked.
+func fetchRevokedCertForCRL(ctx context.Context, req *logical.Request, serial string, issuers map[string]*issuerEntry, defaultIssuerID string) (string, *pkix.RevokedCertificate, error) {
+	var revInfo revocationInfo
+	revokedEntry, err := req.Storage.Get(ctx, "revoked/"+serial)
+	if err != nil {
+		return "", nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch revoked cert with serial %s: %s", serial, err)}
+	}
+	if revokedEntry == nil {
+		return "", nil, nil
+	}
+	if revokedEntry.Value == nil || len(revokedEntry.Value) == 0 {
+		// TODO: In this case, remove it and continue? How likely is this to
+		// happen? Alternately, could skip it entirely, or could implement a
+		// delete function so that there is a way to remove these
+		return "", nil, errutil.InternalError{Err: fmt.Sprintf("found revoked serial but actual certificate is empty")}
+	}
+
+	err = revokedEntry.DecodeJSON(&revInfo)
+	if err != nil {
+		return "", nil, errutil.InternalError{Err: fmt.Sprintf("error decoding revocation entry for serial %s: %s", serial, err)}
+	}
+
+	revokedCert, err := x509.ParseCertificate(revInfo.CertificateBytes)
+	if err != nil {
+		return "", nil, errutil.InternalError{Err: fmt.Sprintf("unable to parse stored revoked certificate with serial %s: %s", serial, err)}
+	}
+
+	// Entries written before issuers were tracked don't know which
+	// issuer they belong to; work it out from the signature, falling
+	// back to the default issuer as the single-CA CRL used to.
+	issuerID := revInfo.CertificateIssuer
+	if _, ok := issuers[issuerID]; !ok {
+		issuerID, err = findIssuerForCert(ctx, req.Storage, revokedCert)
+		if err != nil {
+			return "", nil, errutil.InternalError{Err: fmt.Sprintf("unable to find issuer of revoked certificate with serial %s: %s", serial, err)}
+		}
+		if issuerID == "" {
+			issuerID = defaultIssuerID
+		}
+	}
+
+	// NOTE: We have to change this to UTC time because the CRL standard
+	// mandates it but Go will happily encode the CRL without this.
+	newRevCert := &pkix.RevokedCertificate{
+		SerialNumber: revokedCert.SerialNumber,
+	}
+	if !revInfo.RevocationTimeUTC.IsZero() {
+		newRevCert.RevocationTime = revInfo.RevocationTimeUTC
+	} else {
+		newRevCert.RevocationTime = time.Unix(revInfo.RevocationTime, 0).UTC()
+	}
+	return issuerID, newRevCert, nil
+}
+
+// signIssuerCRL signs a CRL for the given issuer. Issuers sharing a key are
+// equivalent for revocation purposes, so a certificate revoked under one
+// appears on the CRL of every issuer holding the same key.
+func signIssuerCRL(ctx context.Context, req *logical.Request, issuers map[string]*issuerEntry, issuerID string, revokedByIssuer map[string][]pkix.RevokedCertificate, number int64, thisUpdate, nextUpdate time.Time, extensions []pkix.Extension) ([]byte, error) {
+	issuer := issuers[issuerID]
+
+	var revokedCerts []pkix.RevokedCertificate
+	for otherID, other := range issuers {
+		if otherID == issuerID || other.KeyID == issuer.KeyID {
+			revokedCerts = append(revokedCerts, revokedByIssuer[otherID]...)
+		}
+	}
+
+	signingBundle, caErr := fetchCAInfoByIssuerID(ctx, req, issuerID)
 	switch caErr.(type) {
 	case errutil.UserError:
-		return errutil.UserError{Err: fmt.Sprintf("could not fetch the CA certificate: %s", caErr)}
+		return nil, errutil.UserError{Err: fmt.Sprintf("could not fetch the CA certificate: %s", caErr)}
 	case errutil.InternalError:
-		return errutil.InternalError{Err: fmt.Sprintf("error fetching CA certificate: %s", caErr)}
+		return nil, errutil.InternalError{Err: fmt.Sprintf("error fetching CA certificate: %s", caErr)}
 	}
 
-	crlBytes, err := signingBundle.Certificate.CreateCRL(rand.Reader, signingBundle.PrivateKey, revokedCerts, time.Now(), time.Now().Add(crlLifetime))
+	// CRL numbers and extensions need the issuer to be marked for CRL
+	// signing and to carry a subject key identifier; older imported CAs
+	// may lack these, so they keep getting plain CRLs.
+	caCert := signingBundle.Certificate
+	if caCert.KeyUsage&x509.KeyUsageCRLSign == 0 || len(caCert.SubjectKeyId) == 0 {
+		crlBytes, err := caCert.CreateCRL(rand.Reader, signingBundle.PrivateKey, revokedCerts, thisUpdate, nextUpdate)
+		if err != nil {
+			return nil, errutil.InternalError{Err: fmt.Sprintf("error creating new CRL: %s", err)}
+		}
+		return crlBytes, nil
+	}
+
+	crlBytes, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		RevokedCertificates: revokedCerts,
+		Number:              big.NewInt(number),
+		ThisUpdate:          thisUpdate,
+		NextUpdate:          nextUpdate,
+		ExtraExtensions:     extensions,
+	}, caCert, signingBundle.PrivateKey)
 	if err != nil {
-		return errutil.InternalError{Err: fmt.Sprintf("error creating new CRL: %s", err)}
+		return nil, errutil.InternalError{Err: fmt.Sprintf("error creating new CRL: %s", err)}
 	}
+	return crlBytes, nil
+}
+
+var (
+	oidExtensionDeltaCRLIndicator = asn1.ObjectIdentifier{2, 5, 29, 27}
+	oidExtensionFreshestCRL       = asn1.ObjectIdentifier{2, 5, 29, 46}
+)
 
-	err = req.Storage.Put(ctx, &logical.StorageEntry{
-		Key:   "crl",
-		Value: crlBytes,
+// distributionPoint mirrors the ASN.1 structure of RFC 5280 section 4.2.1.13,
+// shared by the CRL distribution points and freshest CRL extensions
+type distributionPoint struct {
+	DistributionPoint distributionPointName `asn1:"optional,tag:0"`
+}
+
+type distributionPointName struct {
+	FullName []asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+// freshestCRLExtension points CRL consumers at the delta CRL
+func freshestCRLExtension(deltaURL string) (pkix.Extension, error) {
+	value, err := asn1.Marshal([]distributionPoint{
+		{
+			DistributionPoint: distributionPointName{
+				FullName: []asn1.RawValue{
+					{Tag: 6, Class: asn1.ClassContextSpecific, Bytes: []byte(deltaURL)},
+				},
+			},
+		},
 	})
 	if err != nil {
-		return errutil.InternalError{Err: fmt.Sprintf("error storing CRL: %s", err)}
+		return pkix.Extension{}, err
 	}
-
-	return nil
+	return pkix.Extension{Id: oidExtensionFreshestCRL, Value: value}, nil
 }
@@ -286,3 +286,40 @@ func addCAIssueFields(fields map[string]*framework.FieldSchema) map[string]*fram
 
 	return fields
 }
+
+// addIssuerRefField adds the field used to select the issuer a request is
+// signed by
+func addIssuerRefField(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["issuer_ref"] = &framework.FieldSchema{
+		Type:    framework.TypeString,
+		Default: defaultRef,
+		Description: `Reference to an existing issuer, either by its
+ID or name, or "default" for the mount's
+default issuer.`,
+		DisplayAttrs: &framework.DisplayAttributes{
+			Name: "Issuer",
+		},
+	}
+
+	return fields
+}
+
+// addIssuerNameFields adds the fields used to name newly created issuers
+// and keys
+func addIssuerNameFields(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["issuer_name"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `Optional name to give the new issuer; it can be
+used in place of the issuer ID when referencing
+it.`,
+	}
+
+	fields["key_name"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `Optional name to give the new key; it can be
+used in place of the key ID when referencing
+it.`,
+	}
+
+	return fields
+}
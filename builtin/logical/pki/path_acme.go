@@ -0,0 +1,1041 @@
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// acmeOperation is an ACME endpoint handler, called once the directory has
+// been resolved and the JWS verified
+type acmeOperation func(ctx context.Context, actx *acmeContext, acmeReq *acmeRequest, req *logical.Request, data *framework.FieldData) (*logical.Response, error)
+
+func pathACMEDirectory(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: acmePattern("directory"),
+		Fields:  addACMEFields(map[string]*framework.FieldSchema{}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathACMEDirectoryRead,
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMENewNonce(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: acmePattern("new-nonce"),
+		Fields:  addACMEFields(map[string]*framework.FieldSchema{}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathACMENewNonce,
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMENewAccount(b *backend) *framework.Path {
+	return acmeUpdatePath(acmePattern("new-account"), b.acmeHandler(true, b.acmeNewAccount))
+}
+
+func pathACMEAccount(b *backend) *framework.Path {
+	return acmeUpdatePath(acmePattern("account/"+framework.GenericNameRegex("account_id")), b.acmeHandler(false, b.acmeAccountUpdate))
+}
+
+func pathACMEAccountOrders(b *backend) *framework.Path {
+	return acmeUpdatePath(acmePattern("account/"+framework.GenericNameRegex("account_id")+"/orders"), b.acmeHandler(false, b.acmeAccountOrders))
+}
+
+func pathACMENewOrder(b *backend) *framework.Path {
+	return acmeUpdatePath(acmePattern("new-order"), b.acmeHandler(false, b.acmeNewOrder))
+}
+
+func pathACMEOrder(b *backend) *framework.Path {
+	return acmeUpdatePath(acmePattern("order/"+framework.GenericNameRegex("order_id")), b.acmeHandler(false, b.acmeOrderFetch))
+}
+
+func pathACMEOrderFinalize(b *backend) *framework.Path {
+	return acmeUpdatePath(acmePattern("order/"+framework.GenericNameRegex("order_id")+"/finalize"), b.acmeHandler(false, b.acmeOrderFinalize))
+}
+
+func pathACMEOrderCert(b *backend) *framework.Path {
+	return acmeUpdatePath(acmePattern("order/"+framework.GenericNameRegex("order_id")+"/cert"), b.acmeHandler(false, b.acmeOrderCert))
+}
+
+func pathACMEAuthorization(b *backend) *framework.Path {
+	return acmeUpdatePath(acmePattern("authorization/"+framework.GenericNameRegex("authz_id")), b.acmeHandler(false, b.acmeAuthorizationFetch))
+}
+
+func pathACMEChallenge(b *backend) *framework.Path {
+	return acmeUpdatePath(acmePattern("challenge/"+framework.GenericNameRegex("authz_id")+"/"+framework.GenericNameRegex("challenge_type")), b.acmeHandler(false, b.acmeChallenge))
+}
+
+func pathACMERevoke(b *backend) *framework.Path {
+	return acmeUpdatePath(acmePattern("revoke-cert"), b.acmeHandler(true, b.acmeRevoke))
+}
+
+// acmeUpdatePath builds a path for an endpoint taking a JWS-signed POST
+func acmeUpdatePath(pattern string, handler framework.OperationFunc) *framework.Path {
+	fields := addACMEFields(map[string]*framework.FieldSchema{})
+	for _, name := range []string{"account_id", "order_id", "authz_id", "challenge_type"} {
+		if strings.Contains(pattern, "<"+name+">") {
+			fields[name] = &framework.FieldSchema{
+				Type: framework.TypeString,
+			}
+		}
+	}
+
+	return &framework.Path{
+		Pattern: pattern,
+		Fields:  fields,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: handler,
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+// acmeHandler resolves the directory and verifies the JWS of a request
+// before passing it on, answering any failure with a problem document
+func (b *backend) acmeHandler(allowJWK bool, op acmeOperation) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		// Nonces and ACME state are managed by the active node
+		if b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby) {
+			return nil, logical.ErrReadOnly
+		}
+
+		actx, acmeErr := b.acmeContextFor(ctx, req, data)
+		if acmeErr != nil {
+			return b.acmeErrorResponse(ctx, req, nil, acmeErr)
+		}
+
+		acmeReq, acmeErr := b.acmeVerify(ctx, req, data, actx, allowJWK)
+		if acmeErr != nil {
+			return b.acmeErrorResponse(ctx, req, actx, acmeErr)
+		}
+
+		// Requests are serialized per account, or per key before an account
+		// exists
+		lockKey := ""
+		if acmeReq.account != nil {
+			lockKey = "account/" + acmeReq.account.ID
+		} else {
+			thumbprint, err := acmeThumbprint(acmeReq.jwk)
+			if err != nil {
+				return nil, err
+			}
+			lockKey = "jwk/" + thumbprint
+		}
+		lock := locksutil.LockForKey(b.acmeLocks, lockKey)
+		lock.Lock()
+		defer lock.Unlock()
+
+		return op(ctx, actx, acmeReq, req, data)
+	}
+}
+
+func (b *backend) pathACMEDirectoryRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// Every response carries a nonce, which only the active node can issue
+	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby) {
+		return nil, logical.ErrReadOnly
+	}
+
+	actx, acmeErr := b.acmeContextFor(ctx, req, data)
+	if acmeErr != nil {
+		return b.acmeErrorResponse(ctx, req, nil, acmeErr)
+	}
+
+	return b.acmeJSONResponse(ctx, req, actx, http.StatusOK, map[string]interface{}{
+		"newNonce":   actx.baseURL + "/new-nonce",
+		"newAccount": actx.baseURL + "/new-account",
+		"newOrder":   actx.baseURL + "/new-order",
+		"revokeCert": actx.baseURL + "/revoke-cert",
+		"meta": map[string]interface{}{
+			"externalAccountRequired": false,
+		},
+	}, "")
+}
+
+func (b *backend) pathACMENewNonce(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby) {
+		return nil, logical.ErrReadOnly
+	}
+
+	actx, acmeErr := b.acmeContextFor(ctx, req, data)
+	if acmeErr != nil {
+		return b.acmeErrorResponse(ctx, req, nil, acmeErr)
+	}
+
+	// RFC 8555 answers HEAD requests with 200 and GET requests with 204
+	status := http.StatusNoContent
+	if req.HTTPRequest != nil && req.HTTPRequest.Method == http.MethodHead {
+		status = http.StatusOK
+	}
+	return b.acmeResponse(ctx, req, actx, status, "", nil, "")
+}
+
+func (b *backend) acmeNewAccount(ctx context.Context, actx *acmeContext, acmeReq *acmeRequest, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if acmeReq.jwk == nil {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("malformed", http.StatusBadRequest, "new accounts must be requested with a jwk"))
+	}
+
+	var payload struct {
+		Contact              []string `json:"contact"`
+		TermsOfServiceAgreed bool     `json:"termsOfServiceAgreed"`
+		OnlyReturnExisting   bool     `json:"onlyReturnExisting"`
+	}
+	if err := json.Unmarshal(acmeReq.payload, &payload); err != nil {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("malformed", http.StatusBadRequest, "unable to parse payload: %v", err))
+	}
+
+	thumbprint, err := acmeThumbprint(acmeReq.jwk)
+	if err != nil {
+		return nil, err
+	}
+	thumbprintPath := acmeThumbprintPrefix + actx.roleName + "/" + thumbprint
+
+	entry, err := req.Storage.Get(ctx, thumbprintPath)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil {
+		account, err := getACMEAccount(ctx, req.Storage, string(entry.Value))
+		if err != nil {
+			return nil, err
+		}
+		if account != nil {
+			return b.acmeJSONResponse(ctx, req, actx, http.StatusOK, acmeAccountResponse(actx, account), actx.accountURL(account.ID))
+		}
+	}
+
+	if payload.OnlyReturnExisting {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("accountDoesNotExist", http.StatusBadRequest, "no account exists for this key"))
+	}
+
+	if acmeErr := validateACMEContacts(payload.Contact); acmeErr != nil {
+		return b.acmeErrorResponse(ctx, req, actx, acmeErr)
+	}
+
+	key, err := acmeReq.jwk.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	account := &acmeAccount{
+		ID:          id,
+		Key:         key,
+		Thumbprint:  thumbprint,
+		Contact:     payload.Contact,
+		Status:      acmeStatusValid,
+		Role:        actx.roleName,
+		CreatedTime: time.Now().UTC(),
+	}
+	if err := writeACMEAccount(ctx, req.Storage, account); err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, &logical.StorageEntry{
+		Key:   thumbprintPath,
+		Value: []byte(account.ID),
+	}); err != nil {
+		return nil, err
+	}
+
+	return b.acmeJSONResponse(ctx, req, actx, http.StatusCreated, acmeAccountResponse(actx, account), actx.accountURL(account.ID))
+}
+
+func (b *backend) acmeAccountUpdate(ctx context.Context, actx *acmeContext, acmeReq *acmeRequest, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	account := acmeReq.account
+	if data.Get("account_id").(string) != account.ID {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("unauthorized", http.StatusUnauthorized, "account URL does not match kid"))
+	}
+
+	// An empty payload is a POST-as-GET
+	if len(acmeReq.payload) > 0 {
+		var payload struct {
+			Contact []string `json:"contact"`
+			Status  string   `json:"status"`
+		}
+		if err := json.Unmarshal(acmeReq.payload, &payload); err != nil {
+			return b.acmeErrorResponse(ctx, req, actx, newACMEError("malformed", http.StatusBadRequest, "unable to parse payload: %v", err))
+		}
+
+		if payload.Contact != nil {
+			if acmeErr := validateACMEContacts(payload.Contact); acmeErr != nil {
+				return b.acmeErrorResponse(ctx, req, actx, acmeErr)
+			}
+			account.Contact = payload.Contact
+		}
+		switch payload.Status {
+		case "":
+		case acmeStatusDeactivated:
+			account.Status = acmeStatusDeactivated
+		default:
+			return b.acmeErrorResponse(ctx, req, actx, newACMEError("malformed", http.StatusBadRequest, "accounts may only be deactivated"))
+		}
+
+		if err := writeACMEAccount(ctx, req.Storage, account); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.acmeJSONResponse(ctx, req, actx, http.StatusOK, acmeAccountResponse(actx, account), "")
+}
+
+func (b *backend) acmeAccountOrders(ctx context.Context, actx *acmeContext, acmeReq *acmeRequest, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	account := acmeReq.account
+	if data.Get("account_id").(string) != account.ID {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("unauthorized", http.StatusUnauthorized, "account URL does not match kid"))
+	}
+
+	orderIDs, err := req.Storage.List(ctx, acmeAccountPrefix+account.ID+"/orders/")
+	if err != nil {
+		return nil, err
+	}
+
+	orders := []string{}
+	for _, orderID := range orderIDs {
+		orders = append(orders, actx.orderURL(orderID))
+	}
+
+	return b.acmeJSONResponse(ctx, req, actx, http.StatusOK, map[string]interface{}{
+		"orders": orders,
+	}, "")
+}
+
+func (b *backend) acmeNewOrder(ctx context.Context, actx *acmeContext, acmeReq *acmeRequest, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	var payload struct {
+		Identifiers []acmeIdentifier `json:"identifiers"`
+		NotBefore   string           `json:"notBefore"`
+		NotAfter    string           `json:"notAfter"`
+	}
+	if err := json.Unmarshal(acmeReq.payload, &payload); err != nil {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("malformed", http.StatusBadRequest, "unable to parse payload: %v", err))
+	}
+	if payload.NotBefore != "" || payload.NotAfter != "" {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("malformed", http.StatusBadRequest, "notBefore and notAfter are not supported; the validity period is set by the role"))
+	}
+	if len(payload.Identifiers) == 0 {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("malformed", http.StatusBadRequest, "at least one identifier is required"))
+	}
+
+	identifiers, acmeErr := b.validateACMEIdentifiers(actx, req, payload.Identifiers)
+	if acmeErr != nil {
+		return b.acmeErrorResponse(ctx, req, actx, acmeErr)
+	}
+
+	orderID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	expires := time.Now().UTC().Add(acmeAuthzTTL).Truncate(time.Second)
+
+	order := &acmeOrder{
+		ID:          orderID,
+		AccountID:   acmeReq.account.ID,
+		Status:      acmeStatusPending,
+		Expires:     expires,
+		Identifiers: identifiers,
+	}
+
+	for _, identifier := range identifiers {
+		authz, err := newACMEAuthorization(acmeReq.account.ID, identifier, expires)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeACMEAuthorization(ctx, req.Storage, authz); err != nil {
+			return nil, err
+		}
+		order.AuthorizationIDs = append(order.AuthorizationIDs, authz.ID)
+	}
+
+	if err := writeACMEOrder(ctx, req.Storage, order); err != nil {
+		return nil, err
+	}
+
+	return b.acmeJSONResponse(ctx, req, actx, http.StatusCreated, acmeOrderResponse(actx, order), actx.orderURL(order.ID))
+}
+
+func (b *backend) acmeOrderFetch(ctx context.Context, actx *acmeContext, acmeReq *acmeRequest, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	order, err := b.fetchACMEOrder(ctx, req.Storage, acmeReq.account.ID, data.Get("order_id").(string))
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("malformed", http.StatusNotFound, "order does not exist"))
+	}
+
+	return b.acmeJSONResponse(ctx, req, actx, http.StatusOK, acmeOrderResponse(actx, order), "")
+}
+
+func (b *backend) acmeOrderFinalize(ctx context.Context, actx *acmeContext, acmeReq *acmeRequest, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	order, err := b.fetchACMEOrder(ctx, req.Storage, acmeReq.account.ID, data.Get("order_id").(string))
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("malformed", http.StatusNotFound, "order does not exist"))
+	}
+	if order.Status != acmeStatusReady {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("orderNotReady", http.StatusForbidden, "order is %s", order.Status))
+	}
+
+	var payload struct {
+		CSR string `json:"csr"`
+	}
+	if err := json.Unmarshal(acmeReq.payload, &payload); err != nil {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("malformed", http.StatusBadRequest, "unable to parse payload: %v", err))
+	}
+	csrBytes, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(payload.CSR, "="))
+	if err != nil {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("badCSR", http.StatusBadRequest, "unable to decode CSR"))
+	}
+	csr, err := x509.ParseCertificateRequest(csrBytes)
+	if err != nil {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("badCSR", http.StatusBadRequest, "unable to parse CSR: %v", err))
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("badCSR", http.StatusBadRequest, "invalid CSR signature"))
+	}
+	if acmeErr := checkACMECSRIdentifiers(csr, order.Identifiers); acmeErr != nil {
+		return b.acmeErrorResponse(ctx, req, actx, acmeErr)
+	}
+
+	serial, chain, err := b.acmeIssueCertificate(ctx, req, actx, csr, order.Identifiers)
+	if err != nil {
+		if _, ok := err.(errutil.UserError); ok {
+			return b.acmeErrorResponse(ctx, req, actx, newACMEError("badCSR", http.StatusBadRequest, "%s", err))
+		}
+		return nil, err
+	}
+
+	order.Status = acmeStatusValid
+	order.CertificateSerial = serial
+	order.Certificate = chain
+	if err := writeACMEOrder(ctx, req.Storage, order); err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, &logical.StorageEntry{
+		Key:   acmeCertPrefix + normalizeSerial(serial),
+		Value: []byte(order.AccountID),
+	}); err != nil {
+		return nil, err
+	}
+
+	return b.acmeJSONResponse(ctx, req, actx, http.StatusOK, acmeOrderResponse(actx, order), actx.orderURL(order.ID))
+}
+
+func (b *backend) acmeOrderCert(ctx context.Context, actx *acmeContext, acmeReq *acmeRequest, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	order, err := getACMEOrder(ctx, req.Storage, acmeReq.account.ID, data.Get("order_id").(string))
+	if err != nil {
+		return nil, err
+	}
+	if order == nil || order.Status != acmeStatusValid {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("malformed", http.StatusNotFound, "certificate does not exist"))
+	}
+
+	return b.acmeResponse(ctx, req, actx, http.StatusOK, acmeCertificateContentType, []byte(order.Certificate), "")
+}
+
+func (b *backend) acmeAuthorizationFetch(ctx context.Context, actx *acmeContext, acmeReq *acmeRequest, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	authz, err := getACMEAuthorization(ctx, req.Storage, acmeReq.account.ID, data.Get("authz_id").(string))
+	if err != nil {
+		return nil, err
+	}
+	if authz == nil {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("malformed", http.StatusNotFound, "authorization does not exist"))
+	}
+
+	if len(acmeReq.payload) > 0 {
+		var payload struct {
+			Status string `json:"status"`
+		}
+		if err := json.Unmarshal(acmeReq.payload, &payload); err != nil {
+			return b.acmeErrorResponse(ctx, req, actx, newACMEError("malformed", http.StatusBadRequest, "unable to parse payload: %v", err))
+		}
+		if payload.Status != acmeStatusDeactivated {
+			return b.acmeErrorResponse(ctx, req, actx, newACMEError("malformed", http.StatusBadRequest, "authorizations may only be deactivated"))
+		}
+		authz.Status = acmeStatusDeactivated
+		if err := writeACMEAuthorization(ctx, req.Storage, authz); err != nil {
+			return nil, err
+		}
+	}
+
+	if authz.Status == acmeStatusPending && time.Now().After(authz.Expires) {
+		authz.Status = acmeStatusInvalid
+	}
+
+	return b.acmeJSONResponse(ctx, req, actx, http.StatusOK, acmeAuthorizationResponse(actx, authz), "")
+}
+
+func (b *backend) acmeChallenge(ctx context.Context, actx *acmeContext, acmeReq *acmeRequest, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	authz, err := getACMEAuthorization(ctx, req.Storage, acmeReq.account.ID, data.Get("authz_id").(string))
+	if err != nil {
+		return nil, err
+	}
+	if authz == nil {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("malformed", http.StatusNotFound, "authorization does not exist"))
+	}
+
+	var challenge *acmeChallenge
+	for _, c := range authz.Challenges {
+		if c.Type == data.Get("challenge_type").(string) {
+			challenge = c
+		}
+	}
+	if challenge == nil {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("malformed", http.StatusNotFound, "challenge does not exist"))
+	}
+
+	// Validation is only started once; repeated requests report progress.
+	// A validation that is no longer running, as it was interrupted by a
+	// leadership change or the backend being reloaded, is started again.
+	if authz.Status == acmeStatusPending && (challenge.Status == acmeStatusPending || challenge.Status == acmeStatusProcessing) {
+		if time.Now().After(authz.Expires) {
+			return b.acmeErrorResponse(ctx, req, actx, newACMEError("malformed", http.StatusForbidden, "authorization has expired"))
+		}
+
+		if challenge.Status == acmeStatusPending {
+			challenge.Status = acmeStatusProcessing
+			if err := writeACMEAuthorization(ctx, req.Storage, authz); err != nil {
+				return nil, err
+			}
+		}
+
+		b.startACMEValidation(req.Storage, acmeReq.account.Thumbprint, authz.AccountID, authz.ID, challenge.Type)
+	}
+
+	resp, err := b.acmeJSONResponse(ctx, req, actx, http.StatusOK, acmeChallengeResponse(actx, authz, challenge), "")
+	if err != nil {
+		return nil, err
+	}
+	resp.Headers["Link"] = append(resp.Headers["Link"], fmt.Sprintf("<%s>;rel=\"up\"", actx.authorizationURL(authz.ID)))
+	return resp, nil
+}
+
+// startACMEValidation validates a challenge in the background, unless this
+// node is already doing so
+func (b *backend) startACMEValidation(s logical.Storage, thumbprint, accountID, authzID, challengeType string) {
+	key := accountID + "/" + authzID + "/" + challengeType
+
+	b.acmeValidationsLock.Lock()
+	defer b.acmeValidationsLock.Unlock()
+	if _, ok := b.acmeValidations[key]; ok || b.acmeValidationCtx.Err() != nil {
+		return
+	}
+	b.acmeValidations[key] = struct{}{}
+
+	b.acmeValidationWG.Add(1)
+	go func() {
+		defer b.acmeValidationWG.Done()
+		defer func() {
+			b.acmeValidationsLock.Lock()
+			delete(b.acmeValidations, key)
+			b.acmeValidationsLock.Unlock()
+		}()
+
+		b.runACMEValidation(b.acmeValidationCtx, s, thumbprint, accountID, authzID, challengeType)
+	}()
+}
+
+// stopACMEValidations cancels the running validations and waits for them to
+// return. Their challenges are left processing, and are validated again when
+// the client next polls them.
+func (b *backend) stopACMEValidations(_ context.Context) {
+	b.acmeValidationsLock.Lock()
+	b.acmeValidationCancel()
+	b.acmeValidationsLock.Unlock()
+
+	b.acmeValidationWG.Wait()
+}
+
+// runACMEValidation validates a challenge and records the result on its
+// authorization
+func (b *backend) runACMEValidation(ctx context.Context, s logical.Storage, thumbprint, accountID, authzID, challengeType string) {
+	logger := b.Logger().Named("acme")
+
+	authz, err := getACMEAuthorization(ctx, s, accountID, authzID)
+	if err != nil || authz == nil {
+		logger.Error("unable to load authorization for validation", "authorization", authzID, "error", err)
+		return
+	}
+
+	var challenge *acmeChallenge
+	for _, c := range authz.Challenges {
+		if c.Type == challengeType {
+			challenge = c
+		}
+	}
+	if challenge == nil {
+		return
+	}
+
+	acmeErr := b.validateACMEChallenge(ctx, authz, challenge, thumbprint)
+	if ctx.Err() != nil {
+		// The backend is being cleaned up; the result may be due to the
+		// cancellation, so it is not recorded
+		return
+	}
+
+	lock := locksutil.LockForKey(b.acmeLocks, "account/"+accountID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Reload, as the authorization may have been deactivated meanwhile
+	authz, err = getACMEAuthorization(ctx, s, accountID, authzID)
+	if err != nil || authz == nil {
+		logger.Error("unable to load authorization for validation", "authorization", authzID, "error", err)
+		return
+	}
+	for _, c := range authz.Challenges {
+		if c.Type == challengeType {
+			challenge = c
+		}
+	}
+	if challenge.Status != acmeStatusProcessing {
+		return
+	}
+
+	if acmeErr != nil {
+		logger.Debug("challenge validation failed", "authorization", authzID, "type", challengeType, "error", acmeErr)
+		challenge.Status = acmeStatusInvalid
+		challenge.Error = acmeErr
+		if authz.Status == acmeStatusPending {
+			authz.Status = acmeStatusInvalid
+		}
+	} else {
+		challenge.Status = acmeStatusValid
+		challenge.Validated = time.Now().UTC()
+		if authz.Status == acmeStatusPending {
+			authz.Status = acmeStatusValid
+		}
+	}
+
+	if err := writeACMEAuthorization(ctx, s, authz); err != nil {
+		logger.Error("unable to store validation result", "authorization", authzID, "error", err)
+	}
+}
+
+func (b *backend) acmeRevoke(ctx context.Context, actx *acmeContext, acmeReq *acmeRequest, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	var payload struct {
+		Certificate string `json:"certificate"`
+		Reason      int    `json:"reason"`
+	}
+	if err := json.Unmarshal(acmeReq.payload, &payload); err != nil {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("malformed", http.StatusBadRequest, "unable to parse payload: %v", err))
+	}
+	certBytes, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(payload.Certificate, "="))
+	if err != nil {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("malformed", http.StatusBadRequest, "unable to decode certificate"))
+	}
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("malformed", http.StatusBadRequest, "unable to parse certificate: %v", err))
+	}
+	serial := certutil.GetHexFormatted(cert.SerialNumber.Bytes(), ":")
+
+	// Either the account which ordered the certificate, or the holder of
+	// its private key, may revoke it
+	switch {
+	case acmeReq.account != nil:
+		entry, err := req.Storage.Get(ctx, acmeCertPrefix+normalizeSerial(serial))
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil || string(entry.Value) != acmeReq.account.ID {
+			return b.acmeErrorResponse(ctx, req, actx, newACMEError("unauthorized", http.StatusForbidden, "certificate was not issued to this account"))
+		}
+	default:
+		matches, err := certutil.ComparePublicKeys(acmeReq.jwk.Key, cert.PublicKey)
+		if err != nil || !matches {
+			return b.acmeErrorResponse(ctx, req, actx, newACMEError("unauthorized", http.StatusForbidden, "jwk does not match the certificate"))
+		}
+	}
+
+	// Only certificates held by this mount may be revoked
+	certEntry, err := getStoredCertEntry(ctx, req.Storage, "certs/", serial)
+	if err != nil {
+		return nil, err
+	}
+	if certEntry == nil || !bytes.Equal(certEntry.Value, cert.Raw) {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("unauthorized", http.StatusForbidden, "certificate was not issued by this mount"))
+	}
+	revEntry, err := getStoredCertEntry(ctx, req.Storage, "revoked/", serial)
+	if err != nil {
+		return nil, err
+	}
+	if revEntry != nil {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("alreadyRevoked", http.StatusBadRequest, "certificate is already revoked"))
+	}
+
+	b.revokeStorageLock.Lock()
+	defer b.revokeStorageLock.Unlock()
+
+	resp, err := revokeCert(ctx, b, req, serial, false)
+	if err != nil {
+		return nil, err
+	}
+	if resp != nil && resp.IsError() {
+		return b.acmeErrorResponse(ctx, req, actx, newACMEError("malformed", http.StatusBadRequest, "%s", resp.Error()))
+	}
+
+	return b.acmeResponse(ctx, req, actx, http.StatusOK, "", nil, "")
+}
+
+// validateACMEIdentifiers checks the requested identifiers against the
+// role, returning them normalized and deduplicated
+func (b *backend) validateACMEIdentifiers(actx *acmeContext, req *logical.Request, requested []acmeIdentifier) ([]acmeIdentifier, *acmeError) {
+	input := &inputBundle{
+		req:  req,
+		role: actx.role,
+	}
+
+	seen := map[acmeIdentifier]bool{}
+	var identifiers []acmeIdentifier
+	for _, identifier := range requested {
+		switch identifier.Type {
+		case "dns":
+			identifier.Value = strings.ToLower(strings.TrimSuffix(identifier.Value, "."))
+			if identifier.Value == "" || net.ParseIP(identifier.Value) != nil {
+				return nil, newACMEError("rejectedIdentifier", http.StatusBadRequest, "invalid dns identifier %q", identifier.Value)
+			}
+			if badName := validateNames(b, input, []string{identifier.Value}); badName != "" {
+				return nil, newACMEError("rejectedIdentifier", http.StatusBadRequest, "%s is not allowed by role %s", badName, actx.roleName)
+			}
+		case "ip":
+			ip := net.ParseIP(identifier.Value)
+			if ip == nil {
+				return nil, newACMEError("rejectedIdentifier", http.StatusBadRequest, "invalid ip identifier %q", identifier.Value)
+			}
+			if !actx.role.AllowIPSANs {
+				return nil, newACMEError("rejectedIdentifier", http.StatusBadRequest, "IP addresses are not allowed by role %s", actx.roleName)
+			}
+			identifier.Value = ip.String()
+		default:
+			return nil, newACMEError("unsupportedIdentifier", http.StatusBadRequest, "unsupported identifier type %q", identifier.Type)
+		}
+
+		if !seen[identifier] {
+			seen[identifier] = true
+			identifiers = append(identifiers, identifier)
+		}
+	}
+
+	return identifiers, nil
+}
+
+func newACMEAuthorization(accountID string, identifier acmeIdentifier, expires time.Time) (*acmeAuthorization, error) {
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	authz := &acmeAuthorization{
+		ID:         id,
+		AccountID:  accountID,
+		Identifier: identifier,
+		Status:     acmeStatusPending,
+		Expires:    expires,
+	}
+	if strings.HasPrefix(identifier.Value, "*.") {
+		authz.Wildcard = true
+		authz.Identifier.Value = strings.TrimPrefix(identifier.Value, "*.")
+	}
+
+	for _, challengeType := range acmeChallengeTypes(identifier, authz.Wildcard) {
+		token, err := acmeRandomToken()
+		if err != nil {
+			return nil, err
+		}
+		authz.Challenges = append(authz.Challenges, &acmeChallenge{
+			Type:   challengeType,
+			Token:  token,
+			Status: acmeStatusPending,
+		})
+	}
+
+	return authz, nil
+}
+
+// fetchACMEOrder loads an order, updating its status from that of its
+// authorizations
+func (b *backend) fetchACMEOrder(ctx context.Context, s logical.Storage, accountID, id string) (*acmeOrder, error) {
+	order, err := getACMEOrder(ctx, s, accountID, id)
+	if err != nil || order == nil {
+		return order, err
+	}
+
+	status := order.Status
+	switch {
+	case status == acmeStatusValid || status == acmeStatusInvalid:
+	case time.Now().After(order.Expires):
+		status = acmeStatusInvalid
+	case status == acmeStatusPending:
+		allValid := true
+		for _, authzID := range order.AuthorizationIDs {
+			authz, err := getACMEAuthorization(ctx, s, accountID, authzID)
+			if err != nil {
+				return nil, err
+			}
+			if authz == nil || authz.Status == acmeStatusInvalid || authz.Status == acmeStatusDeactivated {
+				status = acmeStatusInvalid
+				allValid = false
+				break
+			}
+			if authz.Status != acmeStatusValid {
+				allValid = false
+			}
+		}
+		if allValid {
+			status = acmeStatusReady
+		}
+	}
+
+	if status != order.Status {
+		order.Status = status
+		if err := writeACMEOrder(ctx, s, order); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+// checkACMECSRIdentifiers ensures the CSR requests exactly the identifiers
+// of the order
+func checkACMECSRIdentifiers(csr *x509.CertificateRequest, identifiers []acmeIdentifier) *acmeError {
+	requested := map[acmeIdentifier]bool{}
+	for _, name := range csr.DNSNames {
+		requested[acmeIdentifier{Type: "dns", Value: strings.ToLower(name)}] = true
+	}
+	for _, ip := range csr.IPAddresses {
+		requested[acmeIdentifier{Type: "ip", Value: ip.String()}] = true
+	}
+	if cn := csr.Subject.CommonName; cn != "" {
+		if ip := net.ParseIP(cn); ip != nil {
+			requested[acmeIdentifier{Type: "ip", Value: ip.String()}] = true
+		} else {
+			requested[acmeIdentifier{Type: "dns", Value: strings.ToLower(cn)}] = true
+		}
+	}
+	if len(csr.EmailAddresses) > 0 || len(csr.URIs) > 0 {
+		return newACMEError("badCSR", http.StatusBadRequest, "CSR may only contain DNS names and IP addresses")
+	}
+
+	ordered := map[acmeIdentifier]bool{}
+	for _, identifier := range identifiers {
+		ordered[identifier] = true
+	}
+
+	if len(requested) != len(ordered) {
+		return newACMEError("badCSR", http.StatusBadRequest, "CSR identifiers do not match the order")
+	}
+	for identifier := range requested {
+		if !ordered[identifier] {
+			return newACMEError("badCSR", http.StatusBadRequest, "CSR contains %s %q which is not part of the order", identifier.Type, identifier.Value)
+		}
+	}
+
+	return nil
+}
+
+// acmeIssueCertificate signs the CSR of a finalized order using the
+// directory's role, returning the serial number and PEM certificate chain
+func (b *backend) acmeIssueCertificate(ctx context.Context, req *logical.Request, actx *acmeContext, csr *x509.CertificateRequest, identifiers []acmeIdentifier) (string, string, error) {
+	var dnsNames, ipAddresses []string
+	for _, identifier := range identifiers {
+		switch identifier.Type {
+		case "dns":
+			dnsNames = append(dnsNames, identifier.Value)
+		case "ip":
+			ipAddresses = append(ipAddresses, identifier.Value)
+		}
+	}
+	sort.Strings(dnsNames)
+
+	commonName := csr.Subject.CommonName
+	if commonName == "" && len(dnsNames) > 0 {
+		commonName = dnsNames[0]
+	}
+
+	apiData := &framework.FieldData{
+		Raw: map[string]interface{}{
+			"csr":         string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr.Raw})),
+			"common_name": commonName,
+			"alt_names":   strings.Join(dnsNames, ","),
+			"ip_sans":     strings.Join(ipAddresses, ","),
+		},
+		Schema: pathSign(b).Fields,
+	}
+
+	signingBundle, err := fetchCAInfo(ctx, req, actx.role.Issuer)
+	if err != nil {
+		return "", "", err
+	}
+
+	input := &inputBundle{
+		req:     req,
+		apiData: apiData,
+		role:    actx.role,
+	}
+	parsedBundle, err := signCert(b, input, signingBundle, false, false)
+	if err != nil {
+		return "", "", err
+	}
+
+	cb, err := parsedBundle.ToCertBundle()
+	if err != nil {
+		return "", "", errwrap.Wrapf("error converting raw cert bundle to cert bundle: {{err}}", err)
+	}
+
+	if !actx.role.NoStore {
+		err = req.Storage.Put(ctx, &logical.StorageEntry{
+			Key:   "certs/" + normalizeSerial(cb.SerialNumber),
+			Value: parsedBundle.CertificateBytes,
+		})
+		if err != nil {
+			return "", "", errwrap.Wrapf("unable to store certificate locally: {{err}}", err)
+		}
+		if err := recordRoleCert(ctx, req.Storage, actx.roleName, cb.SerialNumber); err != nil {
+			return "", "", errwrap.Wrapf("unable to index certificate by role: {{err}}", err)
+		}
+	}
+
+	chain := append([]string{cb.Certificate}, cb.CAChain...)
+	return cb.SerialNumber, strings.Join(chain, "\n") + "\n", nil
+}
+
+func validateACMEContacts(contacts []string) *acmeError {
+	for _, contact := range contacts {
+		if !strings.HasPrefix(contact, "mailto:") || strings.Contains(contact, ",") {
+			return newACMEError("invalidContact", http.StatusBadRequest, "unsupported contact %q; only single mailto: addresses are supported", contact)
+		}
+	}
+	return nil
+}
+
+func (a *acmeContext) accountURL(id string) string {
+	return a.baseURL + "/account/" + id
+}
+
+func (a *acmeContext) orderURL(id string) string {
+	return a.baseURL + "/order/" + id
+}
+
+func (a *acmeContext) authorizationURL(id string) string {
+	return a.baseURL + "/authorization/" + id
+}
+
+func (a *acmeContext) challengeURL(authzID, challengeType string) string {
+	return a.baseURL + "/challenge/" + authzID + "/" + challengeType
+}
+
+func acmeAccountResponse(actx *acmeContext, account *acmeAccount) map[string]interface{} {
+	contact := account.Contact
+	if contact == nil {
+		contact = []string{}
+	}
+	return map[string]interface{}{
+		"status":  account.Status,
+		"contact": contact,
+		"orders":  actx.accountURL(account.ID) + "/orders",
+	}
+}
+
+func acmeOrderResponse(actx *acmeContext, order *acmeOrder) map[string]interface{} {
+	authorizations := []string{}
+	for _, authzID := range order.AuthorizationIDs {
+		authorizations = append(authorizations, actx.authorizationURL(authzID))
+	}
+
+	resp := map[string]interface{}{
+		"status":         order.Status,
+		"expires":        order.Expires.Format(time.RFC3339),
+		"identifiers":    order.Identifiers,
+		"authorizations": authorizations,
+		"finalize":       actx.orderURL(order.ID) + "/finalize",
+	}
+	if order.Status == acmeStatusValid {
+		resp["certificate"] = actx.orderURL(order.ID) + "/cert"
+	}
+	return resp
+}
+
+func acmeAuthorizationResponse(actx *acmeContext, authz *acmeAuthorization) map[string]interface{} {
+	challenges := []map[string]interface{}{}
+	for _, challenge := range authz.Challenges {
+		challenges = append(challenges, acmeChallengeResponse(actx, authz, challenge))
+	}
+
+	resp := map[string]interface{}{
+		"status":     authz.Status,
+		"expires":    authz.Expires.Format(time.RFC3339),
+		"identifier": authz.Identifier,
+		"challenges": challenges,
+	}
+	if authz.Wildcard {
+		resp["wildcard"] = true
+	}
+	return resp
+}
+
+func acmeChallengeResponse(actx *acmeContext, authz *acmeAuthorization, challenge *acmeChallenge) map[string]interface{} {
+	resp := map[string]interface{}{
+		"type":   challenge.Type,
+		"url":    actx.challengeURL(authz.ID, challenge.Type),
+		"token":  challenge.Token,
+		"status": challenge.Status,
+	}
+	if !challenge.Validated.IsZero() {
+		resp["validated"] = challenge.Validated.Format(time.RFC3339)
+	}
+	if challenge.Error != nil {
+		resp["error"] = challenge.Error
+	}
+	return resp
+}
+
+const pathACMEHelpSyn = `
+ACME (RFC 8555) server endpoints.
+`
+
+const pathACMEHelpDesc = `
+These unauthenticated endpoints implement an ACME server, allowing ACME
+clients such as certbot or cert-manager to obtain certificates without a
+Vault token. Requests are authenticated by the ACME account key, and
+certificates are only issued for identifiers for which the account has
+completed an http-01 or dns-01 challenge.
+
+The directory at "acme/directory" issues certificates using the default role
+set in "config/acme"; "acme/roles/<role>/directory" uses the named role. The
+role's constraints on names, TTLs, and key types apply to every order.
+`
@@ -0,0 +1,363 @@
+package pki
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const acmeTestBaseURL = "https://vault.example.com:8200/v1/pki"
+
+// acmeTestClient is a minimal ACME client driving the backend directly
+type acmeTestClient struct {
+	t          *testing.T
+	b          *backend
+	storage    logical.Storage
+	directory  string
+	key        *ecdsa.PrivateKey
+	accountURL string
+}
+
+func (c *acmeTestClient) path(endpoint string) string {
+	return strings.TrimPrefix(c.directory+"/"+endpoint, acmeTestBaseURL+"/")
+}
+
+func (c *acmeTestClient) nonce() string {
+	c.t.Helper()
+	httpReq, err := http.NewRequest(http.MethodHead, "/v1/pki/"+c.path("new-nonce"), nil)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	resp, err := c.b.HandleRequest(context.Background(), &logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        c.path("new-nonce"),
+		Storage:     c.storage,
+		HTTPRequest: httpReq,
+	})
+	if err != nil || resp == nil || len(resp.Headers["Replay-Nonce"]) != 1 || resp.Data[logical.HTTPStatusCode] != http.StatusOK {
+		c.t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+	return resp.Headers["Replay-Nonce"][0]
+}
+
+// post sends a JWS-signed request, returning the status, decoded body and
+// response
+func (c *acmeTestClient) post(endpoint string, payload interface{}) (int, map[string]interface{}, *logical.Response) {
+	c.t.Helper()
+
+	var payloadBytes []byte
+	if payload != nil {
+		var err error
+		payloadBytes, err = json.Marshal(payload)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+	}
+
+	opts := &jose.SignerOptions{}
+	opts.WithHeader("nonce", c.nonce())
+	opts.WithHeader("url", c.directory+"/"+endpoint)
+	if c.accountURL != "" {
+		opts.WithHeader("kid", c.accountURL)
+	} else {
+		opts.EmbedJWK = true
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: c.key}, opts)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	jws, err := signer.Sign(payloadBytes)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(jws.FullSerialize()), &data); err != nil {
+		c.t.Fatal(err)
+	}
+
+	resp, err := c.b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      c.path(endpoint),
+		Storage:   c.storage,
+		Data:      data,
+	})
+	if err != nil || resp == nil {
+		c.t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+
+	status := resp.Data[logical.HTTPStatusCode].(int)
+	var body map[string]interface{}
+	if resp.Data[logical.HTTPContentType] != acmeCertificateContentType {
+		if raw, ok := resp.Data[logical.HTTPRawBody].([]byte); ok && len(raw) > 0 {
+			if err := json.Unmarshal(raw, &body); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+	}
+	return status, body, resp
+}
+
+func (c *acmeTestClient) endpointOf(fullURL string) string {
+	return strings.TrimPrefix(fullURL, c.directory+"/")
+}
+
+func TestPki_ACME(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s %s: err: %v resp: %#v", op, path, err, resp)
+		}
+		return resp
+	}
+
+	request(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "root.example.com",
+		"ttl":         "720h",
+	})
+	request(logical.UpdateOperation, "roles/acme", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"allow_ip_sans":    true,
+		"require_cn":       false,
+		"key_type":         "ec",
+		"key_bits":         256,
+		"ttl":              "1h",
+	})
+	request(logical.UpdateOperation, "config/urls", map[string]interface{}{
+		"base_url": acmeTestBaseURL,
+	})
+
+	client := &acmeTestClient{
+		t:         t,
+		b:         b,
+		storage:   storage,
+		directory: acmeTestBaseURL + "/acme/roles/acme",
+	}
+	var err error
+	client.key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ACME is disabled by default
+	resp := request(logical.ReadOperation, client.path("directory"), nil)
+	if resp.Data[logical.HTTPStatusCode] != http.StatusNotFound {
+		t.Fatalf("expected disabled directory, got %#v", resp)
+	}
+
+	request(logical.UpdateOperation, "config/acme", map[string]interface{}{
+		"enabled": true,
+	})
+	resp = request(logical.ReadOperation, client.path("directory"), nil)
+	var directory map[string]interface{}
+	if err := json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &directory); err != nil {
+		t.Fatal(err)
+	}
+	if directory["newOrder"] != client.directory+"/new-order" {
+		t.Fatalf("unexpected directory: %v", directory)
+	}
+
+	// Serve http-01 challenges
+	var challengeLock sync.Mutex
+	keyAuthorizations := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		challengeLock.Lock()
+		defer challengeLock.Unlock()
+		keyAuth, ok := keyAuthorizations[strings.TrimPrefix(r.URL.Path, "/.well-known/acme-challenge/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(keyAuth))
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.acmeHTTPChallengePort, err = strconv.Atoi(serverURL.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, account, resp := client.post("new-account", map[string]interface{}{
+		"contact":              []string{"mailto:admin@example.com"},
+		"termsOfServiceAgreed": true,
+	})
+	if status != http.StatusCreated || account["status"] != acmeStatusValid {
+		t.Fatalf("unexpected account response: %d %v", status, account)
+	}
+	client.accountURL = resp.Headers["Location"][0]
+
+	// Identifiers outside of the role are rejected
+	status, problem, _ := client.post("new-order", map[string]interface{}{
+		"identifiers": []acmeIdentifier{{Type: "dns", Value: "www.example.org"}},
+	})
+	if status != http.StatusBadRequest || problem["type"] != "urn:ietf:params:acme:error:rejectedIdentifier" {
+		t.Fatalf("expected rejected identifier, got %d %v", status, problem)
+	}
+
+	status, order, resp := client.post("new-order", map[string]interface{}{
+		"identifiers": []acmeIdentifier{{Type: "ip", Value: "127.0.0.1"}},
+	})
+	if status != http.StatusCreated || order["status"] != acmeStatusPending {
+		t.Fatalf("unexpected order response: %d %v", status, order)
+	}
+	orderEndpoint := client.endpointOf(resp.Headers["Location"][0])
+	authzEndpoint := client.endpointOf(order["authorizations"].([]interface{})[0].(string))
+
+	_, authz, _ := client.post(authzEndpoint, nil)
+	challenge := authz["challenges"].([]interface{})[0].(map[string]interface{})
+	if challenge["type"] != acmeChallengeHTTP01 {
+		t.Fatalf("expected http-01 challenge, got %v", challenge)
+	}
+
+	jwk := &jose.JSONWebKey{Key: client.key.Public()}
+	thumbprint, err := acmeThumbprint(jwk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := challenge["token"].(string)
+	challengeLock.Lock()
+	keyAuthorizations[token] = token + "." + thumbprint
+	challengeLock.Unlock()
+
+	status, _, _ = client.post(client.endpointOf(challenge["url"].(string)), map[string]interface{}{})
+	if status != http.StatusOK {
+		t.Fatalf("unexpected challenge response status %d", status)
+	}
+
+	for i := 0; ; i++ {
+		_, authz, _ = client.post(authzEndpoint, nil)
+		if authz["status"] == acmeStatusValid {
+			break
+		}
+		if authz["status"] != acmeStatusPending || i > 50 {
+			t.Fatalf("authorization was not validated: %v", authz)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	_, order, _ = client.post(orderEndpoint, nil)
+	if order["status"] != acmeStatusReady {
+		t.Fatalf("expected ready order, got %v", order)
+	}
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:     pkix.Name{},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	}, certKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, order, _ = client.post(client.endpointOf(order["finalize"].(string)), map[string]interface{}{
+		"csr": base64.RawURLEncoding.EncodeToString(csr),
+	})
+	if status != http.StatusOK || order["status"] != acmeStatusValid {
+		t.Fatalf("unexpected finalize response: %d %v", status, order)
+	}
+
+	_, _, resp = client.post(client.endpointOf(order["certificate"].(string)), nil)
+	chain := string(resp.Data[logical.HTTPRawBody].([]byte))
+	cert := parsePEMCert(t, chain)
+	if len(cert.IPAddresses) != 1 || !cert.IPAddresses[0].Equal(net.ParseIP("127.0.0.1")) {
+		t.Fatalf("unexpected certificate IP SANs: %v", cert.IPAddresses)
+	}
+
+	revokePayload := map[string]interface{}{
+		"certificate": base64.RawURLEncoding.EncodeToString(cert.Raw),
+	}
+	status, problem, _ = client.post("revoke-cert", revokePayload)
+	if status != http.StatusOK {
+		t.Fatalf("unexpected revocation response: %d %v", status, problem)
+	}
+	status, problem, _ = client.post("revoke-cert", revokePayload)
+	if status != http.StatusBadRequest || problem["type"] != "urn:ietf:params:acme:error:alreadyRevoked" {
+		t.Fatalf("expected already revoked, got %d %v", status, problem)
+	}
+}
+
+func TestPki_ACMENonceStore(t *testing.T) {
+	ctx := context.Background()
+	s := &logical.InmemStorage{}
+	n := newACMENonceStore()
+
+	nonce, err := n.issue(ctx, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nonces are kept in storage, so another store (as on a new active
+	// node) accepts them
+	if valid, err := newACMENonceStore().consume(ctx, s, nonce); err != nil || !valid {
+		t.Fatalf("expected nonce to be valid, got %t, %v", valid, err)
+	}
+
+	// Nonces can only be used once
+	if valid, err := n.consume(ctx, s, nonce); err != nil || valid {
+		t.Fatalf("nonce was used twice: %v", err)
+	}
+	for _, invalid := range []string{"", "bogus", "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"} {
+		if valid, err := n.consume(ctx, s, invalid); err != nil || valid {
+			t.Fatalf("nonce %q was accepted: %v", invalid, err)
+		}
+	}
+
+	// Expired nonces are rejected, and removed when tidying
+	expired := time.Now().Add(-time.Minute).Unix()
+	raw := make([]byte, 24)
+	binary.BigEndian.PutUint64(raw, uint64(expired))
+	expiredNonce := base64.RawURLEncoding.EncodeToString(raw)
+	if err := s.Put(ctx, &logical.StorageEntry{Key: acmeNonceKey(expired, expiredNonce)}); err != nil {
+		t.Fatal(err)
+	}
+	if valid, err := n.consume(ctx, s, expiredNonce); err != nil || valid {
+		t.Fatalf("expired nonce was accepted: %v", err)
+	}
+	unused, err := n.issue(ctx, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.tidy(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := logical.CollectKeysWithPrefix(ctx, s, acmeNoncePrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || !strings.HasSuffix(keys[0], "/"+unused) {
+		t.Fatalf("expected only the unused nonce to be kept, got %v", keys)
+	}
+}
@@ -0,0 +1,111 @@
+package pki
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func pathConfigACME(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/acme",
+		Fields: map[string]*framework.FieldSchema{
+			"enabled": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: `If set to true, enables the ACME directories of this mount.`,
+			},
+			"allowed_roles": &framework.FieldSchema{
+				Type: framework.TypeCommaStringSlice,
+				Description: `Roles which may be used through ACME, or "*"
+to allow all roles. Defaults to "*".`,
+			},
+			"default_role": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Role used by the default directory at
+"acme/directory". If empty, only the role
+directories at "acme/roles/<role>/directory"
+are available.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathACMEConfigRead,
+			logical.UpdateOperation: b.pathACMEConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigACMEHelpSyn,
+		HelpDescription: pathConfigACMEHelpDesc,
+	}
+}
+
+func (b *backend) pathACMEConfigRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := getACMEConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"enabled":       config.Enabled,
+			"allowed_roles": config.AllowedRoles,
+			"default_role":  config.DefaultRole,
+		},
+	}, nil
+}
+
+func (b *backend) pathACMEConfigWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := getACMEConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if enabledRaw, ok := data.GetOk("enabled"); ok {
+		config.Enabled = enabledRaw.(bool)
+	}
+	if allowedRaw, ok := data.GetOk("allowed_roles"); ok {
+		config.AllowedRoles = strutil.RemoveDuplicates(allowedRaw.([]string), false)
+	}
+	if defaultRaw, ok := data.GetOk("default_role"); ok {
+		config.DefaultRole = defaultRaw.(string)
+	}
+
+	if config.DefaultRole != "" {
+		role, err := b.getRole(ctx, req.Storage, config.DefaultRole)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return logical.ErrorResponse(fmt.Sprintf("default_role %q does not exist", config.DefaultRole)), nil
+		}
+		if !strutil.StrListContains(config.AllowedRoles, "*") && !strutil.StrListContains(config.AllowedRoles, config.DefaultRole) {
+			return logical.ErrorResponse("default_role must be one of allowed_roles"), nil
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON(configACMEPath, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const pathConfigACMEHelpSyn = `
+Configure the ACME server of this mount.
+`
+
+const pathConfigACMEHelpDesc = `
+This endpoint enables the ACME (RFC 8555) directories of this mount and
+controls which roles may be used through them. ACME clients are not
+authenticated with Vault tokens; any client able to complete a challenge for
+an identifier allowed by the role can obtain a certificate.
+
+ACME requires "base_url" to be set in "config/urls", and the mount to be
+tuned to pass the "Replay-Nonce", "Location", and "Link" response headers.
+`
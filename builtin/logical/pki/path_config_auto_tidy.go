@@ -0,0 +1,214 @@
+package pki
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	autoTidyConfigPath = "config/auto-tidy"
+
+	// lastTidyPath holds when the last tidy operation started. Certificates
+	// are tidied by each cluster, so it is kept in local storage.
+	lastTidyPath = "tidy/last-run"
+
+	defaultAutoTidyInterval = 12 * time.Hour
+	defaultTidySafetyBuffer = 72 * time.Hour
+)
+
+func pathConfigAutoTidy(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/auto-tidy",
+		Fields: map[string]*framework.FieldSchema{
+			"enabled": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: `Set to true to enable automatic tidy operations.`,
+			},
+
+			"interval_duration": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `Interval at which to run an automatic tidy
+operation. Defaults to 12 hours.`,
+				Default: int(defaultAutoTidyInterval / time.Second),
+			},
+
+			"tidy_cert_store": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Set to true to enable tidying up
+the certificate store`,
+			},
+
+			"tidy_revoked_certs": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Set to true to expire all revoked
+and expired certificates, removing them both from the CRL and from storage. The
+CRL will be rotated if this causes any values to be removed.`,
+			},
+
+			"safety_buffer": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `The amount of extra time that must have passed
+beyond certificate expiration before it is removed
+from the backend storage and/or revocation list.
+Defaults to 72 hours.`,
+				Default: int(defaultTidySafetyBuffer / time.Second),
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigAutoTidyRead,
+			logical.UpdateOperation: b.pathConfigAutoTidyWrite,
+		},
+
+		HelpSynopsis:    pathConfigAutoTidyHelpSyn,
+		HelpDescription: pathConfigAutoTidyHelpDesc,
+	}
+}
+
+func getAutoTidyConfig(ctx context.Context, s logical.Storage) (*tidyConfig, error) {
+	config := &tidyConfig{
+		Interval:     defaultAutoTidyInterval,
+		SafetyBuffer: defaultTidySafetyBuffer,
+	}
+
+	entry, err := s.Get(ctx, autoTidyConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return config, nil
+	}
+
+	if err := entry.DecodeJSON(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (b *backend) pathConfigAutoTidyRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := getAutoTidyConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"enabled":            config.Enabled,
+			"interval_duration":  int64(config.Interval.Seconds()),
+			"tidy_cert_store":    config.CertStore,
+			"tidy_revoked_certs": config.RevokedCerts,
+			"safety_buffer":      int64(config.SafetyBuffer.Seconds()),
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigAutoTidyWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := getAutoTidyConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if enabledRaw, ok := data.GetOk("enabled"); ok {
+		config.Enabled = enabledRaw.(bool)
+	}
+	if intervalRaw, ok := data.GetOk("interval_duration"); ok {
+		config.Interval = time.Duration(intervalRaw.(int)) * time.Second
+	}
+	if certStoreRaw, ok := data.GetOk("tidy_cert_store"); ok {
+		config.CertStore = certStoreRaw.(bool)
+	}
+	if revokedCertsRaw, ok := data.GetOk("tidy_revoked_certs"); ok {
+		config.RevokedCerts = revokedCertsRaw.(bool)
+	}
+	if safetyBufferRaw, ok := data.GetOk("safety_buffer"); ok {
+		config.SafetyBuffer = time.Duration(safetyBufferRaw.(int)) * time.Second
+	}
+
+	if config.Interval <= 0 {
+		return logical.ErrorResponse("interval_duration must be greater than zero"), nil
+	}
+	if config.SafetyBuffer < time.Second {
+		return logical.ErrorResponse("safety_buffer must be greater than zero"), nil
+	}
+	if config.Enabled && !config.CertStore && !config.RevokedCerts {
+		return logical.ErrorResponse("auto-tidy enabled but no tidy operations were requested; enable tidy_cert_store and/or tidy_revoked_certs"), nil
+	}
+
+	entry, err := logical.StorageEntryJSON(autoTidyConfigPath, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return b.pathConfigAutoTidyRead(ctx, req, data)
+}
+
+// tidyIfScheduled starts an automatic tidy operation once the configured
+// interval has passed since the last one
+func (b *backend) tidyIfScheduled(ctx context.Context, req *logical.Request) error {
+	config, err := getAutoTidyConfig(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+	if !config.Enabled {
+		return nil
+	}
+
+	lastTidy, err := getLastTidy(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+	if lastTidy.IsZero() {
+		// Nothing was tidied yet; schedule the first run from now
+		return setLastTidy(ctx, req.Storage, time.Now())
+	}
+	if time.Now().Before(lastTidy.Add(config.Interval)) {
+		return nil
+	}
+
+	started, err := b.startTidy(ctx, req, config)
+	if err != nil {
+		return err
+	}
+	if started {
+		b.Logger().Info("started automatic tidy operation")
+	}
+	return nil
+}
+
+func getLastTidy(ctx context.Context, s logical.Storage) (time.Time, error) {
+	var lastTidy time.Time
+
+	entry, err := s.Get(ctx, lastTidyPath)
+	if err != nil || entry == nil {
+		return lastTidy, err
+	}
+	if err := entry.DecodeJSON(&lastTidy); err != nil {
+		return lastTidy, err
+	}
+	return lastTidy, nil
+}
+
+func setLastTidy(ctx context.Context, s logical.Storage, lastTidy time.Time) error {
+	entry, err := logical.StorageEntryJSON(lastTidyPath, lastTidy)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+const pathConfigAutoTidyHelpSyn = `
+Configure automatic tidy operations.
+`
+
+const pathConfigAutoTidyHelpDesc = `
+This endpoint configures the backend to periodically run the tidy operation,
+with the same options as the "tidy" endpoint. The interval is measured from
+the start of the last tidy operation, manual or automatic, or from when
+automatic tidy operations were first enabled.
+`
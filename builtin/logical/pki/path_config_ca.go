@@ -2,6 +2,7 @@ package pki
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/sdk/framework"
@@ -63,26 +64,30 @@ func (b *backend) pathCAWrite(ctx context.Context, req *logical.Request, data *f
 		return logical.ErrorResponse("the given certificate is not marked for CA use and cannot be used with this backend"), nil
 	}
 
+	equal, err := certutil.ComparePublicKeys(parsedBundle.Certificate.PublicKey, parsedBundle.PrivateKey.Public())
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("could not compare public and private keys: %s", err)), nil
+	}
+	if !equal {
+		return logical.ErrorResponse("public key of certificate does not match private key"), nil
+	}
+
 	cb, err := parsedBundle.ToCertBundle()
 	if err != nil {
 		return nil, errwrap.Wrapf("error converting raw values into cert bundle: {{err}}", err)
 	}
 
-	entry, err := logical.StorageEntryJSON("config/ca_bundle", cb)
-	if err != nil {
-		return nil, err
+	if _, _, err := importKey(ctx, req.Storage, cb.PrivateKey, ""); err != nil {
+		return nil, errwrap.Wrapf("unable to store CA key: {{err}}", err)
 	}
-	err = req.Storage.Put(ctx, entry)
+	issuer, _, err := importIssuer(ctx, req.Storage, cb.Certificate, cb.CAChain, "")
 	if err != nil {
-		return nil, err
+		return nil, errwrap.Wrapf("unable to store CA certificate: {{err}}", err)
 	}
 
-	// For ease of later use, also store just the certificate at a known
-	// location, plus a fresh CRL
-	entry.Key = "ca"
-	entry.Value = parsedBundle.CertificateBytes
-	err = req.Storage.Put(ctx, entry)
-	if err != nil {
+	// Setting the CA through this path replaces the CA used for issuance,
+	// so the imported issuer becomes the default
+	if err := setDefaultIssuer(ctx, req.Storage, issuer); err != nil {
 		return nil, err
 	}
 
@@ -13,8 +13,30 @@ import (
 
 // CRLConfig holds basic CRL configuration information
 type crlConfig struct {
-	Expiry  string `json:"expiry" mapstructure:"expiry"`
-	Disable bool   `json:"disable"`
+	Expiry                 string `json:"expiry" mapstructure:"expiry"`
+	Disable                bool   `json:"disable"`
+	AutoRebuild            bool   `json:"auto_rebuild"`
+	AutoRebuildGracePeriod string `json:"auto_rebuild_grace_period"`
+	EnableDelta            bool   `json:"enable_delta"`
+	DeltaRebuildInterval   string `json:"delta_rebuild_interval"`
+	SkipRebuildOnRevoke    bool   `json:"skip_rebuild_on_revoke"`
+}
+
+const (
+	defaultCRLAutoRebuildGracePeriod = "12h"
+	defaultCRLDeltaRebuildInterval   = "15m"
+)
+
+// lifetime returns the configured CRL validity, or the given default
+func (c *crlConfig) lifetime(defaultLifetime time.Duration) (time.Duration, error) {
+	if c.Expiry == "" {
+		return defaultLifetime, nil
+	}
+	crlDur, err := time.ParseDuration(c.Expiry)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing CRL duration of %s", c.Expiry)
+	}
+	return crlDur, nil
 }
 
 func pathConfigCRL(b *backend) *framework.Path {
@@ -31,6 +53,37 @@ valid; defaults to 72 hours`,
 				Type:        framework.TypeBool,
 				Description: `If set to true, disables generating the CRL entirely.`,
 			},
+			"auto_rebuild": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set to true, CRLs are rebuilt periodically
+before they expire.`,
+			},
+			"auto_rebuild_grace_period": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `How long before the CRL expires it is
+automatically rebuilt; defaults to 12 hours`,
+				Default: defaultCRLAutoRebuildGracePeriod,
+			},
+			"enable_delta": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set to true, delta CRLs holding the
+certificates revoked since the last full CRL
+are built. Requires auto_rebuild.`,
+			},
+			"delta_rebuild_interval": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `How often delta CRLs are rebuilt when new
+revocations have been recorded; defaults to 15
+minutes`,
+				Default: defaultCRLDeltaRebuildInterval,
+			},
+			"skip_rebuild_on_revoke": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set to true, revoking a certificate does
+not rebuild any CRL; revocations appear on the
+next scheduled delta or full CRL. Requires
+auto_rebuild.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -71,8 +124,13 @@ func (b *backend) pathCRLRead(ctx context.Context, req *logical.Request, data *f
 
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"expiry":  config.Expiry,
-			"disable": config.Disable,
+			"expiry":                    config.Expiry,
+			"disable":                   config.Disable,
+			"auto_rebuild":              config.AutoRebuild,
+			"auto_rebuild_grace_period": config.AutoRebuildGracePeriod,
+			"enable_delta":              config.EnableDelta,
+			"delta_rebuild_interval":    config.DeltaRebuildInterval,
+			"skip_rebuild_on_revoke":    config.SkipRebuildOnRevoke,
 		},
 	}, nil
 }
@@ -85,6 +143,13 @@ func (b *backend) pathCRLWrite(ctx context.Context, req *logical.Request, d *fra
 	if config == nil {
 		config = &crlConfig{}
 	}
+	if config.AutoRebuildGracePeriod == "" {
+		config.AutoRebuildGracePeriod = defaultCRLAutoRebuildGracePeriod
+	}
+	if config.DeltaRebuildInterval == "" {
+		config.DeltaRebuildInterval = defaultCRLDeltaRebuildInterval
+	}
+	oldConfig := *config
 
 	if expiryRaw, ok := d.GetOk("expiry"); ok {
 		expiry := expiryRaw.(string)
@@ -95,12 +160,46 @@ func (b *backend) pathCRLWrite(ctx context.Context, req *logical.Request, d *fra
 		config.Expiry = expiry
 	}
 
-	var oldDisable bool
 	if disableRaw, ok := d.GetOk("disable"); ok {
-		oldDisable = config.Disable
 		config.Disable = disableRaw.(bool)
 	}
 
+	if autoRebuildRaw, ok := d.GetOk("auto_rebuild"); ok {
+		config.AutoRebuild = autoRebuildRaw.(bool)
+	}
+	if gracePeriodRaw, ok := d.GetOk("auto_rebuild_grace_period"); ok {
+		config.AutoRebuildGracePeriod = gracePeriodRaw.(string)
+	}
+	if enableDeltaRaw, ok := d.GetOk("enable_delta"); ok {
+		config.EnableDelta = enableDeltaRaw.(bool)
+	}
+	if deltaIntervalRaw, ok := d.GetOk("delta_rebuild_interval"); ok {
+		config.DeltaRebuildInterval = deltaIntervalRaw.(string)
+	}
+	if skipRaw, ok := d.GetOk("skip_rebuild_on_revoke"); ok {
+		config.SkipRebuildOnRevoke = skipRaw.(bool)
+	}
+
+	gracePeriod, err := time.ParseDuration(config.AutoRebuildGracePeriod)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("given auto_rebuild_grace_period could not be decoded: %s", err)), nil
+	}
+	if _, err := time.ParseDuration(config.DeltaRebuildInterval); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("given delta_rebuild_interval could not be decoded: %s", err)), nil
+	}
+	if config.AutoRebuild {
+		crlLifetime, err := config.lifetime(b.crlLifetime)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		if gracePeriod >= crlLifetime {
+			return logical.ErrorResponse("auto_rebuild_grace_period must be shorter than the CRL expiry"), nil
+		}
+	}
+	if !config.AutoRebuild && (config.EnableDelta || config.SkipRebuildOnRevoke) {
+		return logical.ErrorResponse("enable_delta and skip_rebuild_on_revoke require auto_rebuild"), nil
+	}
+
 	entry, err := logical.StorageEntryJSON("config/crl", config)
 	if err != nil {
 		return nil, err
@@ -110,8 +209,13 @@ func (b *backend) pathCRLWrite(ctx context.Context, req *logical.Request, d *fra
 		return nil, err
 	}
 
-	if oldDisable != config.Disable {
-		// It wasn't disabled but now it is, rotate
+	// Rebuild when the CRL is disabled or re-enabled, and when revocations
+	// may have stopped being recorded for the delta CRLs, so that none are
+	// left off the full CRL
+	if oldConfig.Disable != config.Disable || oldConfig.AutoRebuild != config.AutoRebuild || oldConfig.EnableDelta != config.EnableDelta || oldConfig.SkipRebuildOnRevoke != config.SkipRebuildOnRevoke {
+		b.revokeStorageLock.Lock()
+		defer b.revokeStorageLock.Unlock()
+
 		crlErr := buildCRL(ctx, b, req, true)
 		switch crlErr.(type) {
 		case errutil.UserError:
@@ -125,9 +229,16 @@ func (b *backend) pathCRLWrite(ctx context.Context, req *logical.Request, d *fra
 }
 
 const pathConfigCRLHelpSyn = `
-Configure the CRL expiration.
+Configure the CRL expiration and rebuilding.
 `
 
 const pathConfigCRLHelpDesc = `
-This endpoint allows configuration of the CRL lifetime.
+This endpoint allows configuration of the CRL lifetime, and of how CRLs are
+rebuilt.
+
+By default the full CRL is rebuilt on every revocation. With "auto_rebuild"
+set, CRLs are also rebuilt periodically before they expire. Building the full
+CRL becomes slow with many revoked certificates; "enable_delta" instead
+rebuilds only a delta CRL of the certificates revoked since the last full
+CRL, and "skip_rebuild_on_revoke" leaves all rebuilding to the schedule.
 `
@@ -3,11 +3,13 @@ package pki
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/fatih/structs"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -32,6 +34,22 @@ for the CRL distribution points attribute`,
 				Description: `Comma-separated list of URLs to be used
 for the OCSP servers attribute`,
 			},
+
+			"base_url": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `URL at which clients can reach this mount,
+for example "https://vault.example.com:8200/v1/pki".
+Used to build the URL of the built-in OCSP
+responder.`,
+			},
+
+			"include_ocsp_responder": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, the URL of this mount's OCSP
+responder, derived from base_url, is added to
+the OCSP servers attribute of issued
+certificates.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -54,7 +72,37 @@ func validateURLs(urls []string) string {
 	return ""
 }
 
+// urlConfigEntry is the stored URL configuration. Beyond the URLs encoded
+// verbatim into certificates, it holds settings from which further URLs are
+// derived.
+type urlConfigEntry struct {
+	certutil.URLEntries  `structs:",flatten" mapstructure:",squash"`
+	BaseURL              string `json:"base_url" structs:"base_url" mapstructure:"base_url"`
+	IncludeOCSPResponder bool   `json:"include_ocsp_responder" structs:"include_ocsp_responder" mapstructure:"include_ocsp_responder"`
+}
+
+// getURLs returns the URLs to encode into issued certificates
 func getURLs(ctx context.Context, req *logical.Request) (*certutil.URLEntries, error) {
+	config, err := getURLConfig(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	entries := config.URLEntries
+	if config.IncludeOCSPResponder && config.BaseURL != "" {
+		responderURL := strings.TrimSuffix(config.BaseURL, "/") + "/ocsp"
+		if !strutil.StrListContains(entries.OCSPServers, responderURL) {
+			entries.OCSPServers = append(append([]string{}, entries.OCSPServers...), responderURL)
+		}
+	}
+
+	return &entries, nil
+}
+
+func getURLConfig(ctx context.Context, req *logical.Request) (*urlConfigEntry, error) {
 	entry, err := req.Storage.Get(ctx, "urls")
 	if err != nil {
 		return nil, err
@@ -63,15 +111,15 @@ func getURLs(ctx context.Context, req *logical.Request) (*certutil.URLEntries, e
 		return nil, nil
 	}
 
-	var entries certutil.URLEntries
-	if err := entry.DecodeJSON(&entries); err != nil {
+	var config urlConfigEntry
+	if err := entry.DecodeJSON(&config); err != nil {
 		return nil, err
 	}
 
-	return &entries, nil
+	return &config, nil
 }
 
-func writeURLs(ctx context.Context, req *logical.Request, entries *certutil.URLEntries) error {
+func writeURLs(ctx context.Context, req *logical.Request, entries *urlConfigEntry) error {
 	entry, err := logical.StorageEntryJSON("urls", entries)
 	if err != nil {
 		return err
@@ -89,7 +137,7 @@ func writeURLs(ctx context.Context, req *logical.Request, entries *certutil.URLE
 }
 
 func (b *backend) pathReadURL(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	entries, err := getURLs(ctx, req)
+	entries, err := getURLConfig(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -105,15 +153,17 @@ func (b *backend) pathReadURL(ctx context.Context, req *logical.Request, data *f
 }
 
 func (b *backend) pathWriteURL(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	entries, err := getURLs(ctx, req)
+	entries, err := getURLConfig(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 	if entries == nil {
-		entries = &certutil.URLEntries{
-			IssuingCertificates:   []string{},
-			CRLDistributionPoints: []string{},
-			OCSPServers:           []string{},
+		entries = &urlConfigEntry{
+			URLEntries: certutil.URLEntries{
+				IssuingCertificates:   []string{},
+				CRLDistributionPoints: []string{},
+				OCSPServers:           []string{},
+			},
 		}
 	}
 
@@ -138,6 +188,21 @@ func (b *backend) pathWriteURL(ctx context.Context, req *logical.Request, data *
 				"invalid URL found in OCSP servers: %s", badURL)), nil
 		}
 	}
+	if baseURLRaw, ok := data.GetOk("base_url"); ok {
+		entries.BaseURL = baseURLRaw.(string)
+		if entries.BaseURL != "" {
+			if badURL := validateURLs([]string{entries.BaseURL}); badURL != "" {
+				return logical.ErrorResponse(fmt.Sprintf(
+					"invalid base URL: %s", badURL)), nil
+			}
+		}
+	}
+	if includeRaw, ok := data.GetOk("include_ocsp_responder"); ok {
+		entries.IncludeOCSPResponder = includeRaw.(bool)
+	}
+	if entries.IncludeOCSPResponder && entries.BaseURL == "" {
+		return logical.ErrorResponse("base_url must be set to include the OCSP responder"), nil
+	}
 
 	return nil, writeURLs(ctx, req, entries)
 }
@@ -154,4 +219,8 @@ certificates. To delete URLs, simply re-set the appropriate value with an
 empty string.
 
 Multiple URLs can be specified for each type; use commas to separate them.
+
+If "include_ocsp_responder" is set, the URL of this mount's built-in OCSP
+responder is added to the OCSP servers. This requires "base_url", the URL at
+which clients reach this mount.
 `
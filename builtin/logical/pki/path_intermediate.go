@@ -26,7 +26,32 @@ func pathGenerateIntermediate(b *backend) *framework.Path {
 
 	ret.Fields = addCACommonFields(map[string]*framework.FieldSchema{})
 	ret.Fields = addCAKeyGenerationFields(ret.Fields)
-	ret.Fields["add_basic_constraints"] = &framework.FieldSchema{
+	ret.Fields = addIntermediateGenerationFields(ret.Fields)
+
+	return ret
+}
+
+func pathIssuerGenerateIntermediate(b *backend) *framework.Path {
+	ret := &framework.Path{
+		Pattern: "issuers/generate/intermediate/" + framework.GenericNameRegex("exported"),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathGenerateIntermediate,
+		},
+
+		HelpSynopsis:    pathGenerateIntermediateHelpSyn,
+		HelpDescription: pathGenerateIntermediateHelpDesc,
+	}
+
+	ret.Fields = addCACommonFields(map[string]*framework.FieldSchema{})
+	ret.Fields = addCAKeyGenerationFields(ret.Fields)
+	ret.Fields = addIntermediateGenerationFields(ret.Fields)
+
+	return ret
+}
+
+func addIntermediateGenerationFields(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["add_basic_constraints"] = &framework.FieldSchema{
 		Type: framework.TypeBool,
 		Description: `Whether to add a Basic Constraints
 extension with CA: true. Only needed as a
@@ -34,7 +59,14 @@ workaround in some compatibility scenarios
 with Active Directory Certificate Services.`,
 	}
 
-	return ret
+	fields["key_name"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `Optional name to give the new key; it can be
+used in place of the key ID when referencing
+it.`,
+	}
+
+	return fields
 }
 
 func pathSetSignedIntermediate(b *backend) *framework.Path {
@@ -49,6 +81,12 @@ certificate with a public key matching the
 previously-generated key from the generation
 endpoint.`,
 			},
+			"issuer_name": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Optional name to give the new issuer; it can be
+used in place of the issuer ID when referencing
+it.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -65,6 +103,11 @@ endpoint.`,
 func (b *backend) pathGenerateIntermediate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	var err error
 
+	keyName := data.Get("key_name").(string)
+	if err := validateKeyName(ctx, req.Storage, keyName); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("invalid key_name: %s", err)), nil
+	}
+
 	exported, format, role, errorResp := b.getGenerationParams(data)
 	if errorResp != nil {
 		return errorResp, nil
@@ -126,18 +169,14 @@ func (b *backend) pathGenerateIntermediate(ctx context.Context, req *logical.Req
 		}
 	}
 
-	cb := &certutil.CertBundle{}
-	cb.PrivateKey = csrb.PrivateKey
-	cb.PrivateKeyType = csrb.PrivateKeyType
-
-	entry, err := logical.StorageEntryJSON("config/ca_bundle", cb)
-	if err != nil {
-		return nil, err
-	}
-	err = req.Storage.Put(ctx, entry)
+	// The key is stored on its own; existing issuers keep working until
+	// the signed certificate is provided via set-signed.
+	key, _, err := importKey(ctx, req.Storage, csrb.PrivateKey, keyName)
 	if err != nil {
-		return nil, err
+		return nil, errwrap.Wrapf("unable to store generated key: {{err}}", err)
 	}
+	resp.Data["key_id"] = key.ID
+	resp.Data["key_name"] = key.Name
 
 	return resp, nil
 }
@@ -163,70 +202,67 @@ func (b *backend) pathSetSignedIntermediate(ctx context.Context, req *logical.Re
 		return logical.ErrorResponse("supplied certificate could not be successfully parsed"), nil
 	}
 
-	cb := &certutil.CertBundle{}
-	entry, err := req.Storage.Get(ctx, "config/ca_bundle")
-	if err != nil {
-		return nil, err
-	}
-	if entry == nil {
-		return logical.ErrorResponse("could not find any existing entry with a private key"), nil
+	issuerName := data.Get("issuer_name").(string)
+	if err := validateIssuerName(ctx, req.Storage, issuerName); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("invalid issuer_name: %s", err)), nil
 	}
 
-	err = entry.DecodeJSON(cb)
-	if err != nil {
-		return nil, err
-	}
-
-	if len(cb.PrivateKey) == 0 || cb.PrivateKeyType == "" {
-		return logical.ErrorResponse("could not find an existing private key"), nil
+	if !inputBundle.Certificate.IsCA {
+		return logical.ErrorResponse("the given certificate is not marked for CA use and cannot be used with this backend"), nil
 	}
 
-	parsedCB, err := cb.ToParsedCertBundle()
+	keyIDs, err := listKeys(ctx, req.Storage)
 	if err != nil {
 		return nil, err
 	}
-	if parsedCB.PrivateKey == nil {
-		return nil, fmt.Errorf("saved key could not be parsed successfully")
+	var key *keyEntry
+	for _, keyID := range keyIDs {
+		candidate, err := fetchKeyByID(ctx, req.Storage, keyID)
+		if err != nil {
+			return nil, err
+		}
+		parsedKey, err := candidate.GetSigner()
+		if err != nil {
+			return nil, err
+		}
+		equal, err := certutil.ComparePublicKeys(inputBundle.Certificate.PublicKey, parsedKey.PrivateKey.Public())
+		if err != nil {
+			return nil, err
+		}
+		if equal {
+			key = candidate
+			inputBundle.SetParsedPrivateKey(parsedKey.PrivateKey, parsedKey.PrivateKeyType, parsedKey.PrivateKeyBytes)
+			break
+		}
 	}
-
-	inputBundle.PrivateKey = parsedCB.PrivateKey
-	inputBundle.PrivateKeyType = parsedCB.PrivateKeyType
-	inputBundle.PrivateKeyBytes = parsedCB.PrivateKeyBytes
-
-	if !inputBundle.Certificate.IsCA {
-		return logical.ErrorResponse("the given certificate is not marked for CA use and cannot be used with this backend"), nil
+	if key == nil {
+		return logical.ErrorResponse("could not find an existing private key matching the certificate"), nil
 	}
 
 	if err := inputBundle.Verify(); err != nil {
 		return nil, errwrap.Wrapf("verification of parsed bundle failed: {{err}}", err)
 	}
 
-	cb, err = inputBundle.ToCertBundle()
+	cb, err := inputBundle.ToCertBundle()
 	if err != nil {
 		return nil, errwrap.Wrapf("error converting raw values into cert bundle: {{err}}", err)
 	}
 
-	entry, err = logical.StorageEntryJSON("config/ca_bundle", cb)
+	issuer, _, err := importIssuer(ctx, req.Storage, cb.Certificate, cb.CAChain, issuerName)
 	if err != nil {
-		return nil, err
-	}
-	err = req.Storage.Put(ctx, entry)
-	if err != nil {
-		return nil, err
+		return nil, errwrap.Wrapf("unable to store issuer: {{err}}", err)
 	}
 
-	entry.Key = "certs/" + normalizeSerial(cb.SerialNumber)
-	entry.Value = inputBundle.CertificateBytes
-	err = req.Storage.Put(ctx, entry)
-	if err != nil {
+	// As with the single-CA behavior, the newly set intermediate becomes
+	// the one used for issuance
+	if err := setDefaultIssuer(ctx, req.Storage, issuer); err != nil {
 		return nil, err
 	}
 
-	// For ease of later use, also store just the certificate at a known
-	// location
-	entry.Key = "ca"
-	entry.Value = inputBundle.CertificateBytes
-	err = req.Storage.Put(ctx, entry)
+	err = req.Storage.Put(ctx, &logical.StorageEntry{
+		Key:   "certs/" + normalizeSerial(cb.SerialNumber),
+		Value: inputBundle.CertificateBytes,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -101,6 +101,8 @@ this value to an empty list.`,
 		Description: `A comma-separated string or list of extended key usage oids.`,
 	}
 
+	ret.Fields = addIssuerRefField(ret.Fields)
+
 	return ret
 }
 
@@ -168,6 +170,7 @@ func (b *backend) pathSignVerbatim(ctx context.Context, req *logical.Request, da
 		KeyUsage:             data.Get("key_usage").([]string),
 		ExtKeyUsage:          data.Get("ext_key_usage").([]string),
 		ExtKeyUsageOIDs:      data.Get("ext_key_usage_oids").([]string),
+		Issuer:               defaultRef,
 	}
 
 	*entry.GenerateLease = false
@@ -183,6 +186,12 @@ func (b *backend) pathSignVerbatim(ctx context.Context, req *logical.Request, da
 			*entry.GenerateLease = *role.GenerateLease
 		}
 		entry.NoStore = role.NoStore
+		entry.Issuer = role.Issuer
+	}
+
+	// An explicitly requested issuer takes precedence over the role's
+	if issuerRef, ok := data.GetOk("issuer_ref"); ok {
+		entry.Issuer = issuerRef.(string)
 	}
 
 	return b.pathIssueSignCert(ctx, req, data, entry, true, true)
@@ -201,7 +210,7 @@ func (b *backend) pathIssueSignCert(ctx context.Context, req *logical.Request, d
 	}
 
 	var caErr error
-	signingBundle, caErr := fetchCAInfo(ctx, req)
+	signingBundle, caErr := fetchCAInfo(ctx, req, role.Issuer)
 	switch caErr.(type) {
 	case errutil.UserError:
 		return nil, errutil.UserError{Err: fmt.Sprintf(
@@ -324,6 +333,9 @@ func (b *backend) pathIssueSignCert(ctx context.Context, req *logical.Request, d
 		if err != nil {
 			return nil, errwrap.Wrapf("unable to store certificate locally: {{err}}", err)
 		}
+		if err := recordRoleCert(ctx, req.Storage, data.Get("role").(string), cb.SerialNumber); err != nil {
+			return nil, errwrap.Wrapf("unable to index certificate by role: {{err}}", err)
+		}
 	}
 
 	if useCSR {
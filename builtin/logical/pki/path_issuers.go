@@ -0,0 +1,795 @@
+package pki
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func pathListIssuers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuers/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathListIssuersHandler,
+		},
+
+		HelpSynopsis:    pathListIssuersHelpSyn,
+		HelpDescription: pathListIssuersHelpDesc,
+	}
+}
+
+func pathIssuer(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuer/" + framework.GenericNameRegex("issuer_ref"),
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_ref": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Reference to an existing issuer, either by its
+ID or name, or "default" for the mount's
+default issuer.`,
+			},
+			"issuer_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `New name for the issuer.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathIssuerRead,
+			logical.UpdateOperation: b.pathIssuerWrite,
+			logical.DeleteOperation: b.pathIssuerDelete,
+		},
+
+		HelpSynopsis:    pathIssuerHelpSyn,
+		HelpDescription: pathIssuerHelpDesc,
+	}
+}
+
+func pathCrossSignIssuer(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuer/" + framework.GenericNameRegex("issuer_ref") + "/cross-sign",
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_ref": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Reference to the issuer to cross-sign, either
+by its ID or name, or "default" for the
+mount's default issuer.`,
+			},
+			"signing_issuer": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Reference to the issuer which signs the new
+certificate, either by its ID or name, or
+"default" for the mount's default issuer.`,
+			},
+			"ttl": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `The requested Time To Live for the
+cross-signed certificate. Defaults to the
+remaining lifetime of the issuer being
+cross-signed; either way it is capped to that
+of the signing issuer.`,
+			},
+			"issuer_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Name for the new issuer.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathCrossSignIssuerWrite,
+		},
+
+		HelpSynopsis:    pathCrossSignIssuerHelpSyn,
+		HelpDescription: pathCrossSignIssuerHelpDesc,
+	}
+}
+
+func pathImportIssuer(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuers/import/bundle",
+		Fields: map[string]*framework.FieldSchema{
+			"pem_bundle": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `PEM-format, concatenated unencrypted secret
+keys and CA certificates. Keys are optional;
+certificates without a matching key can be
+used to build chains but not to sign.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathImportIssuers,
+		},
+
+		HelpSynopsis:    pathImportIssuerHelpSyn,
+		HelpDescription: pathImportIssuerHelpDesc,
+	}
+}
+
+func pathConfigIssuers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/issuers",
+		Fields: map[string]*framework.FieldSchema{
+			"default": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Reference (name or ID) of the issuer to use by default.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigIssuersRead,
+			logical.UpdateOperation: b.pathConfigIssuersWrite,
+		},
+
+		HelpSynopsis:    pathConfigIssuersHelpSyn,
+		HelpDescription: pathConfigIssuersHelpDesc,
+	}
+}
+
+// Returns an issuer's certificate in raw format
+func pathFetchIssuerCA(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "ca/issuer/" + framework.GenericNameRegex("issuer_ref") + "(/pem)?",
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Reference to an existing issuer, by ID or name.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchIssuerRead,
+		},
+
+		HelpSynopsis:    pathFetchIssuerHelpSyn,
+		HelpDescription: pathFetchIssuerHelpDesc,
+	}
+}
+
+// Returns an issuer's CRL in raw format
+func pathFetchIssuerCRL(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "crl/issuer/" + framework.GenericNameRegex("issuer_ref") + "(/delta)?(/pem)?",
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Reference to an existing issuer, by ID or name.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchIssuerRead,
+		},
+
+		HelpSynopsis:    pathFetchIssuerHelpSyn,
+		HelpDescription: pathFetchIssuerHelpDesc,
+	}
+}
+
+// Returns an issuer's certificate and chain, or its CRL, in a non-raw
+// format
+func pathFetchIssuerViaCertPath(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "cert/issuer/" + framework.GenericNameRegex("issuer_ref") + "(/crl|/delta-crl)?",
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Reference to an existing issuer, by ID or name.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchIssuerRead,
+		},
+
+		HelpSynopsis:    pathFetchIssuerHelpSyn,
+		HelpDescription: pathFetchIssuerHelpDesc,
+	}
+}
+
+func (b *backend) pathListIssuersHandler(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ids, err := listIssuers(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := map[string]interface{}{}
+	for _, id := range ids {
+		issuer, err := fetchIssuerByID(ctx, req.Storage, id)
+		if err != nil {
+			return nil, err
+		}
+		keyInfo[id] = map[string]interface{}{
+			"issuer_name": issuer.Name,
+			"is_default":  id == config.DefaultIssuerID,
+		}
+	}
+
+	return logical.ListResponseWithInfo(ids, keyInfo), nil
+}
+
+func (b *backend) pathIssuerRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	issuerID, err := resolveIssuerReference(ctx, req.Storage, data.Get("issuer_ref").(string))
+	if err != nil {
+		if _, ok := err.(errutil.UserError); ok {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		return nil, err
+	}
+
+	issuer, err := fetchIssuerByID(ctx, req.Storage, issuerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: issuer.ToResponseData(),
+	}, nil
+}
+
+func (b *backend) pathIssuerWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	issuerID, err := resolveIssuerReference(ctx, req.Storage, data.Get("issuer_ref").(string))
+	if err != nil {
+		if _, ok := err.(errutil.UserError); ok {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		return nil, err
+	}
+
+	issuer, err := fetchIssuerByID(ctx, req.Storage, issuerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if nameRaw, ok := data.GetOk("issuer_name"); ok {
+		name := nameRaw.(string)
+		if name != issuer.Name {
+			if err := validateIssuerName(ctx, req.Storage, name); err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("invalid issuer_name: %s", err)), nil
+			}
+			issuer.Name = name
+		}
+	}
+
+	if err := writeIssuer(ctx, req.Storage, issuer); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: issuer.ToResponseData(),
+	}, nil
+}
+
+func (b *backend) pathIssuerDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	issuerID, err := resolveIssuerReference(ctx, req.Storage, data.Get("issuer_ref").(string))
+	if err != nil {
+		if _, ok := err.(errutil.UserError); ok {
+			// Deletes are idempotent
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	wasDefault, err := deleteIssuer(ctx, req.Storage, issuerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !wasDefault {
+		return nil, nil
+	}
+
+	// The legacy locations mirror the default issuer, which no longer exists
+	for _, path := range []string{"ca", "crl"} {
+		if err := req.Storage.Delete(ctx, path); err != nil {
+			return nil, err
+		}
+	}
+
+	resp := &logical.Response{}
+	resp.AddWarning(fmt.Sprintf("The deleted issuer was the default issuer; set a new default via %sconfig/issuers before issuing further certificates.", req.MountPoint))
+	return resp, nil
+}
+
+// crossSignCopiedExtensions are the extensions of an issuer copied as they are
+// to its cross-signed certificate: name constraints, certificate policies,
+// policy mappings, policy constraints and inhibit any policy.
+var crossSignCopiedExtensions = []asn1.ObjectIdentifier{
+	{2, 5, 29, 30},
+	{2, 5, 29, 32},
+	{2, 5, 29, 33},
+	{2, 5, 29, 36},
+	{2, 5, 29, 54},
+}
+
+func (b *backend) pathCrossSignIssuerWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	signingRef := data.Get("signing_issuer").(string)
+	if signingRef == "" {
+		return logical.ErrorResponse("'signing_issuer' must be set"), nil
+	}
+
+	issuerID, err := resolveIssuerReference(ctx, req.Storage, data.Get("issuer_ref").(string))
+	if err != nil {
+		if _, ok := err.(errutil.UserError); ok {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		return nil, err
+	}
+	signingID, err := resolveIssuerReference(ctx, req.Storage, signingRef)
+	if err != nil {
+		if _, ok := err.(errutil.UserError); ok {
+			return logical.ErrorResponse(fmt.Sprintf("invalid signing_issuer: %s", err)), nil
+		}
+		return nil, err
+	}
+	if signingID == issuerID {
+		return logical.ErrorResponse("an issuer cannot cross-sign itself"), nil
+	}
+
+	name := data.Get("issuer_name").(string)
+	if name != "" {
+		if err := validateIssuerName(ctx, req.Storage, name); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid issuer_name: %s", err)), nil
+		}
+	}
+
+	issuer, err := fetchIssuerByID(ctx, req.Storage, issuerID)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := issuer.GetCertificate()
+	if err != nil {
+		return nil, err
+	}
+
+	signingBundle, err := fetchCAInfoByIssuerID(ctx, req, signingID)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(fmt.Sprintf("invalid signing_issuer: %s", err)), nil
+		default:
+			return nil, err
+		}
+	}
+	signingCert := signingBundle.Certificate
+
+	sameKey, err := samePublicKey(cert.PublicKey, signingCert.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if sameKey {
+		return logical.ErrorResponse("the signing issuer shares the key of the issuer being cross-signed"), nil
+	}
+
+	notAfter := cert.NotAfter
+	if ttl := data.Get("ttl").(int); ttl > 0 {
+		notAfter = time.Now().Add(time.Duration(ttl) * time.Second)
+	}
+	if notAfter.After(signingCert.NotAfter) {
+		notAfter = signingCert.NotAfter
+	}
+	if !notAfter.After(time.Now()) {
+		return logical.ErrorResponse("the issuer being cross-signed has expired"), nil
+	}
+
+	serialNumber, err := certutil.GenerateSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	// The cross-signed certificate keeps the subject, key and constraints
+	// of the original so that either can be used to build a chain. Name
+	// constraints and policies are copied as they were encoded, including
+	// the ones Go doesn't parse, so that it is as constrained as the original.
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		RawSubject:            cert.RawSubject,
+		SubjectKeyId:          cert.SubjectKeyId,
+		NotBefore:             time.Now().Add(-30 * time.Second),
+		NotAfter:              notAfter,
+		KeyUsage:              cert.KeyUsage,
+		ExtKeyUsage:           cert.ExtKeyUsage,
+		UnknownExtKeyUsage:    cert.UnknownExtKeyUsage,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLen:            cert.MaxPathLen,
+		MaxPathLenZero:        cert.MaxPathLenZero,
+	}
+	for _, ext := range cert.Extensions {
+		for _, oid := range crossSignCopiedExtensions {
+			if ext.Id.Equal(oid) {
+				template.ExtraExtensions = append(template.ExtraExtensions, ext)
+			}
+		}
+	}
+	if signingBundle.URLs != nil {
+		template.IssuingCertificateURL = signingBundle.URLs.IssuingCertificates
+		template.CRLDistributionPoints = signingBundle.URLs.CRLDistributionPoints
+		template.OCSPServer = signingBundle.URLs.OCSPServers
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, signingCert, cert.PublicKey, signingBundle.PrivateKey)
+	if err != nil {
+		return nil, errwrap.Wrapf("error cross-signing issuer: {{err}}", err)
+	}
+	certPEM := strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: certBytes,
+	})))
+
+	signingIssuer, err := fetchIssuerByID(ctx, req.Storage, signingID)
+	if err != nil {
+		return nil, err
+	}
+	caChain := append([]string{signingIssuer.Certificate}, signingIssuer.CAChain...)
+
+	crossSigned, _, err := importIssuer(ctx, req.Storage, certPEM, caChain, name)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, errwrap.Wrapf("error storing cross-signed issuer: {{err}}", err)
+		}
+	}
+
+	err = req.Storage.Put(ctx, &logical.StorageEntry{
+		Key:   "certs/" + normalizeSerial(crossSigned.SerialNumber),
+		Value: certBytes,
+	})
+	if err != nil {
+		return nil, errwrap.Wrapf("unable to store certificate locally: {{err}}", err)
+	}
+
+	if err := buildCRL(ctx, b, req, true); err != nil {
+		return nil, err
+	}
+
+	// The original issuer's chain now includes the cross-signed paths
+	issuer, err = fetchIssuerByID(ctx, req.Storage, issuerID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &logical.Response{
+		Data: crossSigned.ToResponseData(),
+	}
+	resp.Data["issuer_ca_chain"] = issuer.CAChain
+	return resp, nil
+}
+
+func (b *backend) pathImportIssuers(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	pemBundle := data.Get("pem_bundle").(string)
+	if pemBundle == "" {
+		return logical.ErrorResponse("'pem_bundle' was empty"), nil
+	}
+
+	var keys, certs []string
+	rest := []byte(pemBundle)
+	for len(rest) > 0 {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		encoded := strings.TrimSpace(string(pem.EncodeToMemory(block)))
+		switch {
+		case block.Type == "CERTIFICATE":
+			certs = append(certs, encoded)
+		case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			keys = append(keys, encoded)
+		default:
+			return logical.ErrorResponse(fmt.Sprintf("unsupported PEM block type %q in bundle", block.Type)), nil
+		}
+	}
+
+	if len(certs) == 0 && len(keys) == 0 {
+		return logical.ErrorResponse("no certificates or keys found in the PEM bundle"), nil
+	}
+
+	// Keys go first so that certificates can be linked to them
+	importedKeys := []string{}
+	for _, keyPEM := range keys {
+		key, existing, err := importKey(ctx, req.Storage, keyPEM, "")
+		if err != nil {
+			switch err.(type) {
+			case errutil.UserError:
+				return logical.ErrorResponse(err.Error()), nil
+			default:
+				return nil, errwrap.Wrapf("error importing key: {{err}}", err)
+			}
+		}
+		if !existing {
+			importedKeys = append(importedKeys, key.ID)
+		}
+	}
+
+	// As with the legacy PEM bundle format, any certificates following a
+	// certificate are treated as its chain
+	importedIssuers := []string{}
+	for i, certPEM := range certs {
+		issuer, existing, err := importIssuer(ctx, req.Storage, certPEM, certs[i+1:], "")
+		if err != nil {
+			switch err.(type) {
+			case errutil.UserError:
+				return logical.ErrorResponse(err.Error()), nil
+			default:
+				return nil, errwrap.Wrapf("error importing certificate: {{err}}", err)
+			}
+		}
+		if !existing {
+			importedIssuers = append(importedIssuers, issuer.ID)
+		}
+	}
+
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if len(importedIssuers) > 0 && config.DefaultIssuerID != "" {
+		if err := buildCRL(ctx, b, req, true); err != nil {
+			return nil, err
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"imported_issuers": importedIssuers,
+			"imported_keys":    importedKeys,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigIssuersRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"default": config.DefaultIssuerID,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigIssuersWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ref := data.Get("default").(string)
+	if ref == "" || ref == defaultRef {
+		return logical.ErrorResponse("a specific issuer name or ID must be provided as the default"), nil
+	}
+
+	issuerID, err := resolveIssuerReference(ctx, req.Storage, ref)
+	if err != nil {
+		if _, ok := err.(errutil.UserError); ok {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		return nil, err
+	}
+
+	issuer, err := fetchIssuerByID(ctx, req.Storage, issuerID)
+	if err != nil {
+		return nil, err
+	}
+	if issuer.KeyID == "" {
+		return logical.ErrorResponse("the default issuer must have an associated key"), nil
+	}
+
+	if err := setDefaultIssuer(ctx, req.Storage, issuer); err != nil {
+		return nil, err
+	}
+
+	// Refresh the legacy CRL location to match the new default
+	if err := buildCRL(ctx, b, req, true); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"default": issuer.ID,
+		},
+	}, nil
+}
+
+func (b *backend) pathFetchIssuerRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	issuerRef := data.Get("issuer_ref").(string)
+	issuerID, err := resolveIssuerReference(ctx, req.Storage, issuerRef)
+	if err != nil {
+		if _, ok := err.(errutil.UserError); ok {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		return nil, err
+	}
+
+	issuer, err := fetchIssuerByID(ctx, req.Storage, issuerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var contentType, pemType string
+	var contents []byte
+	// What follows the reference selects the format, and must be split off
+	// explicitly as issuers may be named like one of the suffixes
+	suffix := req.Path[strings.Index(req.Path, "issuer/")+len("issuer/")+len(issuerRef):]
+	isDelta := strings.HasPrefix(suffix, "/delta")
+	isCRL := isDelta || strings.HasPrefix(req.Path, "crl/") || suffix == "/crl"
+	if isCRL {
+		crlPath := issuerCRLPrefix + issuerID
+		if isDelta {
+			crlPath = deltaCRLPrefix + issuerID
+		}
+		crlEntry, err := req.Storage.Get(ctx, crlPath)
+		if err != nil {
+			return nil, err
+		}
+		if crlEntry != nil {
+			contents = crlEntry.Value
+		}
+		pemType = "X509 CRL"
+		contentType = "application/pkix-crl"
+	} else {
+		cert, err := issuer.GetCertificate()
+		if err != nil {
+			return nil, err
+		}
+		contents = cert.Raw
+		pemType = "CERTIFICATE"
+		contentType = "application/pkix-cert"
+	}
+
+	if !strings.HasPrefix(req.Path, "cert/") {
+		if !strings.HasSuffix(suffix, "/pem") {
+			pemType = ""
+		}
+		if len(pemType) != 0 && len(contents) > 0 {
+			contents = []byte(strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{
+				Type:  pemType,
+				Bytes: contents,
+			}))))
+		}
+
+		resp := &logical.Response{
+			Data: map[string]interface{}{
+				logical.HTTPContentType: contentType,
+				logical.HTTPRawBody:     contents,
+				logical.HTTPStatusCode:  200,
+			},
+		}
+		if len(contents) == 0 {
+			resp.Data[logical.HTTPStatusCode] = 204
+		}
+		return resp, nil
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"issuer_id":   issuer.ID,
+			"issuer_name": issuer.Name,
+		},
+	}
+	if isCRL {
+		var crl string
+		if len(contents) > 0 {
+			crl = strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{
+				Type:  pemType,
+				Bytes: contents,
+			})))
+		}
+		resp.Data["certificate"] = crl
+	} else {
+		resp.Data["certificate"] = issuer.Certificate
+		resp.Data["ca_chain"] = issuer.CAChain
+	}
+
+	return resp, nil
+}
+
+// ToResponseData returns the issuer fields suitable for returning to a
+// client; the private key is never included.
+func (i *issuerEntry) ToResponseData() map[string]interface{} {
+	return map[string]interface{}{
+		"issuer_id":     i.ID,
+		"issuer_name":   i.Name,
+		"key_id":        i.KeyID,
+		"certificate":   i.Certificate,
+		"ca_chain":      i.CAChain,
+		"serial_number": i.SerialNumber,
+	}
+}
+
+const pathListIssuersHelpSyn = `
+List the issuers stored in this mount.
+`
+
+const pathListIssuersHelpDesc = `
+This endpoint lists the IDs of all issuers, along with their names and
+whether they are the mount's default issuer.
+`
+
+const pathIssuerHelpSyn = `
+Read, rename, or delete an issuer.
+`
+
+const pathIssuerHelpDesc = `
+This endpoint manages a single issuer, referenced by its ID, its name, or
+"default" for the mount's default issuer.
+
+Deleting an issuer does not delete its key, and certificates it issued
+remain in storage; they will no longer appear on any CRL unless another
+issuer shares the same key.
+`
+
+const pathCrossSignIssuerHelpSyn = `
+Cross-sign an issuer by another issuer of this mount.
+`
+
+const pathCrossSignIssuerHelpDesc = `
+This endpoint signs the certificate of an existing issuer with the key of
+another issuer in the mount, typically a second root, and stores the result
+as a new issuer sharing the original's key. Certificates issued by the
+original issuer then chain to either root.
+
+The CA chains of all issuers, and the "ca_chain" endpoint, are rebuilt to
+include every valid path, including the cross-signed ones.
+`
+
+const pathImportIssuerHelpSyn = `
+Import CA certificates and keys into the mount as new issuers.
+`
+
+const pathImportIssuerHelpDesc = `
+This endpoint imports a PEM bundle containing any number of CA certificates
+and unencrypted private keys. Certificates and keys already present in the
+mount are skipped. Imported issuers are linked to a stored key with a
+matching public key, if one exists.
+
+Unlike "config/ca", this does not change the mount's default issuer unless
+none is configured yet.
+`
+
+const pathConfigIssuersHelpSyn = `
+Read and set the default issuer of this mount.
+`
+
+const pathConfigIssuersHelpDesc = `
+The default issuer is used by roles and endpoints which reference the issuer
+"default", and is the issuer whose certificate and CRL are served from the
+"ca" and "crl" endpoints. The default issuer must have an associated key.
+`
+
+const pathFetchIssuerHelpSyn = `
+Fetch an issuer's CA certificate or CRL.
+`
+
+const pathFetchIssuerHelpDesc = `
+This allows an issuer's certificate and CRL to be fetched without
+authentication.
+
+Using "ca/issuer/<ref>" or "crl/issuer/<ref>" fetches the appropriate
+information in DER encoding. Add "/pem" to either to get PEM encoding.
+
+Using "crl/issuer/<ref>/delta" fetches the issuer's delta CRL, when delta
+CRLs are enabled.
+
+Using "cert/issuer/<ref>" returns the PEM-encoded certificate and CA chain,
+"cert/issuer/<ref>/crl" the PEM-encoded CRL, and "cert/issuer/<ref>/delta-crl"
+the PEM-encoded delta CRL, in a JSON response.
+`
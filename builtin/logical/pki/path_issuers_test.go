@@ -0,0 +1,445 @@
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestPki_MultipleIssuers(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s %s: err: %v resp: %#v", op, path, err, resp)
+		}
+		return resp
+	}
+
+	resp := request(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "root-a.example.com",
+		"issuer_name": "root-a",
+		"key_name":    "key-a",
+		"ttl":         "720h",
+	})
+	rootA := resp.Data["issuer_id"].(string)
+
+	resp = request(logical.UpdateOperation, "issuers/generate/root/internal", map[string]interface{}{
+		"common_name": "root-b.example.com",
+		"issuer_name": "root-b",
+		"ttl":         "720h",
+	})
+	rootB := resp.Data["issuer_id"].(string)
+	keyB := resp.Data["key_id"].(string)
+	if rootA == rootB {
+		t.Fatalf("expected distinct issuers, got %s twice", rootA)
+	}
+
+	// Generating a second root through the legacy path must not replace
+	// the existing default
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "root/generate/internal",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"common_name": "root-c.example.com",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || len(resp.Warnings) == 0 {
+		t.Fatalf("expected a warning when a default issuer already exists, got %#v", resp)
+	}
+
+	resp = request(logical.ReadOperation, "config/issuers", nil)
+	if resp.Data["default"] != rootA {
+		t.Fatalf("expected default issuer %s, got %v", rootA, resp.Data["default"])
+	}
+
+	resp = request(logical.ListOperation, "issuers/", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 2 {
+		t.Fatalf("expected two issuers, got %v", keys)
+	}
+
+	request(logical.UpdateOperation, "roles/example", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"issuer_ref":       "root-b",
+	})
+	resp = request(logical.UpdateOperation, "issue/example", map[string]interface{}{
+		"common_name": "leaf.example.com",
+		"ttl":         "1h",
+	})
+	if resp.Data["issuing_ca"] != mustReadIssuerPEM(t, b, storage, "root-b") {
+		t.Fatalf("expected leaf to be issued by root-b")
+	}
+	leafSerial := resp.Data["serial_number"].(string)
+
+	request(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": leafSerial,
+	})
+	if !crlContainsSerial(t, b, storage, "crl/issuer/root-b", leafSerial) {
+		t.Fatalf("expected %s on the CRL of root-b", leafSerial)
+	}
+	if crlContainsSerial(t, b, storage, "crl/issuer/root-a", leafSerial) {
+		t.Fatalf("did not expect %s on the CRL of root-a", leafSerial)
+	}
+
+	request(logical.UpdateOperation, "config/issuers", map[string]interface{}{
+		"default": "root-b",
+	})
+	resp = request(logical.ReadOperation, "cert/ca", nil)
+	if resp.Data["certificate"] != mustReadIssuerPEM(t, b, storage, "root-b") {
+		t.Fatalf("expected legacy ca endpoint to serve the new default issuer")
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "key/" + keyB,
+		Storage:   storage,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error deleting a key in use, got %#v", resp)
+	}
+}
+
+func TestPki_MigrateLegacyBundle(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+	ctx := context.Background()
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "root/generate/exported",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"common_name": "legacy.example.com",
+		},
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+
+	// Rewind storage to the single-bundle layout of older versions
+	cb := &certutil.CertBundle{
+		Certificate:    resp.Data["certificate"].(string),
+		PrivateKey:     resp.Data["private_key"].(string),
+		PrivateKeyType: resp.Data["private_key_type"].(certutil.PrivateKeyType),
+	}
+	for _, prefix := range []string{issuerPrefix, keyPrefix} {
+		ids, err := storage.List(ctx, prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range ids {
+			if err := storage.Delete(ctx, prefix+id); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := storage.Delete(ctx, issuersConfigPath); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := logical.StorageEntryJSON(legacyCertBundlePath, cb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put(ctx, entry); err != nil {
+		t.Fatal(err)
+	}
+
+	migrated, err := migrateLegacyBundle(ctx, storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !migrated {
+		t.Fatal("expected legacy bundle to be migrated")
+	}
+
+	if mustReadIssuerPEM(t, b, storage, defaultRef) != strings.TrimSpace(cb.Certificate) {
+		t.Fatal("expected migrated certificate to be the default issuer")
+	}
+	if entry, err := storage.Get(ctx, legacyCertBundlePath); err != nil || entry != nil {
+		t.Fatalf("expected legacy bundle to be removed, got %v, %v", entry, err)
+	}
+
+	migrated, err = migrateLegacyBundle(ctx, storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated {
+		t.Fatal("expected second migration to be a no-op")
+	}
+}
+
+func mustReadIssuerPEM(t *testing.T, b *backend, storage logical.Storage, ref string) string {
+	t.Helper()
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "cert/issuer/" + ref,
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+	return resp.Data["certificate"].(string)
+}
+
+func crlContainsSerial(t *testing.T, b *backend, storage logical.Storage, path, serial string) bool {
+	t.Helper()
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      path + "/pem",
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+
+	block, _ := pem.Decode(resp.Data[logical.HTTPRawBody].([]byte))
+	if block == nil {
+		t.Fatalf("unable to decode CRL from %s", path)
+	}
+	crl, err := x509.ParseCRL(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, revoked := range crl.TBSCertList.RevokedCertificates {
+		if certutil.GetHexFormatted(revoked.SerialNumber.Bytes(), ":") == serial {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPki_CrossSignIssuer(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s %s: err: %v resp: %#v", op, path, err, resp)
+		}
+		return resp
+	}
+
+	request(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "root-a.example.com",
+		"issuer_name": "root-a",
+		"ttl":         "720h",
+	})
+	request(logical.UpdateOperation, "issuers/generate/root/internal", map[string]interface{}{
+		"common_name": "root-b.example.com",
+		"issuer_name": "root-b",
+		"ttl":         "720h",
+	})
+	rootA := parsePEMCert(t, mustReadIssuerPEM(t, b, storage, "root-a"))
+	rootB := parsePEMCert(t, mustReadIssuerPEM(t, b, storage, "root-b"))
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "issuer/root-a/cross-sign",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"signing_issuer": "root-a",
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error cross-signing an issuer by itself, got err: %v resp: %#v", err, resp)
+	}
+
+	resp = request(logical.UpdateOperation, "issuer/root-a/cross-sign", map[string]interface{}{
+		"signing_issuer": "root-b",
+		"issuer_name":    "root-a-by-b",
+	})
+	crossSigned := parsePEMCert(t, resp.Data["certificate"].(string))
+	if !bytes.Equal(crossSigned.RawSubject, rootA.RawSubject) || !bytes.Equal(crossSigned.RawIssuer, rootB.RawSubject) {
+		t.Fatalf("unexpected cross-signed certificate: subject %v issuer %v", crossSigned.Subject, crossSigned.Issuer)
+	}
+	if err := crossSigned.CheckSignatureFrom(rootB); err != nil {
+		t.Fatal(err)
+	}
+	if crossSigned.NotAfter.After(rootB.NotAfter) {
+		t.Fatalf("cross-signed certificate outlives its signer")
+	}
+	if resp.Data["key_id"] == "" {
+		t.Fatalf("expected the cross-signed issuer to share the key of root-a")
+	}
+	if chain := resp.Data["ca_chain"].([]string); len(chain) != 2 {
+		t.Fatalf("expected the cross-signed issuer to chain to root-b and root-a, got %d certificates", len(chain))
+	}
+
+	resp = request(logical.ReadOperation, "issuer/root-a", nil)
+	if chain := resp.Data["ca_chain"].([]string); len(chain) != 2 {
+		t.Fatalf("expected root-a to chain to its cross-signed certificate and root-b, got %d certificates", len(chain))
+	}
+
+	request(logical.UpdateOperation, "roles/example", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"issuer_ref":       "root-a",
+	})
+	resp = request(logical.UpdateOperation, "issue/example", map[string]interface{}{
+		"common_name": "leaf.example.com",
+		"ttl":         "1h",
+	})
+	leaf := parsePEMCert(t, resp.Data["certificate"].(string))
+
+	// Served from the default issuer, root-a; the chain must allow the leaf
+	// to be verified against root-b alone
+	resp = request(logical.ReadOperation, "cert/ca_chain", nil)
+	roots := x509.NewCertPool()
+	roots.AddCert(rootB)
+	intermediates := x509.NewCertPool()
+	rest := []byte(resp.Data["certificate"].(string))
+	count := 0
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		intermediates.AddCert(cert)
+		count++
+	}
+	if count != 3 {
+		t.Fatalf("expected three certificates in ca_chain, got %d", count)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+	}); err != nil {
+		t.Fatalf("leaf did not verify through the cross-signed path: %v", err)
+	}
+
+	request(logical.DeleteOperation, "issuer/root-a-by-b", nil)
+	resp = request(logical.ReadOperation, "issuer/root-a", nil)
+	if chain := resp.Data["ca_chain"].([]string); len(chain) != 0 {
+		t.Fatalf("expected root-a to have no chain once the cross-signed issuer is deleted, got %d certificates", len(chain))
+	}
+}
+
+func TestPki_CrossSignConstrainedIssuer(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s %s: err: %v resp: %#v", op, path, err, resp)
+		}
+		return resp
+	}
+
+	request(logical.UpdateOperation, "issuers/generate/root/internal", map[string]interface{}{
+		"common_name": "root-b.example.com",
+		"issuer_name": "root-b",
+		"ttl":         "720h",
+	})
+
+	// A CA with every kind of name constraint and some policies, which the
+	// backend can't generate itself
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, permittedIPs, _ := net.ParseCIDR("10.0.0.0/8")
+	_, excludedIPs, _ := net.ParseCIDR("10.1.0.0/16")
+	template := &x509.Certificate{
+		SerialNumber:                big.NewInt(1),
+		Subject:                     pkix.Name{CommonName: "constrained.example.com"},
+		NotBefore:                   time.Now().Add(-time.Minute),
+		NotAfter:                    time.Now().Add(24 * time.Hour),
+		KeyUsage:                    x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid:       true,
+		IsCA:                        true,
+		PermittedDNSDomainsCritical: true,
+		PermittedDNSDomains:         []string{"example.com"},
+		ExcludedDNSDomains:          []string{"secret.example.com"},
+		PermittedIPRanges:           []*net.IPNet{permittedIPs},
+		ExcludedIPRanges:            []*net.IPNet{excludedIPs},
+		PermittedEmailAddresses:     []string{"example.com"},
+		ExcludedEmailAddresses:      []string{"root@example.com"},
+		PermittedURIDomains:         []string{".example.com"},
+		ExcludedURIDomains:          []string{"secret.example.com"},
+		PolicyIdentifiers:           []asn1.ObjectIdentifier{{1, 2, 3, 4}, {2, 5, 29, 32, 0}},
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes}))
+	resp := request(logical.UpdateOperation, "issuers/import/bundle", map[string]interface{}{
+		"pem_bundle": bundle,
+	})
+	constrainedID := resp.Data["imported_issuers"].([]string)[0]
+	constrained, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp = request(logical.UpdateOperation, "issuer/"+constrainedID+"/cross-sign", map[string]interface{}{
+		"signing_issuer": "root-b",
+	})
+	crossSigned := parsePEMCert(t, resp.Data["certificate"].(string))
+
+	if !crossSigned.PermittedDNSDomainsCritical ||
+		!reflect.DeepEqual(crossSigned.PermittedDNSDomains, constrained.PermittedDNSDomains) ||
+		!reflect.DeepEqual(crossSigned.ExcludedDNSDomains, constrained.ExcludedDNSDomains) ||
+		!reflect.DeepEqual(crossSigned.PermittedIPRanges, constrained.PermittedIPRanges) ||
+		!reflect.DeepEqual(crossSigned.ExcludedIPRanges, constrained.ExcludedIPRanges) ||
+		!reflect.DeepEqual(crossSigned.PermittedEmailAddresses, constrained.PermittedEmailAddresses) ||
+		!reflect.DeepEqual(crossSigned.ExcludedEmailAddresses, constrained.ExcludedEmailAddresses) ||
+		!reflect.DeepEqual(crossSigned.PermittedURIDomains, constrained.PermittedURIDomains) ||
+		!reflect.DeepEqual(crossSigned.ExcludedURIDomains, constrained.ExcludedURIDomains) {
+		t.Fatalf("cross-signed issuer lost name constraints: %#v", crossSigned)
+	}
+	if !reflect.DeepEqual(crossSigned.PolicyIdentifiers, constrained.PolicyIdentifiers) {
+		t.Fatalf("cross-signed issuer lost policies: %v, expected %v", crossSigned.PolicyIdentifiers, constrained.PolicyIdentifiers)
+	}
+}
@@ -0,0 +1,177 @@
+package pki
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func pathListKeys(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathListKeysHandler,
+		},
+
+		HelpSynopsis:    pathListKeysHelpSyn,
+		HelpDescription: pathListKeysHelpDesc,
+	}
+}
+
+func pathKey(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "key/" + framework.GenericNameRegex("key_ref"),
+		Fields: map[string]*framework.FieldSchema{
+			"key_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Reference to an existing key, either by its ID or name.`,
+			},
+			"key_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `New name for the key.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathKeyRead,
+			logical.UpdateOperation: b.pathKeyWrite,
+			logical.DeleteOperation: b.pathKeyDelete,
+		},
+
+		HelpSynopsis:    pathKeyHelpSyn,
+		HelpDescription: pathKeyHelpDesc,
+	}
+}
+
+func (b *backend) pathListKeysHandler(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ids, err := listKeys(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := map[string]interface{}{}
+	for _, id := range ids {
+		key, err := fetchKeyByID(ctx, req.Storage, id)
+		if err != nil {
+			return nil, err
+		}
+		keyInfo[id] = map[string]interface{}{
+			"key_name": key.Name,
+		}
+	}
+
+	return logical.ListResponseWithInfo(ids, keyInfo), nil
+}
+
+func (b *backend) pathKeyRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	keyID, err := resolveKeyReference(ctx, req.Storage, data.Get("key_ref").(string))
+	if err != nil {
+		if _, ok := err.(errutil.UserError); ok {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		return nil, err
+	}
+
+	key, err := fetchKeyByID(ctx, req.Storage, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"key_id":   key.ID,
+			"key_name": key.Name,
+			"key_type": string(key.PrivateKeyType),
+		},
+	}, nil
+}
+
+func (b *backend) pathKeyWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	keyID, err := resolveKeyReference(ctx, req.Storage, data.Get("key_ref").(string))
+	if err != nil {
+		if _, ok := err.(errutil.UserError); ok {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		return nil, err
+	}
+
+	key, err := fetchKeyByID(ctx, req.Storage, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if nameRaw, ok := data.GetOk("key_name"); ok {
+		name := nameRaw.(string)
+		if name != key.Name {
+			if err := validateKeyName(ctx, req.Storage, name); err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("invalid key_name: %s", err)), nil
+			}
+			key.Name = name
+		}
+	}
+
+	if err := writeKey(ctx, req.Storage, key); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"key_id":   key.ID,
+			"key_name": key.Name,
+			"key_type": string(key.PrivateKeyType),
+		},
+	}, nil
+}
+
+func (b *backend) pathKeyDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	keyID, err := resolveKeyReference(ctx, req.Storage, data.Get("key_ref").(string))
+	if err != nil {
+		if _, ok := err.(errutil.UserError); ok {
+			// Deletes are idempotent
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	issuerIDs, err := listIssuers(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	for _, issuerID := range issuerIDs {
+		issuer, err := fetchIssuerByID(ctx, req.Storage, issuerID)
+		if err != nil {
+			return nil, err
+		}
+		if issuer.KeyID == keyID {
+			return logical.ErrorResponse(fmt.Sprintf("key is in use by issuer %s; delete the issuer first", issuerID)), nil
+		}
+	}
+
+	if err := deleteKey(ctx, req.Storage, keyID); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const pathListKeysHelpSyn = `
+List the keys stored in this mount.
+`
+
+const pathListKeysHelpDesc = `
+This endpoint lists the IDs of all private keys, along with their names.
+`
+
+const pathKeyHelpSyn = `
+Read, rename, or delete a key.
+`
+
+const pathKeyHelpDesc = `
+This endpoint manages a single private key, referenced by its ID or name.
+The private key itself is never returned. A key cannot be deleted while an
+issuer still uses it.
+`
@@ -0,0 +1,319 @@
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	ocspRequestContentType  = "application/ocsp-request"
+	ocspResponseContentType = "application/ocsp-response"
+
+	// maxOCSPRequestSize bounds the size of POSTed requests; a request for a
+	// single certificate is well under a kilobyte.
+	maxOCSPRequestSize = 64 * 1024
+)
+
+// Answers OCSP requests sent via POST with a DER-encoded body
+func pathOCSPPost(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "ocsp",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathOCSPHandler,
+		},
+
+		HelpSynopsis:    pathOCSPHelpSyn,
+		HelpDescription: pathOCSPHelpDesc,
+	}
+}
+
+// Answers OCSP requests sent via GET with a base64-encoded request in the
+// path
+func pathOCSPGet(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "ocsp/" + framework.MatchAllRegex("req"),
+		Fields: map[string]*framework.FieldSchema{
+			"req": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Base64-encoded DER OCSP request.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathOCSPHandler,
+		},
+
+		HelpSynopsis:    pathOCSPHelpSyn,
+		HelpDescription: pathOCSPHelpDesc,
+	}
+}
+
+func (b *backend) pathOCSPHandler(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	der, err := fetchOCSPRequestBytes(req, data)
+	if err != nil {
+		b.Logger().Debug("unable to read OCSP request", "error", err)
+		return ocspErrorResponse(http.StatusBadRequest, ocsp.MalformedRequestErrorResponse), nil
+	}
+
+	ocspReq, err := ocsp.ParseRequest(der)
+	if err != nil {
+		b.Logger().Debug("unable to parse OCSP request", "error", err)
+		return ocspErrorResponse(http.StatusBadRequest, ocsp.MalformedRequestErrorResponse), nil
+	}
+
+	issuer, issuerCert, err := findIssuerForOCSPRequest(ctx, req.Storage, ocspReq)
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		// Either the certificate was not issued by this mount, or the
+		// issuer has no key with which to sign a response
+		return ocspErrorResponse(http.StatusUnauthorized, ocsp.UnauthorizedErrorResponse), nil
+	}
+
+	key, err := fetchKeyByID(ctx, req.Storage, issuer.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := key.GetSigner()
+	if err != nil {
+		return nil, errwrap.Wrapf("error parsing issuer key: {{err}}", err)
+	}
+
+	template, err := ocspResponseTemplate(ctx, req.Storage, ocspReq, issuerCert)
+	if err != nil {
+		return nil, err
+	}
+
+	// The issuer signs responses directly, so no delegated responder
+	// certificate is needed
+	respBytes, err := ocsp.CreateResponse(issuerCert, issuerCert, *template, signer.PrivateKey)
+	if err != nil {
+		return nil, errwrap.Wrapf("error creating OCSP response: {{err}}", err)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: ocspResponseContentType,
+			logical.HTTPRawBody:     respBytes,
+			logical.HTTPStatusCode:  http.StatusOK,
+		},
+	}, nil
+}
+
+func fetchOCSPRequestBytes(req *logical.Request, data *framework.FieldData) ([]byte, error) {
+	if req.Operation == logical.ReadOperation {
+		encoded := data.Get("req").(string)
+		// Some clients do not URL-encode the request, in which case a "+"
+		// arrives as a space
+		encoded = strings.Replace(encoded, " ", "+", -1)
+		return base64.StdEncoding.DecodeString(encoded)
+	}
+
+	if req.HTTPRequest == nil || req.HTTPRequest.Body == nil {
+		return nil, fmt.Errorf("OCSP requests must be sent with content type %s", ocspRequestContentType)
+	}
+
+	der, err := ioutil.ReadAll(io.LimitReader(req.HTTPRequest.Body, maxOCSPRequestSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(der) > maxOCSPRequestSize {
+		return nil, fmt.Errorf("OCSP request exceeds %d bytes", maxOCSPRequestSize)
+	}
+
+	return der, nil
+}
+
+func ocspErrorResponse(status int, body []byte) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: ocspResponseContentType,
+			logical.HTTPRawBody:     body,
+			logical.HTTPStatusCode:  status,
+		},
+	}
+}
+
+// findIssuerForOCSPRequest returns the issuer, along with its parsed
+// certificate, whose name and key hashes match those of the request. Only
+// issuers with a key are considered, as others cannot sign a response.
+func findIssuerForOCSPRequest(ctx context.Context, s logical.Storage, ocspReq *ocsp.Request) (*issuerEntry, *x509.Certificate, error) {
+	if !ocspReq.HashAlgorithm.Available() {
+		return nil, nil, nil
+	}
+
+	issuerIDs, err := listIssuers(ctx, s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, issuerID := range issuerIDs {
+		issuer, err := fetchIssuerByID(ctx, s, issuerID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if issuer.KeyID == "" {
+			continue
+		}
+
+		cert, err := issuer.GetCertificate()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		matches, err := ocspIssuerHashesMatch(ocspReq, cert)
+		if err != nil {
+			return nil, nil, err
+		}
+		if matches {
+			return issuer, cert, nil
+		}
+	}
+
+	return nil, nil, nil
+}
+
+func ocspIssuerHashesMatch(ocspReq *ocsp.Request, cert *x509.Certificate) (bool, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return false, errwrap.Wrapf("error parsing issuer public key: {{err}}", err)
+	}
+
+	if !bytes.Equal(ocspHash(ocspReq.HashAlgorithm, cert.RawSubject), ocspReq.IssuerNameHash) {
+		return false, nil
+	}
+	return bytes.Equal(ocspHash(ocspReq.HashAlgorithm, spki.PublicKey.RightAlign()), ocspReq.IssuerKeyHash), nil
+}
+
+func ocspHash(hash crypto.Hash, data []byte) []byte {
+	h := hash.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// ocspResponseTemplate determines the status of the requested serial
+// number from the certificate and revocation entries in storage. A
+// certificate is only reported as good or revoked if it was issued by the
+// given issuer; anything else is unknown.
+func ocspResponseTemplate(ctx context.Context, s logical.Storage, ocspReq *ocsp.Request, issuerCert *x509.Certificate) (*ocsp.Response, error) {
+	template := &ocsp.Response{
+		Status:       ocsp.Unknown,
+		SerialNumber: ocspReq.SerialNumber,
+		ThisUpdate:   time.Now().UTC().Truncate(time.Minute),
+		IssuerHash:   ocspReq.HashAlgorithm,
+	}
+
+	serial := ocspSerial(ocspReq.SerialNumber)
+
+	// Revoked certificates are looked up first, as their revocation entry
+	// holds the certificate even once it is no longer in certs/, having been
+	// issued with no_store or removed by tidy
+	revEntry, err := getStoredCertEntry(ctx, s, "revoked/", serial)
+	if err != nil {
+		return nil, err
+	}
+	if revEntry != nil {
+		var revInfo revocationInfo
+		if err := revEntry.DecodeJSON(&revInfo); err != nil {
+			return nil, errwrap.Wrapf("error decoding revocation entry: {{err}}", err)
+		}
+		cert, err := x509.ParseCertificate(revInfo.CertificateBytes)
+		if err != nil {
+			return nil, errwrap.Wrapf("error parsing revoked certificate: {{err}}", err)
+		}
+		if !issuedBy(cert, issuerCert) {
+			return template, nil
+		}
+
+		template.Status = ocsp.Revoked
+		template.RevocationReason = ocsp.Unspecified
+		template.RevokedAt = revInfo.RevocationTimeUTC
+		if template.RevokedAt.IsZero() {
+			template.RevokedAt = time.Unix(revInfo.RevocationTime, 0).UTC()
+		}
+		return template, nil
+	}
+
+	certEntry, err := getStoredCertEntry(ctx, s, "certs/", serial)
+	if err != nil {
+		return nil, err
+	}
+	if certEntry == nil {
+		return template, nil
+	}
+
+	cert, err := x509.ParseCertificate(certEntry.Value)
+	if err != nil {
+		return nil, errwrap.Wrapf("error parsing certificate: {{err}}", err)
+	}
+	if issuedBy(cert, issuerCert) {
+		template.Status = ocsp.Good
+	}
+	return template, nil
+}
+
+// issuedBy returns whether the certificate was signed by the given issuer.
+func issuedBy(cert, issuerCert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, issuerCert.RawSubject) && cert.CheckSignatureFrom(issuerCert) == nil
+}
+
+func ocspSerial(serial *big.Int) string {
+	return certutil.GetHexFormatted(serial.Bytes(), ":")
+}
+
+// getStoredCertEntry reads a certificate or revocation entry without
+// migrating legacy paths, as the OCSP endpoint may be served from nodes
+// which cannot write to storage.
+func getStoredCertEntry(ctx context.Context, s logical.Storage, prefix, serial string) (*logical.StorageEntry, error) {
+	for _, path := range []string{
+		prefix + normalizeSerial(serial),
+		prefix + strings.Replace(strings.ToLower(serial), "-", ":", -1),
+	} {
+		entry, err := s.Get(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil && len(entry.Value) > 0 {
+			return entry, nil
+		}
+	}
+
+	return nil, nil
+}
+
+const pathOCSPHelpSyn = `
+Query certificate revocation status using OCSP.
+`
+
+const pathOCSPHelpDesc = `
+This endpoint implements an OCSP responder as described in RFC 6960. Requests
+may be sent via POST with a DER-encoded body and the content type
+"application/ocsp-request", or via GET with the base64-encoded request
+appended to the path.
+
+Responses are signed by the issuer of the requested certificate, which must
+be stored in this mount along with its key.
+`
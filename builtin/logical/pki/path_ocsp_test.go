@@ -0,0 +1,145 @@
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestPki_OCSP(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s %s: err: %v resp: %#v", op, path, err, resp)
+		}
+		return resp
+	}
+
+	request(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "root.example.com",
+		"ttl":         "720h",
+	})
+	request(logical.UpdateOperation, "config/urls", map[string]interface{}{
+		"base_url":               "https://vault.example.com:8200/v1/pki",
+		"include_ocsp_responder": true,
+	})
+	request(logical.UpdateOperation, "roles/example", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+	})
+	resp := request(logical.UpdateOperation, "issue/example", map[string]interface{}{
+		"common_name": "leaf.example.com",
+		"ttl":         "1h",
+	})
+
+	leaf := parsePEMCert(t, resp.Data["certificate"].(string))
+	issuer := parsePEMCert(t, resp.Data["issuing_ca"].(string))
+	if len(leaf.OCSPServer) != 1 || leaf.OCSPServer[0] != "https://vault.example.com:8200/v1/pki/ocsp" {
+		t.Fatalf("expected OCSP responder in AIA, got %v", leaf.OCSPServer)
+	}
+
+	ocspReq, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queryGet := func() *ocsp.Response {
+		t.Helper()
+		resp := request(logical.ReadOperation, "ocsp/"+base64.StdEncoding.EncodeToString(ocspReq), nil)
+		if resp.Data[logical.HTTPStatusCode] != http.StatusOK {
+			t.Fatalf("unexpected status: %v", resp.Data[logical.HTTPStatusCode])
+		}
+		ocspResp, err := ocsp.ParseResponseForCert(resp.Data[logical.HTTPRawBody].([]byte), leaf, issuer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ocspResp
+	}
+
+	if status := queryGet().Status; status != ocsp.Good {
+		t.Fatalf("expected good status, got %d", status)
+	}
+
+	request(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": resp.Data["serial_number"],
+	})
+
+	ocspResp := queryGet()
+	if ocspResp.Status != ocsp.Revoked {
+		t.Fatalf("expected revoked status, got %d", ocspResp.Status)
+	}
+	if ocspResp.RevokedAt.IsZero() {
+		t.Fatal("expected revocation time to be set")
+	}
+
+	// Revoked certificates missing from certs/, e.g. once tidied, are still
+	// reported as revoked
+	serial := resp.Data["serial_number"].(string)
+	if entry, err := storage.Get(context.Background(), "certs/"+normalizeSerial(serial)); err != nil || entry == nil {
+		t.Fatalf("expected stored certificate, got %v, err: %v", entry, err)
+	}
+	if err := storage.Delete(context.Background(), "certs/"+normalizeSerial(serial)); err != nil {
+		t.Fatal(err)
+	}
+	if status := queryGet().Status; status != ocsp.Revoked {
+		t.Fatalf("expected revoked status without the stored certificate, got %d", status)
+	}
+
+	// The same request sent via POST
+	httpReq, err := http.NewRequest("POST", "/v1/pki/ocsp", bytes.NewReader(ocspReq))
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpReq.Header.Set("Content-Type", ocspRequestContentType)
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation:   logical.UpdateOperation,
+		Path:        "ocsp",
+		Storage:     storage,
+		HTTPRequest: httpReq,
+	})
+	if err != nil || resp == nil {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+	ocspResp, err = ocsp.ParseResponseForCert(resp.Data[logical.HTTPRawBody].([]byte), leaf, issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ocspResp.Status != ocsp.Revoked {
+		t.Fatalf("expected revoked status via POST, got %d", ocspResp.Status)
+	}
+
+	// Garbage is answered with a malformed request error
+	resp = request(logical.ReadOperation, "ocsp/"+base64.StdEncoding.EncodeToString([]byte("garbage")), nil)
+	body := resp.Data[logical.HTTPRawBody].([]byte)
+	if !bytes.Equal(body, ocsp.MalformedRequestErrorResponse) {
+		t.Fatalf("expected malformed request response, got %x", body)
+	}
+}
+
+func parsePEMCert(t *testing.T, certPEM string) *x509.Certificate {
+	t.Helper()
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		t.Fatal("unable to decode certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
@@ -1,12 +1,17 @@
 package pki
 
 import (
+	"bytes"
 	"context"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/certutil"
 	"github.com/hashicorp/vault/sdk/helper/consts"
 	"github.com/hashicorp/vault/sdk/helper/errutil"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -21,6 +26,12 @@ func pathRevoke(b *backend) *framework.Path {
 				Description: `Certificate serial number, in colon- or
 hyphen-separated octal`,
 			},
+			"certificate": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `PEM-encoded certificate to revoke, as an
+alternative to serial_number. It must have
+been issued by an issuer of this mount.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -32,6 +43,30 @@ hyphen-separated octal`,
 	}
 }
 
+func pathRevokeRole(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "revoke/role/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Name of the role whose certificates are revoked`,
+			},
+			"dry_run": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set to true, only lists the serial numbers
+of the certificates which would be revoked.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRevokeRoleWrite,
+		},
+
+		HelpSynopsis:    pathRevokeRoleHelpSyn,
+		HelpDescription: pathRevokeRoleHelpDesc,
+	}
+}
+
 func pathRotateCRL(b *backend) *framework.Path {
 	return &framework.Path{
 		Pattern: `crl/rotate`,
@@ -47,27 +82,177 @@ func pathRotateCRL(b *backend) *framework.Path {
 
 func (b *backend) pathRevokeWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	serial := data.Get("serial_number").(string)
-	if len(serial) == 0 {
-		return logical.ErrorResponse("The serial number must be provided"), nil
+	certPEM := data.Get("certificate").(string)
+	switch {
+	case len(serial) == 0 && len(certPEM) == 0:
+		return logical.ErrorResponse("The serial number or certificate must be provided"), nil
+	case len(serial) != 0 && len(certPEM) != 0:
+		return logical.ErrorResponse("Only one of serial_number and certificate may be provided"), nil
 	}
 
 	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby) {
 		return nil, logical.ErrReadOnly
 	}
 
+	b.revokeStorageLock.Lock()
+	defer b.revokeStorageLock.Unlock()
+
+	if len(certPEM) != 0 {
+		var err error
+		serial, err = storeSubmittedCert(ctx, req, certPEM)
+		if err != nil {
+			switch err.(type) {
+			case errutil.UserError:
+				return logical.ErrorResponse(err.Error()), nil
+			default:
+				return nil, err
+			}
+		}
+	}
+
 	// We store and identify by lowercase colon-separated hex, but other
 	// utilities use dashes and/or uppercase, so normalize
 	serial = strings.Replace(strings.ToLower(serial), "-", ":", -1)
 
+	return revokeCert(ctx, b, req, serial, false)
+}
+
+// storeSubmittedCert verifies that a submitted certificate was issued by
+// this mount and returns its serial number. Certificates issued without
+// being stored, such as from roles with no_store set, are stored so that
+// they can be revoked like any other.
+func storeSubmittedCert(ctx context.Context, req *logical.Request, certPEM string) (string, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return "", errutil.UserError{Err: "certificate could not be PEM-decoded"}
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", errutil.UserError{Err: fmt.Sprintf("error parsing certificate: %s", err)}
+	}
+
+	issuerID, err := findIssuerForCert(ctx, req.Storage, cert)
+	if err != nil {
+		return "", errwrap.Wrapf("error finding issuer of certificate: {{err}}", err)
+	}
+	if issuerID == "" {
+		return "", errutil.UserError{Err: "certificate was not issued by this mount"}
+	}
+
+	serial := certutil.GetHexFormatted(cert.SerialNumber.Bytes(), ":")
+	certEntry, err := fetchCertBySerial(ctx, req, "certs/", serial)
+	if err != nil {
+		return "", err
+	}
+	if certEntry != nil {
+		// The serial number alone is not proof that the stored certificate
+		// is the one submitted
+		if !bytes.Equal(certEntry.Value, cert.Raw) {
+			return "", errutil.UserError{Err: fmt.Sprintf("certificate does not match the stored certificate with serial %s", serial)}
+		}
+		return serial, nil
+	}
+
+	err = req.Storage.Put(ctx, &logical.StorageEntry{
+		Key:   "certs/" + normalizeSerial(serial),
+		Value: cert.Raw,
+	})
+	if err != nil {
+		return "", errwrap.Wrapf("unable to store certificate locally: {{err}}", err)
+	}
+	return serial, nil
+}
+
+func (b *backend) pathRevokeRoleWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("name").(string)
+	dryRun := data.Get("dry_run").(bool)
+
+	if !dryRun && b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby) {
+		return nil, logical.ErrReadOnly
+	}
+
 	b.revokeStorageLock.Lock()
 	defer b.revokeStorageLock.Unlock()
 
-	return revokeCert(ctx, b, req, serial, false)
+	// The index outlives the role, so that the certificates of a deleted
+	// role can still be revoked
+	serials, err := req.Storage.List(ctx, roleCertsPrefix+roleName+"/")
+	if err != nil {
+		return nil, errwrap.Wrapf("error fetching list of certs issued by role: {{err}}", err)
+	}
+
+	candidates := []string{}
+	for _, serial := range serials {
+		certEntry, err := req.Storage.Get(ctx, "certs/"+serial)
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("error fetching certificate %q: {{err}}", serial), err)
+		}
+		if certEntry == nil {
+			continue
+		}
+		cert, err := x509.ParseCertificate(certEntry.Value)
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("unable to parse stored certificate with serial %q: {{err}}", serial), err)
+		}
+		if time.Now().After(cert.NotAfter) {
+			continue
+		}
+
+		revEntry, err := req.Storage.Get(ctx, "revoked/"+serial)
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("error fetching revocation entry of %q: {{err}}", serial), err)
+		}
+		if revEntry != nil {
+			continue
+		}
+
+		candidates = append(candidates, strings.Replace(serial, "-", ":", -1))
+	}
+
+	if dryRun {
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"serial_numbers": candidates,
+			},
+		}, nil
+	}
+
+	revoked := []string{}
+	for _, serial := range candidates {
+		resp, err := storeRevocation(ctx, b, req, serial, false)
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("error revoking %q: {{err}}", serial), err)
+		}
+		if resp == nil {
+			continue
+		}
+		if resp.IsError() {
+			return resp, nil
+		}
+		revoked = append(revoked, serial)
+	}
+
+	if len(revoked) > 0 {
+		crlErr := b.crlAfterRevoke(ctx, req, revoked...)
+		switch crlErr.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(fmt.Sprintf("Error during CRL building: %s", crlErr)), nil
+		case errutil.InternalError:
+			return nil, errwrap.Wrapf("error encountered during CRL building: {{err}}", crlErr)
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"serial_numbers": revoked,
+		},
+	}, nil
 }
 
 func (b *backend) pathRotateCRLRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	b.revokeStorageLock.RLock()
-	defer b.revokeStorageLock.RUnlock()
+	// Building the CRL advances the CRL number, so it has to be exclusive
+	b.revokeStorageLock.Lock()
+	defer b.revokeStorageLock.Unlock()
 
 	crlErr := buildCRL(ctx, b, req, false)
 	switch crlErr.(type) {
@@ -85,11 +270,28 @@ func (b *backend) pathRotateCRLRead(ctx context.Context, req *logical.Request, d
 }
 
 const pathRevokeHelpSyn = `
-Revoke a certificate by serial number.
+Revoke a certificate by serial number or by its contents.
 `
 
 const pathRevokeHelpDesc = `
 This allows certificates to be revoked using its serial number. A root token is required.
+
+Alternatively, the PEM-encoded certificate may be submitted. It must have been
+issued by an issuer of this mount; this also allows revoking certificates
+which were issued without being stored.
+`
+
+const pathRevokeRoleHelpSyn = `
+Revoke all certificates issued by a role.
+`
+
+const pathRevokeRoleHelpDesc = `
+This revokes every stored, unexpired certificate issued by the given role
+which has not already been revoked, and returns their serial numbers. With
+"dry_run" set, the certificates are only listed.
+
+Certificates issued before the backend recorded the issuing role are not
+included. The certificates of a deleted role can still be revoked.
 `
 
 const pathRotateCRLHelpSyn = `
@@ -355,6 +355,42 @@ for "generate_lease".`,
 				Description: `A comma-separated string or list of policy oids.`,
 			},
 
+			"allowed_csr_extensions": &framework.FieldSchema{
+				Type: framework.TypeCommaStringSlice,
+				Description: `If set, extensions of a CSR submitted for
+signing which are not set by Vault itself are
+copied into the certificate when listed here, and
+rejected otherwise. Each entry is either an oid,
+allowing any value, or of the form
+<oid>;UTF8:<value> where the value may contain
+globs, or <oid>;HEX:<DER value>.`,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Allowed CSR Extensions",
+				},
+			},
+
+			"allowed_csr_ext_key_usage_oids": &framework.FieldSchema{
+				Type: framework.TypeCommaStringSlice,
+				Description: `If set, extended key usage oids requested by
+a CSR submitted for signing are added to the
+certificate when listed here, and rejected
+otherwise. "*" allows any oid.`,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Allowed CSR Extended Key Usage OIDs",
+				},
+			},
+
+			"allowed_csr_policy_identifiers": &framework.FieldSchema{
+				Type: framework.TypeCommaStringSlice,
+				Description: `If set, policy oids requested by a CSR
+submitted for signing are added to the
+certificate when listed here, and rejected
+otherwise. "*" allows any oid.`,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Allowed CSR Policy Identifiers",
+				},
+			},
+
 			"basic_constraints_valid_for_non_ca": &framework.FieldSchema{
 				Type:        framework.TypeBool,
 				Description: `Mark Basic Constraints valid when issuing non-CA certificates.`,
@@ -370,6 +406,18 @@ for "generate_lease".`,
 					Value: 30,
 				},
 			},
+
+			"issuer_ref": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: defaultRef,
+				Description: `Reference to the issuer used to sign requests
+serviced by this role, either by ID or name. Defaults
+to "default", which always follows the mount's
+default issuer.`,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Issuer",
+				},
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -483,6 +531,19 @@ func (b *backend) getRole(ctx context.Context, s logical.Storage, n string) (*ro
 		modified = true
 	}
 
+	// Upgrade issuer
+	if result.IssuerOld != "" {
+		result.Issuer = result.IssuerOld
+		result.IssuerOld = ""
+		modified = true
+	}
+
+	// Roles written before multiple issuers were supported always used the
+	// mount's only CA, which is now the default issuer
+	if result.Issuer == "" {
+		result.Issuer = defaultRef
+	}
+
 	if modified && (b.System().LocalMount() || !b.System().ReplicationState().HasState(consts.ReplicationPerformanceSecondary)) {
 		jsonEntry, err := logical.StorageEntryJSON("role/"+n, &result)
 		if err != nil {
@@ -577,8 +638,12 @@ func (b *backend) pathRoleCreate(ctx context.Context, req *logical.Request, data
 		RequireCN:                     data.Get("require_cn").(bool),
 		AllowedSerialNumbers:          data.Get("allowed_serial_numbers").([]string),
 		PolicyIdentifiers:             data.Get("policy_identifiers").([]string),
+		AllowedCSRExtensions:          data.Get("allowed_csr_extensions").([]string),
+		AllowedCSRExtKeyUsageOIDs:     data.Get("allowed_csr_ext_key_usage_oids").([]string),
+		AllowedCSRPolicyIdentifiers:   data.Get("allowed_csr_policy_identifiers").([]string),
 		BasicConstraintsValidForNonCA: data.Get("basic_constraints_valid_for_non_ca").(bool),
 		NotBeforeDuration:             time.Duration(data.Get("not_before_duration").(int)) * time.Second,
+		Issuer:                        data.Get("issuer_ref").(string),
 	}
 
 	allowedOtherSANs := data.Get("allowed_other_sans").([]string)
@@ -632,6 +697,34 @@ func (b *backend) pathRoleCreate(ctx context.Context, req *logical.Request, data
 		}
 	}
 
+	if _, err := parseAllowedCSRExtensions(entry.AllowedCSRExtensions); err != nil {
+		return logical.ErrorResponse(errwrap.Wrapf("error parsing allowed_csr_extensions: {{err}}", err).Error()), nil
+	}
+
+	for _, oidstr := range entry.AllowedCSRExtKeyUsageOIDs {
+		if oidstr == "*" {
+			continue
+		}
+		if _, err := certutil.StringToOid(oidstr); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("%q could not be parsed as a valid oid for an extended key usage", oidstr)), nil
+		}
+	}
+
+	for _, oidstr := range entry.AllowedCSRPolicyIdentifiers {
+		if oidstr == "*" {
+			continue
+		}
+		if _, err := certutil.StringToOid(oidstr); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("%q could not be parsed as a valid oid for a policy identifier", oidstr)), nil
+		}
+	}
+
+	if entry.Issuer != defaultRef {
+		if _, err := resolveIssuerReference(ctx, req.Storage, entry.Issuer); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("error resolving issuer_ref: %s", err)), nil
+		}
+	}
+
 	// Store it
 	jsonEntry, err := logical.StorageEntryJSON("role/"+name, entry)
 	if err != nil {
@@ -772,8 +865,13 @@ type roleEntry struct {
 	AllowedURISANs                []string      `json:"allowed_uri_sans" mapstructure:"allowed_uri_sans"`
 	PolicyIdentifiers             []string      `json:"policy_identifiers" mapstructure:"policy_identifiers"`
 	ExtKeyUsageOIDs               []string      `json:"ext_key_usage_oids" mapstructure:"ext_key_usage_oids"`
+	AllowedCSRExtensions          []string      `json:"allowed_csr_extensions" mapstructure:"allowed_csr_extensions"`
+	AllowedCSRExtKeyUsageOIDs     []string      `json:"allowed_csr_ext_key_usage_oids" mapstructure:"allowed_csr_ext_key_usage_oids"`
+	AllowedCSRPolicyIdentifiers   []string      `json:"allowed_csr_policy_identifiers" mapstructure:"allowed_csr_policy_identifiers"`
 	BasicConstraintsValidForNonCA bool          `json:"basic_constraints_valid_for_non_ca" mapstructure:"basic_constraints_valid_for_non_ca"`
 	NotBeforeDuration             time.Duration `json:"not_before_duration" mapstructure:"not_before_duration"`
+	IssuerOld                     string        `json:"issuer,omitempty"`
+	Issuer                        string        `json:"issuer_ref" mapstructure:"issuer_ref"`
 
 	// Used internally for signing intermediates
 	AllowExpirationPastCA bool
@@ -817,8 +915,12 @@ func (r *roleEntry) ToResponseData() map[string]interface{} {
 		"allowed_uri_sans":                   r.AllowedURISANs,
 		"require_cn":                         r.RequireCN,
 		"policy_identifiers":                 r.PolicyIdentifiers,
+		"allowed_csr_extensions":             r.AllowedCSRExtensions,
+		"allowed_csr_ext_key_usage_oids":     r.AllowedCSRExtKeyUsageOIDs,
+		"allowed_csr_policy_identifiers":     r.AllowedCSRPolicyIdentifiers,
 		"basic_constraints_valid_for_non_ca": r.BasicConstraintsValidForNonCA,
 		"not_before_duration":                int64(r.NotBeforeDuration.Seconds()),
+		"issuer_ref":                         r.Issuer,
 	}
 	if r.MaxPathLength != nil {
 		responseData["max_path_length"] = r.MaxPathLength
@@ -2,6 +2,13 @@ package pki
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
 	"testing"
 	"time"
 
@@ -221,6 +228,62 @@ func TestPki_RoleKeyUsage(t *testing.T) {
 	}
 }
 
+func TestPki_RoleIssuerUpgrade(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+	ctx := context.Background()
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "root/generate/internal",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"common_name": "root.example.com",
+		},
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+	issuerID := resp.Data["issuer_id"].(string)
+
+	// Store a role the way it was stored before the issuer was saved as
+	// issuer_ref
+	entry, err := logical.StorageEntryJSON("role/testrole", map[string]interface{}{
+		"allowed_domains_list": []string{"example.com"},
+		"issuer":               issuerID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put(ctx, entry); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "roles/testrole",
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+	if resp.Data["issuer_ref"] != issuerID {
+		t.Fatalf("expected issuer_ref %q, got %v", issuerID, resp.Data["issuer_ref"])
+	}
+
+	// Reading upgraded the stored role
+	entry, err = storage.Get(ctx, "role/testrole")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored map[string]interface{}
+	if err := entry.DecodeJSON(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stored["issuer"]; ok || stored["issuer_ref"] != issuerID {
+		t.Fatalf("role was not upgraded: %#v", stored)
+	}
+}
+
 func TestPki_RoleOUOrganizationUpgrade(t *testing.T) {
 	var resp *logical.Response
 	var err error
@@ -745,3 +808,113 @@ func TestPki_CertsLease(t *testing.T) {
 		t.Fatalf("expected a response that contains a secret")
 	}
 }
+
+func TestPki_RoleCSRExtensionPassthrough(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+
+	resp, err := request(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "root.example.com",
+		"ttl":         "720h",
+	})
+	if err != nil || resp.IsError() {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+
+	// Extensions Vault sets itself can't be allowed through
+	resp, err = request(logical.UpdateOperation, "roles/smartcard", map[string]interface{}{
+		"allowed_csr_extensions": "2.5.29.17",
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error allowing a managed extension, got: err: %v resp: %#v", err, resp)
+	}
+
+	resp, err = request(logical.UpdateOperation, "roles/smartcard", map[string]interface{}{
+		"allow_any_name":                 true,
+		"key_type":                       "any",
+		"ttl":                            "1h",
+		"allowed_csr_extensions":         []string{"1.2.3.4;UTF8:doc-*", "1.2.3.5"},
+		"allowed_csr_ext_key_usage_oids": "1.3.6.1.4.1.311.20.2.2",
+		"allowed_csr_policy_identifiers": "1.2.3.99",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustMarshal := func(val interface{}, params string) []byte {
+		t.Helper()
+		der, err := asn1.MarshalWithParams(val, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+	smartCardLogon := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 20, 2, 2}
+	policy := asn1.ObjectIdentifier{1, 2, 3, 99}
+	signCSR := func(extensions ...pkix.Extension) (*logical.Response, error) {
+		t.Helper()
+		csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			Subject:         pkix.Name{CommonName: "user.example.com"},
+			ExtraExtensions: extensions,
+		}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return request(logical.UpdateOperation, "sign/smartcard", map[string]interface{}{
+			"csr": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr})),
+		})
+	}
+
+	docSigning := pkix.Extension{Id: asn1.ObjectIdentifier{1, 2, 3, 4}, Value: mustMarshal("doc-signing", "utf8")}
+	opaque := pkix.Extension{Id: asn1.ObjectIdentifier{1, 2, 3, 5}, Critical: true, Value: []byte{0x05, 0x00}}
+	eku := pkix.Extension{Id: oidExtensionExtendedKeyUsage, Value: mustMarshal([]asn1.ObjectIdentifier{smartCardLogon}, "")}
+	policies := pkix.Extension{Id: oidExtensionCertificatePolicies, Value: mustMarshal([]policyInformation{{Policy: policy}}, "")}
+
+	resp, err = signCSR(docSigning, opaque, eku, policies)
+	if err != nil || resp.IsError() {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+	cert := parsePEMCert(t, resp.Data["certificate"].(string))
+	for _, expected := range []pkix.Extension{docSigning, opaque} {
+		found := false
+		for _, ext := range cert.Extensions {
+			if ext.Id.Equal(expected.Id) {
+				found = ext.Critical == expected.Critical && string(ext.Value) == string(expected.Value)
+			}
+		}
+		if !found {
+			t.Fatalf("extension %s was not passed through: %v", expected.Id, cert.Extensions)
+		}
+	}
+	if len(cert.UnknownExtKeyUsage) != 1 || !cert.UnknownExtKeyUsage[0].Equal(smartCardLogon) {
+		t.Fatalf("expected smart card logon extended key usage, got %v", cert.UnknownExtKeyUsage)
+	}
+	if len(cert.PolicyIdentifiers) != 1 || !cert.PolicyIdentifiers[0].Equal(policy) {
+		t.Fatalf("expected policy identifier %s, got %v", policy, cert.PolicyIdentifiers)
+	}
+
+	rejected := map[string][]pkix.Extension{
+		"unlisted extension": {{Id: asn1.ObjectIdentifier{1, 2, 3, 6}, Value: []byte{0x05, 0x00}}},
+		"constrained value":  {{Id: asn1.ObjectIdentifier{1, 2, 3, 4}, Value: mustMarshal("code-signing", "utf8")}},
+		"extended key usage": {{Id: oidExtensionExtendedKeyUsage, Value: mustMarshal([]asn1.ObjectIdentifier{{1, 3, 6, 1, 5, 5, 7, 3, 3}}, "")}},
+		"policy identifier":  {{Id: oidExtensionCertificatePolicies, Value: mustMarshal([]policyInformation{{Policy: asn1.ObjectIdentifier{1, 2, 3, 100}}}, "")}},
+	}
+	for name, extensions := range rejected {
+		resp, err = signCSR(extensions...)
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("%s: expected error, got: err: %v resp: %#v", name, err, resp)
+		}
+	}
+}
@@ -34,6 +34,27 @@ func pathGenerateRoot(b *backend) *framework.Path {
 	ret.Fields = addCACommonFields(map[string]*framework.FieldSchema{})
 	ret.Fields = addCAKeyGenerationFields(ret.Fields)
 	ret.Fields = addCAIssueFields(ret.Fields)
+	ret.Fields = addIssuerNameFields(ret.Fields)
+
+	return ret
+}
+
+func pathIssuerGenerateRoot(b *backend) *framework.Path {
+	ret := &framework.Path{
+		Pattern: "issuers/generate/root/" + framework.GenericNameRegex("exported"),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathIssuerGenerateRoot,
+		},
+
+		HelpSynopsis:    pathIssuerGenerateRootHelpSyn,
+		HelpDescription: pathIssuerGenerateRootHelpDesc,
+	}
+
+	ret.Fields = addCACommonFields(map[string]*framework.FieldSchema{})
+	ret.Fields = addCAKeyGenerationFields(ret.Fields)
+	ret.Fields = addCAIssueFields(ret.Fields)
+	ret.Fields = addIssuerNameFields(ret.Fields)
 
 	return ret
 }
@@ -67,6 +88,7 @@ func pathSignIntermediate(b *backend) *framework.Path {
 
 	ret.Fields = addCACommonFields(map[string]*framework.FieldSchema{})
 	ret.Fields = addCAIssueFields(ret.Fields)
+	ret.Fields = addIssuerRefField(ret.Fields)
 
 	ret.Fields["csr"] = &framework.FieldSchema{
 		Type:        framework.TypeString,
@@ -110,26 +132,73 @@ func pathSignSelfIssued(b *backend) *framework.Path {
 		HelpDescription: pathSignSelfIssuedHelpDesc,
 	}
 
+	ret.Fields = addIssuerRefField(ret.Fields)
+
 	return ret
 }
 
 func (b *backend) pathCADeleteRoot(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	return nil, req.Storage.Delete(ctx, "config/ca_bundle")
+	issuerIDs, err := listIssuers(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	for _, issuerID := range issuerIDs {
+		if _, err := deleteIssuer(ctx, req.Storage, issuerID); err != nil {
+			return nil, err
+		}
+	}
+
+	keyIDs, err := listKeys(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	for _, keyID := range keyIDs {
+		if err := deleteKey(ctx, req.Storage, keyID); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, path := range []string{issuersConfigPath, legacyCertBundlePath, "ca", "crl"} {
+		if err := req.Storage.Delete(ctx, path); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
 }
 
 func (b *backend) pathCAGenerateRoot(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	var err error
-
-	entry, err := req.Storage.Get(ctx, "config/ca_bundle")
+	config, err := getIssuersConfig(ctx, req.Storage)
 	if err != nil {
 		return nil, err
 	}
-	if entry != nil {
+	if config.DefaultIssuerID != "" {
 		resp := &logical.Response{}
-		resp.AddWarning(fmt.Sprintf("Refusing to generate a root certificate over an existing root certificate. If you really want to destroy the original root certificate, please issue a delete against %sroot.", req.MountPoint))
+		resp.AddWarning(fmt.Sprintf("Refusing to generate a root certificate over an existing root certificate. If you really want to destroy the original root certificate, please issue a delete against %sroot. To add another root alongside the existing one, use %sissuers/generate/root.", req.MountPoint, req.MountPoint))
 		return resp, nil
 	}
 
+	return b.generateRoot(ctx, req, data, true)
+}
+
+// pathIssuerGenerateRoot generates a new root issuer alongside any existing
+// issuers; it only becomes the default if there is no default yet.
+func (b *backend) pathIssuerGenerateRoot(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return b.generateRoot(ctx, req, data, false)
+}
+
+func (b *backend) generateRoot(ctx context.Context, req *logical.Request, data *framework.FieldData, makeDefault bool) (*logical.Response, error) {
+	var err error
+
+	issuerName := data.Get("issuer_name").(string)
+	keyName := data.Get("key_name").(string)
+	if err := validateIssuerName(ctx, req.Storage, issuerName); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("invalid issuer_name: %s", err)), nil
+	}
+	if err := validateKeyName(ctx, req.Storage, keyName); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("invalid key_name: %s", err)), nil
+	}
+
 	exported, format, role, errorResp := b.getGenerationParams(data)
 	if errorResp != nil {
 		return errorResp, nil
@@ -204,16 +273,26 @@ func (b *backend) pathCAGenerateRoot(ctx context.Context, req *logical.Request,
 		}
 	}
 
-	// Store it as the CA bundle
-	entry, err = logical.StorageEntryJSON("config/ca_bundle", cb)
+	// Store the key and certificate as a new issuer
+	key, _, err := importKey(ctx, req.Storage, cb.PrivateKey, keyName)
 	if err != nil {
-		return nil, err
+		return nil, errwrap.Wrapf("unable to store generated key: {{err}}", err)
 	}
-	err = req.Storage.Put(ctx, entry)
+	issuer, _, err := importIssuer(ctx, req.Storage, cb.Certificate, nil, issuerName)
 	if err != nil {
-		return nil, err
+		return nil, errwrap.Wrapf("unable to store generated issuer: {{err}}", err)
+	}
+	if makeDefault {
+		if err := setDefaultIssuer(ctx, req.Storage, issuer); err != nil {
+			return nil, err
+		}
 	}
 
+	resp.Data["issuer_id"] = issuer.ID
+	resp.Data["issuer_name"] = issuer.Name
+	resp.Data["key_id"] = key.ID
+	resp.Data["key_name"] = key.Name
+
 	// Also store it as just the certificate identified by serial number, so it
 	// can be revoked
 	err = req.Storage.Put(ctx, &logical.StorageEntry{
@@ -224,15 +303,6 @@ func (b *backend) pathCAGenerateRoot(ctx context.Context, req *logical.Request,
 		return nil, errwrap.Wrapf("unable to store certificate locally: {{err}}", err)
 	}
 
-	// For ease of later use, also store just the certificate at a known
-	// location
-	entry.Key = "ca"
-	entry.Value = parsedBundle.CertificateBytes
-	err = req.Storage.Put(ctx, entry)
-	if err != nil {
-		return nil, err
-	}
-
 	// Build a fresh CRL
 	err = buildCRL(ctx, b, req, true)
 	if err != nil {
@@ -280,7 +350,7 @@ func (b *backend) pathCASignIntermediate(ctx context.Context, req *logical.Reque
 	}
 
 	var caErr error
-	signingBundle, caErr := fetchCAInfo(ctx, req)
+	signingBundle, caErr := fetchCAInfo(ctx, req, data.Get("issuer_ref").(string))
 	switch caErr.(type) {
 	case errutil.UserError:
 		return nil, errutil.UserError{Err: fmt.Sprintf(
@@ -406,7 +476,7 @@ func (b *backend) pathCASignSelfIssued(ctx context.Context, req *logical.Request
 	}
 
 	var caErr error
-	signingBundle, caErr := fetchCAInfo(ctx, req)
+	signingBundle, caErr := fetchCAInfo(ctx, req, data.Get("issuer_ref").(string))
 	switch caErr.(type) {
 	case errutil.UserError:
 		return nil, errutil.UserError{Err: fmt.Sprintf(
@@ -457,8 +527,22 @@ const pathGenerateRootHelpDesc = `
 See the API documentation for more information.
 `
 
+const pathIssuerGenerateRootHelpSyn = `
+Generate a new root issuer alongside any existing issuers.
+`
+
+const pathIssuerGenerateRootHelpDesc = `
+This path generates a new self-signed CA certificate and private key and
+stores them as an additional issuer within the mount. Unlike "root/generate",
+existing issuers are left in place; the new issuer only becomes the default
+if no default issuer has been configured yet. Use "config/issuers" to change
+the default issuer afterwards.
+
+See the API documentation for more information.
+`
+
 const pathDeleteRootHelpSyn = `
-Deletes the root CA key to allow a new one to be generated.
+Deletes all issuers and keys to allow a new root to be generated.
 `
 
 const pathDeleteRootHelpDesc = `
@@ -5,10 +5,12 @@ import (
 	"crypto/x509"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/errwrap"
+	hclog "github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/consts"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -55,6 +57,50 @@ Defaults to 72 hours.`,
 	}
 }
 
+func pathTidyStatus(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "tidy-status$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathTidyStatusRead,
+		},
+
+		HelpSynopsis:    pathTidyStatusHelpSyn,
+		HelpDescription: pathTidyStatusHelpDesc,
+	}
+}
+
+// tidyConfig holds the options of a tidy operation; it is also stored as the
+// automatic tidy configuration
+type tidyConfig struct {
+	Enabled      bool          `json:"enabled"`
+	Interval     time.Duration `json:"interval_duration"`
+	CertStore    bool          `json:"tidy_cert_store"`
+	RevokedCerts bool          `json:"tidy_revoked_certs"`
+	SafetyBuffer time.Duration `json:"safety_buffer"`
+}
+
+type tidyStatusState int
+
+const (
+	tidyStatusInactive tidyStatusState = iota
+	tidyStatusStarted
+	tidyStatusFinished
+	tidyStatusError
+)
+
+// tidyStatus describes the last tidy operation run on this node
+type tidyStatus struct {
+	config       *tidyConfig
+	state        tidyStatusState
+	err          error
+	timeStarted  time.Time
+	timeFinished time.Time
+
+	certStoreDeletedCount   uint
+	revokedCertDeletedCount uint
+}
+
 func (b *backend) pathTidyWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	// If we are a performance standby forward the request to the active node
 	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby) {
@@ -70,14 +116,43 @@ func (b *backend) pathTidyWrite(ctx context.Context, req *logical.Request, d *fr
 		return logical.ErrorResponse("safety_buffer must be greater than zero"), nil
 	}
 
-	bufferDuration := time.Duration(safetyBuffer) * time.Second
+	config := &tidyConfig{
+		CertStore:    tidyCertStore,
+		RevokedCerts: tidyRevokedCerts || tidyRevocationList,
+		SafetyBuffer: time.Duration(safetyBuffer) * time.Second,
+	}
 
-	if !atomic.CompareAndSwapUint32(b.tidyCASGuard, 0, 1) {
+	started, err := b.startTidy(ctx, req, config)
+	if err != nil {
+		return nil, err
+	}
+	if !started {
 		resp := &logical.Response{}
 		resp.AddWarning("Tidy operation already in progress.")
 		return resp, nil
 	}
 
+	resp := &logical.Response{}
+	resp.AddWarning("Tidy operation successfully started. Any information from the operation will be printed to Vault's server logs, and its progress is reported by the tidy-status endpoint.")
+	return logical.RespondWithStatusCode(resp, req, http.StatusAccepted)
+}
+
+// startTidy runs a tidy operation in the background, returning false if one
+// is already in progress
+func (b *backend) startTidy(ctx context.Context, req *logical.Request, config *tidyConfig) (bool, error) {
+	if !atomic.CompareAndSwapUint32(b.tidyCASGuard, 0, 1) {
+		return false, nil
+	}
+
+	// Automatic tidy operations are scheduled from the last run, which is
+	// stored so that the schedule survives restarts and leadership changes
+	if err := setLastTidy(ctx, req.Storage, time.Now()); err != nil {
+		atomic.StoreUint32(b.tidyCASGuard, 0)
+		return false, errwrap.Wrapf("error recording tidy start time: {{err}}", err)
+	}
+
+	b.tidyStatusStart(config)
+
 	// Tests using framework will screw up the storage so make a locally
 	// scoped req to hold a reference
 	req = &logical.Request{
@@ -88,132 +163,256 @@ func (b *backend) pathTidyWrite(ctx context.Context, req *logical.Request, d *fr
 		defer atomic.StoreUint32(b.tidyCASGuard, 0)
 
 		// Don't cancel when the original client request goes away
-		ctx = context.Background()
+		ctx := context.Background()
 
 		logger := b.Logger().Named("tidy")
 
-		doTidy := func() error {
-			if tidyCertStore {
-				serials, err := req.Storage.List(ctx, "certs/")
-				if err != nil {
-					return errwrap.Wrapf("error fetching list of certs: {{err}}", err)
+		err := b.doTidy(ctx, req, logger, config)
+		if err != nil {
+			logger.Error("error running tidy", "error", err)
+		}
+		b.tidyStatusStop(err)
+	}()
+
+	return true, nil
+}
+
+func (b *backend) doTidy(ctx context.Context, req *logical.Request, logger hclog.Logger, config *tidyConfig) error {
+	if config.CertStore {
+		serials, err := req.Storage.List(ctx, "certs/")
+		if err != nil {
+			return errwrap.Wrapf("error fetching list of certs: {{err}}", err)
+		}
+
+		for _, serial := range serials {
+			certEntry, err := req.Storage.Get(ctx, "certs/"+serial)
+			if err != nil {
+				return errwrap.Wrapf(fmt.Sprintf("error fetching certificate %q: {{err}}", serial), err)
+			}
+
+			if certEntry == nil {
+				logger.Warn("certificate entry is nil; tidying up since it is no longer useful for any server operations", "serial", serial)
+				if err := req.Storage.Delete(ctx, "certs/"+serial); err != nil {
+					return errwrap.Wrapf(fmt.Sprintf("error deleting nil entry with serial %s: {{err}}", serial), err)
+				}
+				b.tidyStatusIncCertStoreCount()
+				continue
+			}
+
+			if certEntry.Value == nil || len(certEntry.Value) == 0 {
+				logger.Warn("certificate entry has no value; tidying up since it is no longer useful for any server operations", "serial", serial)
+				if err := req.Storage.Delete(ctx, "certs/"+serial); err != nil {
+					return errwrap.Wrapf(fmt.Sprintf("error deleting entry with nil value with serial %s: {{err}}", serial), err)
 				}
+				b.tidyStatusIncCertStoreCount()
+				continue
+			}
 
-				for _, serial := range serials {
-					certEntry, err := req.Storage.Get(ctx, "certs/"+serial)
-					if err != nil {
-						return errwrap.Wrapf(fmt.Sprintf("error fetching certificate %q: {{err}}", serial), err)
-					}
-
-					if certEntry == nil {
-						logger.Warn("certificate entry is nil; tidying up since it is no longer useful for any server operations", "serial", serial)
-						if err := req.Storage.Delete(ctx, "certs/"+serial); err != nil {
-							return errwrap.Wrapf(fmt.Sprintf("error deleting nil entry with serial %s: {{err}}", serial), err)
-						}
-						continue
-					}
-
-					if certEntry.Value == nil || len(certEntry.Value) == 0 {
-						logger.Warn("certificate entry has no value; tidying up since it is no longer useful for any server operations", "serial", serial)
-						if err := req.Storage.Delete(ctx, "certs/"+serial); err != nil {
-							return errwrap.Wrapf(fmt.Sprintf("error deleting entry with nil value with serial %s: {{err}}", serial), err)
-						}
-						continue
-					}
-
-					cert, err := x509.ParseCertificate(certEntry.Value)
-					if err != nil {
-						return errwrap.Wrapf(fmt.Sprintf("unable to parse stored certificate with serial %q: {{err}}", serial), err)
-					}
-
-					if time.Now().After(cert.NotAfter.Add(bufferDuration)) {
-						if err := req.Storage.Delete(ctx, "certs/"+serial); err != nil {
-							return errwrap.Wrapf(fmt.Sprintf("error deleting serial %q from storage: {{err}}", serial), err)
-						}
-					}
+			cert, err := x509.ParseCertificate(certEntry.Value)
+			if err != nil {
+				return errwrap.Wrapf(fmt.Sprintf("unable to parse stored certificate with serial %q: {{err}}", serial), err)
+			}
+
+			if time.Now().After(cert.NotAfter.Add(config.SafetyBuffer)) {
+				if err := req.Storage.Delete(ctx, "certs/"+serial); err != nil {
+					return errwrap.Wrapf(fmt.Sprintf("error deleting serial %q from storage: {{err}}", serial), err)
 				}
+				b.tidyStatusIncCertStoreCount()
 			}
+		}
+	}
 
-			if tidyRevokedCerts || tidyRevocationList {
-				b.revokeStorageLock.Lock()
-				defer b.revokeStorageLock.Unlock()
+	if config.RevokedCerts {
+		b.revokeStorageLock.Lock()
+		defer b.revokeStorageLock.Unlock()
 
-				tidiedRevoked := false
+		tidiedRevoked := false
 
-				revokedSerials, err := req.Storage.List(ctx, "revoked/")
-				if err != nil {
-					return errwrap.Wrapf("error fetching list of revoked certs: {{err}}", err)
+		revokedSerials, err := req.Storage.List(ctx, "revoked/")
+		if err != nil {
+			return errwrap.Wrapf("error fetching list of revoked certs: {{err}}", err)
+		}
+
+		var revInfo revocationInfo
+		for _, serial := range revokedSerials {
+			revokedEntry, err := req.Storage.Get(ctx, "revoked/"+serial)
+			if err != nil {
+				return errwrap.Wrapf(fmt.Sprintf("unable to fetch revoked cert with serial %q: {{err}}", serial), err)
+			}
+
+			if revokedEntry == nil {
+				logger.Warn("revoked entry is nil; tidying up since it is no longer useful for any server operations", "serial", serial)
+				if err := req.Storage.Delete(ctx, "revoked/"+serial); err != nil {
+					return errwrap.Wrapf(fmt.Sprintf("error deleting nil revoked entry with serial %s: {{err}}", serial), err)
 				}
+				b.tidyStatusIncRevokedCertCount()
+				continue
+			}
 
-				var revInfo revocationInfo
-				for _, serial := range revokedSerials {
-					revokedEntry, err := req.Storage.Get(ctx, "revoked/"+serial)
-					if err != nil {
-						return errwrap.Wrapf(fmt.Sprintf("unable to fetch revoked cert with serial %q: {{err}}", serial), err)
-					}
-
-					if revokedEntry == nil {
-						logger.Warn("revoked entry is nil; tidying up since it is no longer useful for any server operations", "serial", serial)
-						if err := req.Storage.Delete(ctx, "revoked/"+serial); err != nil {
-							return errwrap.Wrapf(fmt.Sprintf("error deleting nil revoked entry with serial %s: {{err}}", serial), err)
-						}
-						continue
-					}
-
-					if revokedEntry.Value == nil || len(revokedEntry.Value) == 0 {
-						logger.Warn("revoked entry has nil value; tidying up since it is no longer useful for any server operations", "serial", serial)
-						if err := req.Storage.Delete(ctx, "revoked/"+serial); err != nil {
-							return errwrap.Wrapf(fmt.Sprintf("error deleting revoked entry with nil value with serial %s: {{err}}", serial), err)
-						}
-						continue
-					}
-
-					err = revokedEntry.DecodeJSON(&revInfo)
-					if err != nil {
-						return errwrap.Wrapf(fmt.Sprintf("error decoding revocation entry for serial %q: {{err}}", serial), err)
-					}
-
-					revokedCert, err := x509.ParseCertificate(revInfo.CertificateBytes)
-					if err != nil {
-						return errwrap.Wrapf(fmt.Sprintf("unable to parse stored revoked certificate with serial %q: {{err}}", serial), err)
-					}
-
-					// Remove the matched certificate entries from revoked/ and
-					// cert/ paths. We compare against both the NotAfter time
-					// within the cert itself and the time from the revocation
-					// entry, and perform tidy if either one tells us that the
-					// certificate has already been revoked.
-					now := time.Now()
-					if now.After(revokedCert.NotAfter.Add(bufferDuration)) || now.After(revInfo.RevocationTimeUTC.Add(bufferDuration)) {
-						if err := req.Storage.Delete(ctx, "revoked/"+serial); err != nil {
-							return errwrap.Wrapf(fmt.Sprintf("error deleting serial %q from revoked list: {{err}}", serial), err)
-						}
-						if err := req.Storage.Delete(ctx, "certs/"+serial); err != nil {
-							return errwrap.Wrapf(fmt.Sprintf("error deleting serial %q from store when tidying revoked: {{err}}", serial), err)
-						}
-						tidiedRevoked = true
-					}
+			if revokedEntry.Value == nil || len(revokedEntry.Value) == 0 {
+				logger.Warn("revoked entry has nil value; tidying up since it is no longer useful for any server operations", "serial", serial)
+				if err := req.Storage.Delete(ctx, "revoked/"+serial); err != nil {
+					return errwrap.Wrapf(fmt.Sprintf("error deleting revoked entry with nil value with serial %s: {{err}}", serial), err)
 				}
+				b.tidyStatusIncRevokedCertCount()
+				continue
+			}
 
-				if tidiedRevoked {
-					if err := buildCRL(ctx, b, req, false); err != nil {
-						return err
-					}
+			err = revokedEntry.DecodeJSON(&revInfo)
+			if err != nil {
+				return errwrap.Wrapf(fmt.Sprintf("error decoding revocation entry for serial %q: {{err}}", serial), err)
+			}
+
+			revokedCert, err := x509.ParseCertificate(revInfo.CertificateBytes)
+			if err != nil {
+				return errwrap.Wrapf(fmt.Sprintf("unable to parse stored revoked certificate with serial %q: {{err}}", serial), err)
+			}
+
+			// Remove the matched certificate entries from revoked/ and
+			// cert/ paths. We compare against both the NotAfter time
+			// within the cert itself and the time from the revocation
+			// entry, and perform tidy if either one tells us that the
+			// certificate has already been revoked.
+			now := time.Now()
+			if now.After(revokedCert.NotAfter.Add(config.SafetyBuffer)) || now.After(revInfo.RevocationTimeUTC.Add(config.SafetyBuffer)) {
+				if err := req.Storage.Delete(ctx, "revoked/"+serial); err != nil {
+					return errwrap.Wrapf(fmt.Sprintf("error deleting serial %q from revoked list: {{err}}", serial), err)
 				}
+				if err := req.Storage.Delete(ctx, "certs/"+serial); err != nil {
+					return errwrap.Wrapf(fmt.Sprintf("error deleting serial %q from store when tidying revoked: {{err}}", serial), err)
+				}
+				b.tidyStatusIncRevokedCertCount()
+				tidiedRevoked = true
 			}
+		}
 
-			return nil
+		if tidiedRevoked {
+			if err := buildCRL(ctx, b, req, false); err != nil {
+				return err
+			}
 		}
+	}
 
-		if err := doTidy(); err != nil {
-			logger.Error("error running tidy", "error", err)
-			return
+	if config.CertStore || config.RevokedCerts {
+		if err := tidyRoleCertIndex(ctx, req); err != nil {
+			return err
 		}
-	}()
+	}
 
-	resp := &logical.Response{}
-	resp.AddWarning("Tidy operation successfully started. Any information from the operation will be printed to Vault's server logs.")
-	return logical.RespondWithStatusCode(resp, req, http.StatusAccepted)
+	return nil
+}
+
+// tidyRoleCertIndex removes entries from the role index whose certificates
+// are no longer stored
+func tidyRoleCertIndex(ctx context.Context, req *logical.Request) error {
+	roles, err := req.Storage.List(ctx, roleCertsPrefix)
+	if err != nil {
+		return errwrap.Wrapf("error fetching list of roles with indexed certs: {{err}}", err)
+	}
+
+	for _, role := range roles {
+		serials, err := req.Storage.List(ctx, roleCertsPrefix+role)
+		if err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("error fetching list of certs issued by role %q: {{err}}", strings.TrimSuffix(role, "/")), err)
+		}
+
+		for _, serial := range serials {
+			certEntry, err := req.Storage.Get(ctx, "certs/"+serial)
+			if err != nil {
+				return errwrap.Wrapf(fmt.Sprintf("error fetching certificate %q: {{err}}", serial), err)
+			}
+			if certEntry != nil {
+				continue
+			}
+			if err := req.Storage.Delete(ctx, roleCertsPrefix+role+serial); err != nil {
+				return errwrap.Wrapf(fmt.Sprintf("error deleting serial %q from the role index: {{err}}", serial), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (b *backend) pathTidyStatusRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	b.tidyStatusLock.RLock()
+	defer b.tidyStatusLock.RUnlock()
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"safety_buffer":              nil,
+			"tidy_cert_store":            nil,
+			"tidy_revoked_certs":         nil,
+			"state":                      "Inactive",
+			"error":                      nil,
+			"time_started":               nil,
+			"time_finished":              nil,
+			"cert_store_deleted_count":   nil,
+			"revoked_cert_deleted_count": nil,
+		},
+	}
+
+	if b.tidyStatus == nil || b.tidyStatus.state == tidyStatusInactive {
+		return resp, nil
+	}
+
+	resp.Data["safety_buffer"] = int64(b.tidyStatus.config.SafetyBuffer.Seconds())
+	resp.Data["tidy_cert_store"] = b.tidyStatus.config.CertStore
+	resp.Data["tidy_revoked_certs"] = b.tidyStatus.config.RevokedCerts
+	resp.Data["time_started"] = b.tidyStatus.timeStarted
+	resp.Data["cert_store_deleted_count"] = b.tidyStatus.certStoreDeletedCount
+	resp.Data["revoked_cert_deleted_count"] = b.tidyStatus.revokedCertDeletedCount
+
+	switch b.tidyStatus.state {
+	case tidyStatusStarted:
+		resp.Data["state"] = "Running"
+	case tidyStatusFinished:
+		resp.Data["state"] = "Finished"
+		resp.Data["time_finished"] = b.tidyStatus.timeFinished
+	case tidyStatusError:
+		resp.Data["state"] = "Error"
+		resp.Data["time_finished"] = b.tidyStatus.timeFinished
+		resp.Data["error"] = b.tidyStatus.err.Error()
+	}
+
+	return resp, nil
+}
+
+func (b *backend) tidyStatusStart(config *tidyConfig) {
+	b.tidyStatusLock.Lock()
+	defer b.tidyStatusLock.Unlock()
+
+	b.tidyStatus = &tidyStatus{
+		config:      config,
+		state:       tidyStatusStarted,
+		timeStarted: time.Now(),
+	}
+}
+
+func (b *backend) tidyStatusStop(err error) {
+	b.tidyStatusLock.Lock()
+	defer b.tidyStatusLock.Unlock()
+
+	b.tidyStatus.timeFinished = time.Now()
+	b.tidyStatus.err = err
+	if err == nil {
+		b.tidyStatus.state = tidyStatusFinished
+	} else {
+		b.tidyStatus.state = tidyStatusError
+	}
+}
+
+func (b *backend) tidyStatusIncCertStoreCount() {
+	b.tidyStatusLock.Lock()
+	defer b.tidyStatusLock.Unlock()
+
+	b.tidyStatus.certStoreDeletedCount++
+}
+
+func (b *backend) tidyStatusIncRevokedCertCount() {
+	b.tidyStatusLock.Lock()
+	defer b.tidyStatusLock.Unlock()
+
+	b.tidyStatus.revokedCertDeletedCount++
 }
 
 const pathTidyHelpSyn = `
@@ -242,3 +441,14 @@ certificate storage or in revocation information will then be checked. If the
 current time, minus the value of 'safety_buffer', is greater than the
 expiration, it will be removed.
 `
+
+const pathTidyStatusHelpSyn = `
+Returns the status of the tidy operation.
+`
+
+const pathTidyStatusHelpDesc = `
+This is a read only endpoint that returns information about the current or
+most recent tidy operation on this node, manual or automatic: its options,
+state, start and finish times, any error it ended with, and the number of
+entries it deleted from the certificate store and the revocation list.
+`
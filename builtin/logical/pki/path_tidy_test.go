@@ -0,0 +1,122 @@
+package pki
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestPki_AutoTidy(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s %s: err: %v resp: %#v", op, path, err, resp)
+		}
+		return resp
+	}
+
+	periodic := func() {
+		t.Helper()
+		if err := b.periodicFunc(context.Background(), &logical.Request{Storage: storage}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp := request(logical.ReadOperation, "tidy-status", nil)
+	if resp.Data["state"] != "Inactive" {
+		t.Fatalf("expected inactive tidy status, got %#v", resp.Data)
+	}
+
+	request(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "root.example.com",
+		"ttl":         "720h",
+	})
+	request(logical.UpdateOperation, "roles/example", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+	})
+	request(logical.UpdateOperation, "issue/example", map[string]interface{}{
+		"common_name": "leaf.example.com",
+		"ttl":         "1s",
+	})
+	request(logical.UpdateOperation, "issue/example", map[string]interface{}{
+		"common_name": "leaf.example.com",
+		"ttl":         "1h",
+	})
+
+	// Enabling auto-tidy without anything to tidy is an error
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/auto-tidy",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"enabled": true,
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got: err: %v resp: %#v", err, resp)
+	}
+
+	resp = request(logical.UpdateOperation, "config/auto-tidy", map[string]interface{}{
+		"enabled":           true,
+		"interval_duration": "1h",
+		"tidy_cert_store":   true,
+		"safety_buffer":     "1s",
+	})
+	if resp.Data["interval_duration"] != int64(3600) || resp.Data["safety_buffer"] != int64(1) {
+		t.Fatalf("unexpected auto-tidy config: %#v", resp.Data)
+	}
+
+	// Nothing happens until the interval has passed
+	periodic()
+	resp = request(logical.ReadOperation, "tidy-status", nil)
+	if resp.Data["state"] != "Inactive" {
+		t.Fatalf("expected inactive tidy status, got %#v", resp.Data)
+	}
+
+	time.Sleep(2500 * time.Millisecond)
+	if err := setLastTidy(context.Background(), storage, time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	periodic()
+
+	for i := 0; ; i++ {
+		resp = request(logical.ReadOperation, "tidy-status", nil)
+		if resp.Data["state"] == "Finished" {
+			break
+		}
+		if resp.Data["state"] != "Running" || i > 50 {
+			t.Fatalf("tidy did not finish: %#v", resp.Data)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if resp.Data["cert_store_deleted_count"] != uint(1) || resp.Data["tidy_cert_store"] != true || resp.Data["time_finished"] == nil {
+		t.Fatalf("unexpected tidy status: %#v", resp.Data)
+	}
+
+	certs, err := storage.List(context.Background(), "certs/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The root and the unexpired leaf remain
+	if len(certs) != 2 {
+		t.Fatalf("expected two remaining certificates, got %d", len(certs))
+	}
+
+	// The next automatic run is scheduled from this one
+	periodic()
+	resp = request(logical.ReadOperation, "tidy-status", nil)
+	if resp.Data["state"] != "Finished" || resp.Data["cert_store_deleted_count"] != uint(1) {
+		t.Fatalf("expected no new tidy operation, got %#v", resp.Data)
+	}
+}
@@ -0,0 +1,755 @@
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/errwrap"
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// legacyCertBundlePath is where a mount stored its single CA bundle
+	// before multiple issuers were supported. It is migrated into the
+	// issuer and key storage on initialization.
+	legacyCertBundlePath = "config/ca_bundle"
+
+	issuerPrefix      = "config/issuer/"
+	keyPrefix         = "config/key/"
+	issuersConfigPath = "config/issuers"
+	issuerCRLPrefix   = "crls/"
+
+	// Delta CRLs, the revocations they carry, and the CRL numbering state
+	// live next to the base CRLs in local storage
+	deltaCRLPrefix = "crls/delta/"
+	deltaWALPrefix = "crls/delta-wal/"
+	crlStatePath   = "crls/state"
+
+	// roleCertsPrefix indexes the stored certificates by the role which
+	// issued them, as "role-certs/<role>/<serial>"
+	roleCertsPrefix = "role-certs/"
+
+	// defaultRef is the reference which always resolves to the issuer
+	// currently configured as the mount default.
+	defaultRef = "default"
+)
+
+// issuerEntry is a CA certificate stored within the mount. An issuer may
+// reference a key, in which case it can be used for signing; issuers
+// without a key are kept only to build chains.
+type issuerEntry struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	KeyID        string   `json:"key_id"`
+	Certificate  string   `json:"certificate"`
+	CAChain      []string `json:"ca_chain"`
+	SerialNumber string   `json:"serial_number"`
+}
+
+// keyEntry is a private key stored within the mount. Several issuers may
+// share one key, for instance when a CA is reissued or cross-signed.
+type keyEntry struct {
+	ID             string                  `json:"id"`
+	Name           string                  `json:"name"`
+	PrivateKeyType certutil.PrivateKeyType `json:"private_key_type"`
+	PrivateKey     string                  `json:"private_key"`
+}
+
+// issuerConfigEntry holds mount-wide issuer settings
+type issuerConfigEntry struct {
+	DefaultIssuerID string `json:"default"`
+}
+
+// GetCertificate parses the PEM-encoded certificate of the issuer
+func (i *issuerEntry) GetCertificate() (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(i.Certificate))
+	if block == nil {
+		return nil, fmt.Errorf("unable to decode certificate of issuer %s", i.ID)
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// GetSigner parses the PEM-encoded private key of the key entry
+func (k *keyEntry) GetSigner() (*certutil.ParsedCertBundle, error) {
+	cb := &certutil.CertBundle{
+		PrivateKeyType: k.PrivateKeyType,
+		PrivateKey:     k.PrivateKey,
+	}
+	return cb.ToParsedCertBundle()
+}
+
+func listIssuers(ctx context.Context, s logical.Storage) ([]string, error) {
+	return s.List(ctx, issuerPrefix)
+}
+
+func fetchIssuerByID(ctx context.Context, s logical.Storage, id string) (*issuerEntry, error) {
+	entry, err := s.Get(ctx, issuerPrefix+id)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch issuer %s: %v", id, err)}
+	}
+	if entry == nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("issuer %s does not exist", id)}
+	}
+
+	var issuer issuerEntry
+	if err := entry.DecodeJSON(&issuer); err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to decode issuer %s: %v", id, err)}
+	}
+
+	return &issuer, nil
+}
+
+func writeIssuer(ctx context.Context, s logical.Storage, issuer *issuerEntry) error {
+	entry, err := logical.StorageEntryJSON(issuerPrefix+issuer.ID, issuer)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+func deleteIssuer(ctx context.Context, s logical.Storage, id string) (bool, error) {
+	config, err := getIssuersConfig(ctx, s)
+	if err != nil {
+		return false, err
+	}
+
+	wasDefault := false
+	if config.DefaultIssuerID == id {
+		wasDefault = true
+		config.DefaultIssuerID = ""
+		if err := setIssuersConfig(ctx, s, config); err != nil {
+			return wasDefault, err
+		}
+	}
+
+	if err := s.Delete(ctx, issuerCRLPrefix+id); err != nil {
+		return wasDefault, err
+	}
+	if err := s.Delete(ctx, deltaCRLPrefix+id); err != nil {
+		return wasDefault, err
+	}
+
+	issuer, err := fetchIssuerByID(ctx, s, id)
+	if err != nil {
+		return wasDefault, err
+	}
+	if err := s.Delete(ctx, issuerPrefix+id); err != nil {
+		return wasDefault, err
+	}
+
+	// The certificate must not linger in the chains of other issuers, from
+	// where it would be picked up again when rebuilding them
+	ids, err := listIssuers(ctx, s)
+	if err != nil {
+		return wasDefault, err
+	}
+	for _, otherID := range ids {
+		other, err := fetchIssuerByID(ctx, s, otherID)
+		if err != nil {
+			return wasDefault, err
+		}
+		if !strutil.StrListContains(other.CAChain, issuer.Certificate) {
+			continue
+		}
+		other.CAChain = strutil.StrListDelete(other.CAChain, issuer.Certificate)
+		if err := writeIssuer(ctx, s, other); err != nil {
+			return wasDefault, err
+		}
+	}
+
+	return wasDefault, rebuildIssuersChains(ctx, s)
+}
+
+// rebuildIssuersChains recomputes the CA chain of every issuer from all of
+// the certificates known to the mount: the issuers themselves and the
+// chains they were imported with. An issuer's chain holds every certificate
+// reachable from it by following signatures to their issuers, and by
+// switching to equivalent certificates with the same subject and key, such
+// as cross-signed ones, so that any valid path can be built from it.
+func rebuildIssuersChains(ctx context.Context, s logical.Storage) error {
+	ids, err := listIssuers(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	issuers := make([]*issuerEntry, 0, len(ids))
+	issuerCerts := make([]*x509.Certificate, 0, len(ids))
+	var pool []*x509.Certificate
+	seen := map[string]bool{}
+	addToPool := func(certPEM string) error {
+		block, _ := pem.Decode([]byte(certPEM))
+		if block == nil {
+			return errutil.InternalError{Err: "unable to decode stored chain certificate"}
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("unable to parse stored chain certificate: %v", err)}
+		}
+		if !seen[string(cert.Raw)] {
+			seen[string(cert.Raw)] = true
+			pool = append(pool, cert)
+		}
+		return nil
+	}
+
+	for _, id := range ids {
+		issuer, err := fetchIssuerByID(ctx, s, id)
+		if err != nil {
+			return err
+		}
+		cert, err := issuer.GetCertificate()
+		if err != nil {
+			return err
+		}
+		issuers = append(issuers, issuer)
+		issuerCerts = append(issuerCerts, cert)
+		if err := addToPool(issuer.Certificate); err != nil {
+			return err
+		}
+	}
+	for _, issuer := range issuers {
+		for _, certPEM := range issuer.CAChain {
+			if err := addToPool(certPEM); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, issuer := range issuers {
+		chain, err := buildIssuerChain(issuerCerts[i], pool)
+		if err != nil {
+			return err
+		}
+		if strutil.EquivalentSlices(chain, issuer.CAChain) && len(chain) == len(issuer.CAChain) {
+			continue
+		}
+		issuer.CAChain = chain
+		if err := writeIssuer(ctx, s, issuer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// buildIssuerChain walks the pool breadth-first from the given certificate,
+// returning the PEM-encoded certificates reached, nearest first
+func buildIssuerChain(cert *x509.Certificate, pool []*x509.Certificate) ([]string, error) {
+	var chain []string
+	visited := map[string]bool{string(cert.Raw): true}
+	queue := []*x509.Certificate{cert}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, candidate := range pool {
+			if visited[string(candidate.Raw)] {
+				continue
+			}
+
+			isParent := bytes.Equal(current.RawIssuer, candidate.RawSubject) && current.CheckSignatureFrom(candidate) == nil
+			isEquivalent := false
+			if !isParent && bytes.Equal(current.RawSubject, candidate.RawSubject) {
+				equal, err := samePublicKey(current.PublicKey, candidate.PublicKey)
+				if err != nil {
+					return nil, err
+				}
+				isEquivalent = equal
+			}
+			if !isParent && !isEquivalent {
+				continue
+			}
+
+			visited[string(candidate.Raw)] = true
+			queue = append(queue, candidate)
+			chain = append(chain, strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{
+				Type:  "CERTIFICATE",
+				Bytes: candidate.Raw,
+			}))))
+		}
+	}
+
+	return chain, nil
+}
+
+func listKeys(ctx context.Context, s logical.Storage) ([]string, error) {
+	return s.List(ctx, keyPrefix)
+}
+
+func fetchKeyByID(ctx context.Context, s logical.Storage, id string) (*keyEntry, error) {
+	entry, err := s.Get(ctx, keyPrefix+id)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch key %s: %v", id, err)}
+	}
+	if entry == nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("key %s does not exist", id)}
+	}
+
+	var key keyEntry
+	if err := entry.DecodeJSON(&key); err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to decode key %s: %v", id, err)}
+	}
+
+	return &key, nil
+}
+
+func writeKey(ctx context.Context, s logical.Storage, key *keyEntry) error {
+	entry, err := logical.StorageEntryJSON(keyPrefix+key.ID, key)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+func deleteKey(ctx context.Context, s logical.Storage, id string) error {
+	return s.Delete(ctx, keyPrefix+id)
+}
+
+func getIssuersConfig(ctx context.Context, s logical.Storage) (*issuerConfigEntry, error) {
+	entry, err := s.Get(ctx, issuersConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &issuerConfigEntry{}
+	if entry != nil {
+		if err := entry.DecodeJSON(config); err != nil {
+			return nil, err
+		}
+	}
+
+	return config, nil
+}
+
+func setIssuersConfig(ctx context.Context, s logical.Storage, config *issuerConfigEntry) error {
+	entry, err := logical.StorageEntryJSON(issuersConfigPath, config)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+// resolveIssuerReference turns a reference (the literal "default", an
+// issuer name or an issuer ID) into an issuer ID.
+func resolveIssuerReference(ctx context.Context, s logical.Storage, ref string) (string, error) {
+	if ref == "" || ref == defaultRef {
+		config, err := getIssuersConfig(ctx, s)
+		if err != nil {
+			return "", errutil.InternalError{Err: fmt.Sprintf("unable to fetch issuers config: %v", err)}
+		}
+		if config.DefaultIssuerID == "" {
+			return "", errutil.UserError{Err: "no default issuer is configured"}
+		}
+		return config.DefaultIssuerID, nil
+	}
+
+	ids, err := listIssuers(ctx, s)
+	if err != nil {
+		return "", errutil.InternalError{Err: fmt.Sprintf("unable to list issuers: %v", err)}
+	}
+
+	for _, id := range ids {
+		if id == ref {
+			return id, nil
+		}
+	}
+
+	for _, id := range ids {
+		issuer, err := fetchIssuerByID(ctx, s, id)
+		if err != nil {
+			return "", err
+		}
+		if issuer.Name == ref {
+			return id, nil
+		}
+	}
+
+	return "", errutil.UserError{Err: fmt.Sprintf("unable to find issuer for reference %q", ref)}
+}
+
+// resolveKeyReference turns a key name or key ID into a key ID
+func resolveKeyReference(ctx context.Context, s logical.Storage, ref string) (string, error) {
+	ids, err := listKeys(ctx, s)
+	if err != nil {
+		return "", errutil.InternalError{Err: fmt.Sprintf("unable to list keys: %v", err)}
+	}
+
+	for _, id := range ids {
+		if id == ref {
+			return id, nil
+		}
+	}
+
+	for _, id := range ids {
+		key, err := fetchKeyByID(ctx, s, id)
+		if err != nil {
+			return "", err
+		}
+		if key.Name == ref {
+			return id, nil
+		}
+	}
+
+	return "", errutil.UserError{Err: fmt.Sprintf("unable to find key for reference %q", ref)}
+}
+
+// validateReferenceName ensures a user-supplied issuer or key name cannot
+// be confused with the default reference or with generated IDs.
+func validateReferenceName(name string) error {
+	if name == "" {
+		return nil
+	}
+	if name == defaultRef {
+		return fmt.Errorf("%q is a reserved name", defaultRef)
+	}
+	if _, err := uuid.ParseUUID(name); err == nil {
+		return fmt.Errorf("name %q cannot be a UUID", name)
+	}
+	if !nameRegex.MatchString(name) {
+		return fmt.Errorf("name %q contains invalid characters", name)
+	}
+	return nil
+}
+
+// validateIssuerName checks that name is acceptable for a new issuer and
+// not already in use by another issuer.
+func validateIssuerName(ctx context.Context, s logical.Storage, name string) error {
+	if err := validateReferenceName(name); err != nil || name == "" {
+		return err
+	}
+	if _, err := resolveIssuerReference(ctx, s, name); err == nil {
+		return fmt.Errorf("an issuer named %q already exists", name)
+	}
+	return nil
+}
+
+// validateKeyName checks that name is acceptable for a new key and not
+// already in use by another key.
+func validateKeyName(ctx context.Context, s logical.Storage, name string) error {
+	if err := validateReferenceName(name); err != nil || name == "" {
+		return err
+	}
+	if _, err := resolveKeyReference(ctx, s, name); err == nil {
+		return fmt.Errorf("a key named %q already exists", name)
+	}
+	return nil
+}
+
+// importKey stores a PEM-encoded private key, unless a key with the same
+// public key already exists, in which case the existing entry is returned
+// and the second return value is true.
+func importKey(ctx context.Context, s logical.Storage, keyPEM string, name string) (*keyEntry, bool, error) {
+	parsed, err := (&certutil.CertBundle{PrivateKey: keyPEM}).ToParsedCertBundle()
+	if err != nil {
+		return nil, false, err
+	}
+	if parsed.PrivateKey == nil {
+		return nil, false, errutil.UserError{Err: "private key could not be parsed"}
+	}
+
+	ids, err := listKeys(ctx, s)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, id := range ids {
+		existing, err := fetchKeyByID(ctx, s, id)
+		if err != nil {
+			return nil, false, err
+		}
+		existingParsed, err := existing.GetSigner()
+		if err != nil {
+			return nil, false, err
+		}
+		equal, err := samePublicKey(existingParsed.PrivateKey.Public(), parsed.PrivateKey.Public())
+		if err != nil {
+			return nil, false, err
+		}
+		if equal {
+			return existing, true, nil
+		}
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, false, err
+	}
+
+	key := &keyEntry{
+		ID:             id,
+		Name:           name,
+		PrivateKeyType: parsed.PrivateKeyType,
+		PrivateKey:     strings.TrimSpace(keyPEM),
+	}
+	if err := writeKey(ctx, s, key); err != nil {
+		return nil, false, err
+	}
+
+	return key, false, nil
+}
+
+// importIssuer stores a PEM-encoded CA certificate, linking it to any
+// stored key matching its public key. If the certificate is already
+// present the existing entry is returned and the second return value is
+// true. The first issuer imported with a key becomes the default.
+func importIssuer(ctx context.Context, s logical.Storage, certPEM string, caChain []string, name string) (*issuerEntry, bool, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return nil, false, errutil.UserError{Err: "certificate could not be PEM-decoded"}
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, false, errutil.UserError{Err: fmt.Sprintf("error parsing certificate: %v", err)}
+	}
+	if !cert.IsCA {
+		return nil, false, errutil.UserError{Err: "the given certificate is not marked for CA use and cannot be used with this backend"}
+	}
+
+	ids, err := listIssuers(ctx, s)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, id := range ids {
+		existing, err := fetchIssuerByID(ctx, s, id)
+		if err != nil {
+			return nil, false, err
+		}
+		existingCert, err := existing.GetCertificate()
+		if err != nil {
+			return nil, false, err
+		}
+		if bytes.Equal(existingCert.Raw, cert.Raw) {
+			return existing, true, nil
+		}
+	}
+
+	keyIDs, err := listKeys(ctx, s)
+	if err != nil {
+		return nil, false, err
+	}
+	var keyID string
+	for _, id := range keyIDs {
+		key, err := fetchKeyByID(ctx, s, id)
+		if err != nil {
+			return nil, false, err
+		}
+		parsed, err := key.GetSigner()
+		if err != nil {
+			return nil, false, err
+		}
+		equal, err := samePublicKey(cert.PublicKey, parsed.PrivateKey.Public())
+		if err != nil {
+			return nil, false, err
+		}
+		if equal {
+			keyID = id
+			break
+		}
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, false, err
+	}
+
+	issuer := &issuerEntry{
+		ID:           id,
+		Name:         name,
+		KeyID:        keyID,
+		Certificate:  strings.TrimSpace(string(pem.EncodeToMemory(block))),
+		CAChain:      caChain,
+		SerialNumber: certutil.GetHexFormatted(cert.SerialNumber.Bytes(), ":"),
+	}
+	if err := writeIssuer(ctx, s, issuer); err != nil {
+		return nil, false, err
+	}
+	if err := rebuildIssuersChains(ctx, s); err != nil {
+		return nil, false, err
+	}
+	issuer, err = fetchIssuerByID(ctx, s, issuer.ID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	config, err := getIssuersConfig(ctx, s)
+	if err != nil {
+		return nil, false, err
+	}
+	if config.DefaultIssuerID == "" && keyID != "" {
+		if err := setDefaultIssuer(ctx, s, issuer); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return issuer, false, nil
+}
+
+// setDefaultIssuer updates the mount's default issuer and mirrors its
+// certificate at the legacy "ca" location so that unauthenticated fetches
+// of ca and ca/pem keep working.
+func setDefaultIssuer(ctx context.Context, s logical.Storage, issuer *issuerEntry) error {
+	config, err := getIssuersConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+	config.DefaultIssuerID = issuer.ID
+	if err := setIssuersConfig(ctx, s, config); err != nil {
+		return err
+	}
+
+	cert, err := issuer.GetCertificate()
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, &logical.StorageEntry{
+		Key:   "ca",
+		Value: cert.Raw,
+	})
+}
+
+// fetchCAInfoByIssuerID builds a signing bundle for the given issuer. The
+// issuer must have a key associated with it.
+func fetchCAInfoByIssuerID(ctx context.Context, req *logical.Request, id string) (*certutil.CAInfoBundle, error) {
+	issuer, err := fetchIssuerByID(ctx, req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+	if issuer.KeyID == "" {
+		return nil, errutil.UserError{Err: fmt.Sprintf("issuer %s has no associated key and cannot be used for signing", id)}
+	}
+
+	key, err := fetchKeyByID(ctx, req.Storage, issuer.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &certutil.CertBundle{
+		PrivateKeyType: key.PrivateKeyType,
+		PrivateKey:     key.PrivateKey,
+		Certificate:    issuer.Certificate,
+		CAChain:        issuer.CAChain,
+		SerialNumber:   issuer.SerialNumber,
+	}
+
+	parsedBundle, err := bundle.ToParsedCertBundle()
+	if err != nil {
+		return nil, errutil.InternalError{Err: err.Error()}
+	}
+
+	if parsedBundle.Certificate == nil {
+		return nil, errutil.InternalError{Err: "stored CA information not able to be parsed"}
+	}
+
+	caInfo := &certutil.CAInfoBundle{ParsedCertBundle: *parsedBundle}
+
+	entries, err := getURLs(ctx, req)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch URL information: %v", err)}
+	}
+	if entries == nil {
+		entries = &certutil.URLEntries{
+			IssuingCertificates:   []string{},
+			CRLDistributionPoints: []string{},
+			OCSPServers:           []string{},
+		}
+	}
+	caInfo.URLs = entries
+
+	return caInfo, nil
+}
+
+// findIssuerForCert returns the ID of the stored issuer which signed the
+// given certificate, or an empty string if none did.
+func findIssuerForCert(ctx context.Context, s logical.Storage, cert *x509.Certificate) (string, error) {
+	ids, err := listIssuers(ctx, s)
+	if err != nil {
+		return "", err
+	}
+
+	for _, id := range ids {
+		issuer, err := fetchIssuerByID(ctx, s, id)
+		if err != nil {
+			return "", err
+		}
+		issuerCert, err := issuer.GetCertificate()
+		if err != nil {
+			return "", err
+		}
+		if !bytes.Equal(cert.RawIssuer, issuerCert.RawSubject) {
+			continue
+		}
+		if err := cert.CheckSignatureFrom(issuerCert); err == nil {
+			return id, nil
+		}
+	}
+
+	return "", nil
+}
+
+// migrateLegacyBundle moves a CA bundle written by older versions of this
+// backend into the issuer and key storage.
+func migrateLegacyBundle(ctx context.Context, s logical.Storage) (bool, error) {
+	entry, err := s.Get(ctx, legacyCertBundlePath)
+	if err != nil {
+		return false, err
+	}
+	if entry == nil {
+		return false, nil
+	}
+
+	var bundle certutil.CertBundle
+	if err := entry.DecodeJSON(&bundle); err != nil {
+		return false, errwrap.Wrapf("unable to decode legacy CA bundle: {{err}}", err)
+	}
+
+	if bundle.PrivateKey != "" {
+		if _, _, err := importKey(ctx, s, bundle.PrivateKey, ""); err != nil {
+			return false, errwrap.Wrapf("unable to migrate legacy CA key: {{err}}", err)
+		}
+	}
+
+	if bundle.Certificate != "" {
+		issuer, _, err := importIssuer(ctx, s, bundle.Certificate, bundle.CAChain, "")
+		if err != nil {
+			return false, errwrap.Wrapf("unable to migrate legacy CA certificate: {{err}}", err)
+		}
+
+		// The legacy CRL belonged to this issuer; keep it around until the
+		// next rebuild.
+		crlEntry, err := s.Get(ctx, "crl")
+		if err != nil {
+			return false, err
+		}
+		if crlEntry != nil {
+			crlEntry.Key = issuerCRLPrefix + issuer.ID
+			if err := s.Put(ctx, crlEntry); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	return true, s.Delete(ctx, legacyCertBundlePath)
+}
+
+// recordRoleCert adds a stored certificate to the index of the role which
+// issued it
+func recordRoleCert(ctx context.Context, s logical.Storage, roleName, serial string) error {
+	if roleName == "" {
+		return nil
+	}
+	return s.Put(ctx, &logical.StorageEntry{
+		Key: roleCertsPrefix + roleName + "/" + normalizeSerial(serial),
+	})
+}
@@ -1,7 +1,23 @@
 package pki
 
-import "strings"
+import (
+	"crypto"
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+)
 
 func normalizeSerial(serial string) string {
 	return strings.Replace(strings.ToLower(serial), ":", "-", -1)
 }
+
+// samePublicKey returns whether the given public keys are the same. Unlike
+// certutil.ComparePublicKeys, keys of different types are simply reported as
+// different, as a mount may hold keys of several types.
+func samePublicKey(key1, key2 crypto.PublicKey) (bool, error) {
+	if reflect.TypeOf(key1) != reflect.TypeOf(key2) {
+		return false, nil
+	}
+	return certutil.ComparePublicKeys(key1, key2)
+}
@@ -3,9 +3,11 @@ package transit
 import (
 	"context"
 	"strings"
+	"sync"
 
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
 	"github.com/hashicorp/vault/sdk/helper/keysutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
@@ -29,6 +31,7 @@ func Backend(ctx context.Context, conf *logical.BackendConfig) (*backend, error)
 			SealWrapStorage: []string{
 				"archive/",
 				"policy/",
+				"import/",
 			},
 		},
 
@@ -37,16 +40,30 @@ func Backend(ctx context.Context, conf *logical.BackendConfig) (*backend, error)
 			// as the handler is greedy
 			b.pathConfig(),
 			b.pathRotate(),
+			b.pathCreateCSR(),
+			b.pathSetCertificate(),
+			b.pathContexts(),
 			b.pathRewrap(),
+			b.pathRewrapJobs(),
+			b.pathRewrapJob(),
+			b.pathRewrapJobResults(),
+			b.pathImport(),
+			b.pathImportVersion(),
+			b.pathWrappingKey(),
 			b.pathKeys(),
 			b.pathListKeys(),
 			b.pathExportKeys(),
 			b.pathEncrypt(),
 			b.pathDecrypt(),
+			b.pathStreamEncrypt(),
+			b.pathStreamDecrypt(),
+			b.pathEncode(),
+			b.pathDecode(),
 			b.pathDatakey(),
 			b.pathRandom(),
 			b.pathHash(),
 			b.pathHMAC(),
+			b.pathCMAC(),
 			b.pathSign(),
 			b.pathVerify(),
 			b.pathBackup(),
@@ -55,11 +72,16 @@ func Backend(ctx context.Context, conf *logical.BackendConfig) (*backend, error)
 			b.pathCacheConfig(),
 		},
 
-		Secrets:     []*framework.Secret{},
-		Invalidate:  b.invalidate,
-		BackendType: logical.TypeLogical,
+		Secrets:      []*framework.Secret{},
+		Invalidate:   b.invalidate,
+		Clean:        b.stopRewrapJobs,
+		BackendType:  logical.TypeLogical,
+		PeriodicFunc: b.periodicFunc,
 	}
 
+	b.rewrapJobs = make(map[string]*rewrapJobRunner)
+	b.pendingContexts = make(map[string]map[string]struct{})
+
 	// determine cacheSize to use. Defaults to 0 which means unlimited
 	cacheSize := 0
 	useCache := !conf.System.CachingDisabled()
@@ -76,6 +98,7 @@ func Backend(ctx context.Context, conf *logical.BackendConfig) (*backend, error)
 	if err != nil {
 		return nil, err
 	}
+	b.lm.SetContextObserver(b.observeContext)
 
 	return &b, nil
 }
@@ -83,6 +106,21 @@ func Backend(ctx context.Context, conf *logical.BackendConfig) (*backend, error)
 type backend struct {
 	*framework.Backend
 	lm *keysutil.LockManager
+
+	// wrappingKeyLock serializes the creation of the key used to wrap keys
+	// for import
+	wrappingKeyLock sync.Mutex
+
+	// rewrapJobs holds the rewrap jobs being processed by this node, keyed
+	// by job ID
+	rewrapJobsLock sync.Mutex
+	rewrapJobs     map[string]*rewrapJobRunner
+
+	// pendingContexts holds the hashes of the contexts observed for keys
+	// tracking them, keyed by key name, until they are written to the
+	// context registry. At most maxTrackedContexts are held per key.
+	contextsLock    sync.Mutex
+	pendingContexts map[string]map[string]struct{}
 }
 
 func GetCacheSizeFromStorage(ctx context.Context, s logical.Storage) (int, error) {
@@ -111,3 +149,26 @@ func (b *backend) invalidate(_ context.Context, key string) {
 		b.lm.InvalidatePolicy(name)
 	}
 }
+
+func (b *backend) periodicFunc(ctx context.Context, req *logical.Request) error {
+	// Keys are replicated, so only the active node of the primary cluster
+	// rotates them, rewraps ciphertexts and records derivation contexts,
+	// except for local mounts which every cluster maintains on its own
+	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby | consts.ReplicationDRSecondary) {
+		return nil
+	}
+	if !b.System().LocalMount() && b.System().ReplicationState().HasState(consts.ReplicationPerformanceSecondary) {
+		return nil
+	}
+
+	if err := b.autoRotateKeys(ctx, req); err != nil {
+		return errwrap.Wrapf("error automatically rotating keys: {{err}}", err)
+	}
+	if err := b.resumeRewrapJobs(ctx, req); err != nil {
+		return errwrap.Wrapf("error resuming rewrap jobs: {{err}}", err)
+	}
+	if err := b.flushContexts(ctx, req.Storage, ""); err != nil {
+		return errwrap.Wrapf("error recording derivation contexts: {{err}}", err)
+	}
+	return nil
+}
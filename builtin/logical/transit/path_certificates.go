@@ -0,0 +1,231 @@
+package transit
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func (b *backend) pathCreateCSR() *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("name") + "/csr",
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+
+			"version": {
+				Type:        framework.TypeInt,
+				Description: "Version of the key to create the CSR for. Defaults to the latest version.",
+			},
+
+			"csr": {
+				Type: framework.TypeString,
+				Description: `PEM-encoded CSR used as a template. Its subject,
+subject alternative names and extensions are copied
+to the generated CSR. If not set, the CSR has an
+empty subject.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathCreateCSRWrite,
+		},
+
+		HelpSynopsis:    pathCreateCSRHelpSyn,
+		HelpDescription: pathCreateCSRHelpDesc,
+	}
+}
+
+func (b *backend) pathSetCertificate() *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("name") + "/set-certificate",
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+
+			"version": {
+				Type:        framework.TypeInt,
+				Description: "Version of the key the certificate was issued for. Defaults to the latest version.",
+			},
+
+			"certificate_chain": {
+				Type: framework.TypeString,
+				Description: `PEM-encoded certificate chain, starting with the
+certificate issued for the key, each certificate
+being signed by the next one.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathSetCertificateWrite,
+		},
+
+		HelpSynopsis:    pathSetCertificateHelpSyn,
+		HelpDescription: pathSetCertificateHelpDesc,
+	}
+}
+
+func (b *backend) pathCreateCSRWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	var template *x509.CertificateRequest
+	if csrPEM := d.Get("csr").(string); csrPEM != "" {
+		block, _ := pem.Decode([]byte(csrPEM))
+		if block == nil || block.Type != "CERTIFICATE REQUEST" {
+			return logical.ErrorResponse("csr must be a PEM-encoded CERTIFICATE REQUEST"), logical.ErrInvalidRequest
+		}
+		var err error
+		template, err = x509.ParseCertificateRequest(block.Bytes)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("failed to parse csr: %v", err)), logical.ErrInvalidRequest
+		}
+	}
+
+	p, _, err := b.lm.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: req.Storage,
+		Name:    name,
+	}, b.GetRandomReader())
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("key not found"), logical.ErrInvalidRequest
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(false)
+	}
+	defer p.Unlock()
+
+	if err := p.CheckOperation(keysutil.KeyOperationSign); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	csr, err := p.CreateCSR(d.Get("version").(int), template)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		default:
+			return nil, err
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"name": p.Name,
+			"type": p.Type.String(),
+			"csr": string(pem.EncodeToMemory(&pem.Block{
+				Type:  "CERTIFICATE REQUEST",
+				Bytes: csr,
+			})),
+		},
+	}, nil
+}
+
+func (b *backend) pathSetCertificateWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	chain, err := parseCertificateChain(d.Get("certificate_chain").(string))
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	p, _, err := b.lm.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: req.Storage,
+		Name:    name,
+	}, b.GetRandomReader())
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("key not found"), logical.ErrInvalidRequest
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(true)
+	}
+	defer p.Unlock()
+
+	err = p.SetCertificateChain(ctx, req.Storage, d.Get("version").(int), chain)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		default:
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+// parseCertificateChain parses a sequence of PEM-encoded certificates
+func parseCertificateChain(chainPEM string) ([]*x509.Certificate, error) {
+	var chain []*x509.Certificate
+	rest := []byte(chainPEM)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected PEM block of type %q in certificate chain", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate %d of the chain: %v", len(chain), err)
+		}
+		chain = append(chain, cert)
+	}
+
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("missing PEM-encoded certificate_chain")
+	}
+	if len(strings.TrimSpace(string(rest))) != 0 {
+		return nil, fmt.Errorf("certificate_chain contains data that is not PEM-encoded")
+	}
+	return chain, nil
+}
+
+// encodeCertificateChain returns the PEM encoding of a stored certificate
+// chain
+func encodeCertificateChain(chain [][]byte) string {
+	var out strings.Builder
+	for _, der := range chain {
+		out.Write(pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: der,
+		}))
+	}
+	return out.String()
+}
+
+const pathCreateCSRHelpSyn = `Create a CSR signed by the named key`
+
+const pathCreateCSRHelpDesc = `
+This path creates a PEM-encoded certificate signing request for a
+version of the named ECDSA, Ed25519 or RSA key, signed with its private
+key, so that a certificate can be issued for the key without exporting
+it. The subject, subject alternative names and extensions can be given
+with a template CSR.
+`
+
+const pathSetCertificateHelpSyn = `Set the certificate chain of the named key`
+
+const pathSetCertificateHelpDesc = `
+This path stores the certificate chain issued for a version of the
+named key, starting with the certificate of the key itself. The chain
+is returned along with the public key when reading the key, to be used
+with standard tooling such as code-signing tools.
+`
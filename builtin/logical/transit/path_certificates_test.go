@@ -0,0 +1,170 @@
+package transit
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestTransit_Certificates(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	request := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		var op logical.Operation = logical.UpdateOperation
+		if data == nil {
+			op = logical.ReadOperation
+		}
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: op,
+			Path:      path,
+			Data:      data,
+		})
+	}
+	mustRequest := func(path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := request(path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	// A CA to issue the certificates
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}))
+
+	issue := func(csr *x509.CertificateRequest) string {
+		t.Helper()
+		der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      csr.Subject,
+			DNSNames:     csr.DNSNames,
+			NotBefore:    time.Now().Add(-time.Minute),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		}, caCert, csr.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	}
+
+	templateDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "Code Signing", Organization: []string{"Example"}},
+		DNSNames: []string{"signing.example.com"},
+	}, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	templatePEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: templateDER}))
+
+	for _, keyType := range []string{"ecdsa-p256", "ecdsa-p521", "ed25519", "rsa-2048"} {
+		t.Run(keyType, func(t *testing.T) {
+			mustRequest("keys/"+keyType, map[string]interface{}{
+				"type": keyType,
+			})
+			mustRequest("keys/"+keyType+"/rotate", map[string]interface{}{})
+
+			resp := mustRequest("keys/"+keyType+"/csr", map[string]interface{}{
+				"csr": templatePEM,
+			})
+			block, _ := pem.Decode([]byte(resp.Data["csr"].(string)))
+			if block == nil {
+				t.Fatalf("bad csr: %#v", resp.Data)
+			}
+			csr, err := x509.ParseCertificateRequest(block.Bytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := csr.CheckSignature(); err != nil {
+				t.Fatalf("bad csr signature: %v", err)
+			}
+			if csr.Subject.CommonName != "Code Signing" || len(csr.DNSNames) != 1 || csr.DNSNames[0] != "signing.example.com" {
+				t.Fatalf("template not applied: %#v %#v", csr.Subject, csr.DNSNames)
+			}
+
+			// The CSR of the first version has a different key, so its
+			// certificate cannot be set for the latest version
+			resp = mustRequest("keys/"+keyType+"/csr", map[string]interface{}{
+				"version": 1,
+			})
+			block, _ = pem.Decode([]byte(resp.Data["csr"].(string)))
+			oldCSR, err := x509.ParseCertificateRequest(block.Bytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err = request("keys/"+keyType+"/set-certificate", map[string]interface{}{
+				"certificate_chain": issue(oldCSR) + caPEM,
+			})
+			if err == nil && (resp == nil || !resp.IsError()) {
+				t.Fatalf("expected error setting certificate of another version, got %#v", resp)
+			}
+
+			// The chain must be in order
+			leafPEM := issue(csr)
+			resp, err = request("keys/"+keyType+"/set-certificate", map[string]interface{}{
+				"certificate_chain": caPEM + leafPEM,
+			})
+			if err == nil && (resp == nil || !resp.IsError()) {
+				t.Fatalf("expected error setting unordered chain, got %#v", resp)
+			}
+
+			mustRequest("keys/"+keyType+"/set-certificate", map[string]interface{}{
+				"certificate_chain": leafPEM + caPEM,
+			})
+
+			resp = mustRequest("keys/"+keyType, nil)
+			keys := resp.Data["keys"].(map[string]map[string]interface{})
+			if keys["2"]["certificate_chain"] != leafPEM+caPEM {
+				t.Fatalf("bad certificate chain: %#v", keys["2"])
+			}
+			if _, ok := keys["1"]["certificate_chain"]; ok {
+				t.Fatalf("unexpected certificate chain for version 1: %#v", keys["1"])
+			}
+		})
+	}
+
+	// Symmetric and derived keys are not supported
+	mustRequest("keys/aes", map[string]interface{}{})
+	mustRequest("keys/derived", map[string]interface{}{
+		"type":    "ed25519",
+		"derived": true,
+	})
+	for _, name := range []string{"aes", "derived"} {
+		resp, err := request("keys/"+name+"/csr", map[string]interface{}{})
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("expected error creating csr for %s key, got %#v", name, resp)
+		}
+	}
+}
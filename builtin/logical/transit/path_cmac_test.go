@@ -0,0 +1,144 @@
+package transit
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestTransit_CMAC(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	doRequest := func(op logical.Operation, path string, data map[string]interface{}, errExpected bool) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: op,
+			Path:      path,
+			Data:      data,
+		})
+		if errExpected {
+			if err == nil && (resp == nil || !resp.IsError()) {
+				t.Fatalf("%s: expected error, got %#v", path, resp)
+			}
+			return resp
+		}
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	input := base64.StdEncoding.EncodeToString([]byte("the quick brown fox"))
+
+	for _, keyType := range []string{"aes128-cmac", "aes256-cmac"} {
+		doRequest(logical.UpdateOperation, "keys/"+keyType, map[string]interface{}{
+			"type":       keyType,
+			"exportable": true,
+		}, false)
+
+		resp := doRequest(logical.ReadOperation, "keys/"+keyType, nil, false)
+		if resp.Data["type"] != keyType || resp.Data["supports_encryption"].(bool) || resp.Data["supports_signing"].(bool) {
+			t.Fatalf("bad key: %#v", resp.Data)
+		}
+
+		// The CMAC must match one computed from the exported key
+		resp = doRequest(logical.ReadOperation, "export/cmac-key/"+keyType+"/1", nil, false)
+		key, err := base64.StdEncoding.DecodeString(resp.Data["keys"].(map[string]string)["1"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (keyType == "aes128-cmac" && len(key) != 16) || (keyType == "aes256-cmac" && len(key) != 32) {
+			t.Fatalf("bad key length %d for %s", len(key), keyType)
+		}
+		expected, err := keysutil.AESCMAC(key, []byte("the quick brown fox"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp = doRequest(logical.UpdateOperation, "cmac/"+keyType, map[string]interface{}{
+			"input": input,
+		}, false)
+		cmacV1 := resp.Data["cmac"].(string)
+		if cmacV1 != "vault:v1:"+base64.StdEncoding.EncodeToString(expected) {
+			t.Fatalf("bad cmac: %s", cmacV1)
+		}
+
+		resp = doRequest(logical.UpdateOperation, "verify/"+keyType, map[string]interface{}{
+			"input": input,
+			"cmac":  cmacV1,
+		}, false)
+		if !resp.Data["valid"].(bool) {
+			t.Fatalf("expected valid cmac: %#v", resp.Data)
+		}
+
+		resp = doRequest(logical.UpdateOperation, "verify/"+keyType, map[string]interface{}{
+			"input": base64.StdEncoding.EncodeToString([]byte("the quick brown dog")),
+			"cmac":  cmacV1,
+		}, false)
+		if resp.Data["valid"].(bool) {
+			t.Fatalf("expected invalid cmac: %#v", resp.Data)
+		}
+
+		// Rotation generates a new key while older versions still verify
+		doRequest(logical.UpdateOperation, "keys/"+keyType+"/rotate", nil, false)
+		resp = doRequest(logical.UpdateOperation, "cmac/"+keyType, map[string]interface{}{
+			"batch_input": []interface{}{
+				map[string]interface{}{"input": input},
+				map[string]interface{}{"input": "not base64"},
+			},
+		}, false)
+		results := resp.Data["batch_results"].([]batchResponseCMACItem)
+		if results[0].CMAC == "" || results[0].CMAC[:9] != "vault:v2:" || results[0].CMAC == cmacV1 || results[1].Error == "" {
+			t.Fatalf("bad batch results: %#v", results)
+		}
+
+		resp = doRequest(logical.UpdateOperation, "verify/"+keyType, map[string]interface{}{
+			"batch_input": []interface{}{
+				map[string]interface{}{"input": input, "cmac": cmacV1},
+				map[string]interface{}{"input": input, "cmac": results[0].CMAC},
+			},
+		}, false)
+		verifyResults := resp.Data["batch_results"].([]batchResponseCMACItem)
+		if !verifyResults[0].Valid || !verifyResults[1].Valid {
+			t.Fatalf("bad batch verify results: %#v", verifyResults)
+		}
+
+		// CMAC keys cannot be used for encryption, and other exports are refused
+		doRequest(logical.UpdateOperation, "encrypt/"+keyType, map[string]interface{}{
+			"plaintext": input,
+		}, true)
+		doRequest(logical.ReadOperation, "export/encryption-key/"+keyType, nil, true)
+	}
+
+	// Mixing verification types is not allowed
+	doRequest(logical.UpdateOperation, "verify/aes128-cmac", map[string]interface{}{
+		"batch_input": []interface{}{
+			map[string]interface{}{"input": input, "cmac": "vault:v1:AAAA"},
+			map[string]interface{}{"input": input, "hmac": "vault:v1:AAAA"},
+		},
+	}, true)
+
+	// Other key types do not support CMAC
+	doRequest(logical.UpdateOperation, "keys/aes", map[string]interface{}{
+		"exportable": true,
+	}, false)
+	doRequest(logical.UpdateOperation, "cmac/aes", map[string]interface{}{
+		"input": input,
+	}, true)
+	doRequest(logical.ReadOperation, "export/cmac-key/aes", nil, true)
+	doRequest(logical.UpdateOperation, "verify/aes", map[string]interface{}{
+		"input": input,
+		"cmac":  fmt.Sprintf("vault:v1:%s", base64.StdEncoding.EncodeToString(make([]byte, 16))),
+	}, true)
+
+	// Derivation is not supported
+	doRequest(logical.UpdateOperation, "keys/derived-cmac", map[string]interface{}{
+		"type":    "aes256-cmac",
+		"derived": true,
+	}, true)
+}
@@ -2,10 +2,14 @@ package transit
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -47,6 +51,45 @@ the latest version of the key is allowed.`,
 				Type:        framework.TypeBool,
 				Description: `Enables taking a backup of the named key in plaintext format. Once set, this cannot be disabled.`,
 			},
+
+			"auto_rotate_period": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `Amount of time the key should live before
+being automatically rotated. A value of 0
+(default) disables automatic rotation for the
+key. Must be at least one hour.`,
+			},
+
+			"allowed_operations": &framework.FieldSchema{
+				Type: framework.TypeCommaStringSlice,
+				Description: `Operations the key may be used for, among
+"encrypt", "decrypt", "sign", "verify", "hmac",
+"rewrap" and "datakey". If empty (default), the key
+may be used for every operation its type supports.`,
+			},
+
+			"allowed_contexts": &framework.FieldSchema{
+				Type: framework.TypeCommaStringSlice,
+				Description: `Base64 encoded contexts keys may be derived
+with. Only valid for derived keys. If neither this
+nor allowed_context_regex is set, any context is
+allowed.`,
+			},
+
+			"allowed_context_regex": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Regular expression that contexts not listed
+in allowed_contexts must match in their entirety.
+Only valid for derived keys.`,
+			},
+
+			"track_contexts": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Whether to record the distinct contexts keys
+are derived with, to be counted by the
+keys/<name>/contexts path. Only valid for derived
+keys.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -84,6 +127,11 @@ func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, d *
 	originalDeletionAllowed := p.DeletionAllowed
 	originalExportable := p.Exportable
 	originalAllowPlaintextBackup := p.AllowPlaintextBackup
+	originalAutoRotatePeriod := p.AutoRotatePeriod
+	originalAllowedOperations := p.AllowedOperations
+	originalAllowedContexts := p.AllowedContexts
+	originalAllowedContextRegex := p.AllowedContextRegex
+	originalTrackContexts := p.TrackContexts
 
 	defer func() {
 		if retErr != nil || (resp != nil && resp.IsError()) {
@@ -92,6 +140,11 @@ func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, d *
 			p.DeletionAllowed = originalDeletionAllowed
 			p.Exportable = originalExportable
 			p.AllowPlaintextBackup = originalAllowPlaintextBackup
+			p.AutoRotatePeriod = originalAutoRotatePeriod
+			p.AllowedOperations = originalAllowedOperations
+			p.AllowedContexts = originalAllowedContexts
+			p.AllowedContextRegex = originalAllowedContextRegex
+			p.TrackContexts = originalTrackContexts
 		}
 	}()
 
@@ -185,6 +238,87 @@ func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, d *
 		}
 	}
 
+	autoRotatePeriodRaw, ok := d.GetOk("auto_rotate_period")
+	if ok {
+		autoRotatePeriod := time.Second * time.Duration(autoRotatePeriodRaw.(int))
+		// Provided value must be 0 to disable or at least an hour
+		if autoRotatePeriod != 0 && autoRotatePeriod < time.Hour {
+			return logical.ErrorResponse("auto rotate period must be 0 to disable or at least an hour"), nil
+		}
+
+		if autoRotatePeriod != p.AutoRotatePeriod {
+			p.AutoRotatePeriod = autoRotatePeriod
+			persistNeeded = true
+		}
+	}
+
+	allowedOperationsRaw, ok := d.GetOk("allowed_operations")
+	if ok {
+		allowedOperations := strutil.RemoveDuplicates(allowedOperationsRaw.([]string), true)
+		for _, op := range allowedOperations {
+			if !strutil.StrListContains(keysutil.KeyOperations, op) {
+				return logical.ErrorResponse(fmt.Sprintf("unknown operation %q; allowed operations must be among %s", op, strings.Join(keysutil.KeyOperations, ", "))), nil
+			}
+		}
+
+		if !strutil.EquivalentSlices(allowedOperations, p.AllowedOperations) {
+			p.AllowedOperations = allowedOperations
+			persistNeeded = true
+		}
+	}
+
+	allowedContextsRaw, ok := d.GetOk("allowed_contexts")
+	if ok {
+		if !p.Derived {
+			return logical.ErrorResponse("allowed contexts can only be set for derived keys"), nil
+		}
+
+		// Normalize the encoding so contexts can be compared as strings
+		var allowedContexts []string
+		for _, encoded := range allowedContextsRaw.([]string) {
+			decoded, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil || len(decoded) == 0 {
+				return logical.ErrorResponse(fmt.Sprintf("allowed context %q is not a non-empty base64 encoded value", encoded)), nil
+			}
+			allowedContexts = append(allowedContexts, base64.StdEncoding.EncodeToString(decoded))
+		}
+		allowedContexts = strutil.RemoveDuplicates(allowedContexts, false)
+
+		if !strutil.EquivalentSlices(allowedContexts, p.AllowedContexts) {
+			p.AllowedContexts = allowedContexts
+			persistNeeded = true
+		}
+	}
+
+	allowedContextRegexRaw, ok := d.GetOk("allowed_context_regex")
+	if ok {
+		allowedContextRegex := allowedContextRegexRaw.(string)
+		if !p.Derived {
+			return logical.ErrorResponse("allowed context regex can only be set for derived keys"), nil
+		}
+		if _, err := keysutil.CompileContextRegex(allowedContextRegex); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid allowed context regex: %v", err)), nil
+		}
+
+		if allowedContextRegex != p.AllowedContextRegex {
+			p.AllowedContextRegex = allowedContextRegex
+			persistNeeded = true
+		}
+	}
+
+	trackContextsRaw, ok := d.GetOk("track_contexts")
+	if ok {
+		trackContexts := trackContextsRaw.(bool)
+		if trackContexts && !p.Derived {
+			return logical.ErrorResponse("contexts can only be tracked for derived keys"), nil
+		}
+
+		if trackContexts != p.TrackContexts {
+			p.TrackContexts = trackContexts
+			persistNeeded = true
+		}
+	}
+
 	if !persistNeeded {
 		return nil, nil
 	}
@@ -208,5 +342,11 @@ const pathConfigHelpSyn = `Configure a named encryption key`
 const pathConfigHelpDesc = `
 This path is used to configure the named key. Currently, this
 supports adjusting the minimum version of the key allowed to
-be used for decryption via the min_decryption_version parameter.
+be used for decryption via the min_decryption_version parameter,
+automatically rotating the key via the auto_rotate_period
+parameter, restricting the operations the key may be used for
+via the allowed_operations parameter, and restricting and
+tracking the contexts derived keys are used with via the
+allowed_contexts, allowed_context_regex and track_contexts
+parameters.
 `
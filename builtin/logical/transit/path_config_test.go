@@ -2,10 +2,13 @@ package transit
 
 import (
 	"context"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -283,3 +286,216 @@ func TestTransit_ConfigSettings(t *testing.T) {
 	testHMAC(3, true)
 	testHMAC(2, false)
 }
+
+func TestTransit_AutoRotate(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: op,
+			Path:      path,
+			Data:      data,
+		})
+	}
+
+	for _, name := range []string{"rotated", "manual"} {
+		resp, err := request(logical.UpdateOperation, "keys/"+name, nil)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err: %v resp: %#v", err, resp)
+		}
+	}
+
+	// Periods under an hour are rejected
+	resp, err := request(logical.UpdateOperation, "keys/rotated/config", map[string]interface{}{
+		"auto_rotate_period": "10m",
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got err: %v resp: %#v", err, resp)
+	}
+
+	resp, err = request(logical.UpdateOperation, "keys/rotated/config", map[string]interface{}{
+		"auto_rotate_period": "24h",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v resp: %#v", err, resp)
+	}
+	resp, err = request(logical.ReadOperation, "keys/rotated", nil)
+	if err != nil || resp == nil {
+		t.Fatalf("err: %v resp: %#v", err, resp)
+	}
+	if resp.Data["auto_rotate_period"] != int64(86400) {
+		t.Fatalf("bad auto_rotate_period: %v", resp.Data["auto_rotate_period"])
+	}
+	lastRotated := resp.Data["last_rotation_time"].(time.Time)
+	if time.Since(lastRotated) > time.Minute {
+		t.Fatalf("bad last_rotation_time: %v", lastRotated)
+	}
+
+	periodic := func() {
+		t.Helper()
+		if err := b.periodicFunc(context.Background(), &logical.Request{Storage: storage}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	latestVersion := func(name string) int {
+		t.Helper()
+		resp, err := request(logical.ReadOperation, "keys/"+name, nil)
+		if err != nil || resp == nil {
+			t.Fatalf("err: %v resp: %#v", err, resp)
+		}
+		return resp.Data["latest_version"].(int)
+	}
+
+	// Nothing is rotated before the period has elapsed
+	periodic()
+	if latestVersion("rotated") != 1 || latestVersion("manual") != 1 {
+		t.Fatal("expected no keys to be rotated")
+	}
+
+	// Age both keys beyond the period; only the one configured for
+	// automatic rotation is rotated
+	for _, name := range []string{"rotated", "manual"} {
+		p, _, err := b.lm.GetPolicy(context.Background(), keysutil.PolicyRequest{
+			Storage: storage,
+			Name:    name,
+		}, b.GetRandomReader())
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry := p.Keys["1"]
+		entry.CreationTime = time.Now().Add(-48 * time.Hour)
+		p.Keys["1"] = entry
+		if err := p.Persist(context.Background(), storage); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	periodic()
+	if latestVersion("rotated") != 2 || latestVersion("manual") != 1 {
+		t.Fatal("expected only the key with an auto_rotate_period to be rotated")
+	}
+
+	// The new version resets the clock
+	periodic()
+	if latestVersion("rotated") != 2 {
+		t.Fatal("expected key not to be rotated again")
+	}
+
+	// Setting the period to zero disables automatic rotation
+	resp, err = request(logical.UpdateOperation, "keys/rotated/config", map[string]interface{}{
+		"auto_rotate_period": 0,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v resp: %#v", err, resp)
+	}
+	resp, err = request(logical.ReadOperation, "keys/rotated", nil)
+	if err != nil || resp == nil || resp.Data["auto_rotate_period"] != int64(0) {
+		t.Fatalf("err: %v resp: %#v", err, resp)
+	}
+}
+
+func TestTransit_AllowedOperations(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: op,
+			Path:      path,
+			Data:      data,
+		})
+	}
+	allowed := func(path string, data map[string]interface{}) bool {
+		t.Helper()
+		resp, err := request(logical.UpdateOperation, path, data)
+		if err == nil && resp != nil && resp.IsError() {
+			t.Fatalf("%s: unexpected error response without error: %#v", path, resp)
+		}
+		if err != nil && (resp == nil || !strings.Contains(resp.Error().Error(), "is not allowed for key")) {
+			t.Fatalf("%s: err: %v resp: %#v", path, err, resp)
+		}
+		return err == nil
+	}
+
+	for _, keyType := range []string{"aes256-gcm96", "ecdsa-p256"} {
+		resp, err := request(logical.UpdateOperation, "keys/"+keyType, map[string]interface{}{
+			"type": keyType,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err: %v resp: %#v", err, resp)
+		}
+	}
+
+	plaintext := "dGhlIHF1aWNrIGJyb3duIGZveA=="
+	resp, err := request(logical.UpdateOperation, "encrypt/aes256-gcm96", map[string]interface{}{
+		"plaintext": plaintext,
+	})
+	if err != nil || resp.IsError() {
+		t.Fatalf("err: %v resp: %#v", err, resp)
+	}
+	ciphertext := resp.Data["ciphertext"].(string)
+	resp, err = request(logical.UpdateOperation, "sign/ecdsa-p256", map[string]interface{}{
+		"input": plaintext,
+	})
+	if err != nil || resp.IsError() {
+		t.Fatalf("err: %v resp: %#v", err, resp)
+	}
+	signature := resp.Data["signature"].(string)
+
+	// Unknown operations are rejected
+	resp, err = request(logical.UpdateOperation, "keys/aes256-gcm96/config", map[string]interface{}{
+		"allowed_operations": "encrypt,export",
+	})
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatalf("expected error for unknown operation, got %#v", resp)
+	}
+
+	// Restrict the keys to decryption and verification
+	for _, config := range []struct{ name, ops string }{
+		{"aes256-gcm96", "decrypt"},
+		{"ecdsa-p256", "verify"},
+	} {
+		resp, err = request(logical.UpdateOperation, "keys/"+config.name+"/config", map[string]interface{}{
+			"allowed_operations": config.ops,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err: %v resp: %#v", err, resp)
+		}
+	}
+	resp, err = request(logical.ReadOperation, "keys/aes256-gcm96", nil)
+	if err != nil || resp == nil || !reflect.DeepEqual(resp.Data["allowed_operations"], []string{"decrypt"}) {
+		t.Fatalf("err: %v resp: %#v", err, resp)
+	}
+
+	checks := []struct {
+		path    string
+		data    map[string]interface{}
+		allowed bool
+	}{
+		{"decrypt/aes256-gcm96", map[string]interface{}{"ciphertext": ciphertext}, true},
+		{"encrypt/aes256-gcm96", map[string]interface{}{"plaintext": plaintext}, false},
+		{"rewrap/aes256-gcm96", map[string]interface{}{"ciphertext": ciphertext}, false},
+		{"datakey/plaintext/aes256-gcm96", map[string]interface{}{}, false},
+		{"hmac/aes256-gcm96", map[string]interface{}{"input": plaintext}, false},
+		{"verify/ecdsa-p256", map[string]interface{}{"input": plaintext, "signature": signature}, true},
+		{"sign/ecdsa-p256", map[string]interface{}{"input": plaintext}, false},
+		{"keys/ecdsa-p256/csr", map[string]interface{}{}, false},
+	}
+	for _, check := range checks {
+		if allowed(check.path, check.data) != check.allowed {
+			t.Fatalf("%s: expected allowed to be %v", check.path, check.allowed)
+		}
+	}
+
+	// Clearing the list allows every operation again
+	resp, err = request(logical.UpdateOperation, "keys/aes256-gcm96/config", map[string]interface{}{
+		"allowed_operations": "",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v resp: %#v", err, resp)
+	}
+	if !allowed("encrypt/aes256-gcm96", map[string]interface{}{"plaintext": plaintext}) {
+		t.Fatal("expected encryption to be allowed")
+	}
+}
@@ -0,0 +1,190 @@
+package transit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// contextRegistryPrefix is where the contexts keys have been derived with are
+// recorded. Only the SHA-256 hash of each context is stored.
+const contextRegistryPrefix = "context-registry/"
+
+// maxTrackedContexts is the number of distinct contexts recorded per key.
+// Once reached, further contexts are no longer recorded until the registry is
+// reset, which bounds both the registry and the contexts pending a flush.
+var maxTrackedContexts = 10000
+
+func (b *backend) pathContexts() *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("name") + "/contexts",
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathContextsRead,
+			logical.DeleteOperation: b.pathContextsDelete,
+		},
+
+		HelpSynopsis:    pathContextsHelpSyn,
+		HelpDescription: pathContextsHelpDesc,
+	}
+}
+
+func (b *backend) pathContextsRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	p, _, err := b.lm.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: req.Storage,
+		Name:    name,
+	}, b.GetRandomReader())
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, nil
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(false)
+	}
+	derived, trackContexts := p.Derived, p.TrackContexts
+	allowedContexts, allowedContextRegex := p.AllowedContexts, p.AllowedContextRegex
+	p.Unlock()
+
+	if !derived {
+		return logical.ErrorResponse("key does not use derivation"), logical.ErrInvalidRequest
+	}
+
+	if err := b.flushContexts(ctx, req.Storage, name); err != nil {
+		return nil, err
+	}
+	hashes, err := req.Storage.List(ctx, contextRegistryPrefix+name+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	if allowedContexts == nil {
+		allowedContexts = []string{}
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"track_contexts":         trackContexts,
+			"allowed_contexts":       allowedContexts,
+			"allowed_context_regex":  allowedContextRegex,
+			"distinct_contexts":      len(hashes),
+			"contexts_limit_reached": len(hashes) >= maxTrackedContexts,
+		},
+	}, nil
+}
+
+func (b *backend) pathContextsDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	return nil, b.clearContexts(ctx, req.Storage, d.Get("name").(string))
+}
+
+// observeContext records that a key of the named policy was derived with the
+// given context. Contexts are written to storage by flushContexts, which only
+// runs on the active node, so contexts used on performance standbys are not
+// recorded.
+func (b *backend) observeContext(name string, context []byte) {
+	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby | consts.ReplicationPerformanceSecondary | consts.ReplicationDRSecondary) {
+		return
+	}
+
+	hash := sha256.Sum256(context)
+	b.observeContextHash(name, hex.EncodeToString(hash[:]))
+}
+
+// flushContexts writes the contexts observed for the named policy, or for all
+// policies if name is empty, to the registry
+func (b *backend) flushContexts(ctx context.Context, s logical.Storage, name string) error {
+	b.contextsLock.Lock()
+	pending := make(map[string]map[string]struct{})
+	for pendingName, hashes := range b.pendingContexts {
+		if name == "" || pendingName == name {
+			pending[pendingName] = hashes
+			delete(b.pendingContexts, pendingName)
+		}
+	}
+	b.contextsLock.Unlock()
+
+	var result error
+	for pendingName, hashes := range pending {
+		// Only write the contexts not recorded yet, up to the limit
+		recorded, err := s.List(ctx, contextRegistryPrefix+pendingName+"/")
+		if err != nil {
+			result = multierror.Append(result, err)
+			for hash := range hashes {
+				b.observeContextHash(pendingName, hash)
+			}
+			continue
+		}
+		count := len(recorded)
+		for _, hash := range recorded {
+			delete(hashes, hash)
+		}
+
+		for hash := range hashes {
+			if count >= maxTrackedContexts {
+				break
+			}
+			err := s.Put(ctx, &logical.StorageEntry{
+				Key:   contextRegistryPrefix + pendingName + "/" + hash,
+				Value: []byte{},
+			})
+			if err != nil {
+				result = multierror.Append(result, err)
+
+				// Keep the context to try again on the next flush
+				b.observeContextHash(pendingName, hash)
+				continue
+			}
+			count++
+		}
+	}
+
+	return result
+}
+
+func (b *backend) observeContextHash(name, hash string) {
+	b.contextsLock.Lock()
+	defer b.contextsLock.Unlock()
+
+	hashes, ok := b.pendingContexts[name]
+	if !ok {
+		hashes = make(map[string]struct{})
+		b.pendingContexts[name] = hashes
+	}
+	if len(hashes) >= maxTrackedContexts {
+		return
+	}
+	hashes[hash] = struct{}{}
+}
+
+// clearContexts removes the registry of the named policy
+func (b *backend) clearContexts(ctx context.Context, s logical.Storage, name string) error {
+	b.contextsLock.Lock()
+	delete(b.pendingContexts, name)
+	b.contextsLock.Unlock()
+
+	return logical.ClearView(ctx, logical.NewStorageView(s, contextRegistryPrefix+name+"/"))
+}
+
+const pathContextsHelpSyn = `Read or reset the registry of contexts used with the named derived key`
+
+const pathContextsHelpDesc = `
+Reading this path returns the context restrictions of the named derived
+key and, if context tracking is enabled in the key's configuration, the
+number of distinct contexts keys have been derived with. Only hashes of
+the contexts are recorded, up to 10000 per key. Deleting this path resets
+the count.
+`
@@ -0,0 +1,173 @@
+package transit
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestTransit_ContextRegistry(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: op,
+			Path:      path,
+			Data:      data,
+		})
+	}
+	mustRequest := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := request(op, path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+	mustFail := func(op logical.Operation, path string, data map[string]interface{}) {
+		t.Helper()
+		resp, err := request(op, path, data)
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("%s: expected error, got %#v", path, resp)
+		}
+	}
+	encode := func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+	plaintext := encode("the quick brown fox")
+
+	mustRequest(logical.UpdateOperation, "keys/plain", nil)
+	mustRequest(logical.UpdateOperation, "keys/tenants", map[string]interface{}{
+		"derived": true,
+	})
+
+	// Restrictions only apply to derived keys, and must be valid
+	mustFail(logical.UpdateOperation, "keys/plain/config", map[string]interface{}{
+		"track_contexts": true,
+	})
+	mustFail(logical.UpdateOperation, "keys/tenants/config", map[string]interface{}{
+		"allowed_context_regex": "tenant-[",
+	})
+	mustFail(logical.UpdateOperation, "keys/tenants/config", map[string]interface{}{
+		"allowed_contexts": "not base64!",
+	})
+
+	// Before restricting the key any context can be used
+	resp := mustRequest(logical.UpdateOperation, "encrypt/tenants", map[string]interface{}{
+		"plaintext": plaintext,
+		"context":   encode("tenant-b"),
+	})
+	tenantBCiphertext := resp.Data["ciphertext"].(string)
+
+	mustRequest(logical.UpdateOperation, "keys/tenants/config", map[string]interface{}{
+		"allowed_contexts":      encode("tenant-a"),
+		"allowed_context_regex": "tenant-[0-9]+",
+		"track_contexts":        true,
+	})
+	resp = mustRequest(logical.ReadOperation, "keys/tenants", nil)
+	if resp.Data["allowed_context_regex"] != "tenant-[0-9]+" || !resp.Data["track_contexts"].(bool) {
+		t.Fatalf("bad key: %#v", resp.Data)
+	}
+
+	for _, tenant := range []string{"tenant-a", "tenant-42"} {
+		resp = mustRequest(logical.UpdateOperation, "encrypt/tenants", map[string]interface{}{
+			"plaintext": plaintext,
+			"context":   encode(tenant),
+		})
+		mustRequest(logical.UpdateOperation, "decrypt/tenants", map[string]interface{}{
+			"ciphertext": resp.Data["ciphertext"],
+			"context":    encode(tenant),
+		})
+	}
+
+	// Other contexts fail, including partial matches of the regex
+	for _, tenant := range []string{"tenant-b", "tenant-42x", "x-tenant-42"} {
+		mustFail(logical.UpdateOperation, "encrypt/tenants", map[string]interface{}{
+			"plaintext": plaintext,
+			"context":   encode(tenant),
+		})
+	}
+	mustFail(logical.UpdateOperation, "decrypt/tenants", map[string]interface{}{
+		"ciphertext": tenantBCiphertext,
+		"context":    encode("tenant-b"),
+	})
+	mustFail(logical.UpdateOperation, "datakey/plaintext/tenants", map[string]interface{}{
+		"context": encode("tenant-b"),
+	})
+	resp = mustRequest(logical.UpdateOperation, "encrypt/tenants", map[string]interface{}{
+		"batch_input": []interface{}{
+			map[string]interface{}{"plaintext": plaintext, "context": encode("tenant-a")},
+			map[string]interface{}{"plaintext": plaintext, "context": encode("tenant-b")},
+		},
+	})
+	results := resp.Data["batch_results"].([]EncryptBatchResponseItem)
+	if results[0].Error != "" || results[1].Error == "" {
+		t.Fatalf("bad batch results: %#v", results)
+	}
+
+	// The allowed contexts used are counted once each
+	resp = mustRequest(logical.ReadOperation, "keys/tenants/contexts", nil)
+	if resp.Data["distinct_contexts"] != 2 {
+		t.Fatalf("bad contexts: %#v", resp.Data)
+	}
+
+	// Contexts are also written by the periodic function
+	mustRequest(logical.UpdateOperation, "encrypt/tenants", map[string]interface{}{
+		"plaintext": plaintext,
+		"context":   encode("tenant-7"),
+	})
+	if err := b.periodicFunc(context.Background(), &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+	hashes, err := storage.List(context.Background(), contextRegistryPrefix+"tenants/")
+	if err != nil || len(hashes) != 3 {
+		t.Fatalf("expected 3 recorded contexts, got %v %v", hashes, err)
+	}
+
+	mustRequest(logical.DeleteOperation, "keys/tenants/contexts", nil)
+	resp = mustRequest(logical.ReadOperation, "keys/tenants/contexts", nil)
+	if resp.Data["distinct_contexts"] != 0 {
+		t.Fatalf("bad contexts after reset: %#v", resp.Data)
+	}
+
+	// Lifting the restrictions allows any context again
+	mustRequest(logical.UpdateOperation, "keys/tenants/config", map[string]interface{}{
+		"allowed_contexts":      "",
+		"allowed_context_regex": "",
+	})
+	mustRequest(logical.UpdateOperation, "decrypt/tenants", map[string]interface{}{
+		"ciphertext": tenantBCiphertext,
+		"context":    encode("tenant-b"),
+	})
+
+	// Contexts beyond the limit are neither held nor recorded
+	defer func(max int) { maxTrackedContexts = max }(maxTrackedContexts)
+	maxTrackedContexts = 2
+	for _, tenant := range []string{"tenant-a", "tenant-b", "tenant-c", "tenant-d"} {
+		mustRequest(logical.UpdateOperation, "encrypt/tenants", map[string]interface{}{
+			"plaintext": plaintext,
+			"context":   encode(tenant),
+		})
+	}
+	b.contextsLock.Lock()
+	pending := len(b.pendingContexts["tenants"])
+	b.contextsLock.Unlock()
+	if pending != 2 {
+		t.Fatalf("expected 2 pending contexts, got %d", pending)
+	}
+	resp = mustRequest(logical.ReadOperation, "keys/tenants/contexts", nil)
+	if resp.Data["distinct_contexts"] != 2 || !resp.Data["contexts_limit_reached"].(bool) {
+		t.Fatalf("bad contexts at the limit: %#v", resp.Data)
+	}
+	mustRequest(logical.UpdateOperation, "encrypt/tenants", map[string]interface{}{
+		"plaintext": plaintext,
+		"context":   encode("tenant-e"),
+	})
+	resp = mustRequest(logical.ReadOperation, "keys/tenants/contexts", nil)
+	if resp.Data["distinct_contexts"] != 2 {
+		t.Fatalf("bad contexts beyond the limit: %#v", resp.Data)
+	}
+}
@@ -339,6 +339,11 @@ func (b *backend) pathEncryptWrite(ctx context.Context, req *logical.Request, d
 		p.Lock(false)
 	}
 
+	if err := p.CheckOperation(keysutil.KeyOperationEncrypt); err != nil {
+		p.Unlock()
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
 	// Process batch request items. If encryption of any request
 	// item fails, respectively mark the error in the response
 	// collection and continue to process other items.
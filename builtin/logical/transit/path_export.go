@@ -22,6 +22,7 @@ const (
 	exportTypeEncryptionKey = "encryption-key"
 	exportTypeSigningKey    = "signing-key"
 	exportTypeHMACKey       = "hmac-key"
+	exportTypeCMACKey       = "cmac-key"
 )
 
 func (b *backend) pathExportKeys() *framework.Path {
@@ -30,7 +31,7 @@ func (b *backend) pathExportKeys() *framework.Path {
 		Fields: map[string]*framework.FieldSchema{
 			"type": &framework.FieldSchema{
 				Type:        framework.TypeString,
-				Description: "Type of key to export (encryption-key, signing-key, hmac-key)",
+				Description: "Type of key to export (encryption-key, signing-key, hmac-key, cmac-key)",
 			},
 			"name": &framework.FieldSchema{
 				Type:        framework.TypeString,
@@ -60,6 +61,7 @@ func (b *backend) pathPolicyExportRead(ctx context.Context, req *logical.Request
 	case exportTypeEncryptionKey:
 	case exportTypeSigningKey:
 	case exportTypeHMACKey:
+	case exportTypeCMACKey:
 	default:
 		return logical.ErrorResponse(fmt.Sprintf("invalid export type: %s", exportType)), logical.ErrInvalidRequest
 	}
@@ -92,6 +94,10 @@ func (b *backend) pathPolicyExportRead(ctx context.Context, req *logical.Request
 		if !p.Type.SigningSupported() {
 			return logical.ErrorResponse("signing not supported for the key"), logical.ErrInvalidRequest
 		}
+	case exportTypeCMACKey:
+		if !p.Type.CMACSupported() {
+			return logical.ErrorResponse("CMAC not supported for the key"), logical.ErrInvalidRequest
+		}
 	}
 
 	retKeys := map[string]string{}
@@ -151,8 +157,17 @@ func getExportKey(policy *keysutil.Policy, key *keysutil.KeyEntry, exportType st
 
 	switch exportType {
 	case exportTypeHMACKey:
+		if policy.Type == keysutil.KeyType_HMAC {
+			return strings.TrimSpace(base64.StdEncoding.EncodeToString(key.Key)), nil
+		}
 		return strings.TrimSpace(base64.StdEncoding.EncodeToString(key.HMACKey)), nil
 
+	case exportTypeCMACKey:
+		switch policy.Type {
+		case keysutil.KeyType_AES128_CMAC, keysutil.KeyType_AES256_CMAC:
+			return strings.TrimSpace(base64.StdEncoding.EncodeToString(key.Key)), nil
+		}
+
 	case exportTypeEncryptionKey:
 		switch policy.Type {
 		case keysutil.KeyType_AES128_GCM96, keysutil.KeyType_AES256_GCM96, keysutil.KeyType_ChaCha20_Poly1305:
@@ -185,6 +200,13 @@ func getExportKey(policy *keysutil.Policy, key *keysutil.KeyEntry, exportType st
 
 		case keysutil.KeyType_RSA2048, keysutil.KeyType_RSA3072, keysutil.KeyType_RSA4096:
 			return encodeRSAPrivateKey(key.RSAKey), nil
+
+		case keysutil.KeyType_ML_DSA_44, keysutil.KeyType_ML_DSA_65, keysutil.KeyType_ML_DSA_87:
+			return strings.TrimSpace(base64.StdEncoding.EncodeToString(key.Key)), nil
+
+		case keysutil.KeyType_ML_DSA_65_ED25519:
+			// The ML-DSA seed followed by the Ed25519 private key
+			return strings.TrimSpace(base64.StdEncoding.EncodeToString(append(append([]byte{}, key.PQKey...), key.Key...))), nil
 		}
 	}
 
@@ -0,0 +1,323 @@
+package transit
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mitchellh/mapstructure"
+)
+
+// batchRequestFPEItem represents a request item for batch processing
+type batchRequestFPEItem struct {
+	// Value to encode or decode
+	Value string `json:"value" structs:"value" mapstructure:"value"`
+
+	// Tweak is the base64 encoded FF3-1 tweak
+	Tweak string `json:"tweak" structs:"tweak" mapstructure:"tweak"`
+
+	// The key version to be used
+	KeyVersion int `json:"key_version" structs:"key_version" mapstructure:"key_version"`
+}
+
+// batchResponseFPEItem represents a response item for batch processing
+type batchResponseFPEItem struct {
+	// EncodedValue is the result of encoding the corresponding batch request
+	// item
+	EncodedValue string `json:"encoded_value,omitempty" structs:"encoded_value" mapstructure:"encoded_value"`
+
+	// DecodedValue is the result of decoding the corresponding batch request
+	// item
+	DecodedValue string `json:"decoded_value,omitempty" structs:"decoded_value" mapstructure:"decoded_value"`
+
+	// KeyVersion defines the key version used to encode the value
+	KeyVersion int `json:"key_version,omitempty" structs:"key_version" mapstructure:"key_version"`
+
+	// Error, if set represents a failure encountered while processing the
+	// corresponding batch request item
+	Error string `json:"error,omitempty" structs:"error" mapstructure:"error"`
+}
+
+func (b *backend) pathFPEFields() map[string]*framework.FieldSchema {
+	return map[string]*framework.FieldSchema{
+		"name": {
+			Type:        framework.TypeString,
+			Description: "Name of the key",
+		},
+
+		"value": {
+			Type:        framework.TypeString,
+			Description: "The value to process",
+		},
+
+		"template": {
+			Type: framework.TypeString,
+			Description: `A regular expression the whole value must match. Only
+the characters matched by its capture groups are
+processed; all other characters are left as they are.
+If not set, the whole value is processed.`,
+		},
+
+		"tweak": {
+			Type: framework.TypeString,
+			Description: `Base64 encoded 7 byte tweak. The same tweak must be
+given to decode a value. Defaults to all zeroes.`,
+		},
+
+		"key_version": {
+			Type: framework.TypeInt,
+			Description: `The version of the key to use. Defaults to the latest
+version. Values encoded with older versions of the key
+must be decoded with the version they were encoded with.`,
+		},
+	}
+}
+
+func (b *backend) pathEncode() *framework.Path {
+	return &framework.Path{
+		Pattern: "encode/" + framework.GenericNameRegex("name"),
+		Fields:  b.pathFPEFields(),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathEncodeWrite,
+		},
+
+		HelpSynopsis:    pathEncodeHelpSyn,
+		HelpDescription: pathEncodeHelpDesc,
+	}
+}
+
+func (b *backend) pathDecode() *framework.Path {
+	return &framework.Path{
+		Pattern: "decode/" + framework.GenericNameRegex("name"),
+		Fields:  b.pathFPEFields(),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathDecodeWrite,
+		},
+
+		HelpSynopsis:    pathDecodeHelpSyn,
+		HelpDescription: pathDecodeHelpDesc,
+	}
+}
+
+func (b *backend) pathEncodeWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	return b.pathFPEWrite(ctx, req, d, true)
+}
+
+func (b *backend) pathDecodeWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	return b.pathFPEWrite(ctx, req, d, false)
+}
+
+func (b *backend) pathFPEWrite(ctx context.Context, req *logical.Request, d *framework.FieldData, encode bool) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	var template *regexp.Regexp
+	if raw := d.Get("template").(string); raw != "" {
+		var err error
+		template, err = regexp.Compile("^(?:" + raw + ")$")
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid template: %v", err)), logical.ErrInvalidRequest
+		}
+	}
+
+	batchInputRaw := d.Raw["batch_input"]
+	var batchInputItems []batchRequestFPEItem
+	if batchInputRaw != nil {
+		err := mapstructure.Decode(batchInputRaw, &batchInputItems)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to parse batch input: {{err}}", err)
+		}
+
+		if len(batchInputItems) == 0 {
+			return logical.ErrorResponse("missing batch input to process"), logical.ErrInvalidRequest
+		}
+	} else {
+		valueRaw, ok := d.GetOk("value")
+		if !ok {
+			return logical.ErrorResponse("missing value to process"), logical.ErrInvalidRequest
+		}
+
+		batchInputItems = []batchRequestFPEItem{
+			{
+				Value:      valueRaw.(string),
+				Tweak:      d.Get("tweak").(string),
+				KeyVersion: d.Get("key_version").(int),
+			},
+		}
+	}
+
+	// Get the policy
+	p, _, err := b.lm.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: req.Storage,
+		Name:    name,
+	}, b.GetRandomReader())
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("key not found"), logical.ErrInvalidRequest
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(false)
+	}
+	defer p.Unlock()
+
+	// Encoding and decoding are format-preserving encryption and decryption
+	operation := keysutil.KeyOperationDecrypt
+	if encode {
+		operation = keysutil.KeyOperationEncrypt
+	}
+	if err := p.CheckOperation(operation); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	if !p.Type.FPESupported() {
+		return logical.ErrorResponse(fmt.Sprintf("format-preserving encryption not supported for key type %v", p.Type)), logical.ErrInvalidRequest
+	}
+
+	batchResponseItems := make([]batchResponseFPEItem, len(batchInputItems))
+	for i, item := range batchInputItems {
+		tweak := make([]byte, keysutil.FF31TweakSize)
+		if item.Tweak != "" {
+			tweak, err = base64.StdEncoding.DecodeString(item.Tweak)
+			if err != nil {
+				batchResponseItems[i].Error = fmt.Sprintf("unable to decode tweak as base64: %s", err)
+				continue
+			}
+		}
+
+		keyVersion := item.KeyVersion
+		if keyVersion == 0 {
+			keyVersion = p.LatestVersion
+		}
+
+		result, err := applyFPETemplate(template, item.Value, func(value string) (string, error) {
+			if encode {
+				return p.FPEEncrypt(keyVersion, tweak, value)
+			}
+			return p.FPEDecrypt(keyVersion, tweak, value)
+		})
+		if err != nil {
+			if _, ok := err.(errutil.UserError); ok {
+				batchResponseItems[i].Error = err.Error()
+				continue
+			}
+			return nil, err
+		}
+
+		if encode {
+			batchResponseItems[i].EncodedValue = result
+			batchResponseItems[i].KeyVersion = keyVersion
+		} else {
+			batchResponseItems[i].DecodedValue = result
+		}
+	}
+
+	resp := &logical.Response{}
+	if batchInputRaw != nil {
+		resp.Data = map[string]interface{}{
+			"batch_results": batchResponseItems,
+		}
+		return resp, nil
+	}
+
+	if batchResponseItems[0].Error != "" {
+		return logical.ErrorResponse(batchResponseItems[0].Error), logical.ErrInvalidRequest
+	}
+	if encode {
+		resp.Data = map[string]interface{}{
+			"encoded_value": batchResponseItems[0].EncodedValue,
+			"key_version":   batchResponseItems[0].KeyVersion,
+		}
+	} else {
+		resp.Data = map[string]interface{}{
+			"decoded_value": batchResponseItems[0].DecodedValue,
+		}
+	}
+
+	return resp, nil
+}
+
+// applyFPETemplate runs transform over the characters of value matched by the
+// capture groups of template, leaving all other characters in place. Without
+// a template the whole value is transformed.
+func applyFPETemplate(template *regexp.Regexp, value string, transform func(string) (string, error)) (string, error) {
+	if template == nil {
+		return transform(value)
+	}
+
+	match := template.FindStringSubmatchIndex(value)
+	if match == nil {
+		return "", errutil.UserError{Err: "value does not match the template"}
+	}
+
+	// Collect the spans of the capture groups, ignoring groups nested within
+	// an earlier one
+	var spans [][2]int
+	last := 0
+	for i := 2; i < len(match); i += 2 {
+		start, end := match[i], match[i+1]
+		if start < 0 || start < last || start == end {
+			continue
+		}
+		spans = append(spans, [2]int{start, end})
+		last = end
+	}
+	if len(spans) == 0 {
+		return "", errutil.UserError{Err: "template capture groups do not match any characters of the value"}
+	}
+
+	var selected strings.Builder
+	for _, span := range spans {
+		selected.WriteString(value[span[0]:span[1]])
+	}
+	transformed, err := transform(selected.String())
+	if err != nil {
+		return "", err
+	}
+
+	// Put the transformed characters back in place of the selected ones
+	runes := []rune(transformed)
+	var out strings.Builder
+	pos := 0
+	for _, span := range spans {
+		out.WriteString(value[pos:span[0]])
+		n := utf8.RuneCountInString(value[span[0]:span[1]])
+		out.WriteString(string(runes[:n]))
+		runes = runes[n:]
+		pos = span[1]
+	}
+	out.WriteString(value[pos:])
+
+	return out.String(), nil
+}
+
+const pathEncodeHelpSyn = `Encode a value using format-preserving encryption with the named key`
+
+const pathEncodeHelpDesc = `
+This path uses the named "ff3-1" key to encrypt the value with FF3-1
+format-preserving encryption. The result has the same length as the
+value and only contains characters of the key's alphabet. A template
+may be given to only encrypt parts of the value, e.g. to keep the last
+four digits of a card number.
+
+The encoded value does not carry the key version, which is returned as
+"key_version" and must be stored with it to decode it later.
+`
+
+const pathDecodeHelpSyn = `Decode a value encoded using format-preserving encryption with the named key`
+
+const pathDecodeHelpDesc = `
+This path uses the named "ff3-1" key to decrypt a value encoded by the
+"encode" path. The same template, tweak and key version used to encode
+the value must be given.
+`
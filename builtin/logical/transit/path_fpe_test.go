@@ -0,0 +1,162 @@
+package transit
+
+import (
+	"context"
+	"encoding/base64"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestTransit_FPE(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}, errExpected bool) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: op,
+			Path:      path,
+			Data:      data,
+		})
+		if errExpected {
+			if err == nil && (resp == nil || !resp.IsError()) {
+				t.Fatalf("%s: expected error, got %#v", path, resp)
+			}
+			return resp
+		}
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	request(logical.UpdateOperation, "keys/cards", map[string]interface{}{
+		"type": "ff3-1",
+	}, false)
+	resp := request(logical.ReadOperation, "keys/cards", nil, false)
+	if resp.Data["type"] != "ff3-1" || resp.Data["alphabet"] != "numeric" || resp.Data["supports_encryption"].(bool) {
+		t.Fatalf("bad key: %#v", resp.Data)
+	}
+
+	// Keep the last four digits of a card number
+	const card = "4111-1111-1111-1234"
+	const template = `(\d{4})-(\d{4})-(\d{4})-\d{4}`
+	resp = request(logical.UpdateOperation, "encode/cards", map[string]interface{}{
+		"value":    card,
+		"template": template,
+	}, false)
+	encodedV1 := resp.Data["encoded_value"].(string)
+	if resp.Data["key_version"] != 1 || encodedV1 == card {
+		t.Fatalf("bad encode response: %#v", resp.Data)
+	}
+	if !regexp.MustCompile(`^\d{4}-\d{4}-\d{4}-1234$`).MatchString(encodedV1) {
+		t.Fatalf("encoded value %q does not preserve the format", encodedV1)
+	}
+
+	resp = request(logical.UpdateOperation, "decode/cards", map[string]interface{}{
+		"value":    encodedV1,
+		"template": template,
+	}, false)
+	if resp.Data["decoded_value"] != card {
+		t.Fatalf("bad decoded value: %#v", resp.Data)
+	}
+
+	// Values encoded with older versions still decode after rotation
+	request(logical.UpdateOperation, "keys/cards/rotate", nil, false)
+	resp = request(logical.UpdateOperation, "encode/cards", map[string]interface{}{
+		"value":    card,
+		"template": template,
+	}, false)
+	if resp.Data["key_version"] != 2 || resp.Data["encoded_value"] == encodedV1 {
+		t.Fatalf("bad encode response after rotation: %#v", resp.Data)
+	}
+	resp = request(logical.UpdateOperation, "decode/cards", map[string]interface{}{
+		"value":       encodedV1,
+		"template":    template,
+		"key_version": 1,
+	}, false)
+	if resp.Data["decoded_value"] != card {
+		t.Fatalf("bad decoded value: %#v", resp.Data)
+	}
+
+	// Batches, with a tweak per item
+	tweak := base64.StdEncoding.EncodeToString([]byte("tweak01"))
+	resp = request(logical.UpdateOperation, "encode/cards", map[string]interface{}{
+		"batch_input": []interface{}{
+			map[string]interface{}{"value": "123456789"},
+			map[string]interface{}{"value": "123456789", "tweak": tweak},
+			map[string]interface{}{"value": "12345"},
+			map[string]interface{}{"value": "12345678a"},
+		},
+	}, false)
+	results := resp.Data["batch_results"].([]batchResponseFPEItem)
+	if len(results[0].EncodedValue) != 9 || results[0].EncodedValue == results[1].EncodedValue || results[2].Error == "" || results[3].Error == "" {
+		t.Fatalf("bad batch results: %#v", results)
+	}
+	if results[0].KeyVersion != 2 || results[1].KeyVersion != 2 {
+		t.Fatalf("bad batch results: %#v", results)
+	}
+	resp = request(logical.UpdateOperation, "decode/cards", map[string]interface{}{
+		"batch_input": []interface{}{
+			map[string]interface{}{"value": results[0].EncodedValue},
+			map[string]interface{}{"value": results[1].EncodedValue, "tweak": tweak},
+		},
+	}, false)
+	decoded := resp.Data["batch_results"].([]batchResponseFPEItem)
+	if decoded[0].DecodedValue != "123456789" || decoded[1].DecodedValue != "123456789" {
+		t.Fatalf("bad batch decode results: %#v", decoded)
+	}
+
+	// Old versions are refused once below the minimum decryption version
+	request(logical.UpdateOperation, "keys/cards/config", map[string]interface{}{
+		"min_decryption_version": 2,
+	}, false)
+	request(logical.UpdateOperation, "decode/cards", map[string]interface{}{
+		"value":       encodedV1,
+		"template":    template,
+		"key_version": 1,
+	}, true)
+
+	request(logical.UpdateOperation, "encode/cards", map[string]interface{}{
+		"value":    "4111 1111 1111 1234",
+		"template": template,
+	}, true)
+
+	// Custom alphabets
+	request(logical.UpdateOperation, "keys/ids", map[string]interface{}{
+		"type":     "ff3-1",
+		"alphabet": "alphanumericupper",
+	}, false)
+	resp = request(logical.UpdateOperation, "encode/ids", map[string]interface{}{
+		"value":    "AB123456C",
+		"template": `([A-Z0-9]+)`,
+	}, false)
+	encodedID := resp.Data["encoded_value"].(string)
+	if len(encodedID) != 9 || strings.ToUpper(encodedID) != encodedID {
+		t.Fatalf("bad encoded id %q", encodedID)
+	}
+	resp = request(logical.UpdateOperation, "decode/ids", map[string]interface{}{
+		"value": encodedID,
+	}, false)
+	if resp.Data["decoded_value"] != "AB123456C" {
+		t.Fatalf("bad decoded id: %#v", resp.Data)
+	}
+
+	request(logical.UpdateOperation, "keys/bad-alphabet", map[string]interface{}{
+		"type":     "ff3-1",
+		"alphabet": "aa",
+	}, true)
+	request(logical.UpdateOperation, "keys/aes", map[string]interface{}{
+		"alphabet": "numeric",
+	}, true)
+	request(logical.UpdateOperation, "keys/aes", nil, false)
+	request(logical.UpdateOperation, "encode/aes", map[string]interface{}{
+		"value": "123456789",
+	}, true)
+	request(logical.UpdateOperation, "encrypt/cards", map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString([]byte("123456789")),
+	}, true)
+}
@@ -111,6 +111,11 @@ func (b *backend) pathHMACWrite(ctx context.Context, req *logical.Request, d *fr
 		p.Lock(false)
 	}
 
+	if err := p.CheckOperation(keysutil.KeyOperationHMAC); err != nil {
+		p.Unlock()
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
 	switch {
 	case ver == 0:
 		// Allowed, will use latest; set explicitly here to ensure the string
@@ -240,6 +245,11 @@ func (b *backend) pathHMACVerify(ctx context.Context, req *logical.Request, d *f
 		p.Lock(false)
 	}
 
+	if err := p.CheckOperation(keysutil.KeyOperationVerify); err != nil {
+		p.Unlock()
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
 	hashAlgorithm, ok := keysutil.HashTypeMap[algorithm]
 	if !ok {
 		p.Unlock()
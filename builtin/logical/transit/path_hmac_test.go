@@ -2,6 +2,9 @@ package transit
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 	"strconv"
 	"strings"
@@ -332,3 +335,95 @@ func TestTransit_batchHMAC(t *testing.T) {
 		t.Fatalf("expected error validating hmac\nreq\n%#v\nresp\n%#v", *req, *resp)
 	}
 }
+
+func TestTransit_HMACKeyType(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	doRequest := func(op logical.Operation, path string, data map[string]interface{}, errExpected bool) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: op,
+			Path:      path,
+			Data:      data,
+		})
+		if errExpected {
+			if err == nil && (resp == nil || !resp.IsError()) {
+				t.Fatalf("%s: expected error, got %#v", path, resp)
+			}
+			return resp
+		}
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	// The key size must be within bounds and only applies to HMAC keys
+	doRequest(logical.UpdateOperation, "keys/foo", map[string]interface{}{
+		"type":     "hmac",
+		"key_size": 16,
+	}, true)
+	doRequest(logical.UpdateOperation, "keys/foo", map[string]interface{}{
+		"type":     "aes256-gcm96",
+		"key_size": 64,
+	}, true)
+
+	doRequest(logical.UpdateOperation, "keys/foo", map[string]interface{}{
+		"type":       "hmac",
+		"key_size":   64,
+		"exportable": true,
+	}, false)
+	resp := doRequest(logical.ReadOperation, "keys/foo", nil, false)
+	if resp.Data["type"] != "hmac" || resp.Data["key_size"] != 64 || resp.Data["supports_encryption"].(bool) {
+		t.Fatalf("bad key: %#v", resp.Data)
+	}
+
+	// The HMAC is computed with the key material itself
+	resp = doRequest(logical.ReadOperation, "export/hmac-key/foo/1", nil, false)
+	key, err := base64.StdEncoding.DecodeString(resp.Data["keys"].(map[string]string)["1"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key) != 64 {
+		t.Fatalf("bad key length %d", len(key))
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("the quick brown fox"))
+	expected := "vault:v1:" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	input := base64.StdEncoding.EncodeToString([]byte("the quick brown fox"))
+	resp = doRequest(logical.UpdateOperation, "hmac/foo", map[string]interface{}{
+		"input": input,
+	}, false)
+	if resp.Data["hmac"] != expected {
+		t.Fatalf("bad hmac: expected %s, got %v", expected, resp.Data["hmac"])
+	}
+
+	// Rotation keeps the configured size and older versions still verify
+	doRequest(logical.UpdateOperation, "keys/foo/rotate", nil, false)
+	resp = doRequest(logical.ReadOperation, "export/hmac-key/foo/2", nil, false)
+	key2, err := base64.StdEncoding.DecodeString(resp.Data["keys"].(map[string]string)["2"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key2) != 64 || string(key2) == string(key) {
+		t.Fatalf("bad rotated key")
+	}
+	resp = doRequest(logical.UpdateOperation, "verify/foo", map[string]interface{}{
+		"input": input,
+		"hmac":  expected,
+	}, false)
+	if !resp.Data["valid"].(bool) {
+		t.Fatalf("expected valid hmac: %#v", resp.Data)
+	}
+
+	// HMAC keys cannot be used for encryption or signing
+	doRequest(logical.UpdateOperation, "encrypt/foo", map[string]interface{}{
+		"plaintext": input,
+	}, true)
+	doRequest(logical.UpdateOperation, "sign/foo", map[string]interface{}{
+		"input": input,
+	}, true)
+	doRequest(logical.ReadOperation, "export/encryption-key/foo", nil, true)
+}
@@ -0,0 +1,316 @@
+package transit
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// The wrapped key material starts with the ephemeral AES-256 key encrypted
+// with the RSA-4096 wrapping key
+const wrappedEphemeralKeySize = 512
+
+func (b *backend) pathImport() *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("name") + "/import",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+
+			"type": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "aes256-gcm96",
+				Description: `
+The type of the imported key. Any of the types supported by "keys/:name" may
+be used. Defaults to "aes256-gcm96".
+`,
+			},
+
+			"ciphertext": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The base64-encoded key material, wrapped as
+described in the help of this path.`,
+			},
+
+			"hash_function": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "SHA256",
+				Description: `The hash function used for RSA-OAEP when
+wrapping the ephemeral AES key. One of "SHA1",
+"SHA224", "SHA256", "SHA384" or "SHA512".
+Defaults to "SHA256".`,
+			},
+
+			"allow_rotation": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Allows the key to be rotated within Vault,
+which adds a version generated by Vault.`,
+			},
+
+			"derived": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Enables key derivation mode. This
+allows for per-transaction unique
+keys for encryption operations.`,
+			},
+
+			"convergent_encryption": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Whether to support convergent encryption.
+This is only supported when using a key with
+key derivation enabled.`,
+			},
+
+			"exportable": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Enables keys to be exportable.
+This allows for all the valid keys
+in the key ring to be exported.`,
+			},
+
+			"allow_plaintext_backup": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Enables taking a backup of the named
+key in plaintext format. Once set,
+this cannot be disabled.`,
+			},
+
+			"alphabet": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `The alphabet of keys of type "ff3-1", as for "keys/:name".`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathImportWrite,
+		},
+
+		HelpSynopsis:    pathImportHelpSyn,
+		HelpDescription: pathImportHelpDesc,
+	}
+}
+
+func (b *backend) pathImportVersion() *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("name") + "/import_version",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+
+			"ciphertext": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The base64-encoded key material, wrapped as
+described in the help of the "import" path.`,
+			},
+
+			"hash_function": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "SHA256",
+				Description: `The hash function used for RSA-OAEP when
+wrapping the ephemeral AES key. One of "SHA1",
+"SHA224", "SHA256", "SHA384" or "SHA512".
+Defaults to "SHA256".`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathImportVersionWrite,
+		},
+
+		HelpSynopsis:    pathImportVersionHelpSyn,
+		HelpDescription: pathImportVersionHelpDesc,
+	}
+}
+
+func (b *backend) pathImportWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	keyType := d.Get("type").(string)
+	derived := d.Get("derived").(bool)
+	convergent := d.Get("convergent_encryption").(bool)
+
+	if !derived && convergent {
+		return logical.ErrorResponse("convergent encryption requires derivation to be enabled"), nil
+	}
+
+	polReq := keysutil.PolicyRequest{
+		Storage:                  req.Storage,
+		Name:                     name,
+		Derived:                  derived,
+		Convergent:               convergent,
+		Exportable:               d.Get("exportable").(bool),
+		AllowPlaintextBackup:     d.Get("allow_plaintext_backup").(bool),
+		AllowImportedKeyRotation: d.Get("allow_rotation").(bool),
+		FPEAlphabet:              d.Get("alphabet").(string),
+	}
+	var ok bool
+	polReq.KeyType, ok = parseKeyType(keyType)
+	if !ok {
+		return logical.ErrorResponse(fmt.Sprintf("unknown key type %v", keyType)), logical.ErrInvalidRequest
+	}
+
+	key, err := b.unwrapImportedKey(ctx, req.Storage, d)
+	if err != nil {
+		return importErrorResponse(err)
+	}
+
+	// The size of an HMAC key is taken from the imported key material
+	if polReq.KeyType == keysutil.KeyType_HMAC {
+		polReq.KeySize = len(key)
+	}
+
+	err = b.lm.ImportPolicy(ctx, polReq, key, b.GetRandomReader())
+	if err != nil {
+		return importErrorResponse(err)
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathImportVersionWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	p, _, err := b.lm.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: req.Storage,
+		Name:    name,
+	}, b.GetRandomReader())
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("key not found"), logical.ErrInvalidRequest
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(true)
+	}
+	defer p.Unlock()
+
+	if !p.Imported {
+		return logical.ErrorResponse("new versions can only be imported into keys which were imported"), logical.ErrInvalidRequest
+	}
+
+	key, err := b.unwrapImportedKey(ctx, req.Storage, d)
+	if err != nil {
+		return importErrorResponse(err)
+	}
+
+	err = p.Import(ctx, req.Storage, key, b.GetRandomReader())
+	if err != nil {
+		return importErrorResponse(err)
+	}
+
+	return nil, nil
+}
+
+// unwrapImportedKey decrypts key material which was wrapped for import: the
+// ciphertext is an ephemeral AES-256 key encrypted with RSA-OAEP under the
+// wrapping key, followed by the key material wrapped with the ephemeral key
+// using AES-KWP (RFC 5649)
+func (b *backend) unwrapImportedKey(ctx context.Context, storage logical.Storage, d *framework.FieldData) ([]byte, error) {
+	ciphertextB64 := d.Get("ciphertext").(string)
+	if ciphertextB64 == "" {
+		return nil, errutil.UserError{Err: "'ciphertext' must be supplied"}
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(ciphertextB64)
+	if err != nil {
+		return nil, errutil.UserError{Err: "failed to base64-decode ciphertext"}
+	}
+	if len(ciphertext) <= wrappedEphemeralKeySize {
+		return nil, errutil.UserError{Err: "ciphertext is too short to contain a wrapped key"}
+	}
+
+	hashFunc, err := parseOAEPHash(d.Get("hash_function").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := b.getWrappingKey(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+	wrappingKey, ok := p.Keys[strconv.Itoa(p.LatestVersion)]
+	if !ok {
+		return nil, fmt.Errorf("wrapping key version %d not found", p.LatestVersion)
+	}
+
+	ephemeralKey, err := rsa.DecryptOAEP(hashFunc, b.GetRandomReader(), wrappingKey.RSAKey, ciphertext[:wrappedEphemeralKeySize], nil)
+	if err != nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("failed to decrypt ephemeral key: %v", err)}
+	}
+	if len(ephemeralKey) != 32 {
+		return nil, errutil.UserError{Err: "ephemeral key must be a 256-bit AES key"}
+	}
+
+	key, err := keysutil.UnwrapKeyKWP(ephemeralKey, ciphertext[wrappedEphemeralKeySize:])
+	if err != nil {
+		return nil, errutil.UserError{Err: err.Error()}
+	}
+	return key, nil
+}
+
+func parseOAEPHash(name string) (hash.Hash, error) {
+	switch strings.ToUpper(name) {
+	case "SHA1":
+		return crypto.SHA1.New(), nil
+	case "SHA224":
+		return crypto.SHA224.New(), nil
+	case "SHA256":
+		return crypto.SHA256.New(), nil
+	case "SHA384":
+		return crypto.SHA384.New(), nil
+	case "SHA512":
+		return crypto.SHA512.New(), nil
+	default:
+		return nil, errutil.UserError{Err: fmt.Sprintf("unsupported hash function %q", name)}
+	}
+}
+
+func importErrorResponse(err error) (*logical.Response, error) {
+	switch err.(type) {
+	case errutil.UserError:
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	default:
+		return nil, errwrap.Wrapf("error importing key: {{err}}", err)
+	}
+}
+
+const pathImportHelpSyn = `Imports an externally-generated key into a new transit key`
+
+const pathImportHelpDesc = `
+This path is used to import key material generated outside of Vault as a new
+named key. The key material must be wrapped for transport:
+
+  1. Generate an ephemeral 256-bit AES key.
+  2. Wrap the key material with the ephemeral key using AES-KWP (RFC 5649).
+     Symmetric keys are wrapped as raw bytes, asymmetric keys as PKCS#8
+     DER-encoded private keys.
+  3. Encrypt the ephemeral key with RSA-OAEP using the public key returned by
+     the "wrapping_key" path and the chosen hash function.
+  4. Submit the base64 encoding of the encrypted ephemeral key followed by the
+     wrapped key material as "ciphertext".
+
+Imported keys cannot be rotated within Vault unless "allow_rotation" is set;
+new versions can be imported with the "import_version" path instead.
+`
+
+const pathImportVersionHelpSyn = `Imports an externally-generated key as a new version of an imported key`
+
+const pathImportVersionHelpDesc = `
+This path is used to import key material as the latest version of a key which
+was previously imported. The key material must be wrapped as described in the
+help of the "import" path, and must be of the key's type.
+`
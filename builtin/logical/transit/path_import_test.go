@@ -0,0 +1,241 @@
+package transit
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// wrapKeyForImport wraps key material for import as a client would
+func wrapKeyForImport(t *testing.T, b *backend, storage logical.Storage, key []byte) string {
+	t.Helper()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.ReadOperation,
+		Path:      "wrapping_key",
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+	block, _ := pem.Decode([]byte(resp.Data["public_key"].(string)))
+	if block == nil {
+		t.Fatal("failed to decode wrapping key")
+	}
+	wrappingKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ephemeralKey := make([]byte, 32)
+	if _, err := rand.Read(ephemeralKey); err != nil {
+		t.Fatal(err)
+	}
+	wrappedEphemeralKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, wrappingKey.(*rsa.PublicKey), ephemeralKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrappedKey, err := keysutil.WrapKeyKWP(ephemeralKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(append(wrappedEphemeralKey, wrappedKey...))
+}
+
+func TestTransit_Import(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: op,
+			Path:      path,
+			Data:      data,
+		})
+	}
+
+	pkcs8 := func(key interface{}) []byte {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+	pemPublicKey := func(key interface{}) string {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	}
+
+	type importCase struct {
+		keyType   string
+		key       []byte
+		publicKey string
+	}
+	var cases []importCase
+
+	for _, c := range []struct {
+		keyType string
+		size    int
+	}{
+		{"aes128-gcm96", 16},
+		{"aes256-gcm96", 32},
+		{"chacha20-poly1305", 32},
+		{"hmac", 48},
+		{"aes128-cmac", 16},
+		{"aes256-cmac", 32},
+	} {
+		key := make([]byte, c.size)
+		if _, err := rand.Read(key); err != nil {
+			t.Fatal(err)
+		}
+		cases = append(cases, importCase{keyType: c.keyType, key: key})
+	}
+
+	for keyType, curve := range map[string]elliptic.Curve{
+		"ecdsa-p256": elliptic.P256(),
+		"ecdsa-p384": elliptic.P384(),
+		"ecdsa-p521": elliptic.P521(),
+	} {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cases = append(cases, importCase{keyType: keyType, key: pkcs8(key), publicKey: pemPublicKey(key.Public())})
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases = append(cases, importCase{keyType: "ed25519", key: pkcs8(priv), publicKey: base64.StdEncoding.EncodeToString(pub)})
+
+	rsaKeys := map[string]*rsa.PrivateKey{}
+	for keyType, bits := range map[string]int{
+		"rsa-2048": 2048,
+		"rsa-3072": 3072,
+		"rsa-4096": 4096,
+	} {
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsaKeys[keyType] = key
+		cases = append(cases, importCase{keyType: keyType, key: pkcs8(key), publicKey: pemPublicKey(key.Public())})
+	}
+
+	for _, c := range cases {
+		name := "imported-" + c.keyType
+		resp, err := request(logical.UpdateOperation, "keys/"+name+"/import", map[string]interface{}{
+			"type":       c.keyType,
+			"ciphertext": wrapKeyForImport(t, b, storage, c.key),
+			"exportable": true,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err: %v resp: %#v", c.keyType, err, resp)
+		}
+
+		resp, err = request(logical.ReadOperation, "keys/"+name, nil)
+		if err != nil || resp == nil {
+			t.Fatalf("%s: err: %v resp: %#v", c.keyType, err, resp)
+		}
+		if resp.Data["imported_key"] != true {
+			t.Fatalf("%s: expected key to be marked as imported", c.keyType)
+		}
+
+		if c.publicKey == "" {
+			exportType := "encryption-key"
+			switch c.keyType {
+			case "hmac":
+				exportType = "hmac-key"
+			case "aes128-cmac", "aes256-cmac":
+				exportType = "cmac-key"
+			}
+			resp, err = request(logical.ReadOperation, "export/"+exportType+"/"+name+"/1", nil)
+			if err != nil || resp == nil {
+				t.Fatalf("%s: err: %v resp: %#v", c.keyType, err, resp)
+			}
+			exported := resp.Data["keys"].(map[string]string)["1"]
+			if exported != base64.StdEncoding.EncodeToString(c.key) {
+				t.Fatalf("%s: exported key does not match the imported key", c.keyType)
+			}
+			continue
+		}
+
+		publicKey := resp.Data["keys"].(map[string]map[string]interface{})["1"]["public_key"]
+		if publicKey != c.publicKey {
+			t.Fatalf("%s: expected public key %q, got %q", c.keyType, c.publicKey, publicKey)
+		}
+	}
+
+	// Imported keys cannot be rotated unless allowed
+	resp, err := request(logical.UpdateOperation, "keys/imported-aes256-gcm96/rotate", nil)
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error rotating an imported key, got err: %v resp: %#v", err, resp)
+	}
+
+	// New versions must match the key type
+	resp, err = request(logical.UpdateOperation, "keys/imported-rsa-4096/import_version", map[string]interface{}{
+		"ciphertext": wrapKeyForImport(t, b, storage, pkcs8(rsaKeys["rsa-2048"])),
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error importing a key of the wrong type, got err: %v resp: %#v", err, resp)
+	}
+
+	resp, err = request(logical.UpdateOperation, "keys/imported-rsa-2048/import_version", map[string]interface{}{
+		"ciphertext": wrapKeyForImport(t, b, storage, pkcs8(rsaKeys["rsa-2048"])),
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v resp: %#v", err, resp)
+	}
+	resp, err = request(logical.ReadOperation, "keys/imported-rsa-2048", nil)
+	if err != nil || resp == nil || resp.Data["latest_version"] != 2 {
+		t.Fatalf("expected a second version, got err: %v resp: %#v", err, resp)
+	}
+
+	// Existing keys cannot be overwritten
+	resp, err = request(logical.UpdateOperation, "keys/imported-aes128-gcm96/import", map[string]interface{}{
+		"type":       "aes128-gcm96",
+		"ciphertext": wrapKeyForImport(t, b, storage, cases[0].key),
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error importing over an existing key, got err: %v resp: %#v", err, resp)
+	}
+
+	// Tampered key material is rejected
+	ciphertext, _ := base64.StdEncoding.DecodeString(wrapKeyForImport(t, b, storage, cases[1].key))
+	ciphertext[len(ciphertext)-1] ^= 1
+	resp, err = request(logical.UpdateOperation, "keys/tampered/import", map[string]interface{}{
+		"ciphertext": base64.StdEncoding.EncodeToString(ciphertext),
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error importing tampered key material, got err: %v resp: %#v", err, resp)
+	}
+
+	resp, err = request(logical.UpdateOperation, "keys/rotatable/import", map[string]interface{}{
+		"ciphertext":     wrapKeyForImport(t, b, storage, cases[1].key),
+		"allow_rotation": true,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v resp: %#v", err, resp)
+	}
+	resp, err = request(logical.UpdateOperation, "keys/rotatable/rotate", nil)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v resp: %#v", err, resp)
+	}
+}
@@ -47,10 +47,29 @@ func (b *backend) pathKeys() *framework.Path {
 				Description: `
 The type of key to create. Currently, "aes128-gcm96" (symmetric), "aes256-gcm96" (symmetric), "ecdsa-p256"
 (asymmetric), "ecdsa-p384" (asymmetric), "ecdsa-p521" (asymmetric), "ed25519" (asymmetric), "rsa-2048" (asymmetric), "rsa-3072"
-(asymmetric), "rsa-4096" (asymmetric) are supported.  Defaults to "aes256-gcm96".
+(asymmetric), "rsa-4096" (asymmetric), "ml-dsa-44" (post-quantum), "ml-dsa-65" (post-quantum), "ml-dsa-87"
+(post-quantum), "ml-dsa-65-ed25519" (hybrid), "hmac" (MAC), "aes128-cmac" (MAC), "aes256-cmac" (MAC) and "ff3-1"
+(format-preserving encryption) are supported. The ML-DSA types require Vault to be built with Go 1.26 or later.
+Defaults to "aes256-gcm96".
 `,
 			},
 
+			"key_size": &framework.FieldSchema{
+				Type: framework.TypeInt,
+				Description: `The key size in bytes for keys of type
+"hmac", between 32 and 512. Defaults to 32.`,
+			},
+
+			"alphabet": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The alphabet of values encrypted with keys of
+type "ff3-1": one of "numeric", "alphalower",
+"alphaupper", "alphanumericlower",
+"alphanumericupper" and "alphanumeric", or a
+string of the characters of the alphabet.
+Defaults to "numeric".`,
+			},
+
 			"derived": &framework.FieldSchema{
 				Type: framework.TypeBool,
 				Description: `Enables key derivation mode. This
@@ -124,6 +143,8 @@ func (b *backend) pathPolicyWrite(ctx context.Context, req *logical.Request, d *
 	keyType := d.Get("type").(string)
 	exportable := d.Get("exportable").(bool)
 	allowPlaintextBackup := d.Get("allow_plaintext_backup").(bool)
+	keySize := d.Get("key_size").(int)
+	alphabet := d.Get("alphabet").(string)
 
 	if !derived && convergent {
 		return logical.ErrorResponse("convergent encryption requires derivation to be enabled"), nil
@@ -137,32 +158,29 @@ func (b *backend) pathPolicyWrite(ctx context.Context, req *logical.Request, d *
 		Convergent:           convergent,
 		Exportable:           exportable,
 		AllowPlaintextBackup: allowPlaintextBackup,
+		KeySize:              keySize,
+		FPEAlphabet:          alphabet,
 	}
-	switch keyType {
-	case "aes128-gcm96":
-		polReq.KeyType = keysutil.KeyType_AES128_GCM96
-	case "aes256-gcm96":
-		polReq.KeyType = keysutil.KeyType_AES256_GCM96
-	case "chacha20-poly1305":
-		polReq.KeyType = keysutil.KeyType_ChaCha20_Poly1305
-	case "ecdsa-p256":
-		polReq.KeyType = keysutil.KeyType_ECDSA_P256
-	case "ecdsa-p384":
-		polReq.KeyType = keysutil.KeyType_ECDSA_P384
-	case "ecdsa-p521":
-		polReq.KeyType = keysutil.KeyType_ECDSA_P521
-	case "ed25519":
-		polReq.KeyType = keysutil.KeyType_ED25519
-	case "rsa-2048":
-		polReq.KeyType = keysutil.KeyType_RSA2048
-	case "rsa-3072":
-		polReq.KeyType = keysutil.KeyType_RSA3072
-	case "rsa-4096":
-		polReq.KeyType = keysutil.KeyType_RSA4096
-	default:
+	var ok bool
+	polReq.KeyType, ok = parseKeyType(keyType)
+	if !ok {
 		return logical.ErrorResponse(fmt.Sprintf("unknown key type %v", keyType)), logical.ErrInvalidRequest
 	}
 
+	switch {
+	case keySize != 0 && polReq.KeyType != keysutil.KeyType_HMAC:
+		return logical.ErrorResponse("key_size is only supported for keys of type hmac"), logical.ErrInvalidRequest
+	case keySize != 0 && (keySize < keysutil.MinHMACKeySize || keySize > keysutil.MaxHMACKeySize):
+		return logical.ErrorResponse(fmt.Sprintf("key_size must be between %d and %d bytes", keysutil.MinHMACKeySize, keysutil.MaxHMACKeySize)), logical.ErrInvalidRequest
+	case alphabet != "" && !polReq.KeyType.FPESupported():
+		return logical.ErrorResponse("alphabet is only supported for keys of type ff3-1"), logical.ErrInvalidRequest
+	}
+	if alphabet != "" {
+		if _, err := keysutil.ResolveFPEAlphabet(alphabet); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid alphabet: %v", err)), logical.ErrInvalidRequest
+		}
+	}
+
 	p, upserted, err := b.lm.GetPolicy(ctx, polReq, b.GetRandomReader())
 	if err != nil {
 		return nil, err
@@ -182,11 +200,68 @@ func (b *backend) pathPolicyWrite(ctx context.Context, req *logical.Request, d *
 	return nil, nil
 }
 
+// parseKeyType returns the key type with the given name. The ML-DSA key
+// types are known even when this build doesn't support them, so that
+// creating them fails with an error naming the Go version required.
+func parseKeyType(keyType string) (keysutil.KeyType, bool) {
+	switch keyType {
+	case "aes128-gcm96":
+		return keysutil.KeyType_AES128_GCM96, true
+	case "aes256-gcm96":
+		return keysutil.KeyType_AES256_GCM96, true
+	case "chacha20-poly1305":
+		return keysutil.KeyType_ChaCha20_Poly1305, true
+	case "ecdsa-p256":
+		return keysutil.KeyType_ECDSA_P256, true
+	case "ecdsa-p384":
+		return keysutil.KeyType_ECDSA_P384, true
+	case "ecdsa-p521":
+		return keysutil.KeyType_ECDSA_P521, true
+	case "ed25519":
+		return keysutil.KeyType_ED25519, true
+	case "rsa-2048":
+		return keysutil.KeyType_RSA2048, true
+	case "rsa-3072":
+		return keysutil.KeyType_RSA3072, true
+	case "rsa-4096":
+		return keysutil.KeyType_RSA4096, true
+	case "ml-dsa-44":
+		return keysutil.KeyType_ML_DSA_44, true
+	case "ml-dsa-65":
+		return keysutil.KeyType_ML_DSA_65, true
+	case "ml-dsa-87":
+		return keysutil.KeyType_ML_DSA_87, true
+	case "ml-dsa-65-ed25519":
+		return keysutil.KeyType_ML_DSA_65_ED25519, true
+	case "hmac":
+		return keysutil.KeyType_HMAC, true
+	case "aes128-cmac":
+		return keysutil.KeyType_AES128_CMAC, true
+	case "aes256-cmac":
+		return keysutil.KeyType_AES256_CMAC, true
+	case "ff3-1":
+		return keysutil.KeyType_FF3_1, true
+	default:
+		return 0, false
+	}
+}
+
+// allowedOperations returns the operations the key may be used for, or an
+// empty list if it is not restricted
+func allowedOperations(p *keysutil.Policy) []string {
+	if len(p.AllowedOperations) == 0 {
+		return []string{}
+	}
+	return p.AllowedOperations
+}
+
 // Built-in helper type for returning asymmetric keys
 type asymKey struct {
 	Name         string    `json:"name" structs:"name" mapstructure:"name"`
 	PublicKey    string    `json:"public_key" structs:"public_key" mapstructure:"public_key"`
 	CreationTime time.Time `json:"creation_time" structs:"creation_time" mapstructure:"creation_time"`
+
+	CertificateChain string `json:"certificate_chain,omitempty" structs:"certificate_chain,omitempty" mapstructure:"certificate_chain"`
 }
 
 func (b *backend) pathPolicyRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
@@ -220,6 +295,10 @@ func (b *backend) pathPolicyRead(ctx context.Context, req *logical.Request, d *f
 			"latest_version":         p.LatestVersion,
 			"exportable":             p.Exportable,
 			"allow_plaintext_backup": p.AllowPlaintextBackup,
+			"imported_key":           p.Imported,
+			"auto_rotate_period":     int64(p.AutoRotatePeriod.Seconds()),
+			"allowed_operations":     allowedOperations(p),
+			"last_rotation_time":     lastRotationTime(p),
 			"supports_encryption":    p.Type.EncryptionSupported(),
 			"supports_decryption":    p.Type.DecryptionSupported(),
 			"supports_signing":       p.Type.SigningSupported(),
@@ -227,6 +306,18 @@ func (b *backend) pathPolicyRead(ctx context.Context, req *logical.Request, d *f
 		},
 	}
 
+	if p.Imported {
+		resp.Data["imported_key_allow_rotation"] = p.AllowImportedKeyRotation
+	}
+
+	if p.Type == keysutil.KeyType_HMAC {
+		resp.Data["key_size"] = p.KeySize
+	}
+
+	if p.Type.FPESupported() {
+		resp.Data["alphabet"] = p.FPEAlphabet
+	}
+
 	if p.BackupInfo != nil {
 		resp.Data["backup_info"] = map[string]interface{}{
 			"time":    p.BackupInfo.Time,
@@ -252,6 +343,13 @@ func (b *backend) pathPolicyRead(ctx context.Context, req *logical.Request, d *f
 		if p.ConvergentEncryption {
 			resp.Data["convergent_encryption_version"] = p.ConvergentVersion
 		}
+		allowedContexts := p.AllowedContexts
+		if allowedContexts == nil {
+			allowedContexts = []string{}
+		}
+		resp.Data["allowed_contexts"] = allowedContexts
+		resp.Data["allowed_context_regex"] = p.AllowedContextRegex
+		resp.Data["track_contexts"] = p.TrackContexts
 	}
 
 	contextRaw := d.Get("context").(string)
@@ -264,14 +362,15 @@ func (b *backend) pathPolicyRead(ctx context.Context, req *logical.Request, d *f
 	}
 
 	switch p.Type {
-	case keysutil.KeyType_AES128_GCM96, keysutil.KeyType_AES256_GCM96, keysutil.KeyType_ChaCha20_Poly1305:
+	case keysutil.KeyType_AES128_GCM96, keysutil.KeyType_AES256_GCM96, keysutil.KeyType_ChaCha20_Poly1305, keysutil.KeyType_HMAC, keysutil.KeyType_AES128_CMAC, keysutil.KeyType_AES256_CMAC, keysutil.KeyType_FF3_1:
 		retKeys := map[string]int64{}
 		for k, v := range p.Keys {
 			retKeys[k] = v.DeprecatedCreationTime
 		}
 		resp.Data["keys"] = retKeys
 
-	case keysutil.KeyType_ECDSA_P256, keysutil.KeyType_ECDSA_P384, keysutil.KeyType_ECDSA_P521, keysutil.KeyType_ED25519, keysutil.KeyType_RSA2048, keysutil.KeyType_RSA3072, keysutil.KeyType_RSA4096:
+	case keysutil.KeyType_ECDSA_P256, keysutil.KeyType_ECDSA_P384, keysutil.KeyType_ECDSA_P521, keysutil.KeyType_ED25519, keysutil.KeyType_RSA2048, keysutil.KeyType_RSA3072, keysutil.KeyType_RSA4096,
+		keysutil.KeyType_ML_DSA_44, keysutil.KeyType_ML_DSA_65, keysutil.KeyType_ML_DSA_87, keysutil.KeyType_ML_DSA_65_ED25519:
 		retKeys := map[string]map[string]interface{}{}
 		for k, v := range p.Keys {
 			key := asymKey{
@@ -281,6 +380,9 @@ func (b *backend) pathPolicyRead(ctx context.Context, req *logical.Request, d *f
 			if key.CreationTime.IsZero() {
 				key.CreationTime = time.Unix(v.DeprecatedCreationTime, 0)
 			}
+			if len(v.CertificateChain) != 0 {
+				key.CertificateChain = encodeCertificateChain(v.CertificateChain)
+			}
 
 			switch p.Type {
 			case keysutil.KeyType_ECDSA_P256:
@@ -332,6 +434,9 @@ func (b *backend) pathPolicyRead(ctx context.Context, req *logical.Request, d *f
 					return nil, fmt.Errorf("failed to PEM-encode RSA public key")
 				}
 				key.PublicKey = string(pemBytes)
+
+			case keysutil.KeyType_ML_DSA_44, keysutil.KeyType_ML_DSA_65, keysutil.KeyType_ML_DSA_87, keysutil.KeyType_ML_DSA_65_ED25519:
+				key.Name = p.Type.String()
 			}
 
 			retKeys[k] = structs.New(key).Map()
@@ -351,6 +456,14 @@ func (b *backend) pathPolicyDelete(ctx context.Context, req *logical.Request, d
 		return logical.ErrorResponse(fmt.Sprintf("error deleting policy %s: %s", name, err)), err
 	}
 
+	if err := b.clearContexts(ctx, req.Storage, name); err != nil {
+		return nil, errwrap.Wrapf("error deleting context registry: {{err}}", err)
+	}
+
+	if err := b.clearRewrapJobs(ctx, req.Storage, name); err != nil {
+		return nil, errwrap.Wrapf("error deleting rewrap jobs: {{err}}", err)
+	}
+
 	return nil, nil
 }
 
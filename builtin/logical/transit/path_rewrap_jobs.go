@@ -0,0 +1,762 @@
+package transit
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// rewrapJobPageSize is the number of ciphertexts stored, and rewrapped
+	// while holding the key's lock, together
+	rewrapJobPageSize = 256
+
+	// rewrapJobMaxLineSize is the maximum size of a line of the uploaded
+	// NDJSON document
+	rewrapJobMaxLineSize = 1024 * 1024
+
+	rewrapJobDefaultLimit = 1000
+	rewrapJobMaxLimit     = 10000
+
+	rewrapJobStatePending   = "pending"
+	rewrapJobStateRunning   = "running"
+	rewrapJobStateCompleted = "completed"
+	rewrapJobStateFailed    = "failed"
+)
+
+// rewrapJob tracks the progress of a bulk rewrap of uploaded ciphertexts. Its
+// input and results are stored in pages under the job's data prefix.
+type rewrapJob struct {
+	ID             string    `json:"id"`
+	Name           string    `json:"name"`
+	KeyVersion     int       `json:"key_version"`
+	State          string    `json:"state"`
+	Error          string    `json:"error,omitempty"`
+	Total          int       `json:"total"`
+	Processed      int       `json:"processed"`
+	Succeeded      int       `json:"succeeded"`
+	Failed         int       `json:"failed"`
+	CreationTime   time.Time `json:"creation_time"`
+	StartTime      time.Time `json:"start_time"`
+	CompletionTime time.Time `json:"completion_time"`
+}
+
+func (j *rewrapJob) finished() bool {
+	return j.State == rewrapJobStateCompleted || j.State == rewrapJobStateFailed
+}
+
+// rewrapJobItem is a line of the uploaded NDJSON document
+type rewrapJobItem struct {
+	Ciphertext string `json:"ciphertext"`
+	Context    string `json:"context,omitempty"`
+	Nonce      string `json:"nonce,omitempty"`
+}
+
+// rewrapJobResult is a rewrapped ciphertext, identified by the index of its
+// line in the uploaded document
+type rewrapJobResult struct {
+	Index int `json:"index" structs:"index" mapstructure:"index"`
+	EncryptBatchResponseItem
+}
+
+// rewrapJobRunner is a job being processed by this node
+type rewrapJobRunner struct {
+	cancel context.CancelFunc
+	doneCh chan struct{}
+}
+
+func rewrapJobPath(name, id string) string {
+	return "rewrap-job/" + name + "/" + id
+}
+
+func rewrapJobDataPrefix(name, id string) string {
+	return "rewrap-job-data/" + name + "/" + id + "/"
+}
+
+func (b *backend) pathRewrapJobs() *framework.Path {
+	return &framework.Path{
+		Pattern: "rewrap-jobs/" + framework.GenericNameRegex("name") + "/?$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+
+			"key_version": {
+				Type: framework.TypeInt,
+				Description: `The version of the key to rewrap the ciphertexts to.
+Must be 0 (for the latest version when the job is created)
+or a value greater than or equal to the
+min_encryption_version configured on the key.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRewrapJobsWrite,
+			logical.ListOperation:   b.pathRewrapJobsList,
+		},
+
+		HelpSynopsis:    pathRewrapJobsHelpSyn,
+		HelpDescription: pathRewrapJobsHelpDesc,
+	}
+}
+
+func (b *backend) pathRewrapJob() *framework.Path {
+	return &framework.Path{
+		Pattern: "rewrap-jobs/" + framework.GenericNameRegex("name") + "/" + framework.GenericNameRegex("job_id") + "$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+
+			"job_id": {
+				Type:        framework.TypeString,
+				Description: "ID of the rewrap job",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathRewrapJobRead,
+			logical.DeleteOperation: b.pathRewrapJobDelete,
+		},
+
+		HelpSynopsis:    pathRewrapJobHelpSyn,
+		HelpDescription: pathRewrapJobHelpDesc,
+	}
+}
+
+func (b *backend) pathRewrapJobResults() *framework.Path {
+	return &framework.Path{
+		Pattern: "rewrap-jobs/" + framework.GenericNameRegex("name") + "/" + framework.GenericNameRegex("job_id") + "/results$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+
+			"job_id": {
+				Type:        framework.TypeString,
+				Description: "ID of the rewrap job",
+			},
+
+			"offset": {
+				Type:        framework.TypeInt,
+				Description: "Index of the first result to return.",
+			},
+
+			"limit": {
+				Type:        framework.TypeInt,
+				Description: "Maximum number of results to return. Defaults to 1000, and may be at most 10000.",
+				Default:     rewrapJobDefaultLimit,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathRewrapJobResultsRead,
+		},
+
+		HelpSynopsis:    pathRewrapJobResultsHelpSyn,
+		HelpDescription: pathRewrapJobResultsHelpDesc,
+	}
+}
+
+func (b *backend) pathRewrapJobsWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if req.HTTPRequest == nil || req.HTTPRequest.Body == nil {
+		return logical.ErrorResponse("the ciphertexts must be sent as the request body with a Content-Type of application/x-ndjson"), logical.ErrInvalidRequest
+	}
+
+	name := d.Get("name").(string)
+	keyVersion := d.Get("key_version").(int)
+
+	p, _, err := b.lm.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: req.Storage,
+		Name:    name,
+	}, b.GetRandomReader())
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("encryption key not found"), logical.ErrInvalidRequest
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(false)
+	}
+	operationErr := p.CheckOperation(keysutil.KeyOperationRewrap)
+	encryptionSupported := p.Type.EncryptionSupported()
+	latestVersion, minEncryptionVersion := p.LatestVersion, p.MinEncryptionVersion
+	p.Unlock()
+
+	if operationErr != nil {
+		return logical.ErrorResponse(operationErr.Error()), logical.ErrInvalidRequest
+	}
+
+	if !encryptionSupported {
+		return logical.ErrorResponse(fmt.Sprintf("key type %v does not support encryption", p.Type)), logical.ErrInvalidRequest
+	}
+	switch {
+	case keyVersion == 0:
+		keyVersion = latestVersion
+	case keyVersion < 0 || keyVersion > latestVersion:
+		return logical.ErrorResponse("invalid key version"), logical.ErrInvalidRequest
+	case minEncryptionVersion > 0 && keyVersion < minEncryptionVersion:
+		return logical.ErrorResponse("cannot rewrap to a key version lower than the policy's minimum encryption version"), logical.ErrInvalidRequest
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	// The upload is stored page by page as it is read, so that it does not
+	// have to fit in memory
+	view := logical.NewStorageView(req.Storage, rewrapJobDataPrefix(name, id))
+	total, err := storeRewrapJobInput(ctx, view, req.HTTPRequest.Body)
+	if err == nil && total == 0 {
+		err = errutil.UserError{Err: "no ciphertexts found in the request body"}
+	}
+	if err != nil {
+		if clearErr := logical.ClearView(ctx, view); clearErr != nil {
+			b.Logger().Error("failed to clean up rewrap job input", "job_id", id, "error", clearErr)
+		}
+		if _, ok := err.(errutil.UserError); ok {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+		return nil, err
+	}
+
+	job := &rewrapJob{
+		ID:           id,
+		Name:         name,
+		KeyVersion:   keyVersion,
+		State:        rewrapJobStatePending,
+		Total:        total,
+		CreationTime: time.Now(),
+	}
+	if err := putRewrapJob(ctx, req.Storage, job); err != nil {
+		return nil, err
+	}
+
+	b.startRewrapJob(req.Storage, name, id)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"job_id":      id,
+			"key_version": keyVersion,
+			"total":       total,
+		},
+	}, nil
+}
+
+// storeRewrapJobInput parses the uploaded NDJSON document into pages of
+// items, returning the number of items stored
+func storeRewrapJobInput(ctx context.Context, view *logical.StorageView, body io.Reader) (int, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), rewrapJobMaxLineSize)
+
+	var total, line int
+	page := make([]rewrapJobItem, 0, rewrapJobPageSize)
+	flush := func() error {
+		if len(page) == 0 {
+			return nil
+		}
+		entry, err := logical.StorageEntryJSON("input/"+strconv.Itoa(total/rewrapJobPageSize), page)
+		if err != nil {
+			return err
+		}
+		if err := view.Put(ctx, entry); err != nil {
+			return err
+		}
+		total += len(page)
+		page = page[:0]
+		return nil
+	}
+
+	for scanner.Scan() {
+		line++
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		var item rewrapJobItem
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			return 0, errutil.UserError{Err: fmt.Sprintf("failed to parse line %d: %v", line, err)}
+		}
+		page = append(page, item)
+
+		if len(page) == rewrapJobPageSize {
+			if err := flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if err == bufio.ErrTooLong {
+			return 0, errutil.UserError{Err: fmt.Sprintf("line %d exceeds the maximum length of %d bytes", line+1, rewrapJobMaxLineSize)}
+		}
+		return 0, errwrap.Wrapf("failed to read request body: {{err}}", err)
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+func (b *backend) pathRewrapJobsList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	ids, err := req.Storage.List(ctx, "rewrap-job/"+d.Get("name").(string)+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(ids), nil
+}
+
+func (b *backend) pathRewrapJobRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	job, err := getRewrapJob(ctx, req.Storage, d.Get("name").(string), d.Get("job_id").(string))
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, nil
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"job_id":        job.ID,
+			"name":          job.Name,
+			"key_version":   job.KeyVersion,
+			"state":         job.State,
+			"total":         job.Total,
+			"processed":     job.Processed,
+			"succeeded":     job.Succeeded,
+			"failed":        job.Failed,
+			"creation_time": job.CreationTime,
+		},
+	}
+	if !job.StartTime.IsZero() {
+		resp.Data["start_time"] = job.StartTime
+	}
+	if !job.CompletionTime.IsZero() {
+		resp.Data["completion_time"] = job.CompletionTime
+	}
+	if job.Error != "" {
+		resp.Data["error"] = job.Error
+	}
+
+	return resp, nil
+}
+
+func (b *backend) pathRewrapJobResultsRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	id := d.Get("job_id").(string)
+
+	offset := d.Get("offset").(int)
+	if offset < 0 {
+		return logical.ErrorResponse("offset must not be negative"), logical.ErrInvalidRequest
+	}
+	limit := d.Get("limit").(int)
+	if limit <= 0 || limit > rewrapJobMaxLimit {
+		return logical.ErrorResponse(fmt.Sprintf("limit must be between 1 and %d", rewrapJobMaxLimit)), logical.ErrInvalidRequest
+	}
+
+	job, err := getRewrapJob(ctx, req.Storage, name, id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, nil
+	}
+
+	// Only the results of processed pages are available
+	end := offset + limit
+	if end > job.Processed {
+		end = job.Processed
+	}
+
+	view := logical.NewStorageView(req.Storage, rewrapJobDataPrefix(name, id))
+	results := make([]rewrapJobResult, 0)
+	for index := offset; index < end; {
+		var page []EncryptBatchResponseItem
+		if err := getRewrapJobPage(ctx, view, "results/", index/rewrapJobPageSize, &page); err != nil {
+			return nil, err
+		}
+		for i := index % rewrapJobPageSize; i < len(page) && index < end; i++ {
+			results = append(results, rewrapJobResult{
+				Index:                    index,
+				EncryptBatchResponseItem: page[i],
+			})
+			index++
+		}
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"state":   job.State,
+			"results": results,
+		},
+	}
+	if next := offset + len(results); next < job.Total {
+		resp.Data["next_offset"] = next
+	}
+
+	return resp, nil
+}
+
+func (b *backend) pathRewrapJobDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	id := d.Get("job_id").(string)
+
+	b.stopRewrapJob(id)
+
+	if err := logical.ClearView(ctx, logical.NewStorageView(req.Storage, rewrapJobDataPrefix(name, id))); err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Delete(ctx, rewrapJobPath(name, id)); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func getRewrapJob(ctx context.Context, s logical.Storage, name, id string) (*rewrapJob, error) {
+	entry, err := s.Get(ctx, rewrapJobPath(name, id))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var job rewrapJob
+	if err := entry.DecodeJSON(&job); err != nil {
+		return nil, errwrap.Wrapf("failed to decode rewrap job: {{err}}", err)
+	}
+	return &job, nil
+}
+
+func putRewrapJob(ctx context.Context, s logical.Storage, job *rewrapJob) error {
+	entry, err := logical.StorageEntryJSON(rewrapJobPath(job.Name, job.ID), job)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+func getRewrapJobPage(ctx context.Context, view *logical.StorageView, prefix string, page int, out interface{}) error {
+	entry, err := view.Get(ctx, prefix+strconv.Itoa(page))
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return fmt.Errorf("rewrap job page %s%d not found", prefix, page)
+	}
+	return entry.DecodeJSON(out)
+}
+
+// startRewrapJob processes the job in the background, unless this node is
+// already doing so
+func (b *backend) startRewrapJob(s logical.Storage, name, id string) {
+	b.rewrapJobsLock.Lock()
+	defer b.rewrapJobsLock.Unlock()
+
+	if _, ok := b.rewrapJobs[id]; ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runner := &rewrapJobRunner{
+		cancel: cancel,
+		doneCh: make(chan struct{}),
+	}
+	b.rewrapJobs[id] = runner
+
+	go func() {
+		defer func() {
+			b.rewrapJobsLock.Lock()
+			delete(b.rewrapJobs, id)
+			b.rewrapJobsLock.Unlock()
+			cancel()
+			close(runner.doneCh)
+		}()
+
+		logger := b.Logger().Named("rewrap-job").With("job_id", id)
+		if err := b.runRewrapJob(ctx, s, name, id); err != nil {
+			logger.Error("failed to process rewrap job; it will be resumed later", "error", err)
+		}
+	}()
+}
+
+// stopRewrapJob cancels the job if this node is processing it, and waits for
+// it to stop
+func (b *backend) stopRewrapJob(id string) {
+	b.rewrapJobsLock.Lock()
+	runner, ok := b.rewrapJobs[id]
+	b.rewrapJobsLock.Unlock()
+	if !ok {
+		return
+	}
+
+	runner.cancel()
+	<-runner.doneCh
+}
+
+// clearRewrapJobs stops the jobs of the named key this node is processing, and
+// deletes every job of the key along with its input and results. It is called
+// once the key is deleted, so that no new job can be created meanwhile.
+func (b *backend) clearRewrapJobs(ctx context.Context, s logical.Storage, name string) error {
+	ids, err := s.List(ctx, "rewrap-job/"+name+"/")
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		b.stopRewrapJob(id)
+	}
+
+	if err := logical.ClearView(ctx, logical.NewStorageView(s, "rewrap-job-data/"+name+"/")); err != nil {
+		return err
+	}
+	return logical.ClearView(ctx, logical.NewStorageView(s, "rewrap-job/"+name+"/"))
+}
+
+// stopRewrapJobs stops every job this node is processing; they are resumed
+// once the backend is set up again
+func (b *backend) stopRewrapJobs(_ context.Context) {
+	b.rewrapJobsLock.Lock()
+	runners := make([]*rewrapJobRunner, 0, len(b.rewrapJobs))
+	for _, runner := range b.rewrapJobs {
+		runners = append(runners, runner)
+	}
+	b.rewrapJobsLock.Unlock()
+
+	for _, runner := range runners {
+		runner.cancel()
+		<-runner.doneCh
+	}
+}
+
+// resumeRewrapJobs starts the unfinished jobs, such as those interrupted by a
+// restart or a leadership change
+func (b *backend) resumeRewrapJobs(ctx context.Context, req *logical.Request) error {
+	names, err := req.Storage.List(ctx, "rewrap-job/")
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		name = strings.TrimSuffix(name, "/")
+		ids, err := req.Storage.List(ctx, "rewrap-job/"+name+"/")
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			job, err := getRewrapJob(ctx, req.Storage, name, id)
+			if err != nil {
+				return err
+			}
+			if job != nil && !job.finished() {
+				b.startRewrapJob(req.Storage, name, id)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (b *backend) runRewrapJob(ctx context.Context, s logical.Storage, name, id string) error {
+	job, err := getRewrapJob(ctx, s, name, id)
+	if err != nil || job == nil || job.finished() {
+		return err
+	}
+
+	if job.State == rewrapJobStatePending {
+		job.State = rewrapJobStateRunning
+		job.StartTime = time.Now()
+		if err := putRewrapJob(ctx, s, job); err != nil {
+			return err
+		}
+	}
+
+	view := logical.NewStorageView(s, rewrapJobDataPrefix(name, id))
+
+	// Every page but the last is full, so processing picks up at the first
+	// page without results
+	for page := job.Processed / rewrapJobPageSize; job.Processed < job.Total; page++ {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		var items []rewrapJobItem
+		if err := getRewrapJobPage(ctx, view, "input/", page, &items); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		results, err := b.rewrapJobItems(ctx, s, name, job.KeyVersion, items)
+		if err != nil {
+			if _, ok := err.(errutil.UserError); ok {
+				job.State = rewrapJobStateFailed
+				job.Error = err.Error()
+				job.CompletionTime = time.Now()
+				return putRewrapJob(ctx, s, job)
+			}
+			return err
+		}
+
+		entry, err := logical.StorageEntryJSON("results/"+strconv.Itoa(page), results)
+		if err != nil {
+			return err
+		}
+		if err := view.Put(ctx, entry); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		for _, result := range results {
+			if result.Error != "" {
+				job.Failed++
+			} else {
+				job.Succeeded++
+			}
+		}
+		job.Processed += len(results)
+		if err := putRewrapJob(ctx, s, job); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+	}
+
+	job.State = rewrapJobStateCompleted
+	job.CompletionTime = time.Now()
+	return putRewrapJob(ctx, s, job)
+}
+
+// rewrapJobItems rewraps a page of the job's ciphertexts. Failures specific
+// to an item are reported in its result.
+func (b *backend) rewrapJobItems(ctx context.Context, s logical.Storage, name string, keyVersion int, items []rewrapJobItem) ([]EncryptBatchResponseItem, error) {
+	p, _, err := b.lm.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: s,
+		Name:    name,
+	}, b.GetRandomReader())
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, errutil.UserError{Err: "encryption key not found"}
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(false)
+	}
+	defer p.Unlock()
+
+	// The key may have been restricted since the job was created
+	if err := p.CheckOperation(keysutil.KeyOperationRewrap); err != nil {
+		return nil, err
+	}
+
+	results := make([]EncryptBatchResponseItem, len(items))
+	for i, item := range items {
+		ciphertext, err := rewrapJobItemWithPolicy(p, keyVersion, item)
+		if err != nil {
+			if _, ok := err.(errutil.UserError); ok {
+				results[i].Error = err.Error()
+				continue
+			}
+			return nil, err
+		}
+
+		results[i].Ciphertext = ciphertext
+		results[i].KeyVersion = keyVersion
+	}
+
+	return results, nil
+}
+
+func rewrapJobItemWithPolicy(p *keysutil.Policy, keyVersion int, item rewrapJobItem) (string, error) {
+	if item.Ciphertext == "" {
+		return "", errutil.UserError{Err: "missing ciphertext to decrypt"}
+	}
+
+	var decodedContext, decodedNonce []byte
+	var err error
+	if len(item.Context) != 0 {
+		decodedContext, err = base64.StdEncoding.DecodeString(item.Context)
+		if err != nil {
+			return "", errutil.UserError{Err: "failed to base64-decode context"}
+		}
+	}
+	if len(item.Nonce) != 0 {
+		decodedNonce, err = base64.StdEncoding.DecodeString(item.Nonce)
+		if err != nil {
+			return "", errutil.UserError{Err: "failed to base64-decode nonce"}
+		}
+	}
+
+	plaintext, err := p.Decrypt(decodedContext, decodedNonce, item.Ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := p.Encrypt(keyVersion, decodedContext, decodedNonce, plaintext)
+	if err != nil {
+		return "", err
+	}
+	if ciphertext == "" {
+		return "", fmt.Errorf("empty ciphertext returned")
+	}
+
+	return ciphertext, nil
+}
+
+const pathRewrapJobsHelpSyn = `Start or list jobs rewrapping uploaded ciphertexts with the latest version of the named key`
+
+const pathRewrapJobsHelpDesc = `
+Writing to this path starts a job rewrapping a large number of
+ciphertexts in the background. The ciphertexts are sent as the raw
+request body with a Content-Type of application/x-ndjson, one JSON
+object per line holding the "ciphertext" and, if needed, the base64
+encoded "context" and "nonce". The job rewraps every ciphertext to the
+key version current when it was created, or to the version given in the
+"key_version" query parameter, and is resumed automatically if it is
+interrupted.
+
+Listing this path returns the IDs of the jobs of the named key.
+`
+
+const pathRewrapJobHelpSyn = `Read the progress of, or delete, a rewrap job`
+
+const pathRewrapJobHelpDesc = `
+Reading this path returns the state of the rewrap job along with the
+number of ciphertexts processed so far. Deleting it cancels the job if
+it is still running and removes its input and results, which are
+otherwise kept until the job is deleted.
+`
+
+const pathRewrapJobResultsHelpSyn = `Page through the results of a rewrap job`
+
+const pathRewrapJobResultsHelpDesc = `
+This path returns the rewrapped ciphertexts of the job, each along with
+the index of its line in the uploaded document, or the error that
+prevented it from being rewrapped. Results are available as soon as
+they have been processed; "next_offset" is set while further results
+exist or are still to be processed.
+`
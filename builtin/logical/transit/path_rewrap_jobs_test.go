@@ -0,0 +1,275 @@
+package transit
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestTransit_RewrapJobs(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: op,
+			Path:      path,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+	upload := func(path, body string) (*logical.Response, error) {
+		httpReq, err := http.NewRequest(http.MethodPost, "/v1/transit/"+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Storage:     storage,
+			Operation:   logical.UpdateOperation,
+			Path:        path,
+			HTTPRequest: httpReq,
+		})
+	}
+
+	request(logical.UpdateOperation, "keys/existing", nil)
+
+	// Encrypt enough values to span several pages, then rotate the key
+	count := rewrapJobPageSize*2 + 10
+	var lines []string
+	for i := 0; i < count; i++ {
+		resp := request(logical.UpdateOperation, "encrypt/existing", map[string]interface{}{
+			"plaintext": base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("value %d", i))),
+		})
+		lines = append(lines, fmt.Sprintf(`{"ciphertext": %q}`, resp.Data["ciphertext"].(string)))
+	}
+	lines = append(lines, "", `{"ciphertext": "vault:v1:bm90IHZhbGlk"}`)
+	request(logical.UpdateOperation, "keys/existing/rotate", nil)
+
+	resp, err := upload("rewrap-jobs/existing", strings.Join(lines, "\n"))
+	if err != nil || resp.IsError() {
+		t.Fatalf("err: %v resp: %#v", err, resp)
+	}
+	jobID := resp.Data["job_id"].(string)
+	if resp.Data["total"] != count+1 || resp.Data["key_version"] != 2 {
+		t.Fatalf("bad job: %#v", resp.Data)
+	}
+
+	resp = request(logical.ListOperation, "rewrap-jobs/existing/", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != jobID {
+		t.Fatalf("bad list: %#v", resp.Data)
+	}
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		resp = request(logical.ReadOperation, "rewrap-jobs/existing/"+jobID, nil)
+		if resp.Data["state"] == rewrapJobStateCompleted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job did not complete: %#v", resp.Data)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if resp.Data["processed"] != count+1 || resp.Data["succeeded"] != count || resp.Data["failed"] != 1 {
+		t.Fatalf("bad job status: %#v", resp.Data)
+	}
+
+	// Page through the results
+	var results []rewrapJobResult
+	offset := 0
+	for {
+		resp = request(logical.ReadOperation, "rewrap-jobs/existing/"+jobID+"/results", map[string]interface{}{
+			"offset": offset,
+			"limit":  200,
+		})
+		results = append(results, resp.Data["results"].([]rewrapJobResult)...)
+		next, ok := resp.Data["next_offset"]
+		if !ok {
+			break
+		}
+		offset = next.(int)
+	}
+	if len(results) != count+1 {
+		t.Fatalf("expected %d results, got %d", count+1, len(results))
+	}
+	for i, result := range results[:count] {
+		if result.Index != i || result.Error != "" || result.KeyVersion != 2 || !strings.HasPrefix(result.Ciphertext, "vault:v2:") {
+			t.Fatalf("bad result %d: %#v", i, result)
+		}
+	}
+	if results[count].Error == "" {
+		t.Fatalf("expected an error for the invalid ciphertext, got %#v", results[count])
+	}
+
+	resp = request(logical.UpdateOperation, "decrypt/existing", map[string]interface{}{
+		"ciphertext": results[42].Ciphertext,
+	})
+	if plaintext, _ := base64.StdEncoding.DecodeString(resp.Data["plaintext"].(string)); string(plaintext) != "value 42" {
+		t.Fatalf("bad plaintext: %q", plaintext)
+	}
+
+	// Deleting the job removes its data
+	request(logical.DeleteOperation, "rewrap-jobs/existing/"+jobID, nil)
+	resp = request(logical.ReadOperation, "rewrap-jobs/existing/"+jobID, nil)
+	if resp != nil {
+		t.Fatalf("expected job to be deleted, got %#v", resp)
+	}
+	keys, err := storage.List(context.Background(), rewrapJobDataPrefix("existing", jobID))
+	if err != nil || len(keys) != 0 {
+		t.Fatalf("expected job data to be deleted, got %v %v", keys, err)
+	}
+
+	// Malformed uploads are rejected without creating a job
+	for _, body := range []string{"", `{"ciphertext": "vault:v1:abc"}` + "\nnot json"} {
+		resp, err = upload("rewrap-jobs/existing", body)
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("expected error for body %q, got %#v", body, resp)
+		}
+	}
+	resp, err = upload("rewrap-jobs/missing", lines[0])
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatalf("expected error for missing key, got %#v", resp)
+	}
+	keys, err = storage.List(context.Background(), "rewrap-job-data/existing/")
+	if err != nil || len(keys) != 0 {
+		t.Fatalf("expected no job data, got %v %v", keys, err)
+	}
+}
+
+func TestTransit_RewrapJobs_Resume(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+	ctx := context.Background()
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "keys/existing",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v resp: %#v", err, resp)
+	}
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "encrypt/existing",
+		Data: map[string]interface{}{
+			"plaintext": base64.StdEncoding.EncodeToString([]byte("value")),
+		},
+	})
+	if err != nil || resp.IsError() {
+		t.Fatalf("err: %v resp: %#v", err, resp)
+	}
+
+	// Simulate a job interrupted before it started
+	view := logical.NewStorageView(storage, rewrapJobDataPrefix("existing", "interrupted"))
+	total, err := storeRewrapJobInput(ctx, view, strings.NewReader(fmt.Sprintf(`{"ciphertext": %q}`, resp.Data["ciphertext"])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := putRewrapJob(ctx, storage, &rewrapJob{
+		ID:    "interrupted",
+		Name:  "existing",
+		State: rewrapJobStateRunning,
+		Total: total,
+		// Rewrap to the same version
+		KeyVersion: 1,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.resumeRewrapJobs(ctx, &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		job, err := getRewrapJob(ctx, storage, "existing", "interrupted")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.finished() {
+			if job.State != rewrapJobStateCompleted || job.Succeeded != 1 {
+				t.Fatalf("bad job: %#v", job)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job was not resumed: %#v", job)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestTransit_RewrapJobs_KeyDelete(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+	ctx := context.Background()
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Storage:   storage,
+			Operation: op,
+			Path:      path,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	request(logical.UpdateOperation, "keys/existing", nil)
+	request(logical.UpdateOperation, "keys/existing/config", map[string]interface{}{
+		"deletion_allowed": true,
+	})
+	resp := request(logical.UpdateOperation, "encrypt/existing", map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString([]byte("value")),
+	})
+	line := fmt.Sprintf(`{"ciphertext": %q}`, resp.Data["ciphertext"])
+
+	// Start a job spanning several pages, and delete the key while it may
+	// still be running
+	var lines []string
+	for i := 0; i < rewrapJobPageSize*4; i++ {
+		lines = append(lines, line)
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, "/v1/transit/rewrap-jobs/existing", strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Storage:     storage,
+		Operation:   logical.UpdateOperation,
+		Path:        "rewrap-jobs/existing",
+		HTTPRequest: httpReq,
+	})
+	if err != nil || resp.IsError() {
+		t.Fatalf("err: %v resp: %#v", err, resp)
+	}
+
+	request(logical.DeleteOperation, "keys/existing", nil)
+
+	// The jobs are stopped and removed along with their data
+	b.rewrapJobsLock.Lock()
+	running := len(b.rewrapJobs)
+	b.rewrapJobsLock.Unlock()
+	if running != 0 {
+		t.Fatalf("expected no running jobs, got %d", running)
+	}
+	for _, prefix := range []string{"rewrap-job/", "rewrap-job-data/"} {
+		keys, err := logical.CollectKeysWithPrefix(ctx, storage, prefix)
+		if err != nil || len(keys) != 0 {
+			t.Fatalf("expected %s to be empty, got %v %v", prefix, keys, err)
+		}
+	}
+}
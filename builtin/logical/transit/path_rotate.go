@@ -2,8 +2,14 @@ package transit
 
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"time"
 
+	"github.com/hashicorp/errwrap"
+	multierror "github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
 	"github.com/hashicorp/vault/sdk/helper/keysutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
@@ -49,9 +55,79 @@ func (b *backend) pathRotateWrite(ctx context.Context, req *logical.Request, d *
 	err = p.Rotate(ctx, req.Storage, b.GetRandomReader())
 
 	p.Unlock()
+	if _, ok := err.(errutil.UserError); ok {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
 	return nil, err
 }
 
+// autoRotateKeys rotates every key whose latest version is older than its
+// auto_rotate_period. Keys are checked every time the periodic function runs,
+// so that they are rotated close to their rotation time.
+func (b *backend) autoRotateKeys(ctx context.Context, req *logical.Request) error {
+	keys, err := req.Storage.List(ctx, "policy/")
+	if err != nil {
+		return err
+	}
+
+	var result error
+	for _, name := range keys {
+		p, _, err := b.lm.GetPolicy(ctx, keysutil.PolicyRequest{
+			Storage: req.Storage,
+			Name:    name,
+		}, b.GetRandomReader())
+		if err != nil {
+			result = multierror.Append(result, err)
+			continue
+		}
+		if p == nil {
+			continue
+		}
+
+		if err := b.rotateIfRequired(ctx, req, p); err != nil {
+			result = multierror.Append(result, errwrap.Wrapf(fmt.Sprintf("error rotating key %q: {{err}}", name), err))
+		}
+	}
+
+	return result
+}
+
+// rotateIfRequired rotates the key if automatic rotation is enabled and the
+// latest version is older than the rotation period
+func (b *backend) rotateIfRequired(ctx context.Context, req *logical.Request, p *keysutil.Policy) error {
+	if !b.System().CachingDisabled() {
+		p.Lock(true)
+	}
+	defer p.Unlock()
+
+	if p.AutoRotatePeriod == 0 {
+		return nil
+	}
+
+	// Imported keys are only rotated within Vault when explicitly allowed
+	if p.Imported && !p.AllowImportedKeyRotation {
+		return nil
+	}
+
+	if time.Now().Before(lastRotationTime(p).Add(p.AutoRotatePeriod)) {
+		return nil
+	}
+
+	if b.Logger().IsDebug() {
+		b.Logger().Debug("automatically rotating key", "key", p.Name)
+	}
+	return p.Rotate(ctx, req.Storage, b.GetRandomReader())
+}
+
+// lastRotationTime returns the creation time of the latest version of the key
+func lastRotationTime(p *keysutil.Policy) time.Time {
+	entry := p.Keys[strconv.Itoa(p.LatestVersion)]
+	if entry.CreationTime.IsZero() {
+		return time.Unix(entry.DeprecatedCreationTime, 0)
+	}
+	return entry.CreationTime
+}
+
 const pathRotateHelpSyn = `Rotate named encryption key`
 
 const pathRotateHelpDesc = `
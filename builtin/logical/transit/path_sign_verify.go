@@ -164,6 +164,11 @@ derivation is enabled; currently only available with ed25519 keys.`,
 				Description: "The HMAC, including vault header/key version",
 			},
 
+			"cmac": {
+				Type:        framework.TypeString,
+				Description: "The CMAC, including vault header/key version",
+			},
+
 			"input": {
 				Type:        framework.TypeString,
 				Description: "The base64-encoded input data to verify",
@@ -261,6 +266,11 @@ func (b *backend) pathSignWrite(ctx context.Context, req *logical.Request, d *fr
 		p.Lock(false)
 	}
 
+	if err := p.CheckOperation(keysutil.KeyOperationSign); err != nil {
+		p.Unlock()
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
 	if !p.Type.SigningSupported() {
 		p.Unlock()
 		return logical.ErrorResponse(fmt.Sprintf("key type %v does not support signing", p.Type)), logical.ErrInvalidRequest
@@ -399,34 +409,51 @@ func (b *backend) pathVerifyWrite(ctx context.Context, req *logical.Request, d *
 		if hmac, ok := d.GetOk("hmac"); ok {
 			batchInputItems[0]["hmac"] = hmac.(string)
 		}
+		if cmac, ok := d.GetOk("cmac"); ok {
+			batchInputItems[0]["cmac"] = cmac.(string)
+		}
 		batchInputItems[0]["context"] = d.Get("context").(string)
 	}
 
-	// For simplicity, 'signature' and 'hmac' cannot be mixed across batch_input elements.
+	// For simplicity, 'signature', 'hmac' and 'cmac' cannot be mixed across batch_input elements.
 	// If one batch_input item is 'signature', they all must be 'signature'.
 	// If one batch_input item is 'hmac', they all must be 'hmac'.
+	// If one batch_input item is 'cmac', they all must be 'cmac'.
 	sigFound := false
 	hmacFound := false
+	cmacFound := false
+	mixed := false
 	missing := false
 	for _, v := range batchInputItems {
-		if _, ok := v["signature"]; ok {
+		_, hasSig := v["signature"]
+		_, hasHMAC := v["hmac"]
+		_, hasCMAC := v["cmac"]
+		switch {
+		case hasSig && !hasHMAC && !hasCMAC:
 			sigFound = true
-		} else if _, ok := v["hmac"]; ok {
+		case hasHMAC && !hasSig && !hasCMAC:
 			hmacFound = true
-		} else {
+		case hasCMAC && !hasSig && !hasHMAC:
+			cmacFound = true
+		case hasSig || hasHMAC || hasCMAC:
+			mixed = true
+		default:
 			missing = true
 		}
 	}
+	if (sigFound && hmacFound) || (sigFound && cmacFound) || (hmacFound && cmacFound) {
+		mixed = true
+	}
 
 	switch {
-	case batchInputRaw == nil && sigFound && hmacFound:
-		return logical.ErrorResponse("provide one of 'signature' or 'hmac'"), logical.ErrInvalidRequest
+	case batchInputRaw == nil && mixed:
+		return logical.ErrorResponse("provide one of 'signature', 'hmac' or 'cmac'"), logical.ErrInvalidRequest
 
-	case batchInputRaw == nil && !sigFound && !hmacFound:
-		return logical.ErrorResponse("neither a 'signature' nor an 'hmac' were given to verify"), logical.ErrInvalidRequest
+	case batchInputRaw == nil && missing:
+		return logical.ErrorResponse("neither a 'signature', an 'hmac' nor a 'cmac' were given to verify"), logical.ErrInvalidRequest
 
-	case sigFound && hmacFound:
-		return logical.ErrorResponse("elements of batch_input must all provide 'signature' or all provide 'hmac'"), logical.ErrInvalidRequest
+	case mixed:
+		return logical.ErrorResponse("elements of batch_input must all provide 'signature', all provide 'hmac' or all provide 'cmac'"), logical.ErrInvalidRequest
 
 	case missing && sigFound:
 		return logical.ErrorResponse("some elements of batch_input are missing 'signature'"), logical.ErrInvalidRequest
@@ -434,11 +461,17 @@ func (b *backend) pathVerifyWrite(ctx context.Context, req *logical.Request, d *
 	case missing && hmacFound:
 		return logical.ErrorResponse("some elements of batch_input are missing 'hmac'"), logical.ErrInvalidRequest
 
+	case missing && cmacFound:
+		return logical.ErrorResponse("some elements of batch_input are missing 'cmac'"), logical.ErrInvalidRequest
+
 	case missing:
-		return logical.ErrorResponse("no batch_input elements have 'signature' or 'hmac'"), logical.ErrInvalidRequest
+		return logical.ErrorResponse("no batch_input elements have 'signature', 'hmac' or 'cmac'"), logical.ErrInvalidRequest
 
 	case hmacFound:
 		return b.pathHMACVerify(ctx, req, d)
+
+	case cmacFound:
+		return b.pathCMACVerify(ctx, req, d)
 	}
 
 	name := d.Get("name").(string)
@@ -479,6 +512,11 @@ func (b *backend) pathVerifyWrite(ctx context.Context, req *logical.Request, d *
 		p.Lock(false)
 	}
 
+	if err := p.CheckOperation(keysutil.KeyOperationVerify); err != nil {
+		p.Unlock()
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
 	if !p.Type.SigningSupported() {
 		p.Unlock()
 		return logical.ErrorResponse(fmt.Sprintf("key type %v does not support verification", p.Type)), logical.ErrInvalidRequest
@@ -571,8 +609,8 @@ const pathSignHelpSyn = `Generate a signature for input data using the named key
 const pathSignHelpDesc = `
 Generates a signature of the input data using the named key and the given hash algorithm.
 `
-const pathVerifyHelpSyn = `Verify a signature or HMAC for input data created using the named key`
+const pathVerifyHelpSyn = `Verify a signature, HMAC or CMAC for input data created using the named key`
 
 const pathVerifyHelpDesc = `
-Verifies a signature or HMAC of the input data using the named key and the given hash algorithm.
+Verifies a signature, HMAC or CMAC of the input data using the named key and the given hash algorithm.
 `
@@ -0,0 +1,181 @@
+//go:build go1.26
+// +build go1.26
+
+package transit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/mldsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestTransit_SignVerify_MLDSA(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	request := func(path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		var op logical.Operation = logical.UpdateOperation
+		if data == nil {
+			op = logical.ReadOperation
+		}
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: op,
+			Path:      path,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	input := base64.StdEncoding.EncodeToString([]byte("the quick brown fox"))
+	otherInput := base64.StdEncoding.EncodeToString([]byte("the lazy dog"))
+
+	for _, keyType := range []string{"ml-dsa-44", "ml-dsa-65", "ml-dsa-87", "ml-dsa-65-ed25519"} {
+		t.Run(keyType, func(t *testing.T) {
+			request("keys/"+keyType, map[string]interface{}{
+				"type":       keyType,
+				"exportable": true,
+			})
+
+			resp := request("keys/"+keyType, nil)
+			if resp.Data["type"] != keyType || !resp.Data["supports_signing"].(bool) {
+				t.Fatalf("bad key: %#v", resp.Data)
+			}
+			publicKey := resp.Data["keys"].(map[string]map[string]interface{})["1"]["public_key"].(string)
+
+			resp = request("sign/"+keyType, map[string]interface{}{
+				"input": input,
+			})
+			signature := resp.Data["signature"].(string)
+
+			resp = request("verify/"+keyType, map[string]interface{}{
+				"input":     input,
+				"signature": signature,
+			})
+			if !resp.Data["valid"].(bool) {
+				t.Fatal("expected signature to be valid")
+			}
+			resp = request("verify/"+keyType, map[string]interface{}{
+				"input":     otherInput,
+				"signature": signature,
+			})
+			if resp.Data["valid"].(bool) {
+				t.Fatal("expected signature over other input to be invalid")
+			}
+
+			// Check the signature against the exported public key
+			sigBytes, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(signature, "vault:v1:"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var pubKeys []interface{}
+			rest := []byte(publicKey)
+			for {
+				var block *pem.Block
+				block, rest = pem.Decode(rest)
+				if block == nil {
+					break
+				}
+				pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pubKeys = append(pubKeys, pub)
+			}
+
+			message := []byte("the quick brown fox")
+			if keyType == "ml-dsa-65-ed25519" {
+				if len(pubKeys) != 2 {
+					t.Fatalf("expected two public keys, got %d", len(pubKeys))
+				}
+				label := "vault-transit:" + keyType
+				pqSig, edSig := sigBytes[:len(sigBytes)-ed25519.SignatureSize], sigBytes[len(sigBytes)-ed25519.SignatureSize:]
+				if err := mldsa.Verify(pubKeys[0].(*mldsa.PublicKey), message, pqSig, &mldsa.Options{Context: label}); err != nil {
+					t.Fatalf("ML-DSA half of the signature is invalid: %v", err)
+				}
+				if !ed25519.Verify(pubKeys[1].(ed25519.PublicKey), append([]byte(label+"\x00"), message...), edSig) {
+					t.Fatal("Ed25519 half of the signature is invalid")
+				}
+
+				// Neither half is accepted on its own
+				for _, partial := range [][]byte{pqSig, edSig} {
+					resp = request("verify/"+keyType, map[string]interface{}{
+						"input":     input,
+						"signature": "vault:v1:" + base64.StdEncoding.EncodeToString(partial),
+					})
+					if resp.Data["valid"].(bool) {
+						t.Fatal("expected partial signature to be invalid")
+					}
+				}
+			} else {
+				if len(pubKeys) != 1 {
+					t.Fatalf("expected one public key, got %d", len(pubKeys))
+				}
+				if err := mldsa.Verify(pubKeys[0].(*mldsa.PublicKey), message, sigBytes, nil); err != nil {
+					t.Fatalf("signature is invalid: %v", err)
+				}
+			}
+
+			resp = request("export/signing-key/"+keyType, nil)
+			exported, err := base64.StdEncoding.DecodeString(resp.Data["keys"].(map[string]string)["1"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectedLen := mldsa.PrivateKeySize
+			if keyType == "ml-dsa-65-ed25519" {
+				expectedLen += ed25519.PrivateKeySize
+			}
+			if len(exported) != expectedLen {
+				t.Fatalf("expected %d bytes of exported key, got %d", expectedLen, len(exported))
+			}
+		})
+	}
+
+	// Keys can be imported from PKCS#8
+	privKey, err := mldsa.GenerateKey(mldsa.MLDSA65())
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request("keys/imported/import", map[string]interface{}{
+		"type":       "ml-dsa-65",
+		"ciphertext": wrapKeyForImport(t, b, storage, der),
+	})
+	resp := request("sign/imported", map[string]interface{}{
+		"input": input,
+	})
+	sigBytes, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(resp.Data["signature"].(string), "vault:v1:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mldsa.Verify(privKey.PublicKey(), []byte("the quick brown fox"), sigBytes, nil); err != nil {
+		t.Fatalf("signature of imported key is invalid: %v", err)
+	}
+
+	// Derivation is not supported
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "keys/derived",
+		Data: map[string]interface{}{
+			"type":    "ml-dsa-65",
+			"derived": true,
+		},
+	})
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatalf("expected error creating derived key, got %#v", resp)
+	}
+}
@@ -0,0 +1,282 @@
+package transit
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func (b *backend) pathStreamFields() map[string]*framework.FieldSchema {
+	return map[string]*framework.FieldSchema{
+		"name": {
+			Type:        framework.TypeString,
+			Description: "Name of the key",
+		},
+
+		"context": {
+			Type:        framework.TypeString,
+			Description: "Base64 encoded context for key derivation. Required if key derivation is enabled.",
+		},
+	}
+}
+
+func (b *backend) pathStreamEncrypt() *framework.Path {
+	fields := b.pathStreamFields()
+	fields["key_version"] = &framework.FieldSchema{
+		Type: framework.TypeInt,
+		Description: `The version of the key to use for wrapping the data
+key. Must be 0 (for latest) or a value greater than or
+equal to the min_encryption_version configured on the key.`,
+	}
+	fields["chunk_size"] = &framework.FieldSchema{
+		Type:        framework.TypeInt,
+		Description: "The number of plaintext bytes sealed per chunk. Defaults to 65536.",
+		Default:     keysutil.DefaultStreamChunkSize,
+	}
+
+	return &framework.Path{
+		Pattern: "stream/encrypt/" + framework.GenericNameRegex("name"),
+		Fields:  fields,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathStreamEncryptWrite,
+		},
+
+		HelpSynopsis:    pathStreamEncryptHelpSyn,
+		HelpDescription: pathStreamEncryptHelpDesc,
+	}
+}
+
+func (b *backend) pathStreamDecrypt() *framework.Path {
+	return &framework.Path{
+		Pattern: "stream/decrypt/" + framework.GenericNameRegex("name"),
+		Fields:  b.pathStreamFields(),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathStreamDecryptWrite,
+		},
+
+		HelpSynopsis:    pathStreamDecryptHelpSyn,
+		HelpDescription: pathStreamDecryptHelpDesc,
+	}
+}
+
+func (b *backend) pathStreamEncryptWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if req.HTTPRequest == nil || req.HTTPRequest.Body == nil {
+		return logical.ErrorResponse("the plaintext must be sent as the request body with a Content-Type of application/octet-stream"), logical.ErrInvalidRequest
+	}
+
+	chunkSize := d.Get("chunk_size").(int)
+	if chunkSize < 1 || chunkSize > keysutil.MaxStreamChunkSize {
+		return logical.ErrorResponse(fmt.Sprintf("chunk_size must be between 1 and %d", keysutil.MaxStreamChunkSize)), logical.ErrInvalidRequest
+	}
+
+	derivationContext, resp, err := decodeStreamContext(d)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	// Generate the data key of the object and wrap it with the named key
+	dataKey := make([]byte, keysutil.StreamDataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	var wrappedKey string
+	resp, err = b.withStreamPolicy(ctx, req, d, func(p *keysutil.Policy) error {
+		if err := p.CheckOperation(keysutil.KeyOperationEncrypt); err != nil {
+			return err
+		}
+		if !p.Type.EncryptionSupported() {
+			return errutil.UserError{Err: fmt.Sprintf("encryption not supported for key type %v", p.Type)}
+		}
+		var err error
+		wrappedKey, err = p.Encrypt(d.Get("key_version").(int), derivationContext, nil, base64.StdEncoding.EncodeToString(dataKey))
+		return err
+	})
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	return b.writeStream(req, func(w io.Writer) error {
+		enc, err := keysutil.NewStreamEncrypter(w, dataKey, wrappedKey, chunkSize)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(enc, req.HTTPRequest.Body); err != nil {
+			return err
+		}
+		return enc.Close()
+	})
+}
+
+func (b *backend) pathStreamDecryptWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if req.HTTPRequest == nil || req.HTTPRequest.Body == nil {
+		return logical.ErrorResponse("the ciphertext must be sent as the request body with a Content-Type of application/octet-stream"), logical.ErrInvalidRequest
+	}
+
+	derivationContext, resp, err := decodeStreamContext(d)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	body := req.HTTPRequest.Body
+	header, err := keysutil.ReadStreamHeader(body)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	var encodedKey string
+	resp, err = b.withStreamPolicy(ctx, req, d, func(p *keysutil.Policy) error {
+		if err := p.CheckOperation(keysutil.KeyOperationDecrypt); err != nil {
+			return err
+		}
+		if !p.Type.DecryptionSupported() {
+			return errutil.UserError{Err: fmt.Sprintf("decryption not supported for key type %v", p.Type)}
+		}
+		var err error
+		encodedKey, err = p.Decrypt(derivationContext, nil, header.WrappedKey)
+		return err
+	})
+	if resp != nil || err != nil {
+		return resp, err
+	}
+	dataKey, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to decode data key: {{err}}", err)
+	}
+
+	dec, err := keysutil.NewStreamDecrypter(body, header, dataKey)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	// Decrypt the first chunk before anything is written, so that a stream
+	// that is not valid at all results in a regular error response
+	first := make([]byte, header.ChunkSize)
+	n, err := io.ReadFull(dec, first)
+	switch err {
+	case nil, io.EOF, io.ErrUnexpectedEOF:
+	default:
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	return b.writeStream(req, func(w io.Writer) error {
+		if _, err := w.Write(first[:n]); err != nil {
+			return err
+		}
+		_, err := io.Copy(w, dec)
+		return err
+	})
+}
+
+// withStreamPolicy runs fn with the named policy read locked. The lock is
+// released before the stream is processed so that long running requests do
+// not hold up changes to the key.
+func (b *backend) withStreamPolicy(ctx context.Context, req *logical.Request, d *framework.FieldData, fn func(p *keysutil.Policy) error) (*logical.Response, error) {
+	p, _, err := b.lm.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: req.Storage,
+		Name:    d.Get("name").(string),
+	}, b.GetRandomReader())
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("encryption key not found"), logical.ErrInvalidRequest
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(false)
+	}
+	defer p.Unlock()
+
+	if err := fn(p); err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		default:
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func decodeStreamContext(d *framework.FieldData) ([]byte, *logical.Response, error) {
+	contextRaw := d.Get("context").(string)
+	if len(contextRaw) == 0 {
+		return nil, nil, nil
+	}
+	derivationContext, err := base64.StdEncoding.DecodeString(contextRaw)
+	if err != nil {
+		return nil, logical.ErrorResponse("failed to base64-decode context"), logical.ErrInvalidRequest
+	}
+	return derivationContext, nil, nil
+}
+
+// writeStream runs fn with a writer for the response body. If the request
+// carries a response writer the output is streamed to the client as it is
+// produced; an error occurring after the first bytes have been sent is
+// reported in the X-Vault-Stream-Error trailer. Otherwise the output is
+// buffered and returned as a raw response.
+func (b *backend) writeStream(req *logical.Request, fn func(w io.Writer) error) (*logical.Response, error) {
+	if req.ResponseWriter == nil {
+		buf := new(bytes.Buffer)
+		if err := fn(buf); err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+		return &logical.Response{
+			Data: map[string]interface{}{
+				logical.HTTPContentType: "application/octet-stream",
+				logical.HTTPRawBody:     buf.Bytes(),
+				logical.HTTPStatusCode:  http.StatusOK,
+			},
+		}, nil
+	}
+
+	w := req.ResponseWriter
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Trailer", consts.StreamErrorTrailerName)
+
+	if err := fn(w); err != nil {
+		if !w.Written() {
+			w.Header().Del("Trailer")
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+		b.Logger().Error("failed to stream response", "path", req.Path, "error", err)
+		w.Header().Set(consts.StreamErrorTrailerName, err.Error())
+	}
+
+	return nil, nil
+}
+
+const pathStreamEncryptHelpSyn = `Encrypt a stream of data using envelope encryption with the named key`
+
+const pathStreamEncryptHelpDesc = `
+This path encrypts the raw request body, which must be sent with a
+Content-Type of application/octet-stream. A new data key is generated
+for every request and wrapped with the named key. The response body is
+a self-describing stream holding the wrapped data key followed by the
+data, sealed in chunks with AES-256-GCM, and can be decrypted with the
+"stream/decrypt" path. Parameters are given in the query string.
+`
+
+const pathStreamDecryptHelpSyn = `Decrypt a stream of data encrypted with the named key`
+
+const pathStreamDecryptHelpDesc = `
+This path decrypts a stream produced by the "stream/encrypt" path and
+sent as the raw request body with a Content-Type of
+application/octet-stream. The plaintext is streamed back as it is
+authenticated; if the stream turns out to be invalid after part of the
+plaintext has been sent, the error is reported in the
+X-Vault-Stream-Error trailer of the response and the plaintext received
+must be discarded.
+`
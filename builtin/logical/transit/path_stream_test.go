@@ -0,0 +1,142 @@
+package transit
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestTransit_Stream(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	// stream sends body to the given path, streaming the response through a
+	// recorder unless buffered is set
+	stream := func(path string, body []byte, data map[string]interface{}, buffered bool) (*logical.Response, *httptest.ResponseRecorder, error) {
+		t.Helper()
+		req := &logical.Request{
+			Storage:     storage,
+			Operation:   logical.UpdateOperation,
+			Path:        path,
+			Data:        data,
+			HTTPRequest: httptest.NewRequest("POST", "/v1/transit/"+path, bytes.NewReader(body)),
+		}
+		var recorder *httptest.ResponseRecorder
+		if !buffered {
+			recorder = httptest.NewRecorder()
+			req.ResponseWriter = logical.NewHTTPResponseWriter(recorder)
+		}
+		resp, err := b.HandleRequest(context.Background(), req)
+		return resp, recorder, err
+	}
+
+	// result returns the body of a successful streaming request
+	result := func(path string, body []byte, data map[string]interface{}, buffered bool) []byte {
+		t.Helper()
+		resp, recorder, err := stream(path, body, data, buffered)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err: %v resp: %#v", path, err, resp)
+		}
+		if buffered {
+			return resp.Data[logical.HTTPRawBody].([]byte)
+		}
+		if trailer := recorder.Result().Trailer.Get(consts.StreamErrorTrailerName); trailer != "" {
+			t.Fatalf("%s: unexpected stream error: %s", path, trailer)
+		}
+		out, _ := ioutil.ReadAll(recorder.Result().Body)
+		return out
+	}
+
+	_, err := b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "keys/files",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := make([]byte, 100000)
+	rand.Read(plaintext)
+
+	for _, buffered := range []bool{false, true} {
+		ciphertext := result("stream/encrypt/files", plaintext, map[string]interface{}{
+			"chunk_size": "4096",
+		}, buffered)
+		if bytes.Contains(ciphertext, plaintext[:64]) {
+			t.Fatal("ciphertext contains the plaintext")
+		}
+		decrypted := result("stream/decrypt/files", ciphertext, nil, buffered)
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Fatalf("buffered %t: decrypted plaintext does not match", buffered)
+		}
+	}
+
+	// Empty objects round trip
+	ciphertext := result("stream/encrypt/files", nil, nil, false)
+	if decrypted := result("stream/decrypt/files", ciphertext, nil, false); len(decrypted) != 0 {
+		t.Fatalf("expected empty plaintext, got %d bytes", len(decrypted))
+	}
+
+	// Streams that are invalid from the start result in an error response
+	resp, _, err := stream("stream/decrypt/files", []byte("vault:v1:abcd"), nil, false)
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatalf("expected error decrypting an invalid stream, got %#v", resp)
+	}
+	resp, _, err = stream("stream/encrypt/files", plaintext, map[string]interface{}{
+		"chunk_size": "0",
+	}, false)
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatalf("expected error with an invalid chunk size, got %#v", resp)
+	}
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "stream/encrypt/files",
+	})
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatalf("expected error without a request body, got %#v", resp)
+	}
+
+	// A stream damaged after its first chunk is reported in the trailer
+	ciphertext = result("stream/encrypt/files", plaintext, map[string]interface{}{
+		"chunk_size": "4096",
+	}, false)
+	ciphertext[len(ciphertext)-1] ^= 1
+	_, recorder, err := stream("stream/decrypt/files", ciphertext, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Result().Trailer.Get(consts.StreamErrorTrailerName) == "" {
+		t.Fatal("expected the stream error trailer to be set")
+	}
+
+	// Derived keys require the context
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "keys/derived",
+		Data: map[string]interface{}{
+			"derived": true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	context1 := map[string]interface{}{"context": base64.StdEncoding.EncodeToString([]byte("tenant-1"))}
+	context2 := map[string]interface{}{"context": base64.StdEncoding.EncodeToString([]byte("tenant-2"))}
+	ciphertext = result("stream/encrypt/derived", plaintext, context1, false)
+	if decrypted := result("stream/decrypt/derived", ciphertext, context1, false); !bytes.Equal(decrypted, plaintext) {
+		t.Fatal("decrypted plaintext does not match")
+	}
+	resp, _, err = stream("stream/decrypt/derived", ciphertext, context2, false)
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatalf("expected error decrypting with the wrong context, got %#v", resp)
+	}
+}
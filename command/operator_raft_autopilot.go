@@ -0,0 +1,48 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/mitchellh/cli"
+)
+
+var _ cli.Command = (*OperatorRaftAutopilotCommand)(nil)
+
+type OperatorRaftAutopilotCommand struct {
+	*BaseCommand
+}
+
+func (c *OperatorRaftAutopilotCommand) Synopsis() string {
+	return "Inspects and configures autopilot for the Raft cluster"
+}
+
+func (c *OperatorRaftAutopilotCommand) Help() string {
+	helpText := `
+Usage: vault operator raft autopilot <subcommand> [options] [args]
+
+  This command groups subcommands for operators interacting with autopilot,
+  which tracks the health of the servers in the Raft cluster, promotes new
+  servers to voters once they are stable and removes dead servers. Here are a
+  few examples of the autopilot operator commands:
+
+  Returns the health of the servers in the Raft cluster:
+
+      $ vault operator raft autopilot state
+
+  Returns the configuration of autopilot:
+
+      $ vault operator raft autopilot get-config
+
+  Enables the removal of dead servers:
+
+      $ vault operator raft autopilot set-config -cleanup-dead-servers=true
+
+  Please see the individual subcommand help for detailed usage information.
+`
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorRaftAutopilotCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
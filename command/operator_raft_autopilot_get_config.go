@@ -0,0 +1,89 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var _ cli.Command = (*OperatorRaftAutopilotGetConfigCommand)(nil)
+var _ cli.CommandAutocomplete = (*OperatorRaftAutopilotGetConfigCommand)(nil)
+
+type OperatorRaftAutopilotGetConfigCommand struct {
+	*BaseCommand
+}
+
+func (c *OperatorRaftAutopilotGetConfigCommand) Synopsis() string {
+	return "Returns the configuration of autopilot"
+}
+
+func (c *OperatorRaftAutopilotGetConfigCommand) Help() string {
+	helpText := `
+Usage: vault operator raft autopilot get-config
+
+  Returns the configuration of autopilot for the Raft cluster.
+
+	  $ vault operator raft autopilot get-config
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorRaftAutopilotGetConfigCommand) Flags() *FlagSets {
+	return c.flagSet(FlagSetHTTP | FlagSetOutputFormat)
+}
+
+func (c *OperatorRaftAutopilotGetConfigCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *OperatorRaftAutopilotGetConfigCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *OperatorRaftAutopilotGetConfigCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	args = f.Args()
+	if len(args) > 0 {
+		c.UI.Error(fmt.Sprintf("Too many arguments (expected 0, got %d)", len(args)))
+		return 1
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+
+	config, err := client.Sys().RaftAutopilotConfiguration()
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error reading the autopilot configuration: %s", err))
+		return 2
+	}
+
+	if Format(c.UI) != "table" {
+		return OutputData(c.UI, config)
+	}
+
+	out := []string{
+		"Key | Value",
+		fmt.Sprintf("Cleanup Dead Servers | %t", config.CleanupDeadServers),
+		fmt.Sprintf("Last Contact Threshold | %s", config.LastContactThreshold),
+		fmt.Sprintf("Dead Server Last Contact Threshold | %s", config.DeadServerLastContactThreshold),
+		fmt.Sprintf("Max Trailing Logs | %d", config.MaxTrailingLogs),
+		fmt.Sprintf("Min Quorum | %d", config.MinQuorum),
+		fmt.Sprintf("Server Stabilization Time | %s", config.ServerStabilizationTime),
+	}
+	c.UI.Output(tableOutput(out, nil))
+
+	return 0
+}
@@ -0,0 +1,157 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var _ cli.Command = (*OperatorRaftAutopilotSetConfigCommand)(nil)
+var _ cli.CommandAutocomplete = (*OperatorRaftAutopilotSetConfigCommand)(nil)
+
+type OperatorRaftAutopilotSetConfigCommand struct {
+	*BaseCommand
+
+	flagCleanupDeadServers             bool
+	flagLastContactThreshold           time.Duration
+	flagDeadServerLastContactThreshold time.Duration
+	flagMaxTrailingLogs                uint64
+	flagMinQuorum                      uint
+	flagServerStabilizationTime        time.Duration
+}
+
+func (c *OperatorRaftAutopilotSetConfigCommand) Synopsis() string {
+	return "Modifies the configuration of autopilot"
+}
+
+func (c *OperatorRaftAutopilotSetConfigCommand) Help() string {
+	helpText := `
+Usage: vault operator raft autopilot set-config [options]
+
+  Modifies the configuration of autopilot for the Raft cluster. Only the
+  values given as flags are changed.
+
+  Enable the removal of servers which have not been in contact for a day:
+
+	  $ vault operator raft autopilot set-config \
+	      -cleanup-dead-servers=true \
+	      -dead-server-last-contact-threshold=24h
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorRaftAutopilotSetConfigCommand) Flags() *FlagSets {
+	set := c.flagSet(FlagSetHTTP)
+
+	f := set.NewFlagSet("Command Options")
+
+	f.BoolVar(&BoolVar{
+		Name:   "cleanup-dead-servers",
+		Target: &c.flagCleanupDeadServers,
+		Usage:  "Controls whether to remove dead servers from the Raft peer list.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:       "last-contact-threshold",
+		Target:     &c.flagLastContactThreshold,
+		Completion: complete.PredictAnything,
+		Usage:      "Limit on the amount of time a server can go without leader contact before being considered unhealthy.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:       "dead-server-last-contact-threshold",
+		Target:     &c.flagDeadServerLastContactThreshold,
+		Completion: complete.PredictAnything,
+		Usage:      "Limit on the amount of time a server can go without leader contact before being considered failed.",
+	})
+
+	f.Uint64Var(&Uint64Var{
+		Name:       "max-trailing-logs",
+		Target:     &c.flagMaxTrailingLogs,
+		Completion: complete.PredictAnything,
+		Usage:      "Amount of entries in the Raft log that a server can be behind before being considered unhealthy.",
+	})
+
+	f.UintVar(&UintVar{
+		Name:       "min-quorum",
+		Target:     &c.flagMinQuorum,
+		Completion: complete.PredictAnything,
+		Usage:      "Minimum number of voters allowed in the cluster before autopilot can remove dead servers.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:       "server-stabilization-time",
+		Target:     &c.flagServerStabilizationTime,
+		Completion: complete.PredictAnything,
+		Usage:      "Minimum amount of time a server must be healthy before it can become a voter.",
+	})
+
+	return set
+}
+
+func (c *OperatorRaftAutopilotSetConfigCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *OperatorRaftAutopilotSetConfigCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *OperatorRaftAutopilotSetConfigCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	args = f.Args()
+	if len(args) > 0 {
+		c.UI.Error(fmt.Sprintf("Too many arguments (expected 0, got %d)", len(args)))
+		return 1
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+
+	config, err := client.Sys().RaftAutopilotConfiguration()
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error reading the autopilot configuration: %s", err))
+		return 2
+	}
+
+	// Only change the values provided in the CLI
+	f.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "cleanup-dead-servers":
+			config.CleanupDeadServers = c.flagCleanupDeadServers
+		case "last-contact-threshold":
+			config.LastContactThreshold = c.flagLastContactThreshold.String()
+		case "dead-server-last-contact-threshold":
+			config.DeadServerLastContactThreshold = c.flagDeadServerLastContactThreshold.String()
+		case "max-trailing-logs":
+			config.MaxTrailingLogs = c.flagMaxTrailingLogs
+		case "min-quorum":
+			config.MinQuorum = c.flagMinQuorum
+		case "server-stabilization-time":
+			config.ServerStabilizationTime = c.flagServerStabilizationTime.String()
+		}
+	})
+
+	if err := client.Sys().PutRaftAutopilotConfiguration(config); err != nil {
+		c.UI.Error(fmt.Sprintf("Error updating the autopilot configuration: %s", err))
+		return 2
+	}
+
+	c.UI.Output("Success! Updated the autopilot configuration.")
+	return 0
+}
@@ -0,0 +1,109 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var _ cli.Command = (*OperatorRaftAutopilotStateCommand)(nil)
+var _ cli.CommandAutocomplete = (*OperatorRaftAutopilotStateCommand)(nil)
+
+type OperatorRaftAutopilotStateCommand struct {
+	*BaseCommand
+}
+
+func (c *OperatorRaftAutopilotStateCommand) Synopsis() string {
+	return "Displays the state of the Raft cluster under autopilot"
+}
+
+func (c *OperatorRaftAutopilotStateCommand) Help() string {
+	helpText := `
+Usage: vault operator raft autopilot state
+
+  Displays the health of each server in the Raft cluster, as tracked by
+  autopilot on the active node, along with the failure tolerance of the
+  cluster.
+
+	  $ vault operator raft autopilot state
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorRaftAutopilotStateCommand) Flags() *FlagSets {
+	return c.flagSet(FlagSetHTTP | FlagSetOutputFormat)
+}
+
+func (c *OperatorRaftAutopilotStateCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *OperatorRaftAutopilotStateCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *OperatorRaftAutopilotStateCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	args = f.Args()
+	if len(args) > 0 {
+		c.UI.Error(fmt.Sprintf("Too many arguments (expected 0, got %d)", len(args)))
+		return 1
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+
+	state, err := client.Sys().RaftAutopilotState()
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error reading the autopilot state: %s", err))
+		return 2
+	}
+
+	if Format(c.UI) != "table" {
+		return OutputData(c.UI, state)
+	}
+
+	out := []string{
+		fmt.Sprintf("Healthy | %t", state.Healthy),
+		fmt.Sprintf("Failure Tolerance | %d", state.FailureTolerance),
+		fmt.Sprintf("Leader | %s", state.Leader),
+		fmt.Sprintf("Voters | %s", strings.Join(state.Voters, ", ")),
+	}
+	c.UI.Output(tableOutput(out, nil))
+	c.UI.Output("")
+
+	ids := make([]string, 0, len(state.Servers))
+	for id := range state.Servers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	out = []string{"Node | Address | Status | Node Status | Healthy | Last Contact | Last Index | Stable Since"}
+	for _, id := range ids {
+		server := state.Servers[id]
+		stableSince := server.StableSince
+		if stableSince == "" {
+			stableSince = "n/a"
+		}
+		out = append(out, fmt.Sprintf("%s | %s | %s | %s | %t | %s | %d | %s",
+			server.ID, server.Address, server.Status, server.NodeStatus, server.Healthy,
+			server.LastContact, server.LastIndex, stableSince))
+	}
+	c.UI.Output(tableOutput(out, nil))
+
+	return 0
+}
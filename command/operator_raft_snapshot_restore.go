@@ -3,6 +3,7 @@ package command
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/mitchellh/cli"
@@ -13,7 +14,8 @@ var _ cli.Command = (*OperatorRaftSnapshotRestoreCommand)(nil)
 var _ cli.CommandAutocomplete = (*OperatorRaftSnapshotRestoreCommand)(nil)
 
 type OperatorRaftSnapshotRestoreCommand struct {
-	flagForce bool
+	flagForce  bool
+	flagMounts []string
 	*BaseCommand
 }
 
@@ -29,6 +31,12 @@ Usage: vault operator raft snapshot restore <snapshot_file>
 
 	  $ vault operator raft snapshot restore raft.snap
 
+  Restores only the data of the given mounts from the provided snapshot,
+  leaving the rest of the cluster untouched. The mounts must already be
+  enabled, with the same type as in the snapshot:
+
+	  $ vault operator raft snapshot restore -mount=secret/ -mount=auth/userpass/ raft.snap
+
 ` + c.Flags().Help()
 
 	return strings.TrimSpace(helpText)
@@ -46,6 +54,14 @@ func (c *OperatorRaftSnapshotRestoreCommand) Flags() *FlagSets {
 		Usage:   "This bypasses checks ensuring the Autounseal or shamir keys are consistent with the snapshot data.",
 	})
 
+	f.StringSliceVar(&StringSliceVar{
+		Name:   "mount",
+		Target: &c.flagMounts,
+		Usage: "Path of a mount to restore from the snapshot, leaving the rest " +
+			"of the cluster untouched. Paths of auth methods start with auth/. " +
+			"This can be specified multiple times.",
+	})
+
 	return set
 }
 
@@ -94,6 +110,36 @@ func (c *OperatorRaftSnapshotRestoreCommand) Run(args []string) int {
 		return 2
 	}
 
+	if len(c.flagMounts) > 0 {
+		if c.flagForce {
+			c.UI.Error("The -force flag cannot be used when restoring mounts")
+			return 1
+		}
+
+		resp, err := client.Sys().RaftSnapshotRestoreMounts(snapReader, c.flagMounts)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error restoring the mounts: %s", err))
+			return 2
+		}
+
+		if Format(c.UI) != "table" {
+			return OutputData(c.UI, resp)
+		}
+
+		paths := make([]string, 0, len(resp.Keys))
+		for path := range resp.Keys {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		out := []string{"Mount | Keys Restored"}
+		for _, path := range paths {
+			out = append(out, fmt.Sprintf("%s | %d", path, resp.Keys[path]))
+		}
+		c.UI.Output(tableOutput(out, nil))
+		return 0
+	}
+
 	err = client.Sys().RaftSnapshotRestore(snapReader, c.flagForce)
 	if err != nil {
 		c.UI.Error(fmt.Sprintf("Error installing the snapshot: %s", err))
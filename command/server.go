@@ -1122,9 +1122,13 @@ func (c *ServerCommand) Run(args []string) int {
 				config.Seals = append(config.Seals, &configutil.KMS{Type: wrapping.Shamir})
 			}
 		}
+		// With a multi-seal the enabled seals are combined into a single
+		// barrier seal, which can unseal with any of them
+		multiSeal := configutil.IsMultiSeal(config.Seals)
+		var multiSealEntries []*vaultseal.MultiWrapperEntry
 		for _, configSeal := range config.Seals {
 			sealType := wrapping.Shamir
-			if !configSeal.Disabled && os.Getenv("VAULT_SEAL_TYPE") != "" {
+			if !configSeal.Disabled && !multiSeal && os.Getenv("VAULT_SEAL_TYPE") != "" {
 				sealType = os.Getenv("VAULT_SEAL_TYPE")
 				configSeal.Type = sealType
 			} else {
@@ -1149,6 +1153,28 @@ func (c *ServerCommand) Run(args []string) int {
 					return 1
 				}
 			}
+			if multiSeal && !configSeal.Disabled {
+				if wrapper == nil {
+					c.UI.Error(fmt.Sprintf("Seal of type %q cannot be part of a multi-seal", sealType))
+					return 1
+				}
+				name := configSeal.Name
+				if name == "" {
+					name = configSeal.Type
+				}
+				multiSealEntries = append(multiSealEntries, &vaultseal.MultiWrapperEntry{
+					Name:     name,
+					Priority: configSeal.Priority,
+					Wrapper:  wrapper,
+				})
+				for _, k := range sealInfoKeys {
+					key := fmt.Sprintf("Seal %q %s", name, k)
+					infoKeys = append(infoKeys, key)
+					info[key] = sealInfoMap[k]
+				}
+				continue
+			}
+
 			if wrapper == nil {
 				seal = defaultSeal
 			} else {
@@ -1179,6 +1205,25 @@ func (c *ServerCommand) Run(args []string) int {
 			}()
 
 		}
+
+		if multiSeal {
+			multiWrapper, err := vaultseal.NewMultiWrapper(c.logger.ResetNamed("seal.multi"), multiSealEntries...)
+			if err != nil {
+				c.UI.Error(fmt.Sprintf("Error configuring multi-seal: %s", err))
+				return 1
+			}
+			barrierSeal = vault.NewAutoSeal(&vaultseal.Access{
+				Wrapper: multiWrapper,
+			})
+			barrierWrapper = multiWrapper
+
+			// Ensure that the seal finalizer is called, even if using verify-only
+			defer func() {
+				if err := barrierSeal.Finalize(context.Background()); err != nil {
+					c.UI.Error(fmt.Sprintf("Error finalizing seals: %v", err))
+				}
+			}()
+		}
 	}
 
 	if barrierSeal == nil {
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/errwrap"
+	wrapping "github.com/hashicorp/go-kms-wrapping"
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/hcl"
 	"github.com/hashicorp/hcl/hcl/ast"
@@ -294,7 +295,11 @@ func CheckConfig(c *Config, e error) (*Config, error) {
 		return c, e
 	}
 
-	if len(c.Seals) == 2 {
+	if configutil.IsMultiSeal(c.Seals) {
+		if err := checkMultiSeal(c.Seals); err != nil {
+			return nil, err
+		}
+	} else if len(c.Seals) == 2 {
 		switch {
 		case c.Seals[0].Disabled && c.Seals[1].Disabled:
 			return nil, errors.New("seals: two seals provided but both are disabled")
@@ -306,6 +311,38 @@ func CheckConfig(c *Config, e error) (*Config, error) {
 	return c, nil
 }
 
+// checkMultiSeal validates the seals of a multi-seal: every enabled seal
+// must have a priority and a distinct name, and at most one seal can be
+// disabled to be migrated from.
+func checkMultiSeal(seals []*configutil.KMS) error {
+	names := make(map[string]struct{}, len(seals))
+	var disabled int
+	for _, s := range seals {
+		if s.Disabled {
+			disabled++
+			continue
+		}
+		if s.Priority == 0 {
+			return fmt.Errorf("seals: seal %q has no priority, which is required for all enabled seals when using multiple seals", s.Type)
+		}
+		if s.Type == wrapping.Shamir {
+			return errors.New("seals: shamir seals cannot be part of a multi-seal")
+		}
+		name := s.Name
+		if name == "" {
+			name = s.Type
+		}
+		if _, ok := names[name]; ok {
+			return fmt.Errorf("seals: multiple seals named %q, set a distinct name on each seal", name)
+		}
+		names[name] = struct{}{}
+	}
+	if disabled > 1 {
+		return errors.New("seals: only one seal can be disabled")
+	}
+	return nil
+}
+
 // LoadConfigFile loads the configuration from the given file.
 func LoadConfigFile(path string) (*Config, error) {
 	// Read the file
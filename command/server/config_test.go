@@ -57,3 +57,7 @@ func TestConfigRaftRetryJoin(t *testing.T) {
 func TestParseSeals(t *testing.T) {
 	testParseSeals(t)
 }
+
+func TestParseMultiSeal(t *testing.T) {
+	testParseMultiSeal(t)
+}
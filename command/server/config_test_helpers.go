@@ -806,6 +806,47 @@ func testParseSeals(t *testing.T) {
 	require.Equal(t, config, expected)
 }
 
+func testParseMultiSeal(t *testing.T) {
+	config, err := LoadConfig("./test-fixtures/config_multi_seal.hcl")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []*configutil.KMS{
+		{
+			Type:     "awskms",
+			Name:     "aws-east",
+			Priority: 1,
+			Config: map[string]string{
+				"region":     "us-east-1",
+				"kms_key_id": "alias/vault-east",
+			},
+		},
+		{
+			Type:     "awskms",
+			Name:     "aws-west",
+			Priority: 2,
+			Config: map[string]string{
+				"region":     "us-west-2",
+				"kms_key_id": "alias/vault-west",
+			},
+		},
+	}
+	require.Equal(t, expected, config.Seals)
+
+	// Every enabled seal needs a priority and a distinct name
+	config.Seals[1].Priority = 0
+	if _, err := CheckConfig(config, nil); err == nil {
+		t.Fatal("expected error for a seal without priority")
+	}
+	config.Seals[1].Priority = 2
+	config.Seals[1].Name = ""
+	config.Seals[0].Name = ""
+	if _, err := CheckConfig(config, nil); err == nil {
+		t.Fatal("expected error for seals with the same name")
+	}
+}
+
 func testLoadConfigFileLeaseMetrics(t *testing.T) {
 	config, err := LoadConfigFile("./test-fixtures/config5.hcl")
 	if err != nil {
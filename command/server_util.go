@@ -1,13 +1,42 @@
 package command
 
 import (
+	wrapping "github.com/hashicorp/go-kms-wrapping"
 	"github.com/hashicorp/vault/command/server"
+	"github.com/hashicorp/vault/internalshared/configutil"
 	"github.com/hashicorp/vault/vault"
+	"github.com/hashicorp/vault/vault/seal/pkcs11"
 )
 
 var (
 	adjustCoreConfigForEnt = adjustCoreConfigForEntNoop
 )
 
+func init() {
+	configutil.GetPKCS11KMSFunc = getPKCS11KMSFunc
+}
+
 func adjustCoreConfigForEntNoop(config *server.Config, coreConfig *vault.CoreConfig) {
 }
+
+// getPKCS11KMSFunc configures the pkcs11 seal for the server
+func getPKCS11KMSFunc(opts *wrapping.WrapperOptions, kms *configutil.KMS) (wrapping.Wrapper, map[string]string, error) {
+	wrapper := pkcs11.NewWrapper(opts)
+	wrapperInfo, err := wrapper.SetConfig(kms.Config)
+	if err != nil {
+		return nil, nil, err
+	}
+	info := make(map[string]string)
+	if wrapperInfo != nil {
+		info["PKCS11 Library"] = wrapperInfo["lib"]
+		if slot, ok := wrapperInfo["slot"]; ok {
+			info["PKCS11 Slot"] = slot
+		}
+		if tokenLabel, ok := wrapperInfo["token_label"]; ok {
+			info["PKCS11 Token Label"] = tokenLabel
+		}
+		info["PKCS11 Key Label"] = wrapperInfo["key_label"]
+		info["PKCS11 Mechanism"] = wrapperInfo["mechanism"]
+	}
+	return wrapper, info, nil
+}
@@ -100,6 +100,9 @@ func MakeRaftBackendWithConf(t testing.T, coreIdx int, logger hclog.Logger, extr
 		"path":                   raftDir,
 		"node_id":                nodeID,
 		"performance_multiplier": "8",
+		// Autopilot joins new nodes as non-voters until they are stable,
+		// which most tests don't expect
+		"disable_autopilot": "true",
 	}
 	for k, v := range extraConf {
 		conf[k] = v
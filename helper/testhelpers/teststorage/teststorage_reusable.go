@@ -175,6 +175,9 @@ func makeReusableRaftBackend(t testing.T, coreIdx int, logger hclog.Logger, raft
 		"path":                   raftDir,
 		"node_id":                nodeID,
 		"performance_multiplier": "8",
+		// Autopilot joins new nodes as non-voters until they are stable,
+		// which most tests don't expect
+		"disable_autopilot": "true",
 	}
 
 	backend, err := raft.NewRaftBackend(conf, logger)
@@ -14,6 +14,7 @@ import (
 	"net/textproto"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -57,6 +58,15 @@ const (
 	// soft-mandatory Sentinel policies.
 	PolicyOverrideHeaderName = "X-Vault-Policy-Override"
 
+	// RaftIndexHeaderName is the header containing the raft index applied by
+	// the node which handled the request. Clients can send it back to require
+	// that a standby serving local reads has applied at least this index.
+	RaftIndexHeaderName = "X-Vault-Raft-Index"
+
+	// raftIndexWaitTimeout is how long a standby serving local reads waits to
+	// apply the raft index required by a request before forwarding it.
+	raftIndexWaitTimeout = 2 * time.Second
+
 	// DefaultMaxRequestSize is the default maximum accepted request size. This
 	// is to prevent a denial of service attack where no Content-Length is
 	// provided and the server is fed ever more data until it exhausts memory.
@@ -102,6 +112,7 @@ func init() {
 	alwaysRedirectPaths.AddPaths([]string{
 		"sys/storage/raft/snapshot",
 		"sys/storage/raft/snapshot-force",
+		"sys/storage/raft/snapshot-restore-mounts",
 	})
 }
 
@@ -670,6 +681,21 @@ func parseFormRequest(r *http.Request) (map[string]interface{}, error) {
 // falling back on the older behavior of redirecting the client
 func handleRequestForwarding(core *vault.Core, handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Standbys serving reads from their raft FSM only handle the reads of
+		// the selected mounts
+		if core.RaftLocalReadStandby() {
+			ns, err := namespace.FromContext(r.Context())
+			if err != nil {
+				respondError(w, http.StatusBadRequest, err)
+				return
+			}
+			path := ns.TrimmedPath(r.URL.Path[len("/v1/"):])
+			if raftLocalReadAllowed(core, r, path) {
+				handler.ServeHTTP(w, r)
+				return
+			}
+		}
+
 		// If we are a performance standby we can handle the request.
 		if core.PerfStandby() {
 			ns, err := namespace.FromContext(r.Context())
@@ -720,6 +746,38 @@ func handleRequestForwarding(core *vault.Core, handler http.Handler) http.Handle
 	})
 }
 
+// raftLocalReadAllowed returns whether a standby serving reads from its raft
+// FSM handles the request itself. The request must be a read of one of the
+// selected mounts which isn't response wrapped, and the node must have
+// applied the raft index required by the client, if any.
+func raftLocalReadAllowed(core *vault.Core, r *http.Request, path string) bool {
+	switch r.Method {
+	case "GET", "LIST":
+	default:
+		return false
+	}
+
+	if r.Header.Get(WrapTTLHeaderName) != "" || alwaysRedirectPaths.HasPath(path) {
+		return false
+	}
+	if !core.RaftLocalReadPath(r.Context(), path) {
+		return false
+	}
+
+	if indexRaw := r.Header.Get(RaftIndexHeaderName); indexRaw != "" {
+		index, err := strconv.ParseUint(indexRaw, 10, 64)
+		if err != nil {
+			return false
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), raftIndexWaitTimeout)
+		defer cancel()
+		return core.WaitForRaftAppliedIndex(ctx, index)
+	}
+
+	return true
+}
+
 func forwardRequest(core *vault.Core, w http.ResponseWriter, r *http.Request) {
 	if r.Header.Get(vault.IntNoForwardingHeaderName) != "" {
 		respondStandby(core, w, r.URL)
@@ -774,6 +832,13 @@ func forwardRequest(core *vault.Core, w http.ResponseWriter, r *http.Request) {
 // case of an error.
 func request(core *vault.Core, w http.ResponseWriter, rawReq *http.Request, r *logical.Request) (*logical.Response, bool, bool) {
 	resp, err := core.HandleRequest(rawReq.Context(), r)
+	if core.RaftLocalReadPath(rawReq.Context(), r.Path) {
+		// Only the mounts served by local reads need the index to be sent
+		// back by the clients
+		if index := core.RaftAppliedIndex(); index > 0 {
+			w.Header().Set(RaftIndexHeaderName, strconv.FormatUint(index, 10))
+		}
+	}
 	if r.LastRemoteWAL() > 0 && !vault.WaitUntilWALShipped(rawReq.Context(), core, r.LastRemoteWAL()) {
 		if resp == nil {
 			resp = &logical.Response{}
@@ -4,10 +4,12 @@ import (
 	"bufio"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -99,13 +101,47 @@ func buildLogicalRequestNoAuth(perfStandby bool, w http.ResponseWriter, r *http.
 		bufferedBody := newBufferedReader(r.Body)
 		r.Body = bufferedBody
 
-		// If we are uploading a snapshot we don't want to parse it. Instead
-		// we will simply add the HTTP request to the logical request object
-		// for later consumption.
-		if path == "sys/storage/raft/snapshot" || path == "sys/storage/raft/snapshot-force" {
+		// If we are uploading a snapshot we don't want to parse it. Instead we
+		// will simply add the HTTP request to the logical request object for
+		// later consumption.
+		switch {
+		case path == "sys/storage/raft/snapshot" || path == "sys/storage/raft/snapshot-force":
 			passHTTPReq = true
 			origBody = r.Body
-		} else {
+
+		// DER-encoded OCSP requests are handed to the PKI backend unparsed
+		case isOCSPRequest(path, r):
+			body, err := limitRequestBody(w, r)
+			if err != nil {
+				return nil, nil, http.StatusInternalServerError, err
+			}
+			r.Body = body
+			passHTTPReq = true
+			origBody = r.Body
+
+		// The mounts to restore from the uploaded snapshot are taken from the
+		// query string.
+		case path == "sys/storage/raft/snapshot-restore-mounts":
+			passHTTPReq = true
+			origBody = r.Body
+			data = parseQuery(r.URL.Query())
+
+		// Streams sent to the transit stream and rewrap job paths are handed
+		// to the backend unparsed, along with the response writer so that the
+		// result can be streamed back. Parameters are taken from the query
+		// string.
+		case isStreamRequest(path, r):
+			body, err := limitRequestBody(w, r)
+			if err != nil {
+				return nil, nil, http.StatusInternalServerError, err
+			}
+			r.Body = body
+			passHTTPReq = true
+			origBody = r.Body
+			responseWriter = w
+			data = parseQuery(r.URL.Query())
+
+		default:
 			// Sample the first bytes to determine whether this should be parsed as
 			// a form or as JSON. The amount to look ahead (512 bytes) is arbitrary
 			// but extremely tolerant (i.e. allowing 511 bytes of leading whitespace
@@ -146,7 +182,17 @@ func buildLogicalRequestNoAuth(perfStandby bool, w http.ResponseWriter, r *http.
 			path += "/"
 		}
 
-	case "OPTIONS", "HEAD":
+	// HEAD is only supported by the ACME new-nonce endpoints of the PKI
+	// backend, which are read with the HTTP request so that they can tell
+	// HEAD requests apart
+	case "HEAD":
+		if !acmeNewNoncePathRe.MatchString(path) {
+			return nil, nil, http.StatusMethodNotAllowed, nil
+		}
+		op = logical.ReadOperation
+		passHTTPReq = true
+
+	case "OPTIONS":
 	default:
 		return nil, nil, http.StatusMethodNotAllowed, nil
 	}
@@ -175,6 +221,70 @@ func buildLogicalRequestNoAuth(perfStandby bool, w http.ResponseWriter, r *http.
 	return req, origBody, 0, nil
 }
 
+var (
+	// streamPathRe matches the transit paths encrypting and decrypting raw
+	// octet streams, under any mount path
+	streamPathRe = regexp.MustCompile(`^.+/stream/(encrypt|decrypt)/[^/]+$`)
+
+	// rewrapJobPathRe matches the transit paths creating rewrap jobs from
+	// newline-delimited JSON records, under any mount path
+	rewrapJobPathRe = regexp.MustCompile(`^.+/rewrap-jobs/[^/]+/?$`)
+
+	// ocspPathRe matches the path of the PKI OCSP responder, under any mount
+	// path
+	ocspPathRe = regexp.MustCompile(`^.+/ocsp/?$`)
+
+	// acmeNewNoncePathRe matches the paths of the PKI ACME new-nonce
+	// endpoints, under any mount path
+	acmeNewNoncePathRe = regexp.MustCompile(`^.+/acme/(roles/[^/]+/)?new-nonce$`)
+)
+
+// isStreamRequest returns whether the request body is a stream to be read by
+// the backend itself: a raw stream of bytes sent to a transit stream path, or
+// newline-delimited JSON records sent to a transit rewrap job path
+func isStreamRequest(path string, r *http.Request) bool {
+	switch requestContentType(r) {
+	case "application/octet-stream":
+		return streamPathRe.MatchString(path)
+	case "application/x-ndjson":
+		return rewrapJobPathRe.MatchString(path)
+	}
+	return false
+}
+
+// limitRequestBody limits the body of a request which is not parsed to
+// max_request_size, as parseJSONRequest does when it is.
+func limitRequestBody(w http.ResponseWriter, r *http.Request) (io.ReadCloser, error) {
+	maxRequestSize := r.Context().Value("max_request_size")
+	if maxRequestSize == nil {
+		return r.Body, nil
+	}
+	max, ok := maxRequestSize.(int64)
+	if !ok {
+		return nil, errors.New("could not parse max_request_size from request context")
+	}
+	if max <= 0 {
+		return r.Body, nil
+	}
+	return http.MaxBytesReader(w, r.Body, max), nil
+}
+
+// isOCSPRequest returns whether the request carries a DER-encoded OCSP
+// request sent to the PKI backend's OCSP responder
+func isOCSPRequest(path string, r *http.Request) bool {
+	return requestContentType(r) == "application/ocsp-request" && ocspPathRe.MatchString(path)
+}
+
+// requestContentType returns the lower-cased media type of the request,
+// without any parameters
+func requestContentType(r *http.Request) string {
+	contentType := r.Header.Get("Content-Type")
+	if idx := strings.Index(contentType, ";"); idx != -1 {
+		contentType = contentType[:idx]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
 func buildLogicalPath(r *http.Request) (string, int, error) {
 	ns, err := namespace.FromContext(r.Context())
 	if err != nil {
@@ -215,7 +325,7 @@ func buildLogicalPath(r *http.Request) (string, int, error) {
 }
 
 func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Request) (*logical.Request, io.ReadCloser, int, error) {
-	req, origBody, status, err := buildLogicalRequestNoAuth(core.PerfStandby(), w, r)
+	req, origBody, status, err := buildLogicalRequestNoAuth(core.PerfStandby() || core.RaftLocalReadStandby(), w, r)
 	if err != nil || status != 0 {
 		return nil, nil, status, err
 	}
@@ -308,7 +418,7 @@ func handleLogicalInternal(core *vault.Core, injectDataIntoTopLevel bool, noForw
 		}
 
 		// Always forward requests that are using a limited use count token.
-		if core.PerfStandby() && req.ClientTokenRemainingUses > 0 {
+		if (core.PerfStandby() || core.RaftLocalReadStandby()) && req.ClientTokenRemainingUses > 0 {
 			// Prevent forwarding on local-only requests.
 			if noForward {
 				respondError(w, http.StatusBadRequest, vault.ErrCannotForwardLocalOnly)
@@ -354,6 +354,106 @@ func TestLogical_ListSuffix(t *testing.T) {
 	}
 }
 
+func TestLogical_OctetStream(t *testing.T) {
+	req, _ := http.NewRequest("POST", "http://127.0.0.1:8200/v1/transit/stream/encrypt/foo?chunk_size=1024", strings.NewReader(`{"not": "parsed"}`))
+	req = req.WithContext(namespace.RootContext(nil))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	w := httptest.NewRecorder()
+
+	lreq, _, status, err := buildLogicalRequestNoAuth(false, w, req)
+	if err != nil || status != 0 {
+		t.Fatalf("status %d, err: %v", status, err)
+	}
+	if lreq.HTTPRequest == nil || lreq.ResponseWriter == nil {
+		t.Fatal("expected the HTTP request and response writer to be passed through")
+	}
+	if !reflect.DeepEqual(lreq.Data, map[string]interface{}{"chunk_size": "1024"}) {
+		t.Fatalf("bad data: %#v", lreq.Data)
+	}
+	body, err := ioutil.ReadAll(lreq.HTTPRequest.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"not": "parsed"}` {
+		t.Fatalf("bad body: %q", body)
+	}
+
+	// Streams are limited to max_request_size
+	ctx := context.WithValue(namespace.RootContext(nil), "max_request_size", int64(4))
+	req, _ = http.NewRequest("POST", "http://127.0.0.1:8200/v1/transit/stream/encrypt/foo", strings.NewReader(`{"not": "parsed"}`))
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	lreq, _, status, err = buildLogicalRequestNoAuth(false, httptest.NewRecorder(), req)
+	if err != nil || status != 0 {
+		t.Fatalf("status %d, err: %v", status, err)
+	}
+	if _, err := ioutil.ReadAll(lreq.HTTPRequest.Body); err == nil {
+		t.Fatal("expected error reading a body over max_request_size")
+	}
+
+	// Other paths, and other content types on the stream paths, are parsed
+	// as usual
+	for path, contentType := range map[string]string{
+		"secret/foo":                   "application/octet-stream",
+		"transit/encrypt/foo":          "application/octet-stream",
+		"transit/stream/encrypt/foo":   "application/x-ndjson",
+		"transit/rewrap-jobs/foo/jobs": "application/x-ndjson",
+	} {
+		req, _ = http.NewRequest("POST", "http://127.0.0.1:8200/v1/"+path, strings.NewReader(`{"not": "parsed"}`))
+		req = req.WithContext(namespace.RootContext(nil))
+		req.Header.Set("Content-Type", contentType)
+		lreq, _, status, err = buildLogicalRequestNoAuth(false, httptest.NewRecorder(), req)
+		if err != nil || status != 0 {
+			t.Fatalf("%s: status %d, err: %v", path, status, err)
+		}
+		if lreq.HTTPRequest != nil || lreq.ResponseWriter != nil || lreq.Data["not"] != "parsed" {
+			t.Fatalf("%s: expected the body to be parsed: %#v", path, lreq.Data)
+		}
+	}
+
+	req, _ = http.NewRequest("POST", "http://127.0.0.1:8200/v1/transit/rewrap-jobs/foo", strings.NewReader(`{"ciphertext": "a"}`))
+	req = req.WithContext(namespace.RootContext(nil))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	lreq, _, status, err = buildLogicalRequestNoAuth(false, httptest.NewRecorder(), req)
+	if err != nil || status != 0 {
+		t.Fatalf("status %d, err: %v", status, err)
+	}
+	if lreq.HTTPRequest == nil {
+		t.Fatal("expected the HTTP request to be passed through for rewrap jobs")
+	}
+}
+
+func TestLogical_OCSPRequest(t *testing.T) {
+	ctx := context.WithValue(namespace.RootContext(nil), "max_request_size", int64(4))
+	req, _ := http.NewRequest("POST", "http://127.0.0.1:8200/v1/pki/ocsp", strings.NewReader(`{"not": "parsed"}`))
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	lreq, _, status, err := buildLogicalRequestNoAuth(false, httptest.NewRecorder(), req)
+	if err != nil || status != 0 {
+		t.Fatalf("status %d, err: %v", status, err)
+	}
+	if lreq.HTTPRequest == nil {
+		t.Fatal("expected the HTTP request to be passed through")
+	}
+
+	// OCSP requests are limited to max_request_size
+	if _, err := ioutil.ReadAll(lreq.HTTPRequest.Body); err == nil {
+		t.Fatal("expected error reading a body over max_request_size")
+	}
+
+	// Other paths are parsed as usual
+	req, _ = http.NewRequest("POST", "http://127.0.0.1:8200/v1/secret/foo", strings.NewReader(`{"not": "parsed"}`))
+	req = req.WithContext(namespace.RootContext(nil))
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	lreq, _, status, err = buildLogicalRequestNoAuth(false, httptest.NewRecorder(), req)
+	if err != nil || status != 0 {
+		t.Fatalf("status %d, err: %v", status, err)
+	}
+	if lreq.HTTPRequest != nil || lreq.Data["not"] != "parsed" {
+		t.Fatalf("expected the body to be parsed: %#v", lreq.Data)
+	}
+}
+
 func TestLogical_RespondWithStatusCode(t *testing.T) {
 	resp := &logical.Response{
 		Data: map[string]interface{}{
@@ -497,3 +597,23 @@ func TestLogical_ShouldParseForm(t *testing.T) {
 	}
 
 }
+
+func TestLogical_HeadRequest(t *testing.T) {
+	req, _ := http.NewRequest("HEAD", "http://127.0.0.1:8200/v1/pki/acme/new-nonce", nil)
+	req = req.WithContext(namespace.RootContext(nil))
+	lreq, _, status, err := buildLogicalRequestNoAuth(false, httptest.NewRecorder(), req)
+	if err != nil || status != 0 {
+		t.Fatalf("status %d, err: %v", status, err)
+	}
+	if lreq.Operation != logical.ReadOperation || lreq.HTTPRequest == nil {
+		t.Fatalf("expected a read with the HTTP request: %#v", lreq)
+	}
+
+	// Other paths don't support HEAD
+	req, _ = http.NewRequest("HEAD", "http://127.0.0.1:8200/v1/secret/foo", nil)
+	req = req.WithContext(namespace.RootContext(nil))
+	_, _, status, _ = buildLogicalRequestNoAuth(false, httptest.NewRecorder(), req)
+	if status != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status %d, got %d", http.StatusMethodNotAllowed, status)
+	}
+}
@@ -27,7 +27,8 @@ var (
 
 	additionalRoutes = func(mux *http.ServeMux, core *vault.Core) {}
 
-	nonVotersAllowed = false
+	// Read replicas join the raft cluster as non-voters
+	nonVotersAllowed = true
 )
 
 func rateLimitQuotaWrapping(handler http.Handler, core *vault.Core) http.Handler {
@@ -180,6 +180,12 @@ func (c *SharedConfig) Sanitized() map[string]interface{} {
 				"type":     s.Type,
 				"disabled": s.Disabled,
 			}
+			if s.Name != "" {
+				cleanSeal["name"] = s.Name
+			}
+			if s.Priority != 0 {
+				cleanSeal["priority"] = s.Priority
+			}
 			sanitizedSeals = append(sanitizedSeals, cleanSeal)
 		}
 		result["seals"] = sanitizedSeals
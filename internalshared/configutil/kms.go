@@ -2,6 +2,7 @@ package configutil
 
 import (
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
@@ -50,6 +51,23 @@ type KMS struct {
 
 	Disabled bool
 	Config   map[string]string
+
+	// Name and Priority identify and order the seals of a multi-seal, which
+	// is configured by setting a priority on the enabled seals. The name
+	// defaults to the type of the seal.
+	Name     string `hcl:"-"`
+	Priority int    `hcl:"-"`
+}
+
+// IsMultiSeal returns whether the given seals configure a multi-seal, which
+// wraps the keys under each of the enabled seals.
+func IsMultiSeal(seals []*KMS) bool {
+	for _, s := range seals {
+		if !s.Disabled && s.Priority != 0 {
+			return true
+		}
+	}
+	return false
 }
 
 func (k *KMS) GoString() string {
@@ -97,6 +115,27 @@ func parseKMS(result *[]*KMS, list *ast.ObjectList, blockName string, maxKMS int
 			delete(m, "disabled")
 		}
 
+		var name string
+		if v, ok := m["name"]; ok {
+			name, err = parseutil.ParseString(v)
+			if err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("%s.%s:", blockName, key))
+			}
+			delete(m, "name")
+		}
+
+		var priority int64
+		if v, ok := m["priority"]; ok {
+			priority, err = parseutil.ParseInt(v)
+			if err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("%s.%s:", blockName, key))
+			}
+			if priority < 1 {
+				return multierror.Prefix(errors.New("priority must be at least 1"), fmt.Sprintf("%s.%s:", blockName, key))
+			}
+			delete(m, "priority")
+		}
+
 		strMap := make(map[string]string, len(m))
 		for k, v := range m {
 			s, err := parseutil.ParseString(v)
@@ -110,6 +149,8 @@ func parseKMS(result *[]*KMS, list *ast.ObjectList, blockName string, maxKMS int
 			Type:     strings.ToLower(key),
 			Purpose:  purpose,
 			Disabled: disabled,
+			Name:     name,
+			Priority: int(priority),
 		}
 		if len(strMap) > 0 {
 			seal.Config = strMap
@@ -193,7 +234,7 @@ func configureWrapper(configKMS *KMS, infoKeys *[]string, info *map[string]strin
 		wrapper, kmsInfo, err = GetTransitKMSFunc(opts, configKMS)
 
 	case wrapping.PKCS11:
-		return nil, fmt.Errorf("KMS type 'pkcs11' requires the Vault Enterprise HSM binary")
+		wrapper, kmsInfo, err = GetPKCS11KMSFunc(opts, configKMS)
 
 	default:
 		return nil, fmt.Errorf("Unknown KMS type %q", configKMS.Type)
@@ -323,6 +364,13 @@ func GetOCIKMSKMSFunc(opts *wrapping.WrapperOptions, kms *KMS) (wrapping.Wrapper
 	return wrapper, info, nil
 }
 
+// GetPKCS11KMSFunc configures the pkcs11 seal. Its wrapper loads the PKCS#11
+// module through cgo, so it is registered by the server command rather than
+// linked into every user of this package.
+var GetPKCS11KMSFunc = func(opts *wrapping.WrapperOptions, kms *KMS) (wrapping.Wrapper, map[string]string, error) {
+	return nil, nil, fmt.Errorf("KMS type 'pkcs11' is not supported by this binary")
+}
+
 var GetTransitKMSFunc = func(opts *wrapping.WrapperOptions, kms *KMS) (wrapping.Wrapper, map[string]string, error) {
 	wrapper := transit.NewWrapper(opts)
 	wrapperInfo, err := wrapper.SetConfig(kms.Config)
@@ -52,6 +52,10 @@ var _ raft.BatchingFSM = (*FSM)(nil)
 
 type restoreCallback func(context.Context) error
 
+// invalidateCallback is called with the keys modified by the logs applied to
+// the FSM, or with nil keys when the FSM is replaced by a snapshot.
+type invalidateCallback func(keys []string)
+
 // FSMApplyResponse is returned from an FSM apply. It indicates if the apply was
 // successful or not.
 type FSMApplyResponse struct {
@@ -85,7 +89,15 @@ type FSM struct {
 	// retoreCb is called after we've restored a snapshot
 	restoreCb restoreCallback
 
+	// invalidateCb is called after logs modifying keys are applied, and after
+	// a snapshot is installed. It must not block.
+	invalidateCb invalidateCallback
+
 	chunker *raftchunking.ChunkingBatchingFSM
+
+	// usage accounts the keys and the size of the data stored in the FSM by
+	// top-level prefix.
+	usage fsmUsage
 }
 
 // NewFSM constructs a FSM using the given directory
@@ -106,6 +118,9 @@ func NewFSM(path string, logger log.Logger) (*FSM, error) {
 		latestTerm:   latestTerm,
 		latestIndex:  latestIndex,
 		latestConfig: latestConfig,
+		usage: fsmUsage{
+			prefixes: make(map[string]*PrefixUsage),
+		},
 	}
 
 	f.chunker = raftchunking.NewChunkingBatchingFSM(f, &FSMChunkStorage{
@@ -181,6 +196,8 @@ func (f *FSM) openDBFile(dbPath string) error {
 
 			f.latestConfig.Store(&latest)
 		}
+
+		f.usage.reset(scanUsage(tx))
 		return nil
 	})
 	if err != nil {
@@ -271,9 +288,18 @@ func (f *FSM) Delete(ctx context.Context, path string) error {
 	f.l.RLock()
 	defer f.l.RUnlock()
 
-	return f.db.Update(func(tx *bolt.Tx) error {
-		return tx.Bucket(dataBucketName).Delete([]byte(path))
+	delta := make(usageDelta)
+	err := f.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(dataBucketName)
+		delta.delete(b, path)
+		return b.Delete([]byte(path))
 	})
+	if err != nil {
+		return err
+	}
+
+	f.usage.apply(delta)
+	return nil
 }
 
 // Delete deletes the given key from the bolt file.
@@ -283,12 +309,14 @@ func (f *FSM) DeletePrefix(ctx context.Context, prefix string) error {
 	f.l.RLock()
 	defer f.l.RUnlock()
 
+	delta := make(usageDelta)
 	err := f.db.Update(func(tx *bolt.Tx) error {
 		// Assume bucket exists and has keys
 		c := tx.Bucket(dataBucketName).Cursor()
 
 		prefixBytes := []byte(prefix)
-		for k, _ := c.Seek(prefixBytes); k != nil && bytes.HasPrefix(k, prefixBytes); k, _ = c.Next() {
+		for k, v := c.Seek(prefixBytes); k != nil && bytes.HasPrefix(k, prefixBytes); k, v = c.Next() {
+			delta.remove(string(k), int64(len(v)))
 			if err := c.Delete(); err != nil {
 				return err
 			}
@@ -296,8 +324,12 @@ func (f *FSM) DeletePrefix(ctx context.Context, prefix string) error {
 
 		return nil
 	})
+	if err != nil {
+		return err
+	}
 
-	return err
+	f.usage.apply(delta)
+	return nil
 }
 
 // Get retrieves the value at the given path from the bolt file.
@@ -336,6 +368,25 @@ func (f *FSM) Get(ctx context.Context, path string) (*physical.Entry, error) {
 	}, nil
 }
 
+// Usage returns the number of keys stored in the FSM and the size of their
+// values, by top-level storage prefix such as "logical/<uuid>/" for the data
+// of a mount.
+func (f *FSM) Usage() []*PrefixUsage {
+	f.l.RLock()
+	defer f.l.RUnlock()
+
+	return f.usage.all()
+}
+
+// prefixUsage returns the usage of a single top-level prefix. It is read
+// under the FSM lock, so that it is not torn by a snapshot being restored.
+func (f *FSM) prefixUsage(prefix string) PrefixUsage {
+	f.l.RLock()
+	defer f.l.RUnlock()
+
+	return f.usage.get(prefix)
+}
+
 // Put writes the given entry to the bolt file.
 func (f *FSM) Put(ctx context.Context, entry *physical.Entry) error {
 	defer metrics.MeasureSince([]string{"raft_storage", "fsm", "put"}, time.Now())
@@ -344,9 +395,18 @@ func (f *FSM) Put(ctx context.Context, entry *physical.Entry) error {
 	defer f.l.RUnlock()
 
 	// Start a write transaction.
-	return f.db.Update(func(tx *bolt.Tx) error {
-		return tx.Bucket(dataBucketName).Put([]byte(entry.Key), entry.Value)
+	delta := make(usageDelta)
+	err := f.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(dataBucketName)
+		delta.put(b, entry.Key, entry.Value)
+		return b.Put([]byte(entry.Key), entry.Value)
 	})
+	if err != nil {
+		return err
+	}
+
+	f.usage.apply(delta)
+	return nil
 }
 
 // List retrieves the set of keys with the given prefix from the bolt file.
@@ -392,14 +452,17 @@ func (f *FSM) Transaction(ctx context.Context, txns []*physical.TxnEntry) error
 	defer f.l.RUnlock()
 
 	// Start a write transaction.
+	delta := make(usageDelta)
 	err := f.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(dataBucketName)
 		for _, txn := range txns {
 			var err error
 			switch txn.Operation {
 			case physical.PutOperation:
+				delta.put(b, txn.Entry.Key, txn.Entry.Value)
 				err = b.Put([]byte(txn.Entry.Key), txn.Entry.Value)
 			case physical.DeleteOperation:
+				delta.delete(b, txn.Entry.Key)
 				err = b.Delete([]byte(txn.Entry.Key))
 			default:
 				return fmt.Errorf("%q is not a supported transaction operation", txn.Operation)
@@ -411,7 +474,12 @@ func (f *FSM) Transaction(ctx context.Context, txns []*physical.TxnEntry) error
 
 		return nil
 	})
-	return err
+	if err != nil {
+		return err
+	}
+
+	f.usage.apply(delta)
+	return nil
 }
 
 // ApplyBatch will apply a set of logs to the FSM. This is called from the raft
@@ -473,6 +541,8 @@ func (f *FSM) ApplyBatch(logs []*raft.Log) []interface{} {
 		time.Sleep(f.applyDelay)
 	}
 
+	var invalidated []string
+	delta := make(usageDelta)
 	err = f.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(dataBucketName)
 		for _, commandRaw := range commands {
@@ -482,9 +552,13 @@ func (f *FSM) ApplyBatch(logs []*raft.Log) []interface{} {
 					var err error
 					switch op.OpType {
 					case putOp:
+						delta.put(b, op.Key, op.Value)
 						err = b.Put([]byte(op.Key), op.Value)
+						invalidated = append(invalidated, op.Key)
 					case deleteOp:
+						delta.delete(b, op.Key)
 						err = b.Delete([]byte(op.Key))
+						invalidated = append(invalidated, op.Key)
 					case restoreCallbackOp:
 						if f.restoreCb != nil {
 							// Kick off the restore callback function in a go routine
@@ -525,6 +599,8 @@ func (f *FSM) ApplyBatch(logs []*raft.Log) []interface{} {
 		panic("failed to store data")
 	}
 
+	f.usage.apply(delta)
+
 	// If we advanced the latest value, update the in-memory representation too.
 	if len(logIndex) > 0 {
 		atomic.StoreUint64(f.latestTerm, lastLog.Term)
@@ -536,6 +612,10 @@ func (f *FSM) ApplyBatch(logs []*raft.Log) []interface{} {
 		f.latestConfig.Store(latestConfiguration)
 	}
 
+	if f.invalidateCb != nil && len(invalidated) > 0 {
+		f.invalidateCb(invalidated)
+	}
+
 	// Build the responses. The logs array is used here to ensure we reply to
 	// all command values; even if they are not of the types we expect. This
 	// should future proof this function from more log types being provided.
@@ -667,6 +747,11 @@ func (f *FSM) Restore(r io.ReadCloser) error {
 		retErr = multierror.Append(retErr, errwrap.Wrapf("failed to open new bolt file: {{err}}", err))
 	}
 
+	// Any key may have changed
+	if f.invalidateCb != nil {
+		f.invalidateCb(nil)
+	}
+
 	return retErr.ErrorOrNil()
 }
 
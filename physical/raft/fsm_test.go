@@ -159,3 +159,66 @@ func TestFSM_List(t *testing.T) {
 		t.Fatal(diff)
 	}
 }
+
+func TestFSM_Usage(t *testing.T) {
+	fsm, dir := getFSM(t)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	for _, entry := range []*physical.Entry{
+		{Key: "core/mounts", Value: []byte("abc")},
+		{Key: "logical/uuid1/foo", Value: []byte("abcd")},
+		{Key: "logical/uuid1/bar/baz", Value: []byte("ab")},
+		{Key: "logical/uuid1/bar/qux", Value: []byte("a")},
+		{Key: "logical/uuid2/foo", Value: []byte("abcdef")},
+		{Key: "logical/uuid2/bar", Value: []byte{}},
+		{Key: chunkingPrefix + "1/1", Value: []byte("abc")},
+	} {
+		if err := fsm.Put(ctx, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Overwrites only account for the difference in size
+	if err := fsm.Put(ctx, &physical.Entry{Key: "logical/uuid1/foo", Value: []byte("abcdefgh")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsm.Delete(ctx, "logical/uuid2/foo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsm.Delete(ctx, "logical/uuid2/missing"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsm.DeletePrefix(ctx, "logical/uuid1/bar/"); err != nil {
+		t.Fatal(err)
+	}
+	err := fsm.Transaction(ctx, []*physical.TxnEntry{
+		{Operation: physical.PutOperation, Entry: &physical.Entry{Key: "sys/token/id/h1", Value: []byte("ab")}},
+		{Operation: physical.DeleteOperation, Entry: &physical.Entry{Key: "logical/uuid2/bar"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*PrefixUsage{
+		{Prefix: "core/mounts", Keys: 1, Size: 3},
+		{Prefix: "logical/uuid1/", Keys: 1, Size: 8},
+		{Prefix: "sys/token/", Keys: 1, Size: 2},
+	}
+	if diff := deep.Equal(fsm.Usage(), expected); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	// The usage is computed again when the bolt file is opened
+	if err := fsm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fsm, err = NewFSM(dir, fsm.logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fsm.Close()
+	if diff := deep.Equal(fsm.Usage(), expected); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
@@ -0,0 +1,163 @@
+package raft
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// PrefixUsage is the number of keys under a top-level storage prefix, and the
+// total size of their values.
+type PrefixUsage struct {
+	Prefix string `json:"prefix"`
+	Keys   int    `json:"keys"`
+	Size   int64  `json:"size"`
+}
+
+// fsmUsage accounts the keys stored in the FSM and the size of their values,
+// by top-level storage prefix as returned by storagePrefix.
+type fsmUsage struct {
+	l        sync.RWMutex
+	prefixes map[string]*PrefixUsage
+}
+
+// usageDelta is a change to the usage of the FSM made by a bolt transaction.
+// It is computed while the transaction is open, and applied to the usage of
+// the FSM once the transaction is committed.
+type usageDelta map[string]*PrefixUsage
+
+// scanUsage computes the usage of the data stored in the bolt file.
+func scanUsage(tx *bolt.Tx) usageDelta {
+	delta := make(usageDelta)
+	c := tx.Bucket(dataBucketName).Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		delta.add(string(k), int64(len(v)))
+	}
+	return delta
+}
+
+// put accounts for the value of the key being set to value, and must be
+// called before the value is written to the bucket.
+func (d usageDelta) put(b *bolt.Bucket, key string, value []byte) {
+	d.delete(b, key)
+	d.add(key, int64(len(value)))
+}
+
+// delete accounts for the key being deleted, and must be called before it is
+// deleted from the bucket.
+func (d usageDelta) delete(b *bolt.Bucket, key string) {
+	keyBytes := []byte(key)
+	// Use a cursor rather than Get, which doesn't tell apart missing keys
+	// from empty values
+	k, v := b.Cursor().Seek(keyBytes)
+	if !bytes.Equal(k, keyBytes) {
+		return
+	}
+
+	d.remove(key, int64(len(v)))
+}
+
+// add accounts for a key being stored with a value of the given size.
+func (d usageDelta) add(key string, size int64) {
+	u := d.usage(key)
+	if u == nil {
+		return
+	}
+	u.Keys++
+	u.Size += size
+}
+
+// remove accounts for a key being deleted along with its value of the given
+// size.
+func (d usageDelta) remove(key string, size int64) {
+	u := d.usage(key)
+	if u == nil {
+		return
+	}
+	u.Keys--
+	u.Size -= size
+}
+
+// usage returns the usage of the prefix of the key, or nil if the key is not
+// accounted for.
+func (d usageDelta) usage(key string) *PrefixUsage {
+	// Chunks of large logs are only stored until the log is applied
+	if strings.HasPrefix(key, chunkingPrefix) {
+		return nil
+	}
+
+	prefix := storagePrefix(key)
+	u, ok := d[prefix]
+	if !ok {
+		u = &PrefixUsage{
+			Prefix: prefix,
+		}
+		d[prefix] = u
+	}
+	return u
+}
+
+// reset replaces the usage with the one of a freshly opened bolt file.
+func (u *fsmUsage) reset(delta usageDelta) {
+	u.l.Lock()
+	defer u.l.Unlock()
+
+	u.prefixes = delta
+}
+
+// apply adds the changes made by a committed transaction to the usage.
+func (u *fsmUsage) apply(delta usageDelta) {
+	if len(delta) == 0 {
+		return
+	}
+
+	u.l.Lock()
+	defer u.l.Unlock()
+
+	for prefix, d := range delta {
+		current, ok := u.prefixes[prefix]
+		if !ok {
+			current = &PrefixUsage{
+				Prefix: prefix,
+			}
+			u.prefixes[prefix] = current
+		}
+		current.Keys += d.Keys
+		current.Size += d.Size
+		if current.Keys <= 0 {
+			delete(u.prefixes, prefix)
+		}
+	}
+}
+
+// get returns the usage of the given top-level prefix.
+func (u *fsmUsage) get(prefix string) PrefixUsage {
+	u.l.RLock()
+	defer u.l.RUnlock()
+
+	if current, ok := u.prefixes[prefix]; ok {
+		return *current
+	}
+	return PrefixUsage{
+		Prefix: prefix,
+	}
+}
+
+// all returns a copy of the usage of every prefix, sorted by prefix.
+func (u *fsmUsage) all() []*PrefixUsage {
+	u.l.RLock()
+	defer u.l.RUnlock()
+
+	usage := make([]*PrefixUsage, 0, len(u.prefixes))
+	for _, current := range u.prefixes {
+		c := *current
+		usage = append(usage, &c)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].Prefix < usage[j].Prefix
+	})
+	return usage
+}
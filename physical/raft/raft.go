@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,11 +26,13 @@ import (
 	raftboltdb "github.com/hashicorp/vault/physical/raft/logstore"
 	"github.com/hashicorp/vault/sdk/helper/consts"
 	"github.com/hashicorp/vault/sdk/helper/jsonutil"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
 	"github.com/hashicorp/vault/sdk/helper/tlsutil"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/hashicorp/vault/sdk/physical"
 	"github.com/hashicorp/vault/vault/cluster"
 	"github.com/hashicorp/vault/vault/seal"
+	bolt "go.etcd.io/bbolt"
 )
 
 // EnvVaultRaftNodeID is used to fetch the Raft node ID from the environment.
@@ -55,6 +58,19 @@ var (
 	restoreOpDelayDuration = 5 * time.Second
 
 	defaultMaxEntrySize = uint64(2 * raftchunking.ChunkSize)
+
+	// defaultMaxKeySize is the largest key bolt can store. Larger keys would
+	// fail to be applied to the FSM.
+	defaultMaxKeySize = uint64(bolt.MaxKeySize)
+)
+
+const (
+	// ErrKeyTooLarge is returned when writing a key larger than max_key_size
+	ErrKeyTooLarge = "put failed due to key being too large"
+
+	// ErrPrefixTooLarge is returned when a write would grow the data under a
+	// top-level storage prefix beyond max_prefix_size
+	ErrPrefixTooLarge = "put failed due to storage prefix being too large"
 )
 
 // RaftBackend implements the backend interfaces and uses the raft protocol to
@@ -120,6 +136,26 @@ type RaftBackend struct {
 	// It is suggested to use a value of 2x the Raft chunking size for optimal
 	// performance.
 	maxEntrySize uint64
+
+	// maxKeySize imposes a size limit (in bytes) on the keys written.
+	maxKeySize uint64
+
+	// maxPrefixSize imposes a limit (in bytes) on the total size of the
+	// values stored under a top-level storage prefix, such as the data of a
+	// mount. Zero means no limit.
+	maxPrefixSize uint64
+
+	// disableAutopilot prevents autopilot from being started on this node.
+	disableAutopilot bool
+
+	// localReadMounts are the mounts for which this node serves reads from
+	// its FSM while it is a standby.
+	localReadMounts []string
+
+	// autopilot watches the health of the cluster while this node is the
+	// active node.
+	autopilot     *autopilot
+	autopilotLock sync.RWMutex
 }
 
 // LeaderJoinInfo contains information required by a node to join itself as a
@@ -364,18 +400,58 @@ func NewRaftBackend(conf map[string]string, logger log.Logger) (physical.Backend
 		maxEntrySize = uint64(i)
 	}
 
+	maxKeySize := defaultMaxKeySize
+	if maxKeySizeCfg := conf["max_key_size"]; len(maxKeySizeCfg) != 0 {
+		i, err := strconv.Atoi(maxKeySizeCfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse 'max_key_size': %w", err)
+		}
+		if i <= 0 || uint64(i) > defaultMaxKeySize {
+			return nil, fmt.Errorf("'max_key_size' must be between 1 and %d", defaultMaxKeySize)
+		}
+
+		maxKeySize = uint64(i)
+	}
+
+	var maxPrefixSize uint64
+	if maxPrefixSizeCfg := conf["max_prefix_size"]; len(maxPrefixSizeCfg) != 0 {
+		maxPrefixSize, err = strconv.ParseUint(maxPrefixSizeCfg, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse 'max_prefix_size': %w", err)
+		}
+	}
+
+	var disableAutopilot bool
+	if disableAutopilotRaw := conf["disable_autopilot"]; len(disableAutopilotRaw) != 0 {
+		disableAutopilot, err = strconv.ParseBool(disableAutopilotRaw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse 'disable_autopilot': %w", err)
+		}
+	}
+
+	var localReadMounts []string
+	if localReadMountsRaw := conf["local_read_mounts"]; len(localReadMountsRaw) != 0 {
+		for _, mount := range strutil.ParseDedupAndSortStrings(localReadMountsRaw, ",") {
+			localReadMounts = append(localReadMounts, strings.Trim(mount, "/")+"/")
+		}
+	}
+
 	return &RaftBackend{
-		logger:       logger,
-		fsm:          fsm,
-		raftInitCh:   make(chan struct{}),
-		conf:         conf,
-		logStore:     log,
-		stableStore:  stable,
-		snapStore:    snap,
-		dataDir:      path,
-		localID:      localID,
-		permitPool:   physical.NewPermitPool(physical.DefaultParallelOperations),
-		maxEntrySize: maxEntrySize,
+		logger:           logger,
+		fsm:              fsm,
+		raftInitCh:       make(chan struct{}),
+		conf:             conf,
+		logStore:         log,
+		stableStore:      stable,
+		snapStore:        snap,
+		dataDir:          path,
+		localID:          localID,
+		permitPool:       physical.NewPermitPool(physical.DefaultParallelOperations),
+		maxEntrySize:     maxEntrySize,
+		maxKeySize:       maxKeySize,
+		maxPrefixSize:    maxPrefixSize,
+		disableAutopilot: disableAutopilot,
+		localReadMounts:  localReadMounts,
 	}, nil
 }
 
@@ -529,6 +605,16 @@ func (b *RaftBackend) SetRestoreCallback(restoreCb restoreCallback) {
 	b.fsm.l.Unlock()
 }
 
+// SetInvalidateCallback sets the callback to be called with the keys modified
+// by the logs applied to the FSM, or with nil keys when a snapshot replaces
+// the contents of the FSM. The callback is called while the FSM is locked, so
+// it must not block.
+func (b *RaftBackend) SetInvalidateCallback(invalidateCb func(keys []string)) {
+	b.fsm.l.Lock()
+	b.fsm.invalidateCb = invalidateCb
+	b.fsm.l.Unlock()
+}
+
 func (b *RaftBackend) applyConfigSettings(config *raft.Config) error {
 	config.Logger = b.logger
 	multiplierRaw, ok := b.conf["performance_multiplier"]
@@ -710,8 +796,10 @@ func (b *RaftBackend) SetupCluster(ctx context.Context, opts SetupOpts) error {
 			return errwrap.Wrapf("raft recovery failed to parse peers.json: {{err}}", err)
 		}
 
-		// Non-voting servers are only allowed in enterprise. If Suffrage is disabled,
-		// error out to indicate that it isn't allowed.
+		// Non-voting servers are allowed since read replicas can join the
+		// cluster. Autopilot promotes the ones which didn't join as read
+		// replicas once they are healthy. If Suffrage is disabled, error out
+		// to indicate that it isn't allowed.
 		for idx := range recoveryConfig.Servers {
 			if !nonVotersAllowed && recoveryConfig.Servers[idx].Suffrage == raft.Nonvoter {
 				return fmt.Errorf("raft recovery failed to parse configuration for node %q: setting `non_voter` is only supported in enterprise", recoveryConfig.Servers[idx].ID)
@@ -842,6 +930,28 @@ func (b *RaftBackend) AppliedIndex() uint64 {
 	return indexState.Index
 }
 
+// WaitForAppliedIndex blocks until the FSM has applied the given index, and
+// returns false if the context is done first.
+func (b *RaftBackend) WaitForAppliedIndex(ctx context.Context, index uint64) bool {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for b.AppliedIndex() < index {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
+
+// LocalReadMounts returns the mounts for which this node serves reads from its
+// FSM while it is a standby.
+func (b *RaftBackend) LocalReadMounts() []string {
+	return b.localReadMounts
+}
+
 // RemovePeer removes the given peer ID from the raft cluster. If the node is
 // ourselves we will give up leadership.
 func (b *RaftBackend) RemovePeer(ctx context.Context, peerID string) error {
@@ -890,7 +1000,9 @@ func (b *RaftBackend) GetConfiguration(ctx context.Context) (*RaftConfigurationR
 	return config, nil
 }
 
-// AddPeer adds a new server to the raft cluster
+// AddPeer adds a new server to the raft cluster. While autopilot is running
+// the server is added as a non-voter, and promoted to a voter by autopilot
+// once it is stable.
 func (b *RaftBackend) AddPeer(ctx context.Context, peerID, clusterAddr string) error {
 	b.l.RLock()
 	defer b.l.RUnlock()
@@ -899,6 +1011,13 @@ func (b *RaftBackend) AddPeer(ctx context.Context, peerID, clusterAddr string) e
 		return errors.New("raft storage is not initialized")
 	}
 
+	if b.getAutopilot() != nil {
+		b.logger.Debug("adding raft peer as a non-voter until it is stable", "node_id", peerID, "cluster_addr", clusterAddr)
+
+		future := b.raft.AddNonvoter(raft.ServerID(peerID), raft.ServerAddress(clusterAddr), 0, 0)
+		return future.Error()
+	}
+
 	b.logger.Debug("adding raft peer", "node_id", peerID, "cluster_addr", clusterAddr)
 
 	future := b.raft.AddVoter(raft.ServerID(peerID), raft.ServerAddress(clusterAddr), 0, 0)
@@ -1135,6 +1254,95 @@ func (b *RaftBackend) Transaction(ctx context.Context, txns []*physical.TxnEntry
 	return err
 }
 
+// checkEntryLimits returns an error if the given log command writes a key
+// larger than max_key_size, or grows the data under a top-level storage
+// prefix beyond max_prefix_size. Writes shrinking the data of a prefix are
+// always allowed, so that space can be reclaimed.
+//
+// The values being written are first assumed to add to the size of their
+// prefix, which is enough to allow most writes without touching the FSM. Only
+// when that would exceed max_prefix_size are the current values of the keys
+// read, costing one FSM read per key written under that prefix.
+//
+// max_prefix_size is a soft limit: the usage is read from the local FSM
+// before the log is applied, so logs applied concurrently, or not yet applied
+// locally, can take a prefix somewhat past it.
+func (b *RaftBackend) checkEntryLimits(ctx context.Context, command *LogData) error {
+	// The size of the value each key is left with by the command, or -1 if
+	// it is deleted, by prefix
+	written := make(map[string]map[string]int64)
+	for _, op := range command.Operations {
+		if op.OpType != putOp && op.OpType != deleteOp {
+			continue
+		}
+
+		prefix := storagePrefix(op.Key)
+		if op.OpType == putOp && uint64(len(op.Key)) > b.maxKeySize {
+			metrics.IncrCounterWithLabels([]string{"raft-storage", "entry_rejected"}, 1, []metrics.Label{{"reason", "key_size"}})
+			return fmt.Errorf("%s; got %d bytes under %q, max: %d bytes", ErrKeyTooLarge, len(op.Key), prefix, b.maxKeySize)
+		}
+
+		if b.maxPrefixSize == 0 {
+			continue
+		}
+		keys, ok := written[prefix]
+		if !ok {
+			keys = make(map[string]int64)
+			written[prefix] = keys
+		}
+		keys[op.Key] = -1
+		if op.OpType == putOp {
+			keys[op.Key] = int64(len(op.Value))
+		}
+	}
+
+	for prefix, keys := range written {
+		var growth int64
+		for _, size := range keys {
+			if size > 0 {
+				growth += size
+			}
+		}
+		if growth == 0 {
+			continue
+		}
+
+		usage := b.fsm.prefixUsage(prefix)
+		if uint64(usage.Size+growth) <= b.maxPrefixSize {
+			continue
+		}
+
+		// Account for the values being overwritten or deleted
+		for key := range keys {
+			existing, err := b.fsm.Get(ctx, key)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				growth -= int64(len(existing.Value))
+			}
+		}
+		if growth <= 0 {
+			continue
+		}
+		if total := usage.Size + growth; uint64(total) > b.maxPrefixSize {
+			metrics.IncrCounterWithLabels([]string{"raft-storage", "entry_rejected"}, 1, []metrics.Label{{"reason", "prefix_size"}})
+			return fmt.Errorf("%s; %q would hold %d bytes, max: %d bytes", ErrPrefixTooLarge, prefix, total, b.maxPrefixSize)
+		}
+	}
+
+	return nil
+}
+
+// StorageUsage returns the number of keys stored and the size of their
+// values, by top-level storage prefix.
+func (b *RaftBackend) StorageUsage() []*PrefixUsage {
+	if b.fsm == nil {
+		return nil
+	}
+	return b.fsm.Usage()
+}
+
 // applyLog will take a given log command and apply it to the raft log. applyLog
 // doesn't return until the log has been applied to a quorum of servers and is
 // persisted to the local FSM. Caller should hold the backend's read lock.
@@ -1143,6 +1351,10 @@ func (b *RaftBackend) applyLog(ctx context.Context, command *LogData) error {
 		return errors.New("raft storage backend is not initialized")
 	}
 
+	if err := b.checkEntryLimits(ctx, command); err != nil {
+		return err
+	}
+
 	commandBytes, err := proto.Marshal(command)
 	if err != nil {
 		return err
@@ -1166,6 +1378,11 @@ func (b *RaftBackend) applyLog(ctx context.Context, command *LogData) error {
 	}
 
 	if err := applyFuture.Error(); err != nil {
+		// Standbys serving local reads report their storage as read-only, so
+		// that requests needing writes are forwarded to the active node.
+		if err == raft.ErrNotLeader && len(b.localReadMounts) > 0 {
+			return logical.ErrReadOnly
+		}
 		return err
 	}
 
@@ -0,0 +1,646 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+)
+
+const (
+	// AutopilotStatusLeader, AutopilotStatusVoter and AutopilotStatusNonVoter
+	// describe the role of a server in the raft cluster. Read replicas are
+	// non-voters which joined the cluster as such, and which autopilot never
+	// promotes.
+	AutopilotStatusLeader      = "leader"
+	AutopilotStatusVoter       = "voter"
+	AutopilotStatusNonVoter    = "non-voter"
+	AutopilotStatusReadReplica = "read-replica"
+
+	// AutopilotNodeStatusAlive and AutopilotNodeStatusDead describe whether a
+	// server has been in contact within the dead server threshold.
+	AutopilotNodeStatusAlive = "alive"
+	AutopilotNodeStatusDead  = "dead"
+)
+
+var (
+	// autopilotReconcileInterval is how often autopilot updates the state of
+	// the servers and acts on it.
+	autopilotReconcileInterval = 2 * time.Second
+
+	// minDeadServerLastContactThreshold is the lowest dead server threshold
+	// allowed, so that servers which are only briefly unreachable, e.g. while
+	// restarting, are not removed.
+	minDeadServerLastContactThreshold = time.Minute
+
+	errAutopilotNotLeader = errors.New("node is not the raft leader")
+)
+
+// FollowerState is the state of a follower as last reported by it to the
+// active node.
+type FollowerState struct {
+	AppliedIndex  uint64
+	LastHeartbeat time.Time
+}
+
+// FollowerStates tracks the state of the followers of the active node, as
+// reported in their heartbeats.
+type FollowerStates struct {
+	l         sync.RWMutex
+	followers map[string]*FollowerState
+
+	// nonVoters are the followers which joined the cluster as non-voters,
+	// and must stay so.
+	nonVoters map[string]struct{}
+}
+
+// NewFollowerStates returns an empty FollowerStates.
+func NewFollowerStates() *FollowerStates {
+	return &FollowerStates{
+		followers: make(map[string]*FollowerState),
+		nonVoters: make(map[string]struct{}),
+	}
+}
+
+// Update records a heartbeat from the given follower.
+func (s *FollowerStates) Update(nodeID string, appliedIndex uint64) {
+	s.l.Lock()
+	s.followers[nodeID] = &FollowerState{
+		AppliedIndex:  appliedIndex,
+		LastHeartbeat: time.Now(),
+	}
+	s.l.Unlock()
+}
+
+// Delete forgets the given follower.
+func (s *FollowerStates) Delete(nodeID string) {
+	s.l.Lock()
+	delete(s.followers, nodeID)
+	delete(s.nonVoters, nodeID)
+	s.l.Unlock()
+}
+
+// SetNonVoter records whether the given follower joined the cluster as a
+// non-voter, in which case autopilot doesn't promote it.
+func (s *FollowerStates) SetNonVoter(nodeID string, nonVoter bool) {
+	s.l.Lock()
+	if nonVoter {
+		s.nonVoters[nodeID] = struct{}{}
+	} else {
+		delete(s.nonVoters, nodeID)
+	}
+	s.l.Unlock()
+}
+
+// IsNonVoter returns whether the given follower joined the cluster as a
+// non-voter.
+func (s *FollowerStates) IsNonVoter(nodeID string) bool {
+	s.l.RLock()
+	_, ok := s.nonVoters[nodeID]
+	s.l.RUnlock()
+	return ok
+}
+
+// Get returns the state of the given follower, or nil if it is not known.
+func (s *FollowerStates) Get(nodeID string) *FollowerState {
+	s.l.RLock()
+	defer s.l.RUnlock()
+
+	state, ok := s.followers[nodeID]
+	if !ok {
+		return nil
+	}
+	copied := *state
+	return &copied
+}
+
+// MinIndex returns the lowest applied index of the followers.
+func (s *FollowerStates) MinIndex() uint64 {
+	var min uint64 = math.MaxUint64
+	minFunc := func(a, b uint64) uint64 {
+		if a > b {
+			return b
+		}
+		return a
+	}
+
+	s.l.RLock()
+	for _, state := range s.followers {
+		min = minFunc(min, state.AppliedIndex)
+	}
+	s.l.RUnlock()
+
+	if min == math.MaxUint64 {
+		return 0
+	}
+
+	return min
+}
+
+// AutopilotConfig is the configuration of autopilot, which is stored by the
+// active node and applies to the whole cluster.
+type AutopilotConfig struct {
+	// CleanupDeadServers enables the removal of servers that have not been in
+	// contact for DeadServerLastContactThreshold.
+	CleanupDeadServers bool `json:"cleanup_dead_servers"`
+
+	// LastContactThreshold is the longest time a server may go without
+	// contacting the leader before it is considered unhealthy.
+	LastContactThreshold time.Duration `json:"last_contact_threshold"`
+
+	// DeadServerLastContactThreshold is the longest time a server may go
+	// without contacting the leader before it is considered dead.
+	DeadServerLastContactThreshold time.Duration `json:"dead_server_last_contact_threshold"`
+
+	// MaxTrailingLogs is the most a server's applied index may lag behind the
+	// leader's before it is considered unhealthy.
+	MaxTrailingLogs uint64 `json:"max_trailing_logs"`
+
+	// MinQuorum is the lowest number of voters autopilot will leave in the
+	// cluster when removing dead servers.
+	MinQuorum uint `json:"min_quorum"`
+
+	// ServerStabilizationTime is how long a new server must be healthy
+	// before it is promoted to a voter.
+	ServerStabilizationTime time.Duration `json:"server_stabilization_time"`
+}
+
+// DefaultAutopilotConfig returns the configuration used until one is set.
+func DefaultAutopilotConfig() *AutopilotConfig {
+	return &AutopilotConfig{
+		CleanupDeadServers:             false,
+		LastContactThreshold:           10 * time.Second,
+		DeadServerLastContactThreshold: 24 * time.Hour,
+		MaxTrailingLogs:                1000,
+		MinQuorum:                      3,
+		ServerStabilizationTime:        10 * time.Second,
+	}
+}
+
+// Clone returns a copy of the configuration.
+func (c *AutopilotConfig) Clone() *AutopilotConfig {
+	if c == nil {
+		return nil
+	}
+	copied := *c
+	return &copied
+}
+
+// Validate checks that the configuration is usable.
+func (c *AutopilotConfig) Validate() error {
+	switch {
+	case c.LastContactThreshold <= 0:
+		return errors.New("last_contact_threshold must be positive")
+	case c.ServerStabilizationTime < 0:
+		return errors.New("server_stabilization_time must not be negative")
+	case c.DeadServerLastContactThreshold < minDeadServerLastContactThreshold:
+		return fmt.Errorf("dead_server_last_contact_threshold must be at least %s", minDeadServerLastContactThreshold)
+	case c.DeadServerLastContactThreshold < c.LastContactThreshold:
+		return errors.New("dead_server_last_contact_threshold must not be less than last_contact_threshold")
+	case c.CleanupDeadServers && c.MinQuorum < 3:
+		return errors.New("min_quorum must be at least 3 when cleanup_dead_servers is set")
+	}
+	return nil
+}
+
+// AutopilotServer is the state of a server in the raft cluster as seen by
+// autopilot.
+type AutopilotServer struct {
+	ID      string
+	Address string
+
+	// Status is the role of the server in the cluster: leader, voter,
+	// non-voter or read-replica.
+	Status string
+
+	// NodeStatus is dead if the server has not been in contact for the dead
+	// server threshold, and alive otherwise.
+	NodeStatus string
+
+	// Healthy is set if the server has been in contact recently and its
+	// applied index is close enough to the leader's.
+	Healthy bool
+
+	// LastContact is the time since the server was last in contact with the
+	// leader.
+	LastContact time.Duration
+
+	// LastIndex is the last index the server is known to have applied.
+	LastIndex uint64
+
+	// StableSince is the time since when the server has been healthy, or
+	// the zero time if it is not.
+	StableSince time.Time
+}
+
+// AutopilotState is the state of the raft cluster as seen by autopilot.
+type AutopilotState struct {
+	// Healthy is set if all the servers are healthy.
+	Healthy bool
+
+	// FailureTolerance is the number of healthy voters that can fail
+	// without losing quorum.
+	FailureTolerance int
+
+	Leader  string
+	Voters  []string
+	Servers map[string]*AutopilotServer
+}
+
+// autopilot watches the health of the servers of the raft cluster from the
+// leader, removing dead servers and promoting stable non-voters.
+type autopilot struct {
+	logger         log.Logger
+	backend        *RaftBackend
+	followerStates *FollowerStates
+
+	l      sync.RWMutex
+	config *AutopilotConfig
+	state  *AutopilotState
+
+	// firstSeen records when servers that have not yet sent a heartbeat were
+	// first seen, which their last contact is measured from.
+	firstSeen map[string]time.Time
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+func newAutopilot(logger log.Logger, backend *RaftBackend, followerStates *FollowerStates, config *AutopilotConfig) *autopilot {
+	if config == nil {
+		config = DefaultAutopilotConfig()
+	}
+	return &autopilot{
+		logger:         logger,
+		backend:        backend,
+		followerStates: followerStates,
+		config:         config,
+		firstSeen:      make(map[string]time.Time),
+		stopCh:         make(chan struct{}),
+		doneCh:         make(chan struct{}),
+	}
+}
+
+// AutopilotEnabled returns whether autopilot is allowed to run on this node.
+// It is disabled by the disable_autopilot storage option.
+func (b *RaftBackend) AutopilotEnabled() bool {
+	return !b.disableAutopilot
+}
+
+// StartAutopilot starts autopilot, using the given follower states to learn
+// about the health of the other servers. It must only be called on the
+// active node.
+func (b *RaftBackend) StartAutopilot(followerStates *FollowerStates, config *AutopilotConfig) {
+	if b.disableAutopilot {
+		return
+	}
+
+	b.autopilotLock.Lock()
+	defer b.autopilotLock.Unlock()
+
+	if b.autopilot != nil {
+		return
+	}
+
+	b.autopilot = newAutopilot(b.logger.Named("autopilot"), b, followerStates, config)
+	go b.autopilot.run()
+}
+
+// StopAutopilot stops autopilot if it is running.
+func (b *RaftBackend) StopAutopilot() {
+	b.autopilotLock.Lock()
+	a := b.autopilot
+	b.autopilot = nil
+	b.autopilotLock.Unlock()
+
+	if a == nil {
+		return
+	}
+
+	close(a.stopCh)
+	<-a.doneCh
+}
+
+func (b *RaftBackend) getAutopilot() *autopilot {
+	b.autopilotLock.RLock()
+	defer b.autopilotLock.RUnlock()
+	return b.autopilot
+}
+
+// SetAutopilotConfig updates the configuration of the running autopilot.
+func (b *RaftBackend) SetAutopilotConfig(config *AutopilotConfig) {
+	a := b.getAutopilot()
+	if a == nil {
+		return
+	}
+
+	a.l.Lock()
+	a.config = config.Clone()
+	a.l.Unlock()
+}
+
+// AutopilotState returns the state of the cluster as last computed by
+// autopilot.
+func (b *RaftBackend) AutopilotState() (*AutopilotState, error) {
+	a := b.getAutopilot()
+	if a == nil {
+		return nil, errors.New("autopilot is not running")
+	}
+
+	a.l.RLock()
+	state := a.state
+	a.l.RUnlock()
+
+	if state == nil {
+		// Autopilot has not run yet, so compute the state now
+		var err error
+		state, err = a.updateState(time.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return state, nil
+}
+
+func (a *autopilot) run() {
+	defer close(a.doneCh)
+
+	a.logger.Debug("starting autopilot")
+
+	ticker := time.NewTicker(autopilotReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stopCh:
+			a.logger.Debug("stopping autopilot")
+			return
+		case <-ticker.C:
+			err := a.reconcile(time.Now())
+			switch {
+			case err == nil:
+			case errors.Is(err, errAutopilotNotLeader):
+				// Leadership was lost, autopilot is about to be stopped
+				a.logger.Debug("not reconciling raft cluster", "error", err)
+			default:
+				a.logger.Error("failed to reconcile raft cluster", "error", err)
+			}
+		}
+	}
+}
+
+// reconcile updates the state of the servers, then promotes the stable
+// non-voters and removes the dead servers.
+func (a *autopilot) reconcile(now time.Time) error {
+	state, err := a.updateState(now)
+	if err != nil {
+		return err
+	}
+
+	a.l.RLock()
+	config := a.config.Clone()
+	a.l.RUnlock()
+
+	ctx := context.Background()
+
+	for _, id := range serversToPromote(config, state, now) {
+		server := state.Servers[id]
+		a.logger.Info("promoting stable server to voter", "node_id", id)
+		if err := a.backend.promotePeer(ctx, id, server.Address); err != nil {
+			return fmt.Errorf("failed to promote server %q: %w", id, err)
+		}
+	}
+
+	for _, id := range deadServersToRemove(config, state) {
+		a.logger.Info("removing dead server", "node_id", id, "last_contact", state.Servers[id].LastContact)
+		if err := a.backend.RemovePeer(ctx, id); err != nil {
+			return fmt.Errorf("failed to remove dead server %q: %w", id, err)
+		}
+		a.followerStates.Delete(id)
+
+		a.l.Lock()
+		delete(a.firstSeen, id)
+		a.l.Unlock()
+	}
+
+	return nil
+}
+
+// updateState computes and stores the state of the servers in the current
+// raft configuration.
+func (a *autopilot) updateState(now time.Time) (*AutopilotState, error) {
+	servers, lastIndex, err := a.backend.autopilotServers()
+	if err != nil {
+		return nil, err
+	}
+
+	a.l.Lock()
+	defer a.l.Unlock()
+
+	state := a.computeState(now, servers, a.backend.NodeID(), lastIndex)
+	a.state = state
+
+	healthy := float32(0)
+	if state.Healthy {
+		healthy = 1
+	}
+	metrics.SetGauge([]string{"autopilot", "healthy"}, healthy)
+	metrics.SetGauge([]string{"autopilot", "failure_tolerance"}, float32(state.FailureTolerance))
+
+	return state, nil
+}
+
+// computeState computes the state of the given servers. It must be called
+// with the lock held.
+func (a *autopilot) computeState(now time.Time, servers []raft.Server, leaderID string, lastIndex uint64) *AutopilotState {
+	state := &AutopilotState{
+		Leader:  leaderID,
+		Servers: make(map[string]*AutopilotServer, len(servers)),
+	}
+
+	seen := make(map[string]struct{}, len(servers))
+	healthyVoters := 0
+	allHealthy := true
+	for _, s := range servers {
+		id := string(s.ID)
+		seen[id] = struct{}{}
+
+		server := &AutopilotServer{
+			ID:         id,
+			Address:    string(s.Address),
+			Status:     AutopilotStatusNonVoter,
+			NodeStatus: AutopilotNodeStatusAlive,
+		}
+
+		switch {
+		case id == leaderID:
+			server.Status = AutopilotStatusLeader
+			server.LastIndex = lastIndex
+		default:
+			switch {
+			case s.Suffrage == raft.Voter:
+				server.Status = AutopilotStatusVoter
+			case a.followerStates.IsNonVoter(id):
+				server.Status = AutopilotStatusReadReplica
+			}
+
+			lastHeartbeat := now
+			if followerState := a.followerStates.Get(id); followerState != nil {
+				lastHeartbeat = followerState.LastHeartbeat
+				server.LastIndex = followerState.AppliedIndex
+			} else {
+				// The server has not sent a heartbeat yet, so measure its
+				// last contact from when it was first seen.
+				firstSeen, ok := a.firstSeen[id]
+				if !ok {
+					firstSeen = now
+					a.firstSeen[id] = now
+				}
+				lastHeartbeat = firstSeen
+			}
+			if now.After(lastHeartbeat) {
+				server.LastContact = now.Sub(lastHeartbeat)
+			}
+		}
+
+		var lag uint64
+		if lastIndex > server.LastIndex {
+			lag = lastIndex - server.LastIndex
+		}
+		server.Healthy = server.LastContact <= a.config.LastContactThreshold && lag <= a.config.MaxTrailingLogs
+		if server.LastContact > a.config.DeadServerLastContactThreshold {
+			server.NodeStatus = AutopilotNodeStatusDead
+		}
+
+		if server.Healthy {
+			server.StableSince = now
+			if a.state != nil {
+				if prev, ok := a.state.Servers[id]; ok && prev.Healthy {
+					server.StableSince = prev.StableSince
+				}
+			}
+		} else {
+			allHealthy = false
+		}
+
+		if s.Suffrage == raft.Voter {
+			state.Voters = append(state.Voters, id)
+			if server.Healthy {
+				healthyVoters++
+			}
+		}
+
+		state.Servers[id] = server
+	}
+
+	for id := range a.firstSeen {
+		if _, ok := seen[id]; !ok {
+			delete(a.firstSeen, id)
+		}
+	}
+
+	sort.Strings(state.Voters)
+	state.FailureTolerance = healthyVoters - (len(state.Voters)/2 + 1)
+	if state.FailureTolerance < 0 {
+		state.FailureTolerance = 0
+	}
+	state.Healthy = allHealthy && healthyVoters > len(state.Voters)/2
+
+	return state
+}
+
+// serversToPromote returns the non-voters that have been healthy for at
+// least the server stabilization time. Read replicas are never promoted.
+func serversToPromote(config *AutopilotConfig, state *AutopilotState, now time.Time) []string {
+	var ids []string
+	for id, server := range state.Servers {
+		if server.Status != AutopilotStatusNonVoter || !server.Healthy {
+			continue
+		}
+		if now.Sub(server.StableSince) < config.ServerStabilizationTime {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// deadServersToRemove returns the dead servers that can be removed. Voters are
+// only removed as long as min_quorum voters remain, and no more than a
+// minority of the voters is removed at once.
+func deadServersToRemove(config *AutopilotConfig, state *AutopilotState) []string {
+	if !config.CleanupDeadServers {
+		return nil
+	}
+
+	var nonVoters, voters []string
+	for id, server := range state.Servers {
+		if server.NodeStatus != AutopilotNodeStatusDead || server.Status == AutopilotStatusLeader {
+			continue
+		}
+		switch server.Status {
+		case AutopilotStatusVoter:
+			voters = append(voters, id)
+		default:
+			nonVoters = append(nonVoters, id)
+		}
+	}
+	sort.Strings(nonVoters)
+	sort.Strings(voters)
+
+	removable := len(state.Voters) - int(config.MinQuorum)
+	if minority := (len(state.Voters) - 1) / 2; minority < removable {
+		removable = minority
+	}
+	switch {
+	case removable <= 0:
+		voters = nil
+	case len(voters) > removable:
+		voters = voters[:removable]
+	}
+
+	return append(nonVoters, voters...)
+}
+
+// autopilotServers returns the servers in the raft configuration and the last
+// index of the log, if this node is the leader.
+func (b *RaftBackend) autopilotServers() ([]raft.Server, uint64, error) {
+	b.l.RLock()
+	defer b.l.RUnlock()
+
+	if b.raft == nil {
+		return nil, 0, errors.New("raft storage is not initialized")
+	}
+	if b.raft.State() != raft.Leader {
+		return nil, 0, errAutopilotNotLeader
+	}
+
+	future := b.raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return nil, 0, err
+	}
+
+	return future.Configuration().Servers, b.raft.LastIndex(), nil
+}
+
+// promotePeer makes the given non-voter a voter.
+func (b *RaftBackend) promotePeer(ctx context.Context, peerID, clusterAddr string) error {
+	b.l.RLock()
+	defer b.l.RUnlock()
+
+	if b.raft == nil {
+		return errors.New("raft storage is not initialized")
+	}
+
+	future := b.raft.AddVoter(raft.ServerID(peerID), raft.ServerAddress(clusterAddr), 0, 0)
+	return future.Error()
+}
@@ -0,0 +1,194 @@
+package raft
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+)
+
+func TestRaft_Autopilot_State(t *testing.T) {
+	followerStates := NewFollowerStates()
+	config := DefaultAutopilotConfig()
+	config.CleanupDeadServers = true
+	a := newAutopilot(hclog.NewNullLogger(), nil, followerStates, config)
+
+	servers := []raft.Server{
+		{ID: "node1", Address: "node1:8201", Suffrage: raft.Voter},
+		{ID: "node2", Address: "node2:8201", Suffrage: raft.Voter},
+		{ID: "node3", Address: "node3:8201", Suffrage: raft.Voter},
+		{ID: "node4", Address: "node4:8201", Suffrage: raft.Nonvoter},
+	}
+	followerStates.Update("node2", 100)
+	followerStates.Update("node3", 100)
+	followerStates.Update("node4", 100)
+
+	start := time.Now()
+	state := a.computeState(start, servers, "node1", 100)
+	if !state.Healthy || state.FailureTolerance != 1 {
+		t.Fatalf("expected healthy cluster tolerating one failure: %#v", state)
+	}
+	if !reflect.DeepEqual(state.Voters, []string{"node1", "node2", "node3"}) {
+		t.Fatalf("bad voters: %v", state.Voters)
+	}
+	if state.Servers["node1"].Status != AutopilotStatusLeader || state.Servers["node4"].Status != AutopilotStatusNonVoter {
+		t.Fatalf("bad statuses: %#v %#v", state.Servers["node1"], state.Servers["node4"])
+	}
+	a.state = state
+
+	// The non-voter is promoted once it has been stable long enough
+	if ids := serversToPromote(config, state, start); len(ids) != 0 {
+		t.Fatalf("expected no promotion before the stabilization time, got %v", ids)
+	}
+	later := start.Add(config.ServerStabilizationTime)
+	if ids := serversToPromote(config, state, later); !reflect.DeepEqual(ids, []string{"node4"}) {
+		t.Fatalf("expected node4 to be promoted, got %v", ids)
+	}
+
+	// Servers lagging behind are unhealthy, and their stability restarts once
+	// they catch up
+	state = a.computeState(start, servers, "node1", 100+config.MaxTrailingLogs+1)
+	if state.Healthy || state.FailureTolerance != 0 || state.Servers["node4"].Healthy {
+		t.Fatalf("expected lagging servers to be unhealthy: %#v", state)
+	}
+	a.state = state
+	followerStates.Update("node4", 2000)
+	state = a.computeState(later, servers, "node1", 2000)
+	if !state.Servers["node4"].Healthy || !state.Servers["node4"].StableSince.Equal(later) {
+		t.Fatalf("expected node4 to be stable since now: %#v", state.Servers["node4"])
+	}
+	if ids := serversToPromote(config, state, later); len(ids) != 0 {
+		t.Fatalf("expected no promotion of a newly stable server, got %v", ids)
+	}
+
+	// Servers that stop sending heartbeats are unhealthy, then dead
+	dead := start.Add(config.DeadServerLastContactThreshold + time.Second)
+	followerStates.Update("node2", 100)
+	followerStates.followers["node3"].LastHeartbeat = start
+	followerStates.followers["node4"].LastHeartbeat = start
+	followerStates.followers["node2"].LastHeartbeat = dead
+	state = a.computeState(dead, servers, "node1", 100)
+	if state.Servers["node3"].Healthy || state.Servers["node3"].NodeStatus != AutopilotNodeStatusDead {
+		t.Fatalf("expected node3 to be dead: %#v", state.Servers["node3"])
+	}
+	if !state.Servers["node2"].Healthy {
+		t.Fatalf("expected node2 to be healthy: %#v", state.Servers["node2"])
+	}
+
+	// Dead voters are only removed down to min_quorum
+	if ids := deadServersToRemove(config, state); !reflect.DeepEqual(ids, []string{"node4"}) {
+		t.Fatalf("expected only the non-voter to be removed, got %v", ids)
+	}
+	servers = append(servers, raft.Server{ID: "node5", Address: "node5:8201", Suffrage: raft.Voter})
+	followerStates.Update("node5", 100)
+	followerStates.followers["node5"].LastHeartbeat = dead
+	state = a.computeState(dead, servers, "node1", 100)
+	if ids := deadServersToRemove(config, state); !reflect.DeepEqual(ids, []string{"node4", "node3"}) {
+		t.Fatalf("expected node4 and node3 to be removed, got %v", ids)
+	}
+
+	config.CleanupDeadServers = false
+	if ids := deadServersToRemove(config, state); len(ids) != 0 {
+		t.Fatalf("expected no removal with cleanup disabled, got %v", ids)
+	}
+}
+
+func TestRaft_Autopilot_FirstSeen(t *testing.T) {
+	a := newAutopilot(hclog.NewNullLogger(), nil, NewFollowerStates(), nil)
+
+	servers := []raft.Server{
+		{ID: "node1", Address: "node1:8201", Suffrage: raft.Voter},
+		{ID: "node2", Address: "node2:8201", Suffrage: raft.Nonvoter},
+	}
+
+	// Servers that have not sent a heartbeat yet are measured from when they
+	// were first seen
+	start := time.Now()
+	state := a.computeState(start, servers, "node1", 0)
+	if !state.Servers["node2"].Healthy || state.Servers["node2"].LastContact != 0 {
+		t.Fatalf("expected node2 to be healthy when first seen: %#v", state.Servers["node2"])
+	}
+	a.state = state
+
+	later := start.Add(a.config.LastContactThreshold + time.Second)
+	state = a.computeState(later, servers, "node1", 0)
+	if state.Servers["node2"].Healthy || state.Servers["node2"].LastContact != a.config.LastContactThreshold+time.Second {
+		t.Fatalf("expected node2 to be unhealthy: %#v", state.Servers["node2"])
+	}
+
+	// Servers are forgotten once they leave the configuration
+	a.computeState(later, servers[:1], "node1", 0)
+	if len(a.firstSeen) != 0 {
+		t.Fatalf("expected first seen times to be cleared: %v", a.firstSeen)
+	}
+}
+
+func TestRaft_Autopilot_ReadReplicas(t *testing.T) {
+	followerStates := NewFollowerStates()
+	config := DefaultAutopilotConfig()
+	config.CleanupDeadServers = true
+	a := newAutopilot(hclog.NewNullLogger(), nil, followerStates, config)
+
+	servers := []raft.Server{
+		{ID: "node1", Address: "node1:8201", Suffrage: raft.Voter},
+		{ID: "node2", Address: "node2:8201", Suffrage: raft.Nonvoter},
+		{ID: "node3", Address: "node3:8201", Suffrage: raft.Nonvoter},
+	}
+	followerStates.Update("node2", 100)
+	followerStates.Update("node3", 100)
+	followerStates.SetNonVoter("node3", true)
+
+	start := time.Now()
+	state := a.computeState(start, servers, "node1", 100)
+	if state.Servers["node2"].Status != AutopilotStatusNonVoter || state.Servers["node3"].Status != AutopilotStatusReadReplica {
+		t.Fatalf("bad statuses: %#v %#v", state.Servers["node2"], state.Servers["node3"])
+	}
+	if !reflect.DeepEqual(state.Voters, []string{"node1"}) {
+		t.Fatalf("bad voters: %v", state.Voters)
+	}
+	a.state = state
+
+	// Read replicas are never promoted
+	later := start.Add(config.ServerStabilizationTime)
+	if ids := serversToPromote(config, state, later); !reflect.DeepEqual(ids, []string{"node2"}) {
+		t.Fatalf("expected only node2 to be promoted, got %v", ids)
+	}
+
+	// Dead read replicas are removed like other non-voters
+	dead := start.Add(config.DeadServerLastContactThreshold + time.Second)
+	followerStates.followers["node3"].LastHeartbeat = start
+	followerStates.Update("node2", 100)
+	followerStates.followers["node2"].LastHeartbeat = dead
+	state = a.computeState(dead, servers, "node1", 100)
+	if ids := deadServersToRemove(config, state); !reflect.DeepEqual(ids, []string{"node3"}) {
+		t.Fatalf("expected node3 to be removed, got %v", ids)
+	}
+
+	// Forgetting a follower clears its non-voter mark
+	followerStates.Delete("node3")
+	if followerStates.IsNonVoter("node3") {
+		t.Fatal("expected node3 to no longer be a non-voter")
+	}
+}
+
+func TestRaft_Autopilot_ConfigValidate(t *testing.T) {
+	if err := DefaultAutopilotConfig().Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, mutate := range map[string]func(*AutopilotConfig){
+		"no last contact threshold":  func(c *AutopilotConfig) { c.LastContactThreshold = 0 },
+		"short dead threshold":       func(c *AutopilotConfig) { c.DeadServerLastContactThreshold = time.Second },
+		"negative stabilization":     func(c *AutopilotConfig) { c.ServerStabilizationTime = -time.Second },
+		"cleanup with low quorum":    func(c *AutopilotConfig) { c.CleanupDeadServers, c.MinQuorum = true, 2 },
+		"dead threshold below alive": func(c *AutopilotConfig) { c.LastContactThreshold = 2 * time.Hour; c.DeadServerLastContactThreshold = time.Hour },
+	} {
+		config := DefaultAutopilotConfig()
+		mutate(config)
+		if err := config.Validate(); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -284,6 +285,158 @@ func TestRaft_TransactionalBackend_LargeValue(t *testing.T) {
 	}
 }
 
+func TestRaft_Backend_LargeKey(t *testing.T) {
+	b, dir := getRaft(t, true, true)
+	defer os.RemoveAll(dir)
+
+	b.maxKeySize = 16
+
+	txns := []*physical.TxnEntry{
+		&physical.TxnEntry{
+			Operation: physical.PutOperation,
+			Entry: &physical.Entry{
+				Key:   "foo",
+				Value: []byte("bar"),
+			},
+		},
+		&physical.TxnEntry{
+			Operation: physical.PutOperation,
+			Entry: &physical.Entry{
+				Key:   "logical/uuid/" + strings.Repeat("a", 16),
+				Value: []byte("bar"),
+			},
+		},
+	}
+
+	err := b.Transaction(context.Background(), txns)
+	if err == nil {
+		t.Fatal("expected error for transactions")
+	}
+	if !strings.Contains(err.Error(), ErrKeyTooLarge) || !strings.Contains(err.Error(), "logical/uuid/") {
+		t.Fatalf("expected %q, got %v", ErrKeyTooLarge, err)
+	}
+
+	out, err := b.Get(context.Background(), txns[0].Entry.Key)
+	if err != nil {
+		t.Fatalf("unexpected error after failed put: %v", err)
+	}
+	if out != nil {
+		t.Fatal("expected response entry to be nil after a failed put")
+	}
+
+	// Deleting large keys is still allowed
+	err = b.Delete(context.Background(), txns[1].Entry.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRaft_Backend_MaxPrefixSize(t *testing.T) {
+	b, dir := getRaft(t, true, true)
+	defer os.RemoveAll(dir)
+
+	b.maxPrefixSize = 10
+	ctx := context.Background()
+
+	put := func(key string, size int) error {
+		return b.Put(ctx, &physical.Entry{Key: key, Value: make([]byte, size)})
+	}
+
+	if err := put("logical/uuid1/foo", 6); err != nil {
+		t.Fatal(err)
+	}
+	if err := put("logical/uuid1/bar", 4); err != nil {
+		t.Fatal(err)
+	}
+
+	// Other prefixes have their own limit
+	if err := put("logical/uuid2/foo", 8); err != nil {
+		t.Fatal(err)
+	}
+
+	err := put("logical/uuid1/baz", 1)
+	if err == nil || !strings.Contains(err.Error(), ErrPrefixTooLarge) {
+		t.Fatalf("expected %q, got %v", ErrPrefixTooLarge, err)
+	}
+
+	// Overwriting a value with a smaller one is allowed
+	if err := put("logical/uuid1/foo", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := put("logical/uuid1/baz", 4); err != nil {
+		t.Fatal(err)
+	}
+
+	// Transactions account for the values they delete
+	txns := []*physical.TxnEntry{
+		&physical.TxnEntry{
+			Operation: physical.DeleteOperation,
+			Entry: &physical.Entry{
+				Key: "logical/uuid1/bar",
+			},
+		},
+		&physical.TxnEntry{
+			Operation: physical.PutOperation,
+			Entry: &physical.Entry{
+				Key:   "logical/uuid1/qux",
+				Value: make([]byte, 4),
+			},
+		},
+	}
+	if err := b.Transaction(ctx, txns); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the last write of a key in a transaction counts
+	txns = []*physical.TxnEntry{
+		&physical.TxnEntry{
+			Operation: physical.PutOperation,
+			Entry: &physical.Entry{
+				Key:   "logical/uuid1/qux",
+				Value: make([]byte, 8),
+			},
+		},
+		&physical.TxnEntry{
+			Operation: physical.PutOperation,
+			Entry: &physical.Entry{
+				Key:   "logical/uuid1/qux",
+				Value: make([]byte, 4),
+			},
+		},
+	}
+	if err := b.Transaction(ctx, txns); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*PrefixUsage{
+		{Prefix: "logical/uuid1/", Keys: 3, Size: 10},
+		{Prefix: "logical/uuid2/", Keys: 1, Size: 8},
+	}
+	if diff := deep.Equal(b.StorageUsage(), expected); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	// The limit is soft: concurrent writes can go past it, but only by the
+	// writes in flight
+	const writers = 4
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				put(fmt.Sprintf("logical/uuid3/%d-%d", i, j), 2)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, usage := range b.StorageUsage() {
+		if usage.Prefix == "logical/uuid3/" && (usage.Size < 10 || usage.Size > 10+2*writers) {
+			t.Fatalf("unexpected usage after concurrent writes: %#v", usage)
+		}
+	}
+}
+
 func TestRaft_Backend_ListPrefix(t *testing.T) {
 	b, dir := getRaft(t, true, true)
 	defer os.RemoveAll(dir)
@@ -532,6 +685,32 @@ func BenchmarkDB_Puts(b *testing.B) {
 	b.Run("256kb", func(b *testing.B) { bench(b, raft2, 256*1024) })
 }
 
+func BenchmarkRaft_PutsWithPrefixLimit(b *testing.B) {
+	bench := func(b *testing.B, maxPrefixSize uint64) {
+		raft, dir := getRaft(b, true, false)
+		defer os.RemoveAll(dir)
+		raft.maxPrefixSize = maxPrefixSize
+
+		ctx := context.Background()
+		pe := &physical.Entry{
+			Value: make([]byte, 256),
+		}
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			// Overwrite a bounded set of keys, so the prefix size stays constant
+			pe.Key = fmt.Sprintf("logical/uuid/%d", i%100)
+			if err := raft.Put(ctx, pe); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.Run("no-limit", func(b *testing.B) { bench(b, 0) })
+	b.Run("below-limit", func(b *testing.B) { bench(b, 1024*1024) })
+	b.Run("near-limit", func(b *testing.B) { bench(b, 100*256) })
+}
+
 func BenchmarkDB_Snapshot(b *testing.B) {
 	raft, dir := getRaft(b, true, false)
 	defer os.RemoveAll(dir)
@@ -5,11 +5,28 @@ package raft
 import (
 	"context"
 	"errors"
+
+	"github.com/hashicorp/raft"
 )
 
-const nonVotersAllowed = false
+// nonVotersAllowed enables joining the cluster as a read replica, and as a
+// result setting non_voter in the peers.json recovery file.
+const nonVotersAllowed = true
 
-// AddPeer adds a new server to the raft cluster
+// AddNonVotingPeer adds a new server to the raft cluster as a non-voter.
+// Unlike the servers added by AddPeer while autopilot is running, these are
+// not promoted to voters as long as they are marked as non-voters in the
+// follower states given to autopilot.
 func (b *RaftBackend) AddNonVotingPeer(ctx context.Context, peerID, clusterAddr string) error {
-	return errors.New("not implemented")
+	b.l.RLock()
+	defer b.l.RUnlock()
+
+	if b.raft == nil {
+		return errors.New("raft storage is not initialized")
+	}
+
+	b.logger.Debug("adding raft peer as a non-voter", "node_id", peerID, "cluster_addr", clusterAddr)
+
+	future := b.raft.AddNonvoter(raft.ServerID(peerID), raft.ServerAddress(clusterAddr), 0, 0)
+	return future.Error()
 }
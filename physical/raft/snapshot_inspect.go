@@ -0,0 +1,123 @@
+package raft
+
+import (
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/raft"
+	snapshot "github.com/hashicorp/raft-snapshot"
+	"github.com/hashicorp/vault/sdk/plugin/pb"
+)
+
+// SnapshotInspection summarizes the content of a snapshot.
+type SnapshotInspection struct {
+	Index uint64 `json:"index"`
+	Term  uint64 `json:"term"`
+	Keys  int    `json:"keys"`
+	Size  int64  `json:"size"`
+
+	// Prefixes are sorted by prefix.
+	Prefixes []*PrefixUsage `json:"prefixes"`
+}
+
+// ReadSnapshot reads a snapshot archive, as written by Snapshot, and calls fn
+// for each storage entry in it. The values of the entries are still encrypted
+// by the barrier. The sealed hashes of the archive are not verified, so no
+// access to the seal is needed.
+func ReadSnapshot(in io.Reader, fn func(*pb.StorageEntry) error) (*raft.SnapshotMeta, error) {
+	stateReader, stateWriter := io.Pipe()
+
+	readErrCh := make(chan error, 1)
+	go func() {
+		err := ReadSnapshotState(stateReader, fn)
+		// Unblock the archive parsing if we stopped reading early
+		stateReader.CloseWithError(err)
+		readErrCh <- err
+	}()
+
+	metadata, err := snapshot.Parse(in, stateWriter)
+	stateWriter.CloseWithError(err)
+	if readErr := <-readErrCh; readErr != nil {
+		return nil, readErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return metadata, nil
+}
+
+// ReadSnapshotState reads the storage entries of the state of a snapshot, as
+// extracted from the archive by WriteSnapshotToTemp, and calls fn for each of
+// them.
+func ReadSnapshotState(state io.Reader, fn func(*pb.StorageEntry) error) error {
+	// The reader is not closed, so callers can read the state again
+	protoReader := NewDelimitedReader(state, math.MaxInt32)
+	for {
+		entry := new(pb.StorageEntry)
+		err := protoReader.ReadMsg(entry)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// InspectSnapshot reads a snapshot archive and reports the number of keys
+// and the size of the values under each top-level prefix.
+func InspectSnapshot(in io.Reader) (*SnapshotInspection, error) {
+	usage := make(map[string]*PrefixUsage)
+	inspection := &SnapshotInspection{}
+
+	metadata, err := ReadSnapshot(in, func(entry *pb.StorageEntry) error {
+		prefix := storagePrefix(entry.Key)
+		u, ok := usage[prefix]
+		if !ok {
+			u = &PrefixUsage{
+				Prefix: prefix,
+			}
+			usage[prefix] = u
+		}
+		u.Keys++
+		u.Size += int64(len(entry.Value))
+
+		inspection.Keys++
+		inspection.Size += int64(len(entry.Value))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	inspection.Index = metadata.Index
+	inspection.Term = metadata.Term
+	inspection.Prefixes = make([]*PrefixUsage, 0, len(usage))
+	for _, u := range usage {
+		inspection.Prefixes = append(inspection.Prefixes, u)
+	}
+	sort.Slice(inspection.Prefixes, func(i, j int) bool {
+		return inspection.Prefixes[i].Prefix < inspection.Prefixes[j].Prefix
+	})
+
+	return inspection, nil
+}
+
+// storagePrefix returns the top-level prefix a key is accounted under, made
+// of its first two path segments, such as "logical/<uuid>/" for the data of
+// a mount, "namespaces/<id>/" or "sys/token/". Keys with fewer segments, such
+// as "core/mounts", are their own prefix.
+func storagePrefix(key string) string {
+	parts := strings.SplitN(key, "/", 3)
+	if len(parts) < 3 {
+		return key
+	}
+	return parts[0] + "/" + parts[1] + "/"
+}
@@ -0,0 +1,76 @@
+package raft
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/physical"
+	"github.com/hashicorp/vault/sdk/plugin/pb"
+)
+
+func TestRaft_InspectSnapshot(t *testing.T) {
+	b, dir := getRaft(t, true, false)
+	defer os.RemoveAll(dir)
+
+	entries := map[string]string{
+		"core/mounts":           "abc",
+		"logical/uuid1/foo":     "abcd",
+		"logical/uuid1/bar/baz": "ab",
+		"logical/uuid2/foo":     "a",
+		"sys/token/id/h1":       "abcdef",
+	}
+	for key, value := range entries {
+		err := b.Put(context.Background(), &physical.Entry{
+			Key:   key,
+			Value: []byte(value),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var snap bytes.Buffer
+	if err := b.Snapshot(&snap, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	inspection, err := InspectSnapshot(bytes.NewReader(snap.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inspection.Index == 0 || inspection.Keys != 5 || inspection.Size != 16 {
+		t.Fatalf("bad inspection: %#v", inspection)
+	}
+
+	expected := []*PrefixUsage{
+		{Prefix: "core/mounts", Keys: 1, Size: 3},
+		{Prefix: "logical/uuid1/", Keys: 2, Size: 6},
+		{Prefix: "logical/uuid2/", Keys: 1, Size: 1},
+		{Prefix: "sys/token/", Keys: 1, Size: 6},
+	}
+	if !reflect.DeepEqual(inspection.Prefixes, expected) {
+		for _, u := range inspection.Prefixes {
+			t.Logf("%#v", u)
+		}
+		t.Fatal("bad prefixes")
+	}
+
+	// Reading stops at the first error
+	stopErr := fmt.Errorf("stop")
+	_, err = ReadSnapshot(bytes.NewReader(snap.Bytes()), func(*pb.StorageEntry) error {
+		return stopErr
+	})
+	if err != stopErr {
+		t.Fatalf("expected stop error, got %v", err)
+	}
+
+	// Corrupted archives are rejected
+	corrupted := snap.Bytes()[:snap.Len()/2]
+	if _, err := InspectSnapshot(bytes.NewReader(corrupted)); err == nil {
+		t.Fatal("expected error for a truncated snapshot")
+	}
+}
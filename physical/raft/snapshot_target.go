@@ -0,0 +1,252 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/errwrap"
+)
+
+// SnapshotTargetLocal is the storage type of snapshot targets writing to a
+// local directory.
+const SnapshotTargetLocal = "local"
+
+// SnapshotTarget is a destination that automated snapshots are written to,
+// such as a local directory or an object store bucket.
+type SnapshotTarget interface {
+	// Put stores the snapshot read from r under the given name, returning a
+	// URL identifying it.
+	Put(ctx context.Context, name string, r io.Reader) (string, error)
+
+	// List returns the snapshots stored with a name starting with the given
+	// prefix, ordered by name.
+	List(ctx context.Context, prefix string) ([]*SnapshotTargetEntry, error)
+
+	// Delete removes the named snapshot.
+	Delete(ctx context.Context, name string) error
+}
+
+// SnapshotTargetEntry describes a snapshot stored in a SnapshotTarget.
+type SnapshotTargetEntry struct {
+	Name string
+	Size int64
+}
+
+// SnapshotTargetFactory creates a SnapshotTarget writing under the given path
+// prefix, with settings specific to its storage type.
+type SnapshotTargetFactory func(pathPrefix string, conf map[string]string) (SnapshotTarget, error)
+
+var (
+	snapshotTargetsLock sync.RWMutex
+	snapshotTargets     = map[string]SnapshotTargetFactory{
+		SnapshotTargetLocal: NewLocalSnapshotTarget,
+	}
+)
+
+// RegisterSnapshotTarget makes a SnapshotTarget available for the given
+// storage type, so that object stores can be added as snapshot destinations.
+func RegisterSnapshotTarget(storageType string, factory SnapshotTargetFactory) {
+	snapshotTargetsLock.Lock()
+	defer snapshotTargetsLock.Unlock()
+	snapshotTargets[storageType] = factory
+}
+
+// NewSnapshotTarget returns a SnapshotTarget of the given storage type.
+func NewSnapshotTarget(storageType, pathPrefix string, conf map[string]string) (SnapshotTarget, error) {
+	snapshotTargetsLock.RLock()
+	factory, ok := snapshotTargets[storageType]
+	snapshotTargetsLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported snapshot storage type %q", storageType)
+	}
+	return factory(pathPrefix, conf)
+}
+
+// LocalSnapshotTarget writes snapshots to files in a local directory, using up
+// to a maximum amount of space.
+type LocalSnapshotTarget struct {
+	dir      string
+	maxSpace int64
+
+	// filePrefix restricts the files accounted for in the used space to the
+	// snapshots named <filePrefix>-*.snap, when set.
+	filePrefix string
+
+	// retain is the number of snapshots kept once a new one is written. The
+	// older snapshots are about to be deleted, so they don't count towards
+	// the used space. Zero counts all of the snapshots.
+	retain int
+}
+
+var _ SnapshotTarget = (*LocalSnapshotTarget)(nil)
+
+// NewLocalSnapshotTarget returns a LocalSnapshotTarget writing to the
+// directory given as path prefix. The local_max_space setting is required and
+// limits the total size of the snapshots in the directory. The optional
+// file_prefix and retain settings restrict the snapshots accounted for in that
+// space to the ones kept by the retention.
+func NewLocalSnapshotTarget(pathPrefix string, conf map[string]string) (SnapshotTarget, error) {
+	if pathPrefix == "" {
+		return nil, errors.New("path_prefix is required")
+	}
+
+	maxSpaceRaw, ok := conf["local_max_space"]
+	if !ok {
+		return nil, errors.New("local_max_space is required")
+	}
+	maxSpace, err := strconv.ParseInt(maxSpaceRaw, 10, 64)
+	if err != nil || maxSpace <= 0 {
+		return nil, errors.New("local_max_space must be a positive number of bytes")
+	}
+
+	var retain int
+	if retainRaw, ok := conf["retain"]; ok {
+		retain, err = strconv.Atoi(retainRaw)
+		if err != nil || retain < 0 {
+			return nil, errors.New("retain must be a non-negative number of snapshots")
+		}
+	}
+
+	return &LocalSnapshotTarget{
+		dir:        filepath.Clean(pathPrefix),
+		maxSpace:   maxSpace,
+		filePrefix: conf["file_prefix"],
+		retain:     retain,
+	}, nil
+}
+
+func (t *LocalSnapshotTarget) path(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid snapshot name %q", name)
+	}
+	return filepath.Join(t.dir, name), nil
+}
+
+// Put writes the snapshot to a temporary file first, so that a failed or
+// oversized snapshot never replaces a complete one.
+func (t *LocalSnapshotTarget) Put(ctx context.Context, name string, r io.Reader) (string, error) {
+	path, err := t.path(name)
+	if err != nil {
+		return "", err
+	}
+
+	if err := EnsurePath(t.dir, true); err != nil {
+		return "", errwrap.Wrapf("failed to create snapshot directory: {{err}}", err)
+	}
+
+	used, err := t.usedSpace()
+	if err != nil {
+		return "", err
+	}
+
+	f, err := ioutil.TempFile(t.dir, name+".tmp-")
+	if err != nil {
+		return "", errwrap.Wrapf("failed to create snapshot file: {{err}}", err)
+	}
+	tmpPath := f.Name()
+	defer os.Remove(tmpPath)
+
+	// Stop writing as soon as the snapshot is known not to fit
+	remaining := t.maxSpace - used
+	if remaining < 0 {
+		remaining = 0
+	}
+	size, err := io.Copy(f, io.LimitReader(r, remaining+1))
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", errwrap.Wrapf("failed to write snapshot file: {{err}}", err)
+	}
+	if size > remaining {
+		return "", fmt.Errorf("snapshot does not fit in the %d bytes of local_max_space, of which %d are used by existing snapshots", t.maxSpace, used)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return "", errwrap.Wrapf("failed to move snapshot file into place: {{err}}", err)
+	}
+
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String(), nil
+}
+
+func (t *LocalSnapshotTarget) List(ctx context.Context, prefix string) ([]*SnapshotTargetEntry, error) {
+	infos, err := ioutil.ReadDir(t.dir)
+	switch {
+	case os.IsNotExist(err):
+		return nil, nil
+	case err != nil:
+		return nil, errwrap.Wrapf("failed to list snapshot directory: {{err}}", err)
+	}
+
+	var entries []*SnapshotTargetEntry
+	for _, info := range infos {
+		if !info.Mode().IsRegular() || !strings.HasPrefix(info.Name(), prefix) || strings.Contains(info.Name(), ".tmp-") {
+			continue
+		}
+		entries = append(entries, &SnapshotTargetEntry{
+			Name: info.Name(),
+			Size: info.Size(),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	return entries, nil
+}
+
+func (t *LocalSnapshotTarget) Delete(ctx context.Context, name string) error {
+	path, err := t.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errwrap.Wrapf("failed to delete snapshot file: {{err}}", err)
+	}
+	return nil
+}
+
+// usedSpace returns the total size of the snapshots in the directory which
+// are kept once a new snapshot is written.
+func (t *LocalSnapshotTarget) usedSpace() (int64, error) {
+	prefix := ""
+	if t.filePrefix != "" {
+		prefix = t.filePrefix + "-"
+	}
+	entries, err := t.List(context.Background(), prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	if t.filePrefix != "" {
+		snapshots := entries[:0]
+		for _, entry := range entries {
+			if strings.HasSuffix(entry.Name, ".snap") {
+				snapshots = append(snapshots, entry)
+			}
+		}
+		entries = snapshots
+	}
+	if t.retain > 0 && len(entries) > t.retain-1 {
+		entries = entries[len(entries)-(t.retain-1):]
+	}
+
+	var used int64
+	for _, entry := range entries {
+		used += entry.Size
+	}
+	return used, nil
+}
@@ -0,0 +1,127 @@
+package raft
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalSnapshotTarget(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-raft-snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := NewSnapshotTarget(SnapshotTargetLocal, dir, nil); err == nil {
+		t.Fatal("expected error without local_max_space")
+	}
+	if _, err := NewSnapshotTarget("unknown", dir, nil); err == nil {
+		t.Fatal("expected error for an unknown storage type")
+	}
+
+	target, err := NewSnapshotTarget(SnapshotTargetLocal, filepath.Join(dir, "snapshots"), map[string]string{
+		"local_max_space": "10",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	url, err := target.Put(ctx, "snap-1", bytes.NewReader([]byte("abcd")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "file://"+filepath.ToSlash(filepath.Join(dir, "snapshots", "snap-1")) {
+		t.Fatalf("bad url: %s", url)
+	}
+	if _, err := target.Put(ctx, "snap-2", bytes.NewReader([]byte("efgh"))); err != nil {
+		t.Fatal(err)
+	}
+
+	// Snapshots which don't fit in the remaining space are not written
+	_, err = target.Put(ctx, "snap-3", bytes.NewReader([]byte("ijk")))
+	if err == nil || !strings.Contains(err.Error(), "local_max_space") {
+		t.Fatalf("expected space error, got %v", err)
+	}
+	if _, err := target.Put(ctx, "../snap", bytes.NewReader(nil)); err == nil {
+		t.Fatal("expected error for an invalid name")
+	}
+
+	entries, err := target.List(ctx, "snap-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Name != "snap-1" || entries[1].Name != "snap-2" || entries[0].Size != 4 {
+		t.Fatalf("bad entries: %#v", entries)
+	}
+
+	if err := target.Delete(ctx, "snap-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := target.Delete(ctx, "snap-1"); err != nil {
+		t.Fatalf("expected deleting a missing snapshot to succeed: %v", err)
+	}
+	if _, err := target.Put(ctx, "snap-3", bytes.NewReader([]byte("ijk"))); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err = target.List(ctx, "snap-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Name != "snap-2" || entries[1].Name != "snap-3" {
+		t.Fatalf("bad entries: %#v", entries)
+	}
+}
+
+func TestLocalSnapshotTarget_Retention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-raft-snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	target, err := NewSnapshotTarget(SnapshotTargetLocal, dir, map[string]string{
+		"local_max_space": "10",
+		"file_prefix":     "snap",
+		"retain":          "2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// Files other than the snapshots don't count towards the space
+	if err := ioutil.WriteFile(filepath.Join(dir, "unrelated"), make([]byte, 100), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "snap-notes"), make([]byte, 100), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once the space and the retention are both reached, the oldest snapshot
+	// is not accounted for since the retention deletes it after the write
+	for i := 1; i <= 4; i++ {
+		if _, err := target.Put(ctx, fmt.Sprintf("snap-%d.snap", i), bytes.NewReader(make([]byte, 5))); err != nil {
+			t.Fatalf("snapshot %d: %v", i, err)
+		}
+		if i > 2 {
+			if err := target.Delete(ctx, fmt.Sprintf("snap-%d.snap", i-2)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Snapshots larger than the space left by the kept ones are still
+	// rejected
+	_, err = target.Put(ctx, "snap-5.snap", bytes.NewReader(make([]byte, 6)))
+	if err == nil || !strings.Contains(err.Error(), "local_max_space") {
+		t.Fatalf("expected space error, got %v", err)
+	}
+}
@@ -828,6 +828,8 @@ func signCertificate(data *CreationBundle, randReader io.Reader) (*ParsedCertBun
 
 	AddExtKeyUsageOids(data, certTemplate)
 
+	certTemplate.ExtraExtensions = append(certTemplate.ExtraExtensions, data.Params.ExtraExtensions...)
+
 	var certBytes []byte
 
 	certTemplate.IssuingCertificateURL = data.Params.URLs.IssuingCertificates
@@ -678,6 +678,10 @@ type CreationParameters struct {
 	PolicyIdentifiers             []string
 	BasicConstraintsValidForNonCA bool
 
+	// Extensions added to the certificate as-is, such as those of a CSR
+	// allowed through by policy
+	ExtraExtensions []pkix.Extension
+
 	// Only used when signing a CA cert
 	UseCSRValues        bool
 	PermittedDNSDomains []string
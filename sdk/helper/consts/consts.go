@@ -16,6 +16,10 @@ const (
 	// SSRF protection.
 	RequestHeaderName = "X-Vault-Request"
 
+	// StreamErrorTrailerName is the name of the trailer set when a streamed
+	// response fails after part of it has been written.
+	StreamErrorTrailerName = "X-Vault-Stream-Error"
+
 	// PerformanceReplicationALPN is the negotiated protocol used for
 	// performance replication.
 	PerformanceReplicationALPN = "replication_v1"
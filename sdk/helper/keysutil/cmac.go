@@ -0,0 +1,73 @@
+package keysutil
+
+import (
+	"crypto/aes"
+)
+
+// cmacRb is the constant used when generating CMAC subkeys for a 128-bit
+// block cipher
+const cmacRb = 0x87
+
+// AESCMAC computes the AES-CMAC (RFC 4493) of the message using the given
+// 128- or 256-bit AES key
+func AESCMAC(key, message []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	bs := block.BlockSize()
+
+	// Generate the subkeys K1 and K2
+	k1 := make([]byte, bs)
+	block.Encrypt(k1, k1)
+	cmacDouble(k1)
+	k2 := make([]byte, bs)
+	copy(k2, k1)
+	cmacDouble(k2)
+
+	n := (len(message) + bs - 1) / bs
+	complete := n > 0 && len(message)%bs == 0
+	if n == 0 {
+		n = 1
+	}
+
+	last := make([]byte, bs)
+	if complete {
+		copy(last, message[(n-1)*bs:])
+		cmacXOR(last, k1)
+	} else {
+		rem := message[(n-1)*bs:]
+		copy(last, rem)
+		last[len(rem)] = 0x80
+		cmacXOR(last, k2)
+	}
+
+	x := make([]byte, bs)
+	for i := 0; i < n-1; i++ {
+		cmacXOR(x, message[i*bs:(i+1)*bs])
+		block.Encrypt(x, x)
+	}
+	cmacXOR(x, last)
+	block.Encrypt(x, x)
+
+	return x, nil
+}
+
+// cmacDouble multiplies the block by x in GF(2^128), in place
+func cmacDouble(b []byte) {
+	msb := b[0] >> 7
+	for i := 0; i < len(b)-1; i++ {
+		b[i] = b[i]<<1 | b[i+1]>>7
+	}
+	b[len(b)-1] <<= 1
+	if msb == 1 {
+		b[len(b)-1] ^= cmacRb
+	}
+}
+
+// cmacXOR sets dst to dst XOR src
+func cmacXOR(dst, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
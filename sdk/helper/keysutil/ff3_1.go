@@ -0,0 +1,251 @@
+package keysutil
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	// FF31TweakSize is the size in bytes of an FF3-1 tweak (56 bits)
+	FF31TweakSize = 7
+
+	// ff3NumRounds is the number of Feistel rounds of FF3 and FF3-1
+	ff3NumRounds = 8
+
+	// ff3MinDomain is the minimum number of possible values a numeral string
+	// must be able to take, per NIST SP 800-38G Rev. 1
+	ff3MinDomain = 1000000
+)
+
+// DefaultFPEAlphabet is the alphabet used by format-preserving encryption
+// keys when none is given
+const DefaultFPEAlphabet = "numeric"
+
+// FPEAlphabets holds the named alphabets available to format-preserving
+// encryption keys
+var FPEAlphabets = map[string]string{
+	"numeric":           "0123456789",
+	"alphalower":        "abcdefghijklmnopqrstuvwxyz",
+	"alphaupper":        "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"alphanumericlower": "0123456789abcdefghijklmnopqrstuvwxyz",
+	"alphanumericupper": "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"alphanumeric":      "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+}
+
+// ResolveFPEAlphabet returns the characters of the given alphabet, which is
+// either the name of one of FPEAlphabets or the characters themselves
+func ResolveFPEAlphabet(alphabet string) ([]rune, error) {
+	if alphabet == "" {
+		alphabet = DefaultFPEAlphabet
+	}
+	if named, ok := FPEAlphabets[alphabet]; ok {
+		alphabet = named
+	}
+
+	chars := []rune(alphabet)
+	if len(chars) < 2 || len(chars) > 1<<16 {
+		return nil, fmt.Errorf("alphabet must contain between 2 and %d characters", 1<<16)
+	}
+	seen := make(map[rune]bool, len(chars))
+	for _, c := range chars {
+		if seen[c] {
+			return nil, fmt.Errorf("alphabet contains duplicate character %q", c)
+		}
+		seen[c] = true
+	}
+	return chars, nil
+}
+
+// FF31 implements the FF3-1 format-preserving encryption mode of NIST SP
+// 800-38G Rev. 1 over an arbitrary alphabet
+type FF31 struct {
+	block   cipher.Block
+	radix   *big.Int
+	charset []rune
+	index   map[rune]int
+	minLen  int
+	maxLen  int
+}
+
+// NewFF31 returns an FF3-1 cipher using the given AES key and alphabet
+func NewFF31(key []byte, alphabet []rune) (*FF31, error) {
+	// The key is used with its bytes reversed
+	revKey := make([]byte, len(key))
+	for i := range key {
+		revKey[i] = key[len(key)-1-i]
+	}
+	block, err := aes.NewCipher(revKey)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &FF31{
+		block:   block,
+		radix:   big.NewInt(int64(len(alphabet))),
+		charset: alphabet,
+		index:   make(map[rune]int, len(alphabet)),
+	}
+	for i, r := range alphabet {
+		c.index[r] = i
+	}
+
+	// radix^minLen must be at least a million
+	domain := big.NewInt(1)
+	for domain.Cmp(big.NewInt(ff3MinDomain)) < 0 {
+		domain.Mul(domain, c.radix)
+		c.minLen++
+	}
+	if c.minLen < 2 {
+		c.minLen = 2
+	}
+
+	// maxLen is 2 * floor(log_radix(2^96))
+	limit := new(big.Int).Lsh(big.NewInt(1), 96)
+	half := 0
+	domain.SetInt64(1)
+	for {
+		domain.Mul(domain, c.radix)
+		if domain.Cmp(limit) > 0 {
+			break
+		}
+		half++
+	}
+	c.maxLen = 2 * half
+
+	return c, nil
+}
+
+// Encrypt encrypts the given value, which must only contain characters of
+// the alphabet
+func (c *FF31) Encrypt(tweak []byte, value string) (string, error) {
+	return c.cipher(tweak, value, true)
+}
+
+// Decrypt reverses Encrypt
+func (c *FF31) Decrypt(tweak []byte, value string) (string, error) {
+	return c.cipher(tweak, value, false)
+}
+
+func (c *FF31) cipher(tweak []byte, value string, encrypt bool) (string, error) {
+	if len(tweak) != FF31TweakSize {
+		return "", fmt.Errorf("tweak must be %d bytes", FF31TweakSize)
+	}
+
+	// Split the 56-bit tweak into the two 32-bit halves used by the rounds
+	tl := []byte{tweak[0], tweak[1], tweak[2], tweak[3] & 0xf0}
+	tr := []byte{tweak[4], tweak[5], tweak[6], tweak[3] << 4}
+
+	return c.feistel(tl, tr, value, encrypt)
+}
+
+func (c *FF31) feistel(tl, tr []byte, value string, encrypt bool) (string, error) {
+	x := []rune(value)
+	n := len(x)
+	if n < c.minLen || n > c.maxLen {
+		return "", fmt.Errorf("value must be between %d and %d characters long", c.minLen, c.maxLen)
+	}
+	numerals := make([]int, n)
+	for i, r := range x {
+		idx, ok := c.index[r]
+		if !ok {
+			return "", fmt.Errorf("value contains character %q which is not in the alphabet", r)
+		}
+		numerals[i] = idx
+	}
+
+	u := (n + 1) / 2
+	v := n - u
+	a, b := numerals[:u], numerals[u:]
+
+	modU := new(big.Int).Exp(c.radix, big.NewInt(int64(u)), nil)
+	modV := new(big.Int).Exp(c.radix, big.NewInt(int64(v)), nil)
+
+	p := make([]byte, aes.BlockSize)
+	s := make([]byte, aes.BlockSize)
+	for r := 0; r < ff3NumRounds; r++ {
+		i := r
+		if !encrypt {
+			i = ff3NumRounds - 1 - r
+		}
+
+		m, mod, w := u, modU, tr
+		if i%2 == 1 {
+			m, mod, w = v, modV, tl
+		}
+
+		// P = (W xor [i]^4) || [NUM_radix(REV(B))]^12, where B is the half
+		// being carried forward
+		carried := b
+		if !encrypt {
+			carried = a
+		}
+		copy(p, w)
+		p[3] ^= byte(i)
+		for j := 4; j < aes.BlockSize; j++ {
+			p[j] = 0
+		}
+		numBytes := c.num(carried).Bytes()
+		if len(numBytes) > aes.BlockSize-4 {
+			return "", errors.New("numeral string too long for the block size")
+		}
+		copy(p[aes.BlockSize-len(numBytes):], numBytes)
+
+		// S = REVB(CIPH_REVB(K)(REVB(P)))
+		reverseBytes(p)
+		c.block.Encrypt(s, p)
+		reverseBytes(s)
+		y := new(big.Int).SetBytes(s)
+
+		if encrypt {
+			// c = (NUM_radix(REV(A)) + y) mod radix^m
+			y.Add(y, c.num(a))
+			y.Mod(y, mod)
+			a, b = b, c.str(y, m)
+		} else {
+			// c = (NUM_radix(REV(B)) - y) mod radix^m
+			y.Sub(c.num(b), y)
+			y.Mod(y, mod)
+			a, b = c.str(y, m), a
+		}
+	}
+
+	var out strings.Builder
+	for _, idx := range append(append([]int{}, a...), b...) {
+		out.WriteRune(c.charset[idx])
+	}
+	return out.String(), nil
+}
+
+// num returns NUM_radix(REV(x)): the numerals interpreted with the least
+// significant numeral first
+func (c *FF31) num(x []int) *big.Int {
+	result := new(big.Int)
+	for i := len(x) - 1; i >= 0; i-- {
+		result.Mul(result, c.radix)
+		result.Add(result, big.NewInt(int64(x[i])))
+	}
+	return result
+}
+
+// str returns REV(STR^m_radix(y)): the m numerals of y, least significant
+// first
+func (c *FF31) str(y *big.Int, m int) []int {
+	out := make([]int, m)
+	rem := new(big.Int)
+	y = new(big.Int).Set(y)
+	for i := 0; i < m; i++ {
+		y.DivMod(y, c.radix, rem)
+		out[i] = int(rem.Int64())
+	}
+	return out
+}
+
+func reverseBytes(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
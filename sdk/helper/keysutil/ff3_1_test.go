@@ -0,0 +1,124 @@
+package keysutil
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestFF31_FF3Vectors(t *testing.T) {
+	// FF3-1 only differs from FF3 in how the tweak is split, so the rounds
+	// are checked against the FF3 samples published by NIST
+	alphabet, err := ResolveFPEAlphabet("numeric")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		key        string
+		tweak      string
+		plaintext  string
+		ciphertext string
+	}{
+		{"ef4359d8d580aa4f7f036d6f04fc6a94", "d8e7920afa330a73", "890121234567890000", "750918814058654607"},
+		{"ef4359d8d580aa4f7f036d6f04fc6a94", "9a768a92f60e12d8", "890121234567890000", "018989839189395384"},
+		{"ef4359d8d580aa4f7f036d6f04fc6a94", "0000000000000000", "89012123456789000000789000000", "34695224821734535122613701434"},
+	}
+
+	for _, c := range cases {
+		key, _ := hex.DecodeString(c.key)
+		tweak, _ := hex.DecodeString(c.tweak)
+		ff, err := NewFF31(key, alphabet)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ciphertext, err := ff.feistel(tweak[:4], tweak[4:], c.plaintext, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ciphertext != c.ciphertext {
+			t.Fatalf("bad ciphertext: expected %s, got %s", c.ciphertext, ciphertext)
+		}
+
+		plaintext, err := ff.feistel(tweak[:4], tweak[4:], ciphertext, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plaintext != c.plaintext {
+			t.Fatalf("bad plaintext: expected %s, got %s", c.plaintext, plaintext)
+		}
+	}
+}
+
+func TestFF31(t *testing.T) {
+	key, _ := hex.DecodeString("2b7e151628aed2a6abf7158809cf4f3cef4359d8d580aa4f7f036d6f04fc6a94")
+	tweak, _ := hex.DecodeString("d8e7920afa330a")
+
+	for _, c := range []struct {
+		alphabet string
+		value    string
+	}{
+		{"numeric", "4111111111111111"},
+		{"alphanumeric", "Hello1World2"},
+		{"αβγδεζηθικλμ", "αβγδεζηθ"},
+	} {
+		alphabet, err := ResolveFPEAlphabet(c.alphabet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ff, err := NewFF31(key, alphabet)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		encrypted, err := ff.Encrypt(tweak, c.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if encrypted == c.value || len([]rune(encrypted)) != len([]rune(c.value)) {
+			t.Fatalf("bad encrypted value %q for %q", encrypted, c.value)
+		}
+		for _, r := range encrypted {
+			if _, ok := ff.index[r]; !ok {
+				t.Fatalf("encrypted value %q has characters outside of the alphabet", encrypted)
+			}
+		}
+
+		decrypted, err := ff.Decrypt(tweak, encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decrypted != c.value {
+			t.Fatalf("bad decrypted value: expected %q, got %q", c.value, decrypted)
+		}
+
+		// A different tweak gives a different value
+		otherTweak := append([]byte{}, tweak...)
+		otherTweak[6] ^= 1
+		other, err := ff.Encrypt(otherTweak, c.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if other == encrypted {
+			t.Fatalf("expected tweak to change the encrypted value")
+		}
+	}
+
+	alphabet, _ := ResolveFPEAlphabet("numeric")
+	ff, err := NewFF31(key, alphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ff.Encrypt(tweak, "12345"); err == nil {
+		t.Fatal("expected error for a value below the minimum length")
+	}
+	if _, err := ff.Encrypt(tweak, "12345a"); err == nil {
+		t.Fatal("expected error for a value outside of the alphabet")
+	}
+	if _, err := ff.Encrypt(tweak[:6], "123456"); err == nil {
+		t.Fatal("expected error for a short tweak")
+	}
+	if _, err := ResolveFPEAlphabet("aab"); err == nil {
+		t.Fatal("expected error for an alphabet with duplicate characters")
+	}
+}
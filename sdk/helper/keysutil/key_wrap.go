@@ -0,0 +1,107 @@
+package keysutil
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"errors"
+)
+
+// kwpIV is the alternative initial value of RFC 5649, which is followed by
+// the 32-bit length of the wrapped key
+var kwpIV = []byte{0xA6, 0x59, 0x59, 0xA6}
+
+// WrapKeyKWP wraps key material with the given AES key encryption key using
+// AES Key Wrap with Padding (RFC 5649)
+func WrapKeyKWP(kek, key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, errors.New("key to wrap must not be empty")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := (len(key) + 7) / 8
+	aiv := make([]byte, 8)
+	copy(aiv, kwpIV)
+	binary.BigEndian.PutUint32(aiv[4:], uint32(len(key)))
+
+	padded := make([]byte, n*8)
+	copy(padded, key)
+
+	if n == 1 {
+		out := make([]byte, 16)
+		block.Encrypt(out, append(aiv, padded...))
+		return out, nil
+	}
+
+	a := aiv
+	buf := make([]byte, 16)
+	for j := 0; j < 6; j++ {
+		for i := 0; i < n; i++ {
+			copy(buf, a)
+			copy(buf[8:], padded[i*8:(i+1)*8])
+			block.Encrypt(buf, buf)
+
+			t := uint64(n*j + i + 1)
+			binary.BigEndian.PutUint64(a, binary.BigEndian.Uint64(buf[:8])^t)
+			copy(padded[i*8:], buf[8:])
+		}
+	}
+
+	return append(a, padded...), nil
+}
+
+// UnwrapKeyKWP reverses WrapKeyKWP, verifying the integrity of the wrapped
+// key material
+func UnwrapKeyKWP(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 16 || len(wrapped)%8 != 0 {
+		return nil, errors.New("invalid length of wrapped key")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(wrapped)/8 - 1
+	a := make([]byte, 8)
+	plain := make([]byte, n*8)
+
+	if n == 1 {
+		buf := make([]byte, 16)
+		block.Decrypt(buf, wrapped)
+		copy(a, buf[:8])
+		copy(plain, buf[8:])
+	} else {
+		copy(a, wrapped[:8])
+		copy(plain, wrapped[8:])
+		buf := make([]byte, 16)
+		for j := 5; j >= 0; j-- {
+			for i := n - 1; i >= 0; i-- {
+				t := uint64(n*j + i + 1)
+				binary.BigEndian.PutUint64(buf, binary.BigEndian.Uint64(a)^t)
+				copy(buf[8:], plain[i*8:(i+1)*8])
+				block.Decrypt(buf, buf)
+
+				copy(a, buf[:8])
+				copy(plain[i*8:], buf[8:])
+			}
+		}
+	}
+
+	if !bytes.Equal(a[:4], kwpIV) {
+		return nil, errors.New("failed to unwrap key: integrity check failed")
+	}
+	length := int(binary.BigEndian.Uint32(a[4:]))
+	if length <= 8*(n-1) || length > 8*n {
+		return nil, errors.New("failed to unwrap key: integrity check failed")
+	}
+	for _, b := range plain[length:] {
+		if b != 0 {
+			return nil, errors.New("failed to unwrap key: integrity check failed")
+		}
+	}
+
+	return plain[:length], nil
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
 	"github.com/hashicorp/vault/sdk/helper/jsonutil"
 	"github.com/hashicorp/vault/sdk/helper/locksutil"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -52,12 +53,27 @@ type PolicyRequest struct {
 
 	// Whether to allow plaintext backup
 	AllowPlaintextBackup bool
+
+	// Whether to allow rotation of an imported key within Vault
+	AllowImportedKeyRotation bool
+
+	// The size in bytes of the key, for key types that allow choosing it
+	KeySize int
+
+	// The alphabet of format-preserving encryption keys
+	FPEAlphabet string
 }
 
+// ContextObserver is notified of the contexts keys of the named policy are
+// derived with
+type ContextObserver func(name string, context []byte)
+
 type LockManager struct {
 	useCache bool
 	cache    Cache
 	keyLocks []*locksutil.LockEntry
+
+	contextObserver ContextObserver
 }
 
 func NewLockManager(useCache bool, cacheSize int) (*LockManager, error) {
@@ -86,6 +102,13 @@ func NewLockManager(useCache bool, cacheSize int) (*LockManager, error) {
 	return lm, nil
 }
 
+// SetContextObserver sets the function notified of the contexts keys of
+// policies tracking them are derived with. It must be called before any
+// policy is loaded.
+func (lm *LockManager) SetContextObserver(observer ContextObserver) {
+	lm.contextObserver = observer
+}
+
 func (lm *LockManager) GetCacheSize() int {
 	if !lm.useCache {
 		return 0
@@ -197,6 +220,7 @@ func (lm *LockManager) RestorePolicy(ctx context.Context, storage logical.Storag
 	}
 
 	keyData.Policy.l = new(sync.RWMutex)
+	keyData.Policy.contextObserver = lm.contextObserver
 
 	// Update the cache to contain the restored policy
 	if lm.useCache {
@@ -327,56 +351,10 @@ func (lm *LockManager) GetPolicy(ctx context.Context, req PolicyRequest, rand io
 		// to the user to let them know that their request can't be satisfied
 		// because we don't know if the parameters match.
 
-		switch req.KeyType {
-		case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
-			if req.Convergent && !req.Derived {
-				cleanup()
-				return nil, false, fmt.Errorf("convergent encryption requires derivation to be enabled")
-			}
-
-		case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521:
-			if req.Derived || req.Convergent {
-				cleanup()
-				return nil, false, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
-			}
-
-		case KeyType_ED25519:
-			if req.Convergent {
-				cleanup()
-				return nil, false, fmt.Errorf("convergent encryption not supported for keys of type %v", req.KeyType)
-			}
-
-		case KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
-			if req.Derived || req.Convergent {
-				cleanup()
-				return nil, false, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
-			}
-
-		default:
+		p, err = newPolicyFromRequest(req)
+		if err != nil {
 			cleanup()
-			return nil, false, fmt.Errorf("unsupported key type %v", req.KeyType)
-		}
-
-		p = &Policy{
-			l:                    new(sync.RWMutex),
-			Name:                 req.Name,
-			Type:                 req.KeyType,
-			Derived:              req.Derived,
-			Exportable:           req.Exportable,
-			AllowPlaintextBackup: req.AllowPlaintextBackup,
-		}
-
-		if req.Derived {
-			p.KDF = Kdf_hkdf_sha256
-			if req.Convergent {
-				p.ConvergentEncryption = true
-				// As of version 3 we store the version within each key, so we
-				// set to -1 to indicate that the value in the policy has no
-				// meaning. We still, for backwards compatibility, fall back to
-				// this value if the key doesn't have one, which means it will
-				// only be -1 in the case where every key version is >= 3
-				p.ConvergentVersion = -1
-			}
+			return nil, false, err
 		}
 
 		// Performs the actual persist and does setup
@@ -386,6 +364,7 @@ func (lm *LockManager) GetPolicy(ctx context.Context, req PolicyRequest, rand io
 			return nil, false, err
 		}
 
+		p.contextObserver = lm.contextObserver
 		if lm.useCache {
 			lm.cache.Store(req.Name, p)
 		} else {
@@ -407,6 +386,7 @@ func (lm *LockManager) GetPolicy(ctx context.Context, req PolicyRequest, rand io
 		}
 	}
 
+	p.contextObserver = lm.contextObserver
 	if lm.useCache {
 		lm.cache.Store(req.Name, p)
 	} else {
@@ -419,6 +399,139 @@ func (lm *LockManager) GetPolicy(ctx context.Context, req PolicyRequest, rand io
 	return
 }
 
+// ImportPolicy creates a new policy from the given key material, in the
+// format expected by Policy.Import. It fails if the policy already exists.
+func (lm *LockManager) ImportPolicy(ctx context.Context, req PolicyRequest, key []byte, rand io.Reader) error {
+	lock := locksutil.LockForKey(lm.keyLocks, req.Name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if lm.useCache {
+		if _, ok := lm.cache.Load(req.Name); ok {
+			return errutil.UserError{Err: fmt.Sprintf("key %q already exists", req.Name)}
+		}
+	}
+
+	existing, err := lm.getPolicyFromStorage(ctx, req.Storage, req.Name)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return errutil.UserError{Err: fmt.Sprintf("key %q already exists", req.Name)}
+	}
+
+	p, err := newPolicyFromRequest(req)
+	if err != nil {
+		return errutil.UserError{Err: err.Error()}
+	}
+	p.Imported = true
+	p.AllowImportedKeyRotation = req.AllowImportedKeyRotation
+
+	if err := p.Import(ctx, req.Storage, key, rand); err != nil {
+		return err
+	}
+
+	p.contextObserver = lm.contextObserver
+	if lm.useCache {
+		lm.cache.Store(req.Name, p)
+	}
+	return nil
+}
+
+// newPolicyFromRequest validates the parameters of a new policy and returns
+// it without any key versions
+func newPolicyFromRequest(req PolicyRequest) (*Policy, error) {
+	switch req.KeyType {
+	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
+		if req.Convergent && !req.Derived {
+			return nil, fmt.Errorf("convergent encryption requires derivation to be enabled")
+		}
+
+	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521:
+		if req.Derived || req.Convergent {
+			return nil, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
+		}
+
+	case KeyType_ED25519:
+		if req.Convergent {
+			return nil, fmt.Errorf("convergent encryption not supported for keys of type %v", req.KeyType)
+		}
+
+	case KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
+		if req.Derived || req.Convergent {
+			return nil, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
+		}
+
+	case KeyType_HMAC, KeyType_AES128_CMAC, KeyType_AES256_CMAC, KeyType_FF3_1:
+		if req.Derived || req.Convergent {
+			return nil, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
+		}
+
+	case KeyType_ML_DSA_44, KeyType_ML_DSA_65, KeyType_ML_DSA_87, KeyType_ML_DSA_65_ED25519:
+		if !MLDSAAvailable {
+			return nil, errMLDSAUnsupported
+		}
+		if req.Derived || req.Convergent {
+			return nil, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %v", req.KeyType)
+	}
+
+	keySize := 0
+	if req.KeyType == KeyType_HMAC {
+		keySize = req.KeySize
+		if keySize == 0 {
+			keySize = DefaultHMACKeySize
+		}
+		if keySize < MinHMACKeySize || keySize > MaxHMACKeySize {
+			return nil, fmt.Errorf("invalid key size for key type %v: must be between %d and %d bytes", req.KeyType, MinHMACKeySize, MaxHMACKeySize)
+		}
+	} else if req.KeySize != 0 {
+		return nil, fmt.Errorf("key size is only supported for keys of type %v", KeyType_HMAC)
+	}
+
+	fpeAlphabet := ""
+	if req.KeyType.FPESupported() {
+		fpeAlphabet = req.FPEAlphabet
+		if fpeAlphabet == "" {
+			fpeAlphabet = DefaultFPEAlphabet
+		}
+		if _, err := ResolveFPEAlphabet(fpeAlphabet); err != nil {
+			return nil, err
+		}
+	} else if req.FPEAlphabet != "" {
+		return nil, fmt.Errorf("alphabet is only supported for keys of type %v", KeyType_FF3_1)
+	}
+
+	p := &Policy{
+		l:                    new(sync.RWMutex),
+		Name:                 req.Name,
+		Type:                 req.KeyType,
+		KeySize:              keySize,
+		FPEAlphabet:          fpeAlphabet,
+		Derived:              req.Derived,
+		Exportable:           req.Exportable,
+		AllowPlaintextBackup: req.AllowPlaintextBackup,
+	}
+
+	if req.Derived {
+		p.KDF = Kdf_hkdf_sha256
+		if req.Convergent {
+			p.ConvergentEncryption = true
+			// As of version 3 we store the version within each key, so we
+			// set to -1 to indicate that the value in the policy has no
+			// meaning. We still, for backwards compatibility, fall back to
+			// this value if the key doesn't have one, which means it will
+			// only be -1 in the case where every key version is >= 3
+			p.ConvergentVersion = -1
+		}
+	}
+
+	return p, nil
+}
+
 func (lm *LockManager) DeletePolicy(ctx context.Context, storage logical.Storage, name string) error {
 	var p *Policy
 	var err error
@@ -0,0 +1,94 @@
+//go:build go1.26
+// +build go1.26
+
+package keysutil
+
+import (
+	"crypto/mldsa"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/hashicorp/errwrap"
+)
+
+// MLDSAAvailable reports whether ML-DSA keys can be used with this build,
+// which requires Go 1.26 or later
+const MLDSAAvailable = true
+
+func mldsaParameters(kt KeyType) (mldsa.Parameters, error) {
+	switch kt {
+	case KeyType_ML_DSA_44:
+		return mldsa.MLDSA44(), nil
+	case KeyType_ML_DSA_65, KeyType_ML_DSA_65_ED25519:
+		return mldsa.MLDSA65(), nil
+	case KeyType_ML_DSA_87:
+		return mldsa.MLDSA87(), nil
+	}
+	return mldsa.Parameters{}, fmt.Errorf("key type %v has no ML-DSA component", kt)
+}
+
+func mldsaPrivateKey(kt KeyType, seed []byte) (*mldsa.PrivateKey, error) {
+	params, err := mldsaParameters(kt)
+	if err != nil {
+		return nil, err
+	}
+	return mldsa.NewPrivateKey(params, seed)
+}
+
+// mldsaPublicKeyPEM returns the PEM-encoded SubjectPublicKeyInfo of the
+// ML-DSA key with the given seed
+func mldsaPublicKeyPEM(kt KeyType, seed []byte) (string, error) {
+	key, err := mldsaPrivateKey(kt, seed)
+	if err != nil {
+		return "", err
+	}
+	derBytes, err := x509.MarshalPKIXPublicKey(key.PublicKey())
+	if err != nil {
+		return "", errwrap.Wrapf("error marshaling public key: {{err}}", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: derBytes,
+	})), nil
+}
+
+// mldsaSignatureSize returns the size of the ML-DSA signatures of the key type
+func mldsaSignatureSize(kt KeyType) int {
+	params, err := mldsaParameters(kt)
+	if err != nil {
+		return 0
+	}
+	return params.SignatureSize()
+}
+
+func mldsaSign(kt KeyType, seed, message []byte, context string) ([]byte, error) {
+	key, err := mldsaPrivateKey(kt, seed)
+	if err != nil {
+		return nil, err
+	}
+	return key.Sign(rand.Reader, message, &mldsa.Options{Context: context})
+}
+
+func mldsaVerify(kt KeyType, seed, message, sig []byte, context string) (bool, error) {
+	key, err := mldsaPrivateKey(kt, seed)
+	if err != nil {
+		return false, err
+	}
+	return mldsa.Verify(key.PublicKey(), message, sig, &mldsa.Options{Context: context}) == nil, nil
+}
+
+// mldsaSeedFromPrivateKey returns the seed of a parsed PKCS#8 ML-DSA private
+// key, checking that it matches the key type
+func mldsaSeedFromPrivateKey(kt KeyType, parsed interface{}) ([]byte, error) {
+	params, err := mldsaParameters(kt)
+	if err != nil {
+		return nil, err
+	}
+	privKey, ok := parsed.(*mldsa.PrivateKey)
+	if !ok || privKey.PublicKey().Parameters() != params {
+		return nil, fmt.Errorf("private key is not a %v key", kt)
+	}
+	return privKey.Bytes(), nil
+}
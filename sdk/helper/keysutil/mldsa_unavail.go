@@ -0,0 +1,30 @@
+//go:build !go1.26
+// +build !go1.26
+
+package keysutil
+
+// MLDSAAvailable reports whether ML-DSA keys can be used with this build,
+// which requires Go 1.26 or later. The go directive of the SDK module is
+// older, so builds made with older toolchains get these stubs, which fail
+// with errMLDSAUnsupported whenever an ML-DSA key is created or used.
+const MLDSAAvailable = false
+
+func mldsaPublicKeyPEM(kt KeyType, seed []byte) (string, error) {
+	return "", errMLDSAUnsupported
+}
+
+func mldsaSignatureSize(kt KeyType) int {
+	return 0
+}
+
+func mldsaSign(kt KeyType, seed, message []byte, context string) ([]byte, error) {
+	return nil, errMLDSAUnsupported
+}
+
+func mldsaVerify(kt KeyType, seed, message, sig []byte, context string) (bool, error) {
+	return false, errMLDSAUnsupported
+}
+
+func mldsaSeedFromPrivateKey(kt KeyType, parsed interface{}) ([]byte, error) {
+	return nil, errMLDSAUnsupported
+}
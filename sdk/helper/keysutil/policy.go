@@ -22,6 +22,7 @@ import (
 	"io"
 	"math/big"
 	"path"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -37,6 +38,7 @@ import (
 	"github.com/hashicorp/vault/sdk/helper/errutil"
 	"github.com/hashicorp/vault/sdk/helper/jsonutil"
 	"github.com/hashicorp/vault/sdk/helper/kdf"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -59,8 +61,50 @@ const (
 	KeyType_ECDSA_P521
 	KeyType_AES128_GCM96
 	KeyType_RSA3072
+	KeyType_HMAC
+	KeyType_AES128_CMAC
+	KeyType_AES256_CMAC
+	KeyType_FF3_1
+	KeyType_ML_DSA_44
+	KeyType_ML_DSA_65
+	KeyType_ML_DSA_87
+	KeyType_ML_DSA_65_ED25519
 )
 
+const (
+	// DefaultHMACKeySize is the size in bytes of a standalone HMAC key when
+	// no size is requested
+	DefaultHMACKeySize = 32
+
+	// MinHMACKeySize and MaxHMACKeySize bound the size in bytes of a
+	// standalone HMAC key
+	MinHMACKeySize = 32
+	MaxHMACKeySize = 512
+)
+
+// Operations to which the use of a key can be restricted
+const (
+	KeyOperationEncrypt = "encrypt"
+	KeyOperationDecrypt = "decrypt"
+	KeyOperationSign    = "sign"
+	KeyOperationVerify  = "verify"
+	KeyOperationHMAC    = "hmac"
+	KeyOperationRewrap  = "rewrap"
+	KeyOperationDatakey = "datakey"
+)
+
+// KeyOperations lists the operations to which the use of a key can be
+// restricted
+var KeyOperations = []string{
+	KeyOperationEncrypt,
+	KeyOperationDecrypt,
+	KeyOperationSign,
+	KeyOperationVerify,
+	KeyOperationHMAC,
+	KeyOperationRewrap,
+	KeyOperationDatakey,
+}
+
 const (
 	// ErrTooOld is returned whtn the ciphertext or signatures's key version is
 	// too old.
@@ -111,6 +155,8 @@ func (kt KeyType) SigningSupported() bool {
 	switch kt {
 	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521, KeyType_ED25519, KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
 		return true
+	case KeyType_ML_DSA_44, KeyType_ML_DSA_65, KeyType_ML_DSA_87, KeyType_ML_DSA_65_ED25519:
+		return true
 	}
 	return false
 }
@@ -123,6 +169,38 @@ func (kt KeyType) HashSignatureInput() bool {
 	return false
 }
 
+func (kt KeyType) CMACSupported() bool {
+	switch kt {
+	case KeyType_AES128_CMAC, KeyType_AES256_CMAC:
+		return true
+	}
+	return false
+}
+
+func (kt KeyType) FPESupported() bool {
+	switch kt {
+	case KeyType_FF3_1:
+		return true
+	}
+	return false
+}
+
+// PostQuantum returns whether the key type signs with ML-DSA, either alone or
+// as part of a hybrid signature
+func (kt KeyType) PostQuantum() bool {
+	switch kt {
+	case KeyType_ML_DSA_44, KeyType_ML_DSA_65, KeyType_ML_DSA_87, KeyType_ML_DSA_65_ED25519:
+		return true
+	}
+	return false
+}
+
+// Hybrid returns whether the key type produces composite signatures made of
+// a classical and a post-quantum signature
+func (kt KeyType) Hybrid() bool {
+	return kt == KeyType_ML_DSA_65_ED25519
+}
+
 func (kt KeyType) DerivationSupported() bool {
 	switch kt {
 	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_ED25519:
@@ -153,6 +231,22 @@ func (kt KeyType) String() string {
 		return "rsa-3072"
 	case KeyType_RSA4096:
 		return "rsa-4096"
+	case KeyType_HMAC:
+		return "hmac"
+	case KeyType_AES128_CMAC:
+		return "aes128-cmac"
+	case KeyType_AES256_CMAC:
+		return "aes256-cmac"
+	case KeyType_FF3_1:
+		return "ff3-1"
+	case KeyType_ML_DSA_44:
+		return "ml-dsa-44"
+	case KeyType_ML_DSA_65:
+		return "ml-dsa-65"
+	case KeyType_ML_DSA_87:
+		return "ml-dsa-87"
+	case KeyType_ML_DSA_65_ED25519:
+		return "ml-dsa-65-ed25519"
 	}
 
 	return "[unknown]"
@@ -180,6 +274,12 @@ type KeyEntry struct {
 
 	RSAKey *rsa.PrivateKey `json:"rsa_key"`
 
+	// ML-DSA seed of the post-quantum half of hybrid keys
+	PQKey []byte `json:"pq_key"`
+
+	// DER-encoded certificates issued for the key, starting with its own
+	CertificateChain [][]byte `json:"certificate_chain"`
+
 	// The public key in an appropriate format for the type of key
 	FormattedPublicKey string `json:"public_key"`
 
@@ -354,6 +454,36 @@ type Policy struct {
 	// The type of key
 	Type KeyType `json:"type"`
 
+	// KeySize is the size in bytes of the key material of standalone HMAC
+	// keys
+	KeySize int `json:"key_size"`
+
+	// FPEAlphabet is the alphabet of values encrypted with format-preserving
+	// encryption keys, either the name of one of FPEAlphabets or its
+	// characters
+	FPEAlphabet string `json:"fpe_alphabet"`
+
+	// AutoRotatePeriod is the period after which a new version of the key is
+	// generated automatically; zero disables automatic rotation
+	AutoRotatePeriod time.Duration `json:"auto_rotate_period"`
+
+	// AllowedOperations restricts the operations the key may be used for to
+	// a subset of KeyOperations; every operation is allowed if empty
+	AllowedOperations []string `json:"allowed_operations"`
+
+	// AllowedContexts lists the base64-encoded contexts keys may be derived
+	// with. If neither it nor AllowedContextRegex is set, any context is
+	// allowed.
+	AllowedContexts []string `json:"allowed_contexts"`
+
+	// AllowedContextRegex is a regular expression that contexts not listed in
+	// AllowedContexts must match in their entirety
+	AllowedContextRegex string `json:"allowed_context_regex"`
+
+	// TrackContexts reports the contexts keys are derived with to the
+	// observer set on the lock manager
+	TrackContexts bool `json:"track_contexts"`
+
 	// BackupInfo indicates the information about the backup action taken on
 	// this policy
 	BackupInfo *BackupInfo `json:"backup_info"`
@@ -374,9 +504,70 @@ type Policy struct {
 	// policy object.
 	StoragePrefix string `json:"storage_prefix"`
 
+	// Imported indicates that the key material was imported rather than
+	// generated by Vault
+	Imported bool `json:"imported"`
+
+	// AllowImportedKeyRotation allows an imported key to be rotated, which
+	// adds a version generated by Vault
+	AllowImportedKeyRotation bool `json:"allow_imported_key_rotation"`
+
 	// versionPrefixCache stores caches of version prefix strings and the split
 	// version template.
 	versionPrefixCache sync.Map
+
+	// contextRegexCache stores the compiled AllowedContextRegex
+	contextRegexCache sync.Map
+
+	// contextObserver is notified of the contexts keys are derived with if
+	// TrackContexts is set
+	contextObserver ContextObserver
+}
+
+// CheckOperation returns an error if the key may not be used for the given
+// operation
+func (p *Policy) CheckOperation(op string) error {
+	if len(p.AllowedOperations) == 0 || strutil.StrListContains(p.AllowedOperations, op) {
+		return nil
+	}
+	return errutil.UserError{Err: fmt.Sprintf("operation %q is not allowed for key %q", op, p.Name)}
+}
+
+// CheckContext returns an error if keys may not be derived with the given
+// context
+func (p *Policy) CheckContext(context []byte) error {
+	if len(p.AllowedContexts) == 0 && p.AllowedContextRegex == "" {
+		return nil
+	}
+
+	if strutil.StrListContains(p.AllowedContexts, base64.StdEncoding.EncodeToString(context)) {
+		return nil
+	}
+
+	if p.AllowedContextRegex != "" {
+		var re *regexp.Regexp
+		if cached, ok := p.contextRegexCache.Load(p.AllowedContextRegex); ok {
+			re = cached.(*regexp.Regexp)
+		} else {
+			var err error
+			re, err = CompileContextRegex(p.AllowedContextRegex)
+			if err != nil {
+				return errutil.InternalError{Err: fmt.Sprintf("invalid allowed context regex: %v", err)}
+			}
+			p.contextRegexCache.Store(p.AllowedContextRegex, re)
+		}
+		if re.Match(context) {
+			return nil
+		}
+	}
+
+	return errutil.UserError{Err: fmt.Sprintf("context is not allowed for key %q", p.Name)}
+}
+
+// CompileContextRegex compiles a regular expression restricting derivation
+// contexts, which must match contexts in their entirety
+func CompileContextRegex(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + pattern + ")$")
 }
 
 func (p *Policy) Lock(exclusive bool) {
@@ -731,6 +922,13 @@ func (p *Policy) DeriveKey(context, salt []byte, ver int, numBytes int) ([]byte,
 		return nil, errutil.UserError{Err: "missing 'context' for key derivation; the key was created using a derived key, which means additional, per-request information must be included in order to perform operations with the key"}
 	}
 
+	if err := p.CheckContext(context); err != nil {
+		return nil, err
+	}
+	if p.TrackContexts && p.contextObserver != nil {
+		p.contextObserver(p.Name, context)
+	}
+
 	keyEntry, err := p.safeGetKeyEntry(ver)
 	if err != nil {
 		return nil, err
@@ -1007,6 +1205,9 @@ func (p *Policy) HMACKey(version int) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	if p.Type == KeyType_HMAC {
+		return keyEntry.Key, nil
+	}
 	if keyEntry.HMACKey == nil {
 		return nil, fmt.Errorf("no HMAC key exists for that key version")
 	}
@@ -1014,6 +1215,90 @@ func (p *Policy) HMACKey(version int) ([]byte, error) {
 	return keyEntry.HMACKey, nil
 }
 
+func (p *Policy) CMACKey(version int) ([]byte, error) {
+	if !p.Type.CMACSupported() {
+		return nil, fmt.Errorf("CMAC not supported for key type %v", p.Type)
+	}
+	switch {
+	case version < 0:
+		return nil, fmt.Errorf("key version does not exist (cannot be negative)")
+	case version > p.LatestVersion:
+		return nil, fmt.Errorf("key version does not exist; latest key version is %d", p.LatestVersion)
+	}
+	keyEntry, err := p.safeGetKeyEntry(version)
+	if err != nil {
+		return nil, err
+	}
+
+	return keyEntry.Key, nil
+}
+
+// FPEEncrypt encrypts the value with the given version of a format-preserving
+// encryption key, returning a value of the same length over the same
+// alphabet
+func (p *Policy) FPEEncrypt(ver int, tweak []byte, value string) (string, error) {
+	switch {
+	case ver == 0:
+		ver = p.LatestVersion
+	case ver < 0:
+		return "", errutil.UserError{Err: "requested version for encryption is negative"}
+	case ver > p.LatestVersion:
+		return "", errutil.UserError{Err: "requested version for encryption is higher than the latest key version"}
+	case ver < p.MinEncryptionVersion:
+		return "", errutil.UserError{Err: "requested version for encryption is less than the minimum encryption key version"}
+	}
+
+	ff, err := p.fpeCipher(ver)
+	if err != nil {
+		return "", err
+	}
+	encrypted, err := ff.Encrypt(tweak, value)
+	if err != nil {
+		return "", errutil.UserError{Err: err.Error()}
+	}
+	return encrypted, nil
+}
+
+// FPEDecrypt reverses FPEEncrypt with the given key version
+func (p *Policy) FPEDecrypt(ver int, tweak []byte, value string) (string, error) {
+	switch {
+	case ver == 0:
+		ver = p.LatestVersion
+	case ver < 0:
+		return "", errutil.UserError{Err: "requested version for decryption is negative"}
+	case ver > p.LatestVersion:
+		return "", errutil.UserError{Err: "requested version for decryption is higher than the latest key version"}
+	case p.MinDecryptionVersion > 0 && ver < p.MinDecryptionVersion:
+		return "", errutil.UserError{Err: ErrTooOld}
+	}
+
+	ff, err := p.fpeCipher(ver)
+	if err != nil {
+		return "", err
+	}
+	decrypted, err := ff.Decrypt(tweak, value)
+	if err != nil {
+		return "", errutil.UserError{Err: err.Error()}
+	}
+	return decrypted, nil
+}
+
+func (p *Policy) fpeCipher(ver int) (*FF31, error) {
+	if !p.Type.FPESupported() {
+		return nil, errutil.UserError{Err: fmt.Sprintf("format-preserving encryption not supported for key type %v", p.Type)}
+	}
+
+	keyEntry, err := p.safeGetKeyEntry(ver)
+	if err != nil {
+		return nil, err
+	}
+	alphabet, err := ResolveFPEAlphabet(p.FPEAlphabet)
+	if err != nil {
+		return nil, errutil.InternalError{Err: err.Error()}
+	}
+	return NewFF31(keyEntry.Key, alphabet)
+}
+
 func (p *Policy) Sign(ver int, context, input []byte, hashAlgorithm HashType, sigAlgorithm string, marshaling MarshalingType) (*SigningResult, error) {
 	if !p.Type.SigningSupported() {
 		return nil, fmt.Errorf("message signing not supported for key type %v", p.Type)
@@ -1125,6 +1410,18 @@ func (p *Policy) Sign(ver int, context, input []byte, hashAlgorithm HashType, si
 			return nil, err
 		}
 
+	case KeyType_ML_DSA_44, KeyType_ML_DSA_65, KeyType_ML_DSA_87:
+		sig, err = mldsaSign(p.Type, keyParams.Key, input, "")
+		if err != nil {
+			return nil, err
+		}
+
+	case KeyType_ML_DSA_65_ED25519:
+		sig, err = keyParams.signHybrid(p.Type, input)
+		if err != nil {
+			return nil, err
+		}
+
 	case KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
 		key := keyParams.RSAKey
 
@@ -1291,6 +1588,22 @@ func (p *Policy) VerifySignature(context, input []byte, hashAlgorithm HashType,
 
 		return ed25519.Verify(key.Public().(ed25519.PublicKey), input, sigBytes), nil
 
+	case KeyType_ML_DSA_44, KeyType_ML_DSA_65, KeyType_ML_DSA_87:
+		keyEntry, err := p.safeGetKeyEntry(ver)
+		if err != nil {
+			return false, err
+		}
+
+		return mldsaVerify(p.Type, keyEntry.Key, input, sigBytes, "")
+
+	case KeyType_ML_DSA_65_ED25519:
+		keyEntry, err := p.safeGetKeyEntry(ver)
+		if err != nil {
+			return false, err
+		}
+
+		return keyEntry.verifyHybrid(p.Type, input, sigBytes)
+
 	case KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
 		keyEntry, err := p.safeGetKeyEntry(ver)
 		if err != nil {
@@ -1335,7 +1648,149 @@ func (p *Policy) VerifySignature(context, input []byte, hashAlgorithm HashType,
 	}
 }
 
+// CreateCSR returns a DER-encoded certificate signing request for the given
+// version of the key, signed with its private key. The subject, subject
+// alternative names and extensions are copied from the template, if given.
+func (p *Policy) CreateCSR(ver int, template *x509.CertificateRequest) ([]byte, error) {
+	signer, err := p.certificateSigner(ver)
+	if err != nil {
+		return nil, err
+	}
+
+	csrTemplate := &x509.CertificateRequest{}
+	if template != nil {
+		csrTemplate.RawSubject = template.RawSubject
+		csrTemplate.DNSNames = template.DNSNames
+		csrTemplate.EmailAddresses = template.EmailAddresses
+		csrTemplate.IPAddresses = template.IPAddresses
+		csrTemplate.URIs = template.URIs
+		csrTemplate.ExtraExtensions = template.Extensions
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, csrTemplate, signer)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("error creating certificate signing request: %v", err)}
+	}
+	return csr, nil
+}
+
+// SetCertificateChain stores the certificate chain issued for the given
+// version of the key. The chain starts with the certificate of the key and
+// each certificate must be signed by the next one.
+func (p *Policy) SetCertificateChain(ctx context.Context, storage logical.Storage, ver int, chain []*x509.Certificate) error {
+	signer, err := p.certificateSigner(ver)
+	if err != nil {
+		return err
+	}
+	if ver == 0 {
+		ver = p.LatestVersion
+	}
+
+	if len(chain) == 0 {
+		return errutil.UserError{Err: "certificate chain is empty"}
+	}
+	pubKey, ok := signer.Public().(interface {
+		Equal(crypto.PublicKey) bool
+	})
+	if !ok || !pubKey.Equal(chain[0].PublicKey) {
+		return errutil.UserError{Err: fmt.Sprintf("the first certificate of the chain is not for version %d of the key", ver)}
+	}
+	for i := 0; i < len(chain)-1; i++ {
+		if err := chain[i].CheckSignatureFrom(chain[i+1]); err != nil {
+			return errutil.UserError{Err: fmt.Sprintf("certificate %d of the chain is not signed by the next one: %v", i, err)}
+		}
+	}
+
+	rawChain := make([][]byte, 0, len(chain))
+	for _, cert := range chain {
+		rawChain = append(rawChain, cert.Raw)
+	}
+
+	// Every version is also kept in the archive, which is where keys are
+	// restored from when the minimum decryption version is lowered
+	archive, err := p.LoadArchive(ctx, storage)
+	if err != nil {
+		return err
+	}
+	if idx := ver - p.MinAvailableVersion; idx >= 0 && idx < len(archive.Keys) {
+		archive.Keys[idx].CertificateChain = rawChain
+		if err := p.storeArchive(ctx, storage, archive); err != nil {
+			return err
+		}
+	}
+
+	verStr := strconv.Itoa(ver)
+	priorEntry := p.Keys[verStr]
+	entry := priorEntry
+	entry.CertificateChain = rawChain
+	p.Keys[verStr] = entry
+
+	if err := p.Persist(ctx, storage); err != nil {
+		p.Keys[verStr] = priorEntry
+		return err
+	}
+	return nil
+}
+
+// certificateSigner returns the private key of the given version of the key,
+// for use with X.509
+func (p *Policy) certificateSigner(ver int) (crypto.Signer, error) {
+	switch p.Type {
+	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521, KeyType_ED25519, KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
+	default:
+		return nil, errutil.UserError{Err: fmt.Sprintf("certificates are not supported for key type %v", p.Type)}
+	}
+	if p.Derived {
+		return nil, errutil.UserError{Err: "certificates are not supported for derived keys"}
+	}
+
+	switch {
+	case ver == 0:
+		ver = p.LatestVersion
+	case ver < 0:
+		return nil, errutil.UserError{Err: "requested version is negative"}
+	case ver > p.LatestVersion:
+		return nil, errutil.UserError{Err: "requested version is higher than the latest key version"}
+	case p.MinEncryptionVersion > 0 && ver < p.MinEncryptionVersion:
+		return nil, errutil.UserError{Err: "requested version is less than the minimum encryption key version"}
+	}
+
+	keyParams, err := p.safeGetKeyEntry(ver)
+	if err != nil {
+		return nil, err
+	}
+
+	switch p.Type {
+	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521:
+		curve := elliptic.P256()
+		switch p.Type {
+		case KeyType_ECDSA_P384:
+			curve = elliptic.P384()
+		case KeyType_ECDSA_P521:
+			curve = elliptic.P521()
+		}
+		return &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: curve,
+				X:     keyParams.EC_X,
+				Y:     keyParams.EC_Y,
+			},
+			D: keyParams.EC_D,
+		}, nil
+
+	case KeyType_ED25519:
+		return ed25519.PrivateKey(keyParams.Key), nil
+
+	default:
+		return keyParams.RSAKey, nil
+	}
+}
+
 func (p *Policy) Rotate(ctx context.Context, storage logical.Storage, randReader io.Reader) (retErr error) {
+	if p.Imported && !p.AllowImportedKeyRotation {
+		return errutil.UserError{Err: "imported key does not allow rotation within Vault"}
+	}
+
 	priorLatestVersion := p.LatestVersion
 	priorMinDecryptionVersion := p.MinDecryptionVersion
 	var priorKeys keyEntryMap
@@ -1388,6 +1843,28 @@ func (p *Policy) Rotate(ctx context.Context, storage logical.Storage, randReader
 		}
 		entry.Key = newKey
 
+	case KeyType_HMAC:
+		numBytes := p.KeySize
+		if numBytes == 0 {
+			numBytes = DefaultHMACKeySize
+		}
+		newKey, err := uuid.GenerateRandomBytesWithReader(numBytes, randReader)
+		if err != nil {
+			return err
+		}
+		entry.Key = newKey
+
+	case KeyType_AES128_CMAC, KeyType_AES256_CMAC, KeyType_FF3_1:
+		numBytes := 32
+		if p.Type == KeyType_AES128_CMAC {
+			numBytes = 16
+		}
+		newKey, err := uuid.GenerateRandomBytesWithReader(numBytes, randReader)
+		if err != nil {
+			return err
+		}
+		entry.Key = newKey
+
 	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521:
 		var curve elliptic.Curve
 		switch p.Type {
@@ -1403,22 +1880,9 @@ func (p *Policy) Rotate(ctx context.Context, storage logical.Storage, randReader
 		if err != nil {
 			return err
 		}
-		entry.EC_D = privKey.D
-		entry.EC_X = privKey.X
-		entry.EC_Y = privKey.Y
-		derBytes, err := x509.MarshalPKIXPublicKey(privKey.Public())
-		if err != nil {
-			return errwrap.Wrapf("error marshaling public key: {{err}}", err)
-		}
-		pemBlock := &pem.Block{
-			Type:  "PUBLIC KEY",
-			Bytes: derBytes,
-		}
-		pemBytes := pem.EncodeToMemory(pemBlock)
-		if pemBytes == nil || len(pemBytes) == 0 {
-			return fmt.Errorf("error PEM-encoding public key")
+		if err := entry.setECDSAKey(privKey); err != nil {
+			return err
 		}
-		entry.FormattedPublicKey = string(pemBytes)
 
 	case KeyType_ED25519:
 		pub, pri, err := ed25519.GenerateKey(randReader)
@@ -1428,6 +1892,28 @@ func (p *Policy) Rotate(ctx context.Context, storage logical.Storage, randReader
 		entry.Key = pri
 		entry.FormattedPublicKey = base64.StdEncoding.EncodeToString(pub)
 
+	case KeyType_ML_DSA_44, KeyType_ML_DSA_65, KeyType_ML_DSA_87:
+		seed, err := uuid.GenerateRandomBytesWithReader(32, randReader)
+		if err != nil {
+			return err
+		}
+		if err := entry.setMLDSAKey(p.Type, seed); err != nil {
+			return err
+		}
+
+	case KeyType_ML_DSA_65_ED25519:
+		seed, err := uuid.GenerateRandomBytesWithReader(32, randReader)
+		if err != nil {
+			return err
+		}
+		_, pri, err := ed25519.GenerateKey(randReader)
+		if err != nil {
+			return err
+		}
+		if err := entry.setHybridKey(p.Type, seed, pri); err != nil {
+			return err
+		}
+
 	case KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
 		bitSize := 2048
 		if p.Type == KeyType_RSA3072 {
@@ -1461,6 +1947,241 @@ func (p *Policy) Rotate(ctx context.Context, storage logical.Storage, randReader
 	return p.Persist(ctx, storage)
 }
 
+// Import adds a new version to the policy using the given key material: the
+// raw key for symmetric key types, and a PKCS#8 DER-encoded private key for
+// asymmetric ones. The HMAC key of the version is always generated.
+func (p *Policy) Import(ctx context.Context, storage logical.Storage, key []byte, randReader io.Reader) (retErr error) {
+	now := time.Now()
+	entry := KeyEntry{
+		CreationTime:           now,
+		DeprecatedCreationTime: now.Unix(),
+	}
+
+	hmacKey, err := uuid.GenerateRandomBytesWithReader(32, randReader)
+	if err != nil {
+		return err
+	}
+	entry.HMACKey = hmacKey
+
+	switch p.Type {
+	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_AES128_CMAC, KeyType_AES256_CMAC, KeyType_FF3_1:
+		numBytes := 32
+		if p.Type == KeyType_AES128_GCM96 || p.Type == KeyType_AES128_CMAC {
+			numBytes = 16
+		}
+		if len(key) != numBytes {
+			return errutil.UserError{Err: fmt.Sprintf("invalid key size for key type %v: expected %d bytes, got %d", p.Type, numBytes, len(key))}
+		}
+		entry.Key = key
+
+	case KeyType_HMAC:
+		numBytes := p.KeySize
+		if numBytes == 0 {
+			numBytes = DefaultHMACKeySize
+		}
+		if len(key) != numBytes {
+			return errutil.UserError{Err: fmt.Sprintf("invalid key size for key type %v: expected %d bytes, got %d", p.Type, numBytes, len(key))}
+		}
+		entry.Key = key
+
+	case KeyType_ML_DSA_65_ED25519:
+		return errutil.UserError{Err: fmt.Sprintf("import is not supported for key type %v", p.Type)}
+
+	default:
+		parsed, err := x509.ParsePKCS8PrivateKey(key)
+		if err != nil {
+			return errutil.UserError{Err: fmt.Sprintf("error parsing PKCS#8 private key: %v", err)}
+		}
+
+		switch p.Type {
+		case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521:
+			privKey, ok := parsed.(*ecdsa.PrivateKey)
+			var curve elliptic.Curve
+			switch p.Type {
+			case KeyType_ECDSA_P384:
+				curve = elliptic.P384()
+			case KeyType_ECDSA_P521:
+				curve = elliptic.P521()
+			default:
+				curve = elliptic.P256()
+			}
+			if !ok || privKey.Curve != curve {
+				return errutil.UserError{Err: fmt.Sprintf("private key is not a %v key", p.Type)}
+			}
+			if err := entry.setECDSAKey(privKey); err != nil {
+				return err
+			}
+
+		case KeyType_ED25519:
+			privKey, ok := parsed.(ed25519.PrivateKey)
+			if !ok {
+				return errutil.UserError{Err: fmt.Sprintf("private key is not a %v key", p.Type)}
+			}
+			entry.Key = privKey
+			entry.FormattedPublicKey = base64.StdEncoding.EncodeToString(privKey.Public().(ed25519.PublicKey))
+
+		case KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
+			bitSize := 2048
+			if p.Type == KeyType_RSA3072 {
+				bitSize = 3072
+			}
+			if p.Type == KeyType_RSA4096 {
+				bitSize = 4096
+			}
+			privKey, ok := parsed.(*rsa.PrivateKey)
+			if !ok || privKey.N.BitLen() != bitSize {
+				return errutil.UserError{Err: fmt.Sprintf("private key is not a %v key", p.Type)}
+			}
+			entry.RSAKey = privKey
+
+		case KeyType_ML_DSA_44, KeyType_ML_DSA_65, KeyType_ML_DSA_87:
+			seed, err := mldsaSeedFromPrivateKey(p.Type, parsed)
+			if err != nil {
+				return errutil.UserError{Err: err.Error()}
+			}
+			if err := entry.setMLDSAKey(p.Type, seed); err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("unsupported key type %v", p.Type)
+		}
+	}
+
+	if p.ConvergentEncryption {
+		if p.ConvergentVersion == -1 || p.ConvergentVersion > 1 {
+			entry.ConvergentVersion = currentConvergentVersion
+		}
+	}
+
+	priorLatestVersion := p.LatestVersion
+	priorMinDecryptionVersion := p.MinDecryptionVersion
+	var priorKeys keyEntryMap
+
+	if p.Keys != nil {
+		priorKeys = keyEntryMap{}
+		for k, v := range p.Keys {
+			priorKeys[k] = v
+		}
+	}
+
+	defer func() {
+		if retErr != nil {
+			p.LatestVersion = priorLatestVersion
+			p.MinDecryptionVersion = priorMinDecryptionVersion
+			p.Keys = priorKeys
+		}
+	}()
+
+	if p.Keys == nil {
+		p.Keys = keyEntryMap{}
+	}
+
+	p.LatestVersion += 1
+	p.Keys[strconv.Itoa(p.LatestVersion)] = entry
+
+	if p.MinDecryptionVersion == 0 {
+		p.MinDecryptionVersion = 1
+	}
+
+	return p.Persist(ctx, storage)
+}
+
+// setECDSAKey stores an ECDSA private key in the entry along with its
+// PEM-encoded public key
+func (ke *KeyEntry) setECDSAKey(privKey *ecdsa.PrivateKey) error {
+	ke.EC_D = privKey.D
+	ke.EC_X = privKey.X
+	ke.EC_Y = privKey.Y
+	derBytes, err := x509.MarshalPKIXPublicKey(privKey.Public())
+	if err != nil {
+		return errwrap.Wrapf("error marshaling public key: {{err}}", err)
+	}
+	pemBlock := &pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: derBytes,
+	}
+	pemBytes := pem.EncodeToMemory(pemBlock)
+	if pemBytes == nil || len(pemBytes) == 0 {
+		return fmt.Errorf("error PEM-encoding public key")
+	}
+	ke.FormattedPublicKey = string(pemBytes)
+	return nil
+}
+
+// setMLDSAKey stores the seed of an ML-DSA private key in the entry along
+// with its PEM-encoded public key
+func (ke *KeyEntry) setMLDSAKey(kt KeyType, seed []byte) error {
+	pemKey, err := mldsaPublicKeyPEM(kt, seed)
+	if err != nil {
+		return err
+	}
+	ke.Key = seed
+	ke.FormattedPublicKey = pemKey
+	return nil
+}
+
+// setHybridKey stores both halves of a hybrid key in the entry. The public
+// key is the PEM-encoded ML-DSA public key followed by the PEM-encoded
+// Ed25519 public key.
+func (ke *KeyEntry) setHybridKey(kt KeyType, seed []byte, edKey ed25519.PrivateKey) error {
+	pqPEM, err := mldsaPublicKeyPEM(kt, seed)
+	if err != nil {
+		return err
+	}
+	derBytes, err := x509.MarshalPKIXPublicKey(edKey.Public())
+	if err != nil {
+		return errwrap.Wrapf("error marshaling public key: {{err}}", err)
+	}
+	edPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: derBytes,
+	})
+
+	ke.PQKey = seed
+	ke.Key = edKey
+	ke.FormattedPublicKey = pqPEM + string(edPEM)
+	return nil
+}
+
+// errMLDSAUnsupported is returned when ML-DSA keys are created or used with a
+// build that lacks ML-DSA support
+var errMLDSAUnsupported = errutil.UserError{Err: "ML-DSA keys are not supported by this build; Vault must be built with Go 1.26 or later to use them"}
+
+// hybridSignatureLabel is used as the ML-DSA context and to prefix the
+// Ed25519 input of hybrid signatures, so that neither half can be passed off
+// as a standalone signature
+func hybridSignatureLabel(kt KeyType) string {
+	return "vault-transit:" + kt.String()
+}
+
+// signHybrid returns the ML-DSA signature of the input followed by its
+// Ed25519 signature
+func (ke *KeyEntry) signHybrid(kt KeyType, input []byte) ([]byte, error) {
+	label := hybridSignatureLabel(kt)
+	pqSig, err := mldsaSign(kt, ke.PQKey, input, label)
+	if err != nil {
+		return nil, err
+	}
+	edSig := ed25519.Sign(ed25519.PrivateKey(ke.Key), append([]byte(label+"\x00"), input...))
+	return append(pqSig, edSig...), nil
+}
+
+// verifyHybrid checks both halves of a hybrid signature
+func (ke *KeyEntry) verifyHybrid(kt KeyType, input, sig []byte) (bool, error) {
+	if len(sig) != mldsaSignatureSize(kt)+ed25519.SignatureSize {
+		return false, nil
+	}
+	pqSig, edSig := sig[:len(sig)-ed25519.SignatureSize], sig[len(sig)-ed25519.SignatureSize:]
+
+	label := hybridSignatureLabel(kt)
+	edKey := ed25519.PrivateKey(ke.Key)
+	if !ed25519.Verify(edKey.Public().(ed25519.PublicKey), append([]byte(label+"\x00"), input...), edSig) {
+		return false, nil
+	}
+	return mldsaVerify(kt, ke.PQKey, input, pqSig, label)
+}
+
 func (p *Policy) MigrateKeyToKeysMap() {
 	now := time.Now()
 	p.Keys = keyEntryMap{
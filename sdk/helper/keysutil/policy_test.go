@@ -641,3 +641,46 @@ func BenchmarkSymmetric(b *testing.B) {
 		}
 	}
 }
+
+func Test_DerivationContextRestrictions(t *testing.T) {
+	ctx := context.Background()
+	storage := &logical.InmemStorage{}
+
+	lm, err := NewLockManager(false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var observed []string
+	lm.SetContextObserver(func(name string, context []byte) {
+		observed = append(observed, name+":"+string(context))
+	})
+
+	p, _, err := lm.GetPolicy(ctx, PolicyRequest{
+		Upsert:  true,
+		Storage: storage,
+		KeyType: KeyType_AES256_GCM96,
+		Name:    "test",
+		Derived: true,
+	}, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.AllowedContexts = []string{"YWxsb3dlZA=="} // "allowed"
+	p.AllowedContextRegex = "tenant-[a-z]+"
+	p.TrackContexts = true
+
+	for _, context := range []string{"allowed", "tenant-abc"} {
+		if _, err := p.GetKey([]byte(context), 1, 32); err != nil {
+			t.Fatalf("expected context %q to be allowed: %v", context, err)
+		}
+	}
+	for _, context := range []string{"denied", "tenant-abc1", "my-tenant-abc"} {
+		if _, err := p.GetKey([]byte(context), 1, 32); err == nil {
+			t.Fatalf("expected context %q to be denied", context)
+		}
+	}
+
+	if !reflect.DeepEqual(observed, []string{"test:allowed", "test:tenant-abc"}) {
+		t.Fatalf("bad observed contexts: %v", observed)
+	}
+}
@@ -0,0 +1,315 @@
+package keysutil
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The streaming ciphertext format consists of a header followed by a sequence
+// of chunks, each sealed with AES-256-GCM under a per-object data key:
+//
+//	header: magic "VTS" | version (1 byte) | chunk size (uint32)
+//	        | wrapped key length (uint16) | wrapped key
+//	chunk:  final flag (1 byte) | ciphertext length (uint32) | ciphertext
+//
+// The nonce of each chunk is built from its index and the final flag, and the
+// header is authenticated as additional data of every chunk, so that chunks
+// cannot be reordered, dropped, truncated or moved between objects without
+// decryption failing.
+const (
+	// StreamFormatVersion is the version of the streaming ciphertext format
+	StreamFormatVersion = 1
+
+	// DefaultStreamChunkSize is the amount of plaintext sealed per chunk when
+	// none is given
+	DefaultStreamChunkSize = 64 * 1024
+
+	// MaxStreamChunkSize is the largest chunk size accepted, bounding the
+	// memory needed to decrypt a stream
+	MaxStreamChunkSize = 16 * 1024 * 1024
+
+	// StreamDataKeySize is the size of the per-object data key
+	StreamDataKeySize = 32
+)
+
+var streamMagic = []byte("VTS")
+
+// StreamHeader holds the parameters of a streaming ciphertext
+type StreamHeader struct {
+	// ChunkSize is the maximum amount of plaintext in a single chunk
+	ChunkSize int
+
+	// WrappedKey is the data key of the object, encrypted by the named key
+	WrappedKey string
+
+	raw []byte
+}
+
+func (h *StreamHeader) marshal() ([]byte, error) {
+	if h.ChunkSize < 1 || h.ChunkSize > MaxStreamChunkSize {
+		return nil, fmt.Errorf("chunk size must be between 1 and %d bytes", MaxStreamChunkSize)
+	}
+	if len(h.WrappedKey) == 0 || len(h.WrappedKey) > 0xffff {
+		return nil, errors.New("invalid length of wrapped key")
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Write(streamMagic)
+	buf.WriteByte(StreamFormatVersion)
+	binary.Write(buf, binary.BigEndian, uint32(h.ChunkSize))
+	binary.Write(buf, binary.BigEndian, uint16(len(h.WrappedKey)))
+	buf.WriteString(h.WrappedKey)
+	return buf.Bytes(), nil
+}
+
+// ReadStreamHeader reads the header of a streaming ciphertext from r, leaving
+// r positioned at the first chunk
+func ReadStreamHeader(r io.Reader) (*StreamHeader, error) {
+	fixed := make([]byte, len(streamMagic)+1+4+2)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, errors.New("invalid stream: unable to read header")
+	}
+	if !bytes.Equal(fixed[:len(streamMagic)], streamMagic) {
+		return nil, errors.New("invalid stream: not a transit stream")
+	}
+	pos := len(streamMagic)
+	if fixed[pos] != StreamFormatVersion {
+		return nil, fmt.Errorf("invalid stream: unsupported format version %d", fixed[pos])
+	}
+	pos++
+	chunkSize := binary.BigEndian.Uint32(fixed[pos:])
+	pos += 4
+	keyLen := binary.BigEndian.Uint16(fixed[pos:])
+
+	if chunkSize < 1 || chunkSize > MaxStreamChunkSize {
+		return nil, errors.New("invalid stream: invalid chunk size")
+	}
+	if keyLen == 0 {
+		return nil, errors.New("invalid stream: missing wrapped key")
+	}
+
+	wrappedKey := make([]byte, keyLen)
+	if _, err := io.ReadFull(r, wrappedKey); err != nil {
+		return nil, errors.New("invalid stream: unable to read wrapped key")
+	}
+
+	return &StreamHeader{
+		ChunkSize:  int(chunkSize),
+		WrappedKey: string(wrappedKey),
+		raw:        append(fixed, wrappedKey...),
+	}, nil
+}
+
+func newStreamAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != StreamDataKeySize {
+		return nil, fmt.Errorf("data key must be %d bytes", StreamDataKeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// streamNonce returns the nonce of the chunk with the given index. Each data
+// key only ever encrypts a single stream, so the index is enough to keep the
+// nonces unique.
+func streamNonce(index uint64, final bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], index)
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+type streamEncrypter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte
+	buf    []byte
+	size   int
+	index  uint64
+	closed bool
+}
+
+// NewStreamEncrypter returns a writer that encrypts everything written to it
+// with the given data key and writes the framed ciphertext to w. The wrapped
+// form of the data key is stored in the header so that the stream can later
+// be decrypted. Close must be called to write the final chunk.
+func NewStreamEncrypter(w io.Writer, key []byte, wrappedKey string, chunkSize int) (io.WriteCloser, error) {
+	header := &StreamHeader{
+		ChunkSize:  chunkSize,
+		WrappedKey: wrappedKey,
+	}
+	raw, err := header.marshal()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newStreamAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(raw); err != nil {
+		return nil, err
+	}
+
+	return &streamEncrypter{
+		w:      w,
+		aead:   aead,
+		header: raw,
+		buf:    make([]byte, 0, chunkSize),
+		size:   chunkSize,
+	}, nil
+}
+
+func (e *streamEncrypter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("write to closed stream")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// A full chunk is only sealed once more data arrives, since the last
+		// chunk must be marked as final
+		if len(e.buf) == e.size {
+			if err := e.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := e.size - len(e.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+		e.buf = append(e.buf, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close seals the remaining plaintext as the final chunk
+func (e *streamEncrypter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.seal(true)
+}
+
+func (e *streamEncrypter) seal(final bool) error {
+	ciphertext := e.aead.Seal(nil, streamNonce(e.index, final), e.buf, e.header)
+	e.index++
+	e.buf = e.buf[:0]
+
+	frame := make([]byte, 5)
+	if final {
+		frame[0] = 1
+	}
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(ciphertext)))
+	if _, err := e.w.Write(frame); err != nil {
+		return err
+	}
+	_, err := e.w.Write(ciphertext)
+	return err
+}
+
+type streamDecrypter struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	header *StreamHeader
+	buf    []byte
+	index  uint64
+	done   bool
+	err    error
+}
+
+// NewStreamDecrypter returns a reader yielding the plaintext of the stream
+// whose header has been read from r, using the unwrapped data key. Only
+// authenticated plaintext is returned; a stream that is truncated or has data
+// following its final chunk results in an error.
+func NewStreamDecrypter(r io.Reader, header *StreamHeader, key []byte) (io.Reader, error) {
+	if header == nil || header.raw == nil {
+		return nil, errors.New("stream header has not been read")
+	}
+	aead, err := newStreamAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &streamDecrypter{
+		r:      r,
+		aead:   aead,
+		header: header,
+	}, nil
+}
+
+func (d *streamDecrypter) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		if d.done {
+			d.err = d.checkEnd()
+			continue
+		}
+		d.err = d.open()
+	}
+
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+func (d *streamDecrypter) open() error {
+	frame := make([]byte, 5)
+	if _, err := io.ReadFull(d.r, frame); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return errors.New("invalid stream: stream is truncated")
+		}
+		return err
+	}
+	final := frame[0] == 1
+	if frame[0] > 1 {
+		return errors.New("invalid stream: invalid chunk frame")
+	}
+	length := binary.BigEndian.Uint32(frame[1:])
+	if int64(length) > int64(d.header.ChunkSize+d.aead.Overhead()) || int(length) < d.aead.Overhead() {
+		return errors.New("invalid stream: invalid chunk length")
+	}
+
+	ciphertext := make([]byte, length)
+	if _, err := io.ReadFull(d.r, ciphertext); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return errors.New("invalid stream: stream is truncated")
+		}
+		return err
+	}
+
+	plaintext, err := d.aead.Open(ciphertext[:0], streamNonce(d.index, final), ciphertext, d.header.raw)
+	if err != nil {
+		return errors.New("invalid stream: message authentication failed")
+	}
+	d.index++
+	d.buf = plaintext
+	d.done = final
+	return nil
+}
+
+// checkEnd makes sure nothing follows the final chunk
+func (d *streamDecrypter) checkEnd() error {
+	var extra [1]byte
+	n, err := io.ReadFull(d.r, extra[:])
+	switch {
+	case n > 0:
+		return errors.New("invalid stream: data found after the final chunk")
+	case err == io.EOF:
+		return io.EOF
+	default:
+		return err
+	}
+}
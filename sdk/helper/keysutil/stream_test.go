@@ -0,0 +1,131 @@
+package keysutil
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"testing"
+)
+
+func encryptStream(t *testing.T, key, plaintext []byte, chunkSize int) []byte {
+	t.Helper()
+
+	out := new(bytes.Buffer)
+	w, err := NewStreamEncrypter(out, key, "vault:v1:wrapped", chunkSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Write in odd sized pieces to exercise the chunk buffering
+	for len(plaintext) > 0 {
+		n := 7
+		if n > len(plaintext) {
+			n = len(plaintext)
+		}
+		if _, err := w.Write(plaintext[:n]); err != nil {
+			t.Fatal(err)
+		}
+		plaintext = plaintext[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func decryptStream(key, ciphertext []byte) ([]byte, error) {
+	r := bytes.NewReader(ciphertext)
+	header, err := ReadStreamHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	dr, err := NewStreamDecrypter(r, header, key)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(dr)
+}
+
+func TestStream_RoundTrip(t *testing.T) {
+	key := make([]byte, StreamDataKeySize)
+	rand.Read(key)
+
+	for _, size := range []int{0, 1, 15, 16, 17, 64, 1000} {
+		plaintext := make([]byte, size)
+		rand.Read(plaintext)
+
+		ciphertext := encryptStream(t, key, plaintext, 16)
+
+		header, err := ReadStreamHeader(bytes.NewReader(ciphertext))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.ChunkSize != 16 || header.WrappedKey != "vault:v1:wrapped" {
+			t.Fatalf("bad header: %#v", header)
+		}
+
+		decrypted, err := decryptStream(key, ciphertext)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Fatalf("size %d: plaintext mismatch", size)
+		}
+	}
+}
+
+func TestStream_Tampering(t *testing.T) {
+	key := make([]byte, StreamDataKeySize)
+	rand.Read(key)
+	plaintext := make([]byte, 100)
+	rand.Read(plaintext)
+
+	ciphertext := encryptStream(t, key, plaintext, 16)
+	// 100 bytes in chunks of 16 are six full chunks and a final one of 4
+	frameLen := 5 + 16 + 16
+	finalLen := 5 + 4 + 16
+	headerLen := len(ciphertext) - 6*frameLen - finalLen
+
+	wrongKey := make([]byte, StreamDataKeySize)
+	rand.Read(wrongKey)
+	if _, err := decryptStream(wrongKey, ciphertext); err == nil {
+		t.Fatal("expected error decrypting with the wrong key")
+	}
+
+	// Drop the final chunk
+	if _, err := decryptStream(key, ciphertext[:len(ciphertext)-finalLen]); err == nil {
+		t.Fatal("expected error on truncated stream")
+	}
+
+	// Cut the stream at a chunk boundary and mark the last remaining chunk as
+	// final
+	truncated := append([]byte{}, ciphertext[:headerLen+2*frameLen]...)
+	truncated[headerLen+frameLen] = 1
+	if _, err := decryptStream(key, truncated); err == nil {
+		t.Fatal("expected error on truncated stream marked final")
+	}
+
+	// Swap two chunks
+	swapped := append([]byte{}, ciphertext...)
+	copy(swapped[headerLen:], ciphertext[headerLen+frameLen:headerLen+2*frameLen])
+	copy(swapped[headerLen+frameLen:], ciphertext[headerLen:headerLen+frameLen])
+	if _, err := decryptStream(key, swapped); err == nil {
+		t.Fatal("expected error on reordered chunks")
+	}
+
+	// Modify the header
+	modified := append([]byte{}, ciphertext...)
+	modified[headerLen-1] ^= 1
+	if _, err := decryptStream(key, modified); err == nil {
+		t.Fatal("expected error on modified header")
+	}
+
+	// Append data after the final chunk
+	if _, err := decryptStream(key, append(append([]byte{}, ciphertext...), 0)); err == nil {
+		t.Fatal("expected error on trailing data")
+	}
+
+	// Not a stream at all
+	if _, err := decryptStream(key, []byte("vault:v1:abcd")); err == nil {
+		t.Fatal("expected error on invalid header")
+	}
+}
@@ -297,7 +297,7 @@ func (c *Core) loadAudits(ctx context.Context) error {
 		entry.namespace = ns
 	}
 
-	if !needPersist || c.perfStandby {
+	if !needPersist || c.perfStandby || c.raftLocalReadStandby {
 		return nil
 	}
 
@@ -540,7 +540,7 @@ func (c *Core) loadCredentials(ctx context.Context) error {
 		entry.SyncCache()
 	}
 
-	if !needPersist {
+	if !needPersist || c.raftLocalReadStandby {
 		return nil
 	}
 
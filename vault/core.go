@@ -32,6 +32,7 @@ import (
 	"github.com/hashicorp/vault/helper/metricsutil"
 	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/internalshared/reloadutil"
+	"github.com/hashicorp/vault/physical/raft"
 	"github.com/hashicorp/vault/sdk/helper/certutil"
 	"github.com/hashicorp/vault/sdk/helper/consts"
 	"github.com/hashicorp/vault/sdk/helper/jsonutil"
@@ -262,6 +263,7 @@ type Core struct {
 
 	standby              bool
 	perfStandby          bool
+	raftLocalReadStandby bool
 	standbyDoneCh        chan struct{}
 	standbyStopCh        *atomic.Value
 	manualStepDownCh     chan struct{}
@@ -505,9 +507,13 @@ type Core struct {
 	counters counters
 
 	// Stores the raft applied index for standby nodes
-	raftFollowerStates *raftFollowerStates
+	raftFollowerStates *raft.FollowerStates
 	// Stop channel for raft TLS rotations
 	raftTLSRotationStopCh chan struct{}
+	// Runs the automated raft snapshots on the active node
+	raftAutoSnapshots *raftAutoSnapshots
+	// Serves the reads of selected mounts from the raft FSM on standbys
+	raftLocalReads *raftLocalReads
 	// Stores the pending peers we are waiting to give answers
 	pendingRaftPeers *sync.Map
 
@@ -2267,8 +2273,10 @@ func (c *Core) adjustForSealMigration(unwrapSeal Seal) error {
 		// With unwrapSeal==nil, either we're not migrating, or we're migrating
 		// from shamir.
 		switch {
-		case existBarrierSealConfig.Type == c.seal.BarrierType():
-			// We have the same barrier type and the unwrap seal is nil so we're not
+		case barrierTypeCompatible(c.seal, existBarrierSealConfig):
+			// We have the same barrier type, a multi-seal including the stored
+			// barrier type, or one of the seals of the stored multi-seal, and
+			// the unwrap seal is nil so we're not
 			// migrating from same to same, IOW we assume it's not a migration.
 			return nil
 		case c.seal.BarrierType() == wrapping.Shamir:
@@ -10,6 +10,7 @@ import (
 	"github.com/armon/go-metrics"
 	"github.com/hashicorp/vault/helper/metricsutil"
 	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/physical/raft"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -26,7 +27,7 @@ func (c *Core) metricsLoop(stopCh chan struct{}) {
 	for {
 		select {
 		case <-emitTimer:
-			if !c.PerfStandby() {
+			if !c.PerfStandby() && !c.RaftLocalReadStandby() {
 				c.metricsMutex.Lock()
 				// Emit on active node only
 				if c.expiration != nil {
@@ -217,6 +218,18 @@ func (c *Core) emitMetrics(stopCh chan struct{}) {
 			c.activeEntityGaugeCollector,
 			"",
 		},
+		{
+			[]string{"raft_storage", "mount", "keys"},
+			[]metrics.Label{{"gauge", "raft_storage_keys_by_mountpoint"}},
+			c.raftStorageGaugeCollector(func(u *MountStorageUsage) float32 { return float32(u.Keys) }),
+			"",
+		},
+		{
+			[]string{"raft_storage", "mount", "size"},
+			[]metrics.Label{{"gauge", "raft_storage_size_by_mountpoint"}},
+			c.raftStorageGaugeCollector(func(u *MountStorageUsage) float32 { return float32(u.Size) }),
+			"",
+		},
 	}
 
 	// Disable collection if configured, or if we're a performance standby
@@ -460,6 +473,35 @@ func (c *Core) entityGaugeCollectorByMount(ctx context.Context) ([]metricsutil.G
 	return values, nil
 }
 
+// raftStorageGaugeCollector returns a collector of the given value of the
+// storage used by each mount, when raft is used for storage.
+func (c *Core) raftStorageGaugeCollector(value func(*MountStorageUsage) float32) metricsutil.GaugeCollector {
+	return func(ctx context.Context) ([]metricsutil.GaugeLabelValues, error) {
+		if _, ok := c.underlyingPhysical.(*raft.RaftBackend); !ok {
+			return []metricsutil.GaugeLabelValues{}, nil
+		}
+
+		usage, err := c.countStorageUsage(ctx)
+		if err != nil {
+			return []metricsutil.GaugeLabelValues{}, err
+		}
+
+		values := make([]metricsutil.GaugeLabelValues, 0, len(usage.Mounts))
+		for _, u := range usage.Mounts {
+			values = append(values, metricsutil.GaugeLabelValues{
+				Labels: []metrics.Label{
+					metricsutil.NamespaceLabel(u.entry.namespace),
+					{"mount_point", strings.TrimPrefix(u.Path, u.entry.namespace.Path)},
+					{"type", u.Type},
+				},
+				Value: value(u),
+			})
+		}
+
+		return values, nil
+	}
+}
+
 func (c *Core) cachedGaugeMetricsEmitter() {
 	if c.metricsHelper == nil {
 		return
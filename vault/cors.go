@@ -26,6 +26,7 @@ var StdAllowedHeaders = []string{
 	"X-Vault-Wrap-Format",
 	"X-Vault-Wrap-TTL",
 	"X-Vault-Policy-Override",
+	"X-Vault-Raft-Index",
 	"Authorization",
 	consts.AuthHeaderName,
 }
@@ -2,11 +2,13 @@ package vault
 
 import (
 	"context"
+	"errors"
 	"sort"
 	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/physical/raft"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -239,3 +241,90 @@ func (c *Core) countActiveEntities(ctx context.Context) (*ActiveEntities, error)
 		},
 	}, nil
 }
+
+// StorageUsage contains the storage used by the data of each mount.
+type StorageUsage struct {
+	// Mounts contains the storage used by each mount, sorted by path.
+	Mounts []*MountStorageUsage `json:"mounts"`
+
+	// Total is the storage used by all of the data, including the data not
+	// belonging to a mount.
+	Total StorageCounter `json:"total"`
+}
+
+// MountStorageUsage is the storage used by the data of a mount.
+type MountStorageUsage struct {
+	Path string `json:"path"`
+	Type string `json:"type"`
+	StorageCounter
+
+	entry *MountEntry
+}
+
+// StorageCounter counts the keys stored and the size of their values
+type StorageCounter struct {
+	Keys int   `json:"keys"`
+	Size int64 `json:"size"`
+}
+
+// countStorageUsage returns the storage used by the data of each mount, as
+// accounted by the raft FSM.
+func (c *Core) countStorageUsage(ctx context.Context) (*StorageUsage, error) {
+	// Raft used for HA only doesn't hold the data
+	raftBackend, ok := c.underlyingPhysical.(*raft.RaftBackend)
+	if !ok {
+		return nil, errors.New("storage usage is only available with raft storage")
+	}
+
+	usage := &StorageUsage{
+		Mounts: []*MountStorageUsage{},
+	}
+	byPrefix := make(map[string]*raft.PrefixUsage)
+	for _, u := range raftBackend.StorageUsage() {
+		byPrefix[u.Prefix] = u
+		usage.Total.Keys += u.Keys
+		usage.Total.Size += u.Size
+	}
+
+	for _, entry := range c.allMountEntries() {
+		u, ok := byPrefix[entry.ViewPath()]
+		if !ok {
+			continue
+		}
+		usage.Mounts = append(usage.Mounts, &MountStorageUsage{
+			Path: entry.APIPath(),
+			Type: entry.Type,
+			StorageCounter: StorageCounter{
+				Keys: u.Keys,
+				Size: u.Size,
+			},
+			entry: entry,
+		})
+	}
+	sort.Slice(usage.Mounts, func(i, j int) bool {
+		return usage.Mounts[i].Path < usage.Mounts[j].Path
+	})
+
+	return usage, nil
+}
+
+// allMountEntries returns the entries of the secrets engines and auth
+// methods.
+func (c *Core) allMountEntries() []*MountEntry {
+	var entries []*MountEntry
+
+	// This may run during or after the seal process, so the tables may be nil
+	c.mountsLock.RLock()
+	if c.mounts != nil {
+		entries = append(entries, c.mounts.Entries...)
+	}
+	c.mountsLock.RUnlock()
+
+	c.authLock.RLock()
+	if c.auth != nil {
+		entries = append(entries, c.auth.Entries...)
+	}
+	c.authLock.RUnlock()
+
+	return entries
+}
@@ -167,7 +167,9 @@ func (d dynamicSystemView) LocalMount() bool {
 // in read mode.
 func (d dynamicSystemView) ReplicationState() consts.ReplicationState {
 	state := d.core.ReplicationState()
-	if d.core.perfStandby {
+	// Standbys serving local reads are read-only as well, so their plugins
+	// must behave as on a performance standby
+	if d.core.perfStandby || d.core.raftLocalReadStandby {
 		state |= consts.ReplicationPerformanceStandby
 	}
 	return state
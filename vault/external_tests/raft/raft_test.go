@@ -4,15 +4,19 @@ import (
 	"bytes"
 	"context"
 	"crypto/md5"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/go-hclog"
 	uuid "github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/api"
 	credUserpass "github.com/hashicorp/vault/builtin/credential/userpass"
@@ -23,6 +27,7 @@ import (
 	"github.com/hashicorp/vault/physical/raft"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/hashicorp/vault/vault"
+	testinginterface "github.com/mitchellh/go-testing-interface"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/net/http2"
 )
@@ -308,6 +313,528 @@ func TestRaft_Configuration(t *testing.T) {
 	}
 }
 
+func TestRaft_Autopilot(t *testing.T) {
+	t.Parallel()
+	conf := &vault.CoreConfig{}
+	var opts = vault.TestClusterOptions{HandlerFunc: vaulthttp.Handler}
+	teststorage.RaftBackendSetup(conf, &opts)
+	opts.PhysicalFactory = func(t testinginterface.T, coreIdx int, logger hclog.Logger) *vault.PhysicalBackendBundle {
+		return teststorage.MakeRaftBackendWithConf(t, coreIdx, logger, map[string]string{
+			"disable_autopilot": "false",
+		})
+	}
+	cluster := vault.NewTestCluster(t, conf, &opts)
+	cluster.Start()
+	defer cluster.Cleanup()
+	vault.TestWaitActive(t, cluster.Cores[0].Core)
+
+	client := cluster.Cores[0].Client
+
+	// The followers joined as non-voters and are promoted once stable
+	var state *api.AutopilotState
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		var err error
+		state, err = client.Sys().RaftAutopilotState()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.Healthy && len(state.Voters) == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("servers were not promoted: %#v", state)
+		}
+		time.Sleep(time.Second)
+	}
+	if state.Leader != "core-0" || state.FailureTolerance != 1 {
+		t.Fatalf("bad state: %#v", state)
+	}
+	for _, id := range []string{"core-0", "core-1", "core-2"} {
+		server, ok := state.Servers[id]
+		if !ok || !server.Healthy || server.NodeStatus != "alive" {
+			t.Fatalf("bad server %s: %#v", id, server)
+		}
+	}
+
+	config, err := client.Sys().RaftAutopilotConfiguration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.CleanupDeadServers || config.DeadServerLastContactThreshold != "24h0m0s" {
+		t.Fatalf("bad default configuration: %#v", config)
+	}
+
+	config.CleanupDeadServers = true
+	config.DeadServerLastContactThreshold = "10m"
+	if err := client.Sys().PutRaftAutopilotConfiguration(config); err != nil {
+		t.Fatal(err)
+	}
+	config, err = client.Sys().RaftAutopilotConfiguration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.CleanupDeadServers || config.DeadServerLastContactThreshold != "10m0s" {
+		t.Fatalf("bad configuration: %#v", config)
+	}
+
+	// Dead servers must not be removed below min_quorum
+	config.MinQuorum = 1
+	if err := client.Sys().PutRaftAutopilotConfiguration(config); err == nil {
+		t.Fatal("expected error for a low min_quorum")
+	}
+}
+
+func TestRaft_LocalReads(t *testing.T) {
+	t.Parallel()
+	conf := &vault.CoreConfig{
+		LogicalBackends: map[string]logical.Factory{
+			"leased-kv": vault.LeasedPassthroughBackendFactory,
+		},
+	}
+	var opts = vault.TestClusterOptions{HandlerFunc: vaulthttp.Handler}
+	teststorage.RaftBackendSetup(conf, &opts)
+	opts.SetupFunc = nil
+	opts.PhysicalFactory = func(t testinginterface.T, coreIdx int, logger hclog.Logger) *vault.PhysicalBackendBundle {
+		return teststorage.MakeRaftBackendWithConf(t, coreIdx, logger, map[string]string{
+			"disable_autopilot": "false",
+			"local_read_mounts": "secret,leased",
+		})
+	}
+	cluster := vault.NewTestCluster(t, conf, &opts)
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	addressProvider := &testhelpers.TestRaftServerAddressProvider{Cluster: cluster}
+	atomic.StoreUint32(&vault.TestingUpdateClusterAddr, 1)
+
+	leaderCore := cluster.Cores[0]
+	{
+		testhelpers.EnsureCoreSealed(t, leaderCore)
+		leaderCore.UnderlyingRawStorage.(*raft.RaftBackend).SetServerAddressProvider(addressProvider)
+		cluster.UnsealCore(t, leaderCore)
+		vault.TestWaitActive(t, leaderCore.Core)
+	}
+
+	leaderInfos := []*raft.LeaderJoinInfo{
+		&raft.LeaderJoinInfo{
+			LeaderAPIAddr: leaderCore.Client.Address(),
+			TLSConfig:     leaderCore.TLSConfig,
+		},
+	}
+
+	// core-2 joins as a read replica
+	for i, nonVoter := range []bool{false, true} {
+		core := cluster.Cores[i+1]
+		core.UnderlyingRawStorage.(*raft.RaftBackend).SetServerAddressProvider(addressProvider)
+		_, err := core.JoinRaftCluster(namespace.RootContext(context.Background()), leaderInfos, nonVoter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cluster.UnsealCore(t, core)
+	}
+	testhelpers.WaitForNCoresUnsealed(t, cluster, len(cluster.Cores))
+
+	leaderClient := leaderCore.Client
+
+	// Only core-1 gets promoted
+	var state *api.AutopilotState
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		var err error
+		state, err = leaderClient.Sys().RaftAutopilotState()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(state.Voters) == 2 && state.Servers["core-2"] != nil && state.Servers["core-2"].Status == "read-replica" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("bad state: %#v", state)
+		}
+		time.Sleep(time.Second)
+	}
+	if state.Voters[0] == "core-2" || state.Voters[1] == "core-2" {
+		t.Fatalf("read replica was promoted: %#v", state)
+	}
+
+	// The standbys start serving local reads
+	standby := cluster.Cores[2]
+	deadline = time.Now().Add(30 * time.Second)
+	for !standby.Core.RaftLocalReadStandby() {
+		if time.Now().After(deadline) {
+			t.Fatal("standby did not start serving local reads")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if standby.Core.PerfStandby() {
+		t.Fatal("standby serving local reads is a performance standby")
+	}
+
+	if err := leaderClient.Sys().Mount("other", &api.MountInput{
+		Type: "kv",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := leaderClient.Sys().Mount("leased", &api.MountInput{
+		Type: "leased-kv",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the local read mounts are set up on the standby
+	ctx := namespace.RootContext(context.Background())
+	deadline = time.Now().Add(30 * time.Second)
+	for standby.Core.MatchingMount(ctx, "leased/foo") != "leased/" {
+		if time.Now().After(deadline) {
+			t.Fatal("standby did not pick up the new mount")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if mount := standby.Core.MatchingMount(ctx, "other/foo"); mount != "" {
+		t.Fatalf("unexpected mount %q set up on the standby", mount)
+	}
+	if _, err := leaderClient.Logical().Write("leased/foo", map[string]interface{}{"test": "data", "ttl": "1h"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := leaderClient.Sys().PutPolicy("reader", `path "secret/*" { capabilities = ["read"] }
+path "leased/*" { capabilities = ["read"] }`); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := leaderClient.Auth().Token().Create(&api.TokenCreateRequest{
+		Policies: []string{"reader"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	readerToken := secret.Auth.ClientToken
+
+	// Writes return the raft index which was applied
+	req := leaderClient.NewRequest("PUT", "/v1/secret/foo")
+	if err := req.SetJSONBody(map[string]interface{}{"test": "data"}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := leaderClient.RawRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	index := resp.Header.Get(vaulthttp.RaftIndexHeaderName)
+	if index == "" {
+		t.Fatal("missing raft index header")
+	}
+
+	transport := cleanhttp.DefaultPooledTransport()
+	transport.TLSClientConfig = standby.TLSConfig.Clone()
+	if err := http2.ConfigureTransport(transport); err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	// Forwarding is disabled, so only requests served locally succeed
+	localRead := func(path, token string) *http.Response {
+		t.Helper()
+		req := standby.Client.NewRequest("GET", "/v1/"+path)
+		req.ClientToken = token
+		req.Headers = http.Header{
+			vaulthttp.RaftIndexHeaderName:           []string{index},
+			vaulthttp.NoRequestForwardingHeaderName: []string{"true"},
+		}
+		httpReq, err := req.ToHTTP()
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(httpReq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp2 := localRead("secret/foo", readerToken)
+	defer resp2.Body.Close()
+	if resp2.StatusCode != http.StatusOK {
+		t.Fatalf("bad status: %d", resp2.StatusCode)
+	}
+	var body map[string]interface{}
+	if err := json.NewDecoder(resp2.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body["data"].(map[string]interface{})["test"] != "data" {
+		t.Fatalf("bad response: %#v", body)
+	}
+
+	// Reads of other mounts are not served locally, and their responses
+	// don't contain the raft index
+	resp3 := localRead("sys/policy/reader", cluster.RootToken)
+	resp3.Body.Close()
+	if resp3.StatusCode != http.StatusTemporaryRedirect {
+		t.Fatalf("bad status: %d", resp3.StatusCode)
+	}
+	resp, err = leaderClient.RawRequest(leaderClient.NewRequest("GET", "/v1/sys/policy/reader"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get(vaulthttp.RaftIndexHeaderName) != "" {
+		t.Fatal("unexpected raft index header")
+	}
+
+	// Reads creating leases are forwarded
+	resp5 := localRead("leased/foo", readerToken)
+	resp5.Body.Close()
+	if resp5.StatusCode != http.StatusTemporaryRedirect {
+		t.Fatalf("bad status: %d", resp5.StatusCode)
+	}
+	standby.Client.SetToken(readerToken)
+	secret, err = standby.Client.Logical().Read("leased/foo")
+	standby.Client.SetToken(cluster.RootToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret == nil || secret.LeaseID == "" {
+		t.Fatalf("bad secret: %#v", secret)
+	}
+
+	// Tokens unknown to the standby are forwarded rather than denied
+	resp6 := localRead("secret/foo", "s.unknowntoken")
+	resp6.Body.Close()
+	if resp6.StatusCode != http.StatusTemporaryRedirect {
+		t.Fatalf("bad status: %d", resp6.StatusCode)
+	}
+
+	// Requests using limited use tokens are forwarded, so that the active
+	// node decrements their use count
+	secret, err = leaderClient.Auth().Token().Create(&api.TokenCreateRequest{
+		Policies: []string{"reader"},
+		NumUses:  3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limitedToken := secret.Auth.ClientToken
+	resp7 := localRead("secret/foo", limitedToken)
+	resp7.Body.Close()
+	if resp7.StatusCode != http.StatusTemporaryRedirect {
+		t.Fatalf("bad status: %d", resp7.StatusCode)
+	}
+	standby.Client.SetToken(limitedToken)
+	_, err = standby.Client.Logical().Read("secret/foo")
+	standby.Client.SetToken(cluster.RootToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err = leaderClient.Auth().Token().Lookup(limitedToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numUses, _ := secret.Data["num_uses"].(json.Number).Int64(); numUses != 2 {
+		t.Fatalf("bad num_uses: %v", secret.Data["num_uses"])
+	}
+
+	// Policy changes are picked up by the standby
+	if err := leaderClient.Sys().PutPolicy("reader", `path "secret/bar" { capabilities = ["read"] }`); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = leaderClient.RawRequest(leaderClient.NewRequest("GET", "/v1/secret/foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	index = resp.Header.Get(vaulthttp.RaftIndexHeaderName)
+
+	resp4 := localRead("secret/foo", readerToken)
+	resp4.Body.Close()
+	if resp4.StatusCode != http.StatusForbidden {
+		t.Fatalf("bad status: %d", resp4.StatusCode)
+	}
+
+	// Writes through the standby are forwarded to the active node
+	if _, err := standby.Client.Logical().Write("secret/bar", map[string]interface{}{"test": "data"}); err != nil {
+		t.Fatal(err)
+	}
+	secret, err = leaderClient.Logical().Read("secret/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret == nil || secret.Data["test"] != "data" {
+		t.Fatalf("bad secret: %#v", secret)
+	}
+}
+
+func TestRaft_AutoSnapshots(t *testing.T) {
+	t.Parallel()
+	cluster := raftCluster(t)
+	defer cluster.Cleanup()
+
+	client := cluster.Cores[0].Client
+
+	dir, err := ioutil.TempDir("", "vault-raft-autosnapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The local storage type requires local_max_space
+	_, err = client.Logical().Write("sys/storage/raft/snapshot-auto/config/test", map[string]interface{}{
+		"interval":     "1s",
+		"path_prefix":  dir,
+		"storage_type": "local",
+	})
+	if err == nil {
+		t.Fatal("expected error without local_max_space")
+	}
+
+	_, err = client.Logical().Write("sys/storage/raft/snapshot-auto/config/test", map[string]interface{}{
+		"interval":        "1s",
+		"retain":          2,
+		"path_prefix":     dir,
+		"storage_type":    "local",
+		"local_max_space": 100 * 1024 * 1024,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret, err := client.Logical().List("sys/storage/raft/snapshot-auto/config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := secret.Data["keys"].([]interface{}); len(keys) != 1 || keys[0] != "test" {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	secret, err = client.Logical().Read("sys/storage/raft/snapshot-auto/config/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Data["file_prefix"] != "vault-snapshot" || secret.Data["interval"].(json.Number).String() != "1" {
+		t.Fatalf("bad config: %#v", secret.Data)
+	}
+
+	// Wait for enough snapshots for the retention to apply
+	var status map[string]interface{}
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		secret, err = client.Logical().Read("sys/storage/raft/snapshot-auto/status/test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		status = secret.Data
+		if status["last_snapshot_error"] != "" {
+			t.Fatalf("snapshot failed: %#v", status)
+		}
+		if start, _ := time.Parse(time.RFC3339, status["snapshot_start"].(string)); !start.IsZero() && time.Since(start) < time.Minute {
+			files, err := ioutil.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) == 2 {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("snapshots were not taken: %#v", status)
+		}
+		time.Sleep(time.Second)
+	}
+
+	url := status["snapshot_url"].(string)
+	if !strings.HasPrefix(url, "file://"+dir+"/vault-snapshot-") {
+		t.Fatalf("bad url: %s", url)
+	}
+	info, err := os.Stat(strings.TrimPrefix(url, "file://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size, _ := status["snapshot_size"].(json.Number).Int64(); size == 0 || size != info.Size() {
+		t.Fatalf("bad size %d, expected %d", size, info.Size())
+	}
+
+	// Deleting the configuration stops the snapshots
+	if _, err := client.Logical().Delete("sys/storage/raft/snapshot-auto/config/test"); err != nil {
+		t.Fatal(err)
+	}
+	secret, err = client.Logical().Read("sys/storage/raft/snapshot-auto/status/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret != nil {
+		t.Fatalf("expected no status after deletion: %#v", secret.Data)
+	}
+}
+
+func TestRaft_AutoSnapshots_SpaceAndRetention(t *testing.T) {
+	t.Parallel()
+	cluster := raftCluster(t)
+	defer cluster.Cleanup()
+
+	client := cluster.Cores[0].Client
+
+	dir, err := ioutil.TempDir("", "vault-raft-autosnapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var snap bytes.Buffer
+	if err := client.Sys().RaftSnapshot(&snap); err != nil {
+		t.Fatal(err)
+	}
+
+	// Other files in the directory don't count towards the space
+	if err := ioutil.WriteFile(filepath.Join(dir, "unrelated"), snap.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// There is room for the retained snapshots, but not for another one
+	// before the retention deletes the oldest
+	_, err = client.Logical().Write("sys/storage/raft/snapshot-auto/config/test", map[string]interface{}{
+		"interval":        "1s",
+		"retain":          2,
+		"path_prefix":     dir,
+		"storage_type":    "local",
+		"local_max_space": snap.Len() * 5 / 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	taken := make(map[string]bool)
+	deadline := time.Now().Add(30 * time.Second)
+	for len(taken) < 4 {
+		secret, err := client.Logical().Read("sys/storage/raft/snapshot-auto/status/test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if secret != nil {
+			if secret.Data["last_snapshot_error"] != "" {
+				t.Fatalf("snapshot failed: %#v", secret.Data)
+			}
+			if url, _ := secret.Data["snapshot_url"].(string); url != "" {
+				taken[url] = true
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("snapshots were not taken: %v", taken)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "vault-snapshot-*.snap"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) > 2 {
+		t.Fatalf("expected at most 2 snapshots, got %v", files)
+	}
+}
+
 func TestRaft_ShamirUnseal(t *testing.T) {
 	t.Parallel()
 	cluster := raftCluster(t)
@@ -399,6 +926,149 @@ func TestRaft_SnapshotAPI(t *testing.T) {
 	}
 }
 
+func TestRaft_SnapshotAPI_RestoreMounts(t *testing.T) {
+	t.Parallel()
+	cluster := raftCluster(t)
+	defer cluster.Cleanup()
+
+	client := cluster.Cores[0].Client
+
+	if err := client.Sys().Mount("kv", &api.MountInput{Type: "kv"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Sys().EnableAuthWithOptions("userpass", &api.EnableAuthOptions{Type: "userpass"}); err != nil {
+		t.Fatal(err)
+	}
+	writes := map[string]map[string]interface{}{
+		"secret/a":                  {"value": "1"},
+		"kv/a":                      {"value": "1"},
+		"auth/userpass/users/alice": {"password": "secret"},
+	}
+	for path, data := range writes {
+		if _, err := client.Logical().Write(path, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var snap bytes.Buffer
+	if err := client.Sys().RaftSnapshot(&snap); err != nil {
+		t.Fatal(err)
+	}
+
+	// The data of the mounts can be found in the snapshot offline
+	mounts, err := client.Sys().ListMounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	inspection, err := raft.InspectSnapshot(bytes.NewReader(snap.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, u := range inspection.Prefixes {
+		if u.Prefix == "logical/"+mounts["kv/"].UUID+"/" && u.Keys == 1 {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("kv mount not found in the snapshot: %#v", inspection)
+	}
+
+	// Change the data, and re-create the kv mount, which gets a new UUID
+	writes = map[string]map[string]interface{}{
+		"secret/a": {"value": "2"},
+		"secret/b": {"value": "2"},
+	}
+	for path, data := range writes {
+		if _, err := client.Logical().Write(path, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := client.Logical().Delete("auth/userpass/users/alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Sys().Unmount("kv"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Sys().Mount("kv", &api.MountInput{Type: "kv"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Logical().Write("kv/b", map[string]interface{}{"value": "2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mounts must exist in the snapshot and the cluster
+	for _, path := range []string{"nope", "sys", "auth/token"} {
+		_, err := client.Sys().RaftSnapshotRestoreMounts(bytes.NewReader(snap.Bytes()), []string{path})
+		if err == nil {
+			t.Fatalf("expected error restoring %q", path)
+		}
+	}
+
+	resp, err := client.Sys().RaftSnapshotRestoreMounts(bytes.NewReader(snap.Bytes()), []string{"secret", "kv/", "auth/userpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Keys["secret/"] != 1 || resp.Keys["kv/"] != 1 || resp.Keys["auth/userpass/"] == 0 {
+		t.Fatalf("bad response: %#v", resp)
+	}
+
+	expected := map[string]interface{}{
+		"secret/a":                  "1",
+		"secret/b":                  nil,
+		"kv/a":                      "1",
+		"kv/b":                      nil,
+		"auth/userpass/users/alice": "default",
+	}
+	for path, value := range expected {
+		secret, err := client.Logical().Read(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case value == nil && secret != nil:
+			t.Fatalf("expected %q to be deleted: %#v", path, secret)
+		case value == nil:
+		case secret == nil:
+			t.Fatalf("expected %q to be restored", path)
+		case path == "auth/userpass/users/alice":
+			if _, ok := secret.Data["token_policies"]; !ok {
+				t.Fatalf("bad user: %#v", secret.Data)
+			}
+		case secret.Data["value"] != value:
+			t.Fatalf("bad value for %q: %#v", path, secret.Data)
+		}
+	}
+
+	// The restored user can log in
+	if _, err := client.Logical().Write("auth/userpass/login/alice", map[string]interface{}{"password": "secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A snapshot taken with another keyring is rejected before the data of
+	// the mount is changed
+	otherCluster := raftCluster(t)
+	defer otherCluster.Cleanup()
+	otherClient := otherCluster.Cores[0].Client
+	if _, err := otherClient.Logical().Write("secret/c", map[string]interface{}{"value": "3"}); err != nil {
+		t.Fatal(err)
+	}
+	var otherSnap bytes.Buffer
+	if err := otherClient.Sys().RaftSnapshot(&otherSnap); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Sys().RaftSnapshotRestoreMounts(bytes.NewReader(otherSnap.Bytes()), []string{"secret"}); err == nil {
+		t.Fatal("expected error restoring a snapshot of another cluster")
+	}
+	secret, err := client.Logical().Read("secret/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret == nil || secret.Data["value"] != "1" {
+		t.Fatalf("expected secret/a to be left intact: %#v", secret)
+	}
+}
+
 func TestRaft_SnapshotAPI_RekeyRotate_Backward(t *testing.T) {
 	tCases := []struct {
 		Name   string
@@ -974,3 +1644,46 @@ func TestRaft_Join_InitStatus(t *testing.T) {
 		verifyInitStatus(i, true)
 	}
 }
+
+func TestRaft_StorageUsage(t *testing.T) {
+	t.Parallel()
+	cluster := raftCluster(t)
+	defer cluster.Cleanup()
+
+	client := cluster.Cores[0].Client
+
+	if err := client.Sys().Mount("kv", &api.MountInput{Type: "kv"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"kv/a", "kv/b"} {
+		if _, err := client.Logical().Write(path, map[string]interface{}{"value": "1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	secret, err := client.Logical().Read("sys/internal/counters/storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(secret.Data["counters"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var usage vault.StorageUsage
+	if err := json.Unmarshal(raw, &usage); err != nil {
+		t.Fatal(err)
+	}
+
+	var kvUsage *vault.MountStorageUsage
+	for _, u := range usage.Mounts {
+		if u.Path == "kv/" {
+			kvUsage = u
+		}
+	}
+	if kvUsage == nil || kvUsage.Type != "kv" || kvUsage.Keys < 2 || kvUsage.Size == 0 {
+		t.Fatalf("bad usage of the kv mount: %s", raw)
+	}
+	if usage.Total.Keys <= kvUsage.Keys || usage.Total.Size <= kvUsage.Size {
+		t.Fatalf("bad total usage: %s", raw)
+	}
+}
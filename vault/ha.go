@@ -70,6 +70,15 @@ func (c *Core) PerfStandby() bool {
 	return perfStandby
 }
 
+// RaftLocalReadStandby checks if the vault is a standby serving reads from its
+// raft FSM
+func (c *Core) RaftLocalReadStandby() bool {
+	c.stateLock.RLock()
+	localReadStandby := c.raftLocalReadStandby
+	c.stateLock.RUnlock()
+	return localReadStandby
+}
+
 func (c *Core) ActiveTime() time.Time {
 	c.stateLock.RLock()
 	activeTime := c.activeTime
@@ -379,6 +388,18 @@ func (c *Core) runStandby(doneCh, manualStepDownCh, stopCh chan struct{}) {
 			c.logger.Debug("shutting down periodic leader refresh")
 		})
 	}
+	if localReads := c.newRaftLocalReads(); localReads != nil {
+		// Serve the reads of the selected mounts from the raft FSM
+		localReadsStop := make(chan struct{})
+
+		g.Add(func() error {
+			localReads.run(localReadsStop)
+			return nil
+		}, func(error) {
+			close(localReadsStop)
+			c.logger.Debug("shutting down raft local reads")
+		})
+	}
 	{
 		// Wait for leadership
 		leaderStopCh := make(chan struct{})
@@ -465,6 +486,9 @@ func (c *Core) waitForLeadership(newLeaderCh chan func(), manualStepDownCh, stop
 			return
 		}
 
+		// Stop serving local reads before becoming active
+		c.stopRaftLocalReads()
+
 		if c.Sealed() {
 			c.logger.Warn("grabbed HA lock but already sealed, exiting")
 			lock.Unlock()
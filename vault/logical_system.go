@@ -3455,6 +3455,21 @@ func (b *SystemBackend) pathInternalCountersEntities(ctx context.Context, req *l
 	return resp, nil
 }
 
+func (b *SystemBackend) pathInternalCountersStorage(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	storageUsage, err := b.Core.countStorageUsage(ctx)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"counters": storageUsage,
+		},
+	}
+
+	return resp, nil
+}
+
 func (b *SystemBackend) pathInternalUIResultantACL(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	if req.ClientToken == "" {
 		// 204 -- no ACL
@@ -4573,6 +4588,10 @@ This path responds to the following HTTP methods.
 		"Count of active entities in this Vault cluster.",
 		"Count of active entities in this Vault cluster.",
 	},
+	"internal-counters-storage": {
+		"Storage used by the data of each mount in this Vault cluster.",
+		"Number of keys and size in bytes of the data of each secrets engine and auth method, as stored by the integrated Raft storage. Only available when Raft is used for storage.",
+	},
 	"host-info": {
 		"Information about the host instance that this Vault server is running on.",
 		`Information about the host instance that this Vault server is running on.
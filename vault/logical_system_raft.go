@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"strings"
+	"time"
 
 	proto "github.com/golang/protobuf/proto"
 	wrapping "github.com/hashicorp/go-kms-wrapping"
@@ -117,6 +118,150 @@ func (b *SystemBackend) raftStoragePaths() []*framework.Path {
 			HelpSynopsis:    strings.TrimSpace(sysRaftHelp["raft-configuration"][0]),
 			HelpDescription: strings.TrimSpace(sysRaftHelp["raft-configuration"][1]),
 		},
+		{
+			Pattern: "storage/raft/autopilot/state",
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleStorageRaftAutopilotState(),
+					Summary:  "Returns the state of the raft cluster under autopilot.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysRaftHelp["raft-autopilot-state"][0]),
+			HelpDescription: strings.TrimSpace(sysRaftHelp["raft-autopilot-state"][1]),
+		},
+		{
+			Pattern: "storage/raft/autopilot/configuration",
+
+			Fields: map[string]*framework.FieldSchema{
+				"cleanup_dead_servers": {
+					Type:        framework.TypeBool,
+					Description: "Controls whether to remove dead servers from the raft peer list periodically.",
+				},
+				"last_contact_threshold": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Limit on the amount of time a server can go without leader contact before being considered unhealthy.",
+				},
+				"dead_server_last_contact_threshold": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Limit on the amount of time a server can go without leader contact before being considered failed. This takes effect only when cleanup_dead_servers is set.",
+				},
+				"max_trailing_logs": {
+					Type:        framework.TypeInt,
+					Description: "Amount of entries in the raft log that a server can be behind before being considered unhealthy.",
+				},
+				"min_quorum": {
+					Type:        framework.TypeInt,
+					Description: "Minimum number of voters allowed in the cluster before autopilot can prune dead servers. This takes effect only when cleanup_dead_servers is set.",
+				},
+				"server_stabilization_time": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Minimum amount of time a server must be in a healthy state before it can become a voter.",
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleStorageRaftAutopilotConfigRead(),
+					Summary:  "Returns the configuration of autopilot.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleStorageRaftAutopilotConfigUpdate(),
+					Summary:  "Updates the configuration of autopilot.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysRaftHelp["raft-autopilot-configuration"][0]),
+			HelpDescription: strings.TrimSpace(sysRaftHelp["raft-autopilot-configuration"][1]),
+		},
+		{
+			Pattern: "storage/raft/snapshot-auto/config/?$",
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.handleStorageRaftSnapshotAutoConfigList(),
+					Summary:  "Lists the automated snapshot configurations.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysRaftHelp["raft-snapshot-auto-config-list"][0]),
+			HelpDescription: strings.TrimSpace(sysRaftHelp["raft-snapshot-auto-config-list"][1]),
+		},
+		{
+			Pattern: "storage/raft/snapshot-auto/config/" + framework.GenericNameRegex("name"),
+
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the automated snapshot configuration.",
+				},
+				"interval": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Time between snapshots.",
+				},
+				"retain": {
+					Type:        framework.TypeInt,
+					Default:     1,
+					Description: "Number of snapshots to keep; the oldest snapshots beyond this number are deleted after each snapshot.",
+				},
+				"path_prefix": {
+					Type:        framework.TypeString,
+					Description: "For the local storage type, the directory to write the snapshots to. For other storage types, the prefix of the snapshot objects.",
+				},
+				"file_prefix": {
+					Type:        framework.TypeString,
+					Default:     raftAutoSnapshotDefaultFilePrefix,
+					Description: "Prefix of the snapshot file or object names, within path_prefix.",
+				},
+				"storage_type": {
+					Type:        framework.TypeString,
+					Description: `Type of storage the snapshots are written to, such as "local".`,
+				},
+				"local_max_space": {
+					Type:        framework.TypeInt,
+					Description: "For the local storage type, the maximum space, in bytes, used by the snapshots.",
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleStorageRaftSnapshotAutoConfigRead(),
+					Summary:  "Returns an automated snapshot configuration.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleStorageRaftSnapshotAutoConfigUpdate(),
+					Summary:  "Creates or updates an automated snapshot configuration.",
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.handleStorageRaftSnapshotAutoConfigDelete(),
+					Summary:  "Deletes an automated snapshot configuration.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysRaftHelp["raft-snapshot-auto-config"][0]),
+			HelpDescription: strings.TrimSpace(sysRaftHelp["raft-snapshot-auto-config"][1]),
+		},
+		{
+			Pattern: "storage/raft/snapshot-auto/status/" + framework.GenericNameRegex("name"),
+
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the automated snapshot configuration.",
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleStorageRaftSnapshotAutoStatusRead(),
+					Summary:  "Returns the status of an automated snapshot configuration.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysRaftHelp["raft-snapshot-auto-status"][0]),
+			HelpDescription: strings.TrimSpace(sysRaftHelp["raft-snapshot-auto-status"][1]),
+		},
 		{
 			Pattern: "storage/raft/snapshot",
 			Operations: map[logical.Operation]framework.OperationHandler{
@@ -145,6 +290,26 @@ func (b *SystemBackend) raftStoragePaths() []*framework.Path {
 			HelpSynopsis:    strings.TrimSpace(sysRaftHelp["raft-snapshot-force"][0]),
 			HelpDescription: strings.TrimSpace(sysRaftHelp["raft-snapshot-force"][1]),
 		},
+		{
+			Pattern: "storage/raft/snapshot-restore-mounts",
+
+			Fields: map[string]*framework.FieldSchema{
+				"mounts": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Paths of the mounts to restore from the snapshot. Paths of auth mounts start with auth/.",
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleStorageRaftSnapshotRestoreMounts(),
+					Summary:  "Restores the data of the given mounts from the provided snapshot, leaving the rest of the cluster untouched.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysRaftHelp["raft-snapshot-restore-mounts"][0]),
+			HelpDescription: strings.TrimSpace(sysRaftHelp["raft-snapshot-restore-mounts"][1]),
+		},
 	}
 }
 
@@ -168,6 +333,304 @@ func (b *SystemBackend) handleRaftConfigurationGet() framework.OperationFunc {
 	}
 }
 
+func (b *SystemBackend) handleStorageRaftAutopilotState() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		raftBackend := b.Core.getRaftBackend()
+		if raftBackend == nil {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+
+		state, err := raftBackend.AutopilotState()
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+
+		servers := make(map[string]interface{}, len(state.Servers))
+		for id, server := range state.Servers {
+			var stableSince string
+			if !server.StableSince.IsZero() {
+				stableSince = server.StableSince.Format(time.RFC3339Nano)
+			}
+			servers[id] = map[string]interface{}{
+				"id":           server.ID,
+				"address":      server.Address,
+				"status":       server.Status,
+				"node_status":  server.NodeStatus,
+				"healthy":      server.Healthy,
+				"last_contact": server.LastContact.String(),
+				"last_index":   server.LastIndex,
+				"stable_since": stableSince,
+			}
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"healthy":           state.Healthy,
+				"failure_tolerance": state.FailureTolerance,
+				"leader":            state.Leader,
+				"voters":            state.Voters,
+				"servers":           servers,
+			},
+		}, nil
+	}
+}
+
+func (b *SystemBackend) handleStorageRaftAutopilotConfigRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		if b.Core.getRaftBackend() == nil {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+
+		config, err := b.Core.loadRaftAutopilotConfiguration(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"cleanup_dead_servers":               config.CleanupDeadServers,
+				"last_contact_threshold":             config.LastContactThreshold.String(),
+				"dead_server_last_contact_threshold": config.DeadServerLastContactThreshold.String(),
+				"max_trailing_logs":                  config.MaxTrailingLogs,
+				"min_quorum":                         config.MinQuorum,
+				"server_stabilization_time":          config.ServerStabilizationTime.String(),
+			},
+		}, nil
+	}
+}
+
+func (b *SystemBackend) handleStorageRaftAutopilotConfigUpdate() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		raftBackend := b.Core.getRaftBackend()
+		if raftBackend == nil {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+
+		config, err := b.Core.loadRaftAutopilotConfiguration(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if cleanupRaw, ok := d.GetOk("cleanup_dead_servers"); ok {
+			config.CleanupDeadServers = cleanupRaw.(bool)
+		}
+		if thresholdRaw, ok := d.GetOk("last_contact_threshold"); ok {
+			config.LastContactThreshold = time.Duration(thresholdRaw.(int)) * time.Second
+		}
+		if thresholdRaw, ok := d.GetOk("dead_server_last_contact_threshold"); ok {
+			config.DeadServerLastContactThreshold = time.Duration(thresholdRaw.(int)) * time.Second
+		}
+		if maxTrailingLogsRaw, ok := d.GetOk("max_trailing_logs"); ok {
+			maxTrailingLogs := maxTrailingLogsRaw.(int)
+			if maxTrailingLogs < 0 {
+				return logical.ErrorResponse("max_trailing_logs must not be negative"), logical.ErrInvalidRequest
+			}
+			config.MaxTrailingLogs = uint64(maxTrailingLogs)
+		}
+		if minQuorumRaw, ok := d.GetOk("min_quorum"); ok {
+			minQuorum := minQuorumRaw.(int)
+			if minQuorum < 0 {
+				return logical.ErrorResponse("min_quorum must not be negative"), logical.ErrInvalidRequest
+			}
+			config.MinQuorum = uint(minQuorum)
+		}
+		if stabilizationTimeRaw, ok := d.GetOk("server_stabilization_time"); ok {
+			config.ServerStabilizationTime = time.Duration(stabilizationTimeRaw.(int)) * time.Second
+		}
+
+		if err := config.Validate(); err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+
+		entry, err := logical.StorageEntryJSON(raftAutopilotStoragePath, config)
+		if err != nil {
+			return nil, err
+		}
+		if err := b.Core.barrier.Put(ctx, entry); err != nil {
+			return nil, err
+		}
+
+		raftBackend.SetAutopilotConfig(config)
+
+		return nil, nil
+	}
+}
+
+func (b *SystemBackend) handleStorageRaftSnapshotAutoConfigList() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		if b.Core.raftAutoSnapshots == nil {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+
+		names, err := b.Core.barrier.List(ctx, raftAutoSnapshotConfigPath)
+		if err != nil {
+			return nil, err
+		}
+
+		return logical.ListResponse(names), nil
+	}
+}
+
+func (b *SystemBackend) handleStorageRaftSnapshotAutoConfigRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		if b.Core.raftAutoSnapshots == nil {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+
+		config, err := b.Core.raftAutoSnapshotConfig(ctx, d.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		if config == nil {
+			return nil, nil
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"interval":        int64(config.Interval.Seconds()),
+				"retain":          config.Retain,
+				"path_prefix":     config.PathPrefix,
+				"file_prefix":     config.FilePrefix,
+				"storage_type":    config.StorageType,
+				"local_max_space": config.LocalMaxSpace,
+			},
+		}, nil
+	}
+}
+
+func (b *SystemBackend) handleStorageRaftSnapshotAutoConfigUpdate() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		if b.Core.raftAutoSnapshots == nil {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+
+		name := d.Get("name").(string)
+		config, err := b.Core.raftAutoSnapshotConfig(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if config == nil {
+			config = &raftAutoSnapshotConfig{
+				Retain:     d.Get("retain").(int),
+				FilePrefix: d.Get("file_prefix").(string),
+			}
+		}
+
+		if intervalRaw, ok := d.GetOk("interval"); ok {
+			config.Interval = time.Duration(intervalRaw.(int)) * time.Second
+		}
+		if retainRaw, ok := d.GetOk("retain"); ok {
+			config.Retain = retainRaw.(int)
+		}
+		if pathPrefixRaw, ok := d.GetOk("path_prefix"); ok {
+			config.PathPrefix = pathPrefixRaw.(string)
+		}
+		if filePrefixRaw, ok := d.GetOk("file_prefix"); ok {
+			config.FilePrefix = filePrefixRaw.(string)
+		}
+		if storageTypeRaw, ok := d.GetOk("storage_type"); ok {
+			config.StorageType = storageTypeRaw.(string)
+		}
+		if localMaxSpaceRaw, ok := d.GetOk("local_max_space"); ok {
+			config.LocalMaxSpace = int64(localMaxSpaceRaw.(int))
+		}
+
+		switch {
+		case config.Interval <= 0:
+			return logical.ErrorResponse("interval must be positive"), logical.ErrInvalidRequest
+		case config.Retain < 1:
+			return logical.ErrorResponse("retain must be at least 1"), logical.ErrInvalidRequest
+		case config.FilePrefix == "" || strings.ContainsAny(config.FilePrefix, `/\`):
+			return logical.ErrorResponse("file_prefix must be a non-empty name without path separators"), logical.ErrInvalidRequest
+		case config.StorageType == "":
+			return logical.ErrorResponse("storage_type is required"), logical.ErrInvalidRequest
+		}
+		if _, err := config.target(); err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+
+		entry, err := logical.StorageEntryJSON(raftAutoSnapshotConfigPath+name, config)
+		if err != nil {
+			return nil, err
+		}
+		if err := b.Core.barrier.Put(ctx, entry); err != nil {
+			return nil, err
+		}
+
+		if err := b.Core.raftAutoSnapshots.configure(name, config); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+}
+
+func (b *SystemBackend) handleStorageRaftSnapshotAutoConfigDelete() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		if b.Core.raftAutoSnapshots == nil {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+
+		name := d.Get("name").(string)
+		if err := b.Core.raftAutoSnapshots.configure(name, nil); err != nil {
+			return nil, err
+		}
+		if err := b.Core.barrier.Delete(ctx, raftAutoSnapshotConfigPath+name); err != nil {
+			return nil, err
+		}
+		if err := b.Core.barrier.Delete(ctx, raftAutoSnapshotStatusPath+name); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+}
+
+func (b *SystemBackend) handleStorageRaftSnapshotAutoStatusRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		if b.Core.raftAutoSnapshots == nil {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+
+		name := d.Get("name").(string)
+		config, err := b.Core.raftAutoSnapshotConfig(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if config == nil {
+			return nil, nil
+		}
+
+		status, err := b.Core.raftAutoSnapshotStatus(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if status == nil {
+			status = &raftAutoSnapshotStatus{}
+		}
+
+		formatTime := func(t time.Time) string {
+			if t.IsZero() {
+				return ""
+			}
+			return t.Format(time.RFC3339)
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"snapshot_start":      formatTime(status.SnapshotStart),
+				"snapshot_url":        status.SnapshotURL,
+				"snapshot_size":       status.SnapshotSize,
+				"last_snapshot_start": formatTime(status.LastSnapshotStart),
+				"last_snapshot_end":   formatTime(status.LastSnapshotEnd),
+				"last_snapshot_url":   status.LastSnapshotURL,
+				"last_snapshot_error": status.LastSnapshotError,
+				"consecutive_errors":  status.ConsecutiveErrors,
+			},
+		}, nil
+	}
+}
+
 func (b *SystemBackend) handleRaftRemovePeerUpdate() framework.OperationFunc {
 	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 		serverID := d.Get("server_id").(string)
@@ -183,8 +646,11 @@ func (b *SystemBackend) handleRaftRemovePeerUpdate() framework.OperationFunc {
 		if err := raftBackend.RemovePeer(ctx, serverID); err != nil {
 			return nil, err
 		}
+		if err := b.Core.barrier.Delete(ctx, raftNonVotersStoragePath+serverID); err != nil {
+			return nil, err
+		}
 		if b.Core.raftFollowerStates != nil {
-			b.Core.raftFollowerStates.delete(serverID)
+			b.Core.raftFollowerStates.Delete(serverID)
 		}
 
 		return nil, nil
@@ -286,6 +752,12 @@ func (b *SystemBackend) handleRaftBootstrapAnswerWrite() framework.OperationFunc
 			return nil, errors.New("could not decode raft TLS configuration")
 		}
 
+		// Record non-voters before adding them, so that autopilot never
+		// promotes them
+		if err := b.Core.setRaftNonVoter(ctx, serverID, nonVoter); err != nil {
+			return nil, err
+		}
+
 		switch nonVoter {
 		case true:
 			err = raftBackend.AddNonVotingPeer(ctx, serverID, clusterAddr)
@@ -297,7 +769,7 @@ func (b *SystemBackend) handleRaftBootstrapAnswerWrite() framework.OperationFunc
 		}
 
 		if b.Core.raftFollowerStates != nil {
-			b.Core.raftFollowerStates.update(serverID, 0)
+			b.Core.raftFollowerStates.Update(serverID, 0)
 		}
 
 		peers, err := raftBackend.Peers(ctx)
@@ -335,6 +807,52 @@ func (b *SystemBackend) handleStorageRaftSnapshotRead() framework.OperationFunc
 	}
 }
 
+func (b *SystemBackend) handleStorageRaftSnapshotRestoreMounts() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		raftStorage, ok := b.Core.underlyingPhysical.(*raft.RaftBackend)
+		if !ok {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+		if req.HTTPRequest == nil || req.HTTPRequest.Body == nil {
+			return nil, errors.New("no reader for request")
+		}
+
+		mounts := d.Get("mounts").([]string)
+		if len(mounts) == 0 {
+			return logical.ErrorResponse("at least one mount must be provided"), logical.ErrInvalidRequest
+		}
+
+		// The mount data is decrypted with the barrier, so the snapshot must
+		// have been taken with the same keys
+		snapFile, cleanup, _, err := raftStorage.WriteSnapshotToTemp(req.HTTPRequest.Body, b.Core.seal.GetAccess())
+		switch {
+		case err == nil:
+		case strings.Contains(err.Error(), "failed to open the sealed hashes"):
+			switch b.Core.seal.BarrierType() {
+			case wrapping.Shamir:
+				return logical.ErrorResponse("could not verify hash file, possibly the snapshot is using a different set of unseal keys"), logical.ErrInvalidRequest
+			default:
+				return logical.ErrorResponse("could not verify hash file, possibly the snapshot is using a different autoseal key"), logical.ErrInvalidRequest
+			}
+		case err != nil:
+			b.Core.logger.Error("raft snapshot restore: failed to write snapshot", "error", err)
+			return nil, err
+		}
+		defer cleanup()
+
+		keys, err := b.Core.restoreRaftSnapshotMounts(ctx, snapFile, mounts)
+		if err != nil {
+			return handleError(err)
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"keys": keys,
+			},
+		}, nil
+	}
+}
+
 func (b *SystemBackend) handleStorageRaftSnapshotWrite(force bool) framework.OperationFunc {
 	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 		raftStorage, ok := b.Core.underlyingPhysical.(*raft.RaftBackend)
@@ -467,6 +985,34 @@ var sysRaftHelp = map[string][2]string{
 		"Removes a peer from the raft cluster.",
 		"",
 	},
+	"raft-autopilot-state": {
+		"Returns the state of the raft cluster under autopilot.",
+		`Reports the health of each server in the raft cluster, as tracked by
+		autopilot on the active node, along with the failure tolerance of the
+		cluster.`,
+	},
+	"raft-autopilot-configuration": {
+		"Reads and updates the configuration of autopilot.",
+		`Autopilot tracks the health of the servers in the raft cluster,
+		promotes new servers to voters once they are stable, and, when
+		cleanup_dead_servers is set, removes servers which have not been in
+		contact for dead_server_last_contact_threshold.`,
+	},
+	"raft-snapshot-auto-config-list": {
+		"Lists the automated snapshot configurations.",
+		"",
+	},
+	"raft-snapshot-auto-config": {
+		"Reads, creates, updates and deletes automated snapshot configurations.",
+		`The active node takes a snapshot of the raft storage every interval
+		of each configuration and writes it to the configured storage type,
+		keeping the most recent retain snapshots.`,
+	},
+	"raft-snapshot-auto-status": {
+		"Returns the status of an automated snapshot configuration.",
+		`Reports the last successful snapshot, with its URL and size, and the
+		outcome of the last attempt.`,
+	},
 	"raft-snapshot": {
 		"Restores and saves snapshots from the raft cluster.",
 		"",
@@ -475,4 +1021,11 @@ var sysRaftHelp = map[string][2]string{
 		"Force restore a raft cluster snapshot",
 		"",
 	},
+	"raft-snapshot-restore-mounts": {
+		"Restores the data of selected mounts from a raft cluster snapshot",
+		`The snapshot is sent as the request body, and the mounts to restore as
+the "mounts" query parameter. The current data of the mounts is replaced with
+their data in the snapshot, while the rest of the cluster is left untouched.
+The mounts must already be enabled, with the same type as in the snapshot.`,
+	},
 }
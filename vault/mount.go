@@ -997,9 +997,10 @@ func (c *Core) loadMounts(ctx context.Context) error {
 		}
 	}
 
-	// If this node is a performance standby we do not want to attempt to
-	// upgrade the mount table, this will be the active node's responsibility.
-	if !c.perfStandby {
+	// If this node is a performance standby or serves local reads we do not
+	// want to attempt to upgrade the mount table, this will be the active
+	// node's responsibility.
+	if !c.perfStandby && !c.raftLocalReadStandby {
 		err := c.runMountUpdates(ctx, needPersist)
 		if err != nil {
 			c.logger.Error("failed to run mount table upgrades", "error", err)
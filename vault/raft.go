@@ -5,7 +5,6 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"math"
 	"net/http"
 	"net/url"
 	"strings"
@@ -32,56 +31,15 @@ import (
 )
 
 var (
-	raftTLSStoragePath    = "core/raft/tls"
-	raftTLSRotationPeriod = 24 * time.Hour
+	raftTLSStoragePath       = "core/raft/tls"
+	raftTLSRotationPeriod    = 24 * time.Hour
+	raftAutopilotStoragePath = "core/raft/autopilot/configuration"
+	raftNonVotersStoragePath = "core/raft/non-voters/"
 
 	// TestingUpdateClusterAddr is used in tests to override the cluster address
 	TestingUpdateClusterAddr uint32
 )
 
-type raftFollowerStates struct {
-	l         sync.RWMutex
-	followers map[string]uint64
-}
-
-func (s *raftFollowerStates) update(nodeID string, appliedIndex uint64) {
-	s.l.Lock()
-	s.followers[nodeID] = appliedIndex
-	s.l.Unlock()
-}
-func (s *raftFollowerStates) delete(nodeID string) {
-	s.l.RLock()
-	delete(s.followers, nodeID)
-	s.l.RUnlock()
-}
-func (s *raftFollowerStates) get(nodeID string) uint64 {
-	s.l.RLock()
-	index := s.followers[nodeID]
-	s.l.RUnlock()
-	return index
-}
-func (s *raftFollowerStates) minIndex() uint64 {
-	var min uint64 = math.MaxUint64
-	minFunc := func(a, b uint64) uint64 {
-		if a > b {
-			return b
-		}
-		return a
-	}
-
-	s.l.RLock()
-	for _, i := range s.followers {
-		min = minFunc(min, i)
-	}
-	s.l.RUnlock()
-
-	if min == math.MaxUint64 {
-		return 0
-	}
-
-	return min
-}
-
 func (c *Core) GetRaftIndexes() (committed uint64, applied uint64) {
 	c.stateLock.RLock()
 	defer c.stateLock.RUnlock()
@@ -199,14 +157,102 @@ func (c *Core) startRaftBackend(ctx context.Context) (retErr error) {
 
 func (c *Core) setupRaftActiveNode(ctx context.Context) error {
 	c.pendingRaftPeers = &sync.Map{}
-	return c.startPeriodicRaftTLSRotate(ctx)
+	if err := c.startPeriodicRaftTLSRotate(ctx); err != nil {
+		return err
+	}
+	if err := c.loadRaftNonVoters(ctx); err != nil {
+		return err
+	}
+	if err := c.startRaftAutopilot(ctx); err != nil {
+		return err
+	}
+	return c.startRaftAutoSnapshots(ctx)
 }
 
 func (c *Core) stopRaftActiveNode() {
 	c.pendingRaftPeers = nil
+	c.stopRaftAutoSnapshots()
+	c.stopRaftAutopilot()
 	c.stopPeriodicRaftTLSRotate()
 }
 
+// startRaftAutopilot starts autopilot on the raft backend with the stored
+// configuration. Autopilot relies on the follower heartbeats, so it is not
+// run when raft is used for HA-only.
+func (c *Core) startRaftAutopilot(ctx context.Context) error {
+	raftBackend := c.getRaftBackend()
+	if raftBackend == nil || c.raftFollowerStates == nil || !raftBackend.AutopilotEnabled() {
+		return nil
+	}
+
+	config, err := c.loadRaftAutopilotConfiguration(ctx)
+	if err != nil {
+		return err
+	}
+
+	raftBackend.StartAutopilot(c.raftFollowerStates, config)
+	return nil
+}
+
+func (c *Core) stopRaftAutopilot() {
+	if raftBackend := c.getRaftBackend(); raftBackend != nil {
+		raftBackend.StopAutopilot()
+	}
+}
+
+// setRaftNonVoter records whether the given node joined the raft cluster as
+// a non-voter, in which case autopilot never promotes it.
+func (c *Core) setRaftNonVoter(ctx context.Context, nodeID string, nonVoter bool) error {
+	if nonVoter {
+		if err := c.barrier.Put(ctx, &logical.StorageEntry{Key: raftNonVotersStoragePath + nodeID}); err != nil {
+			return err
+		}
+	} else {
+		if err := c.barrier.Delete(ctx, raftNonVotersStoragePath+nodeID); err != nil {
+			return err
+		}
+	}
+
+	if c.raftFollowerStates != nil {
+		c.raftFollowerStates.SetNonVoter(nodeID, nonVoter)
+	}
+	return nil
+}
+
+// loadRaftNonVoters marks the stored non-voters in the follower states.
+func (c *Core) loadRaftNonVoters(ctx context.Context) error {
+	if c.raftFollowerStates == nil {
+		return nil
+	}
+
+	nodeIDs, err := c.barrier.List(ctx, raftNonVotersStoragePath)
+	if err != nil {
+		return errwrap.Wrapf("failed to list raft non-voters: {{err}}", err)
+	}
+	for _, nodeID := range nodeIDs {
+		c.raftFollowerStates.SetNonVoter(nodeID, true)
+	}
+	return nil
+}
+
+// loadRaftAutopilotConfiguration returns the stored autopilot configuration,
+// or the default one if none is stored.
+func (c *Core) loadRaftAutopilotConfiguration(ctx context.Context) (*raft.AutopilotConfig, error) {
+	entry, err := c.barrier.Get(ctx, raftAutopilotStoragePath)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return raft.DefaultAutopilotConfig(), nil
+	}
+
+	var config raft.AutopilotConfig
+	if err := entry.DecodeJSON(&config); err != nil {
+		return nil, errwrap.Wrapf("failed to decode raft autopilot configuration: {{err}}", err)
+	}
+	return &config, nil
+}
+
 func (c *Core) startPeriodicRaftTLSRotate(ctx context.Context) error {
 	raftBackend := c.getRaftBackend()
 
@@ -334,9 +380,7 @@ func (c *Core) raftTLSRotateDirect(ctx context.Context, logger hclog.Logger, sto
 // to reconnect with the cluster. Additionally, only one outstanding key
 // is allowed for this same reason (max keyring size of 2).
 func (c *Core) raftTLSRotatePhased(ctx context.Context, logger hclog.Logger, raftBackend *raft.RaftBackend, stopCh chan struct{}) error {
-	followerStates := &raftFollowerStates{
-		followers: make(map[string]uint64),
-	}
+	followerStates := raft.NewFollowerStates()
 
 	// Pre-populate the follower list with the set of peers.
 	raftConfig, err := raftBackend.GetConfiguration(ctx)
@@ -345,7 +389,7 @@ func (c *Core) raftTLSRotatePhased(ctx context.Context, logger hclog.Logger, raf
 	}
 	for _, server := range raftConfig.Servers {
 		if server.NodeID != raftBackend.NodeID() {
-			followerStates.update(server.NodeID, 0)
+			followerStates.Update(server.NodeID, 0)
 		}
 	}
 	c.raftFollowerStates = followerStates
@@ -437,7 +481,7 @@ func (c *Core) raftTLSRotatePhased(ctx context.Context, logger hclog.Logger, raf
 		case keyring.Keys[1].AppliedIndex != keyring.AppliedIndex:
 			// We haven't fully committed the new key, continue here
 			return nil
-		case followerStates.minIndex() < keyring.AppliedIndex:
+		case followerStates.MinIndex() < keyring.AppliedIndex:
 			// Not all the followers have applied the latest key
 			return nil
 		}
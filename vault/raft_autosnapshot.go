@@ -0,0 +1,369 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/physical/raft"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	raftAutoSnapshotConfigPath = "core/raft/snapshot-auto/config/"
+	raftAutoSnapshotStatusPath = "core/raft/snapshot-auto/status/"
+
+	raftAutoSnapshotDefaultFilePrefix = "vault-snapshot"
+)
+
+// raftAutoSnapshotConfig is a named configuration of automated snapshots.
+type raftAutoSnapshotConfig struct {
+	Interval    time.Duration `json:"interval"`
+	Retain      int           `json:"retain"`
+	PathPrefix  string        `json:"path_prefix"`
+	FilePrefix  string        `json:"file_prefix"`
+	StorageType string        `json:"storage_type"`
+
+	// LocalMaxSpace is the maximum space used by snapshots in the local
+	// storage type.
+	LocalMaxSpace int64 `json:"local_max_space"`
+}
+
+// target returns the snapshot target the configuration writes to.
+func (c *raftAutoSnapshotConfig) target() (raft.SnapshotTarget, error) {
+	conf := make(map[string]string)
+	if c.StorageType == raft.SnapshotTargetLocal {
+		conf["local_max_space"] = strconv.FormatInt(c.LocalMaxSpace, 10)
+		// Only the snapshots kept by the retention once a new one is
+		// written count towards the space, otherwise a full allowance would
+		// prevent the snapshot making room for itself
+		conf["file_prefix"] = c.FilePrefix
+		conf["retain"] = strconv.Itoa(c.Retain)
+	}
+	return raft.NewSnapshotTarget(c.StorageType, c.PathPrefix, conf)
+}
+
+// raftAutoSnapshotStatus records the outcome of the automated snapshots of a
+// configuration. The Snapshot fields describe the last successful snapshot,
+// and the LastSnapshot fields the last attempt.
+type raftAutoSnapshotStatus struct {
+	SnapshotStart time.Time `json:"snapshot_start"`
+	SnapshotURL   string    `json:"snapshot_url"`
+	SnapshotSize  int64     `json:"snapshot_size"`
+
+	LastSnapshotStart time.Time `json:"last_snapshot_start"`
+	LastSnapshotEnd   time.Time `json:"last_snapshot_end"`
+	LastSnapshotURL   string    `json:"last_snapshot_url"`
+	LastSnapshotError string    `json:"last_snapshot_error"`
+
+	ConsecutiveErrors int `json:"consecutive_errors"`
+}
+
+// raftAutoSnapshots runs the automated snapshot configurations on the active
+// node.
+type raftAutoSnapshots struct {
+	core   *Core
+	logger hclog.Logger
+	ctx    context.Context
+
+	l       sync.Mutex
+	runners map[string]*raftAutoSnapshotRunner
+}
+
+type raftAutoSnapshotRunner struct {
+	name   string
+	config *raftAutoSnapshotConfig
+	target raft.SnapshotTarget
+
+	// lastAttempt is when the last snapshot was attempted, which the next one
+	// is scheduled from.
+	lastAttempt time.Time
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// startRaftAutoSnapshots starts the stored automated snapshot configurations.
+// Snapshots are only available when raft is the storage backend.
+func (c *Core) startRaftAutoSnapshots(ctx context.Context) error {
+	raftBackend, ok := c.underlyingPhysical.(*raft.RaftBackend)
+	if !ok || raftBackend == nil {
+		return nil
+	}
+
+	a := &raftAutoSnapshots{
+		core:    c,
+		logger:  c.logger.Named("raft").Named("snapshot-auto"),
+		ctx:     ctx,
+		runners: make(map[string]*raftAutoSnapshotRunner),
+	}
+
+	names, err := c.barrier.List(ctx, raftAutoSnapshotConfigPath)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		config, err := c.raftAutoSnapshotConfig(ctx, name)
+		if err != nil {
+			return err
+		}
+		if config == nil {
+			continue
+		}
+		if err := a.configure(name, config); err != nil {
+			a.logger.Error("failed to start automated snapshots", "name", name, "error", err)
+		}
+	}
+
+	c.raftAutoSnapshots = a
+	return nil
+}
+
+func (c *Core) stopRaftAutoSnapshots() {
+	if c.raftAutoSnapshots == nil {
+		return
+	}
+	c.raftAutoSnapshots.stop()
+	c.raftAutoSnapshots = nil
+}
+
+func (c *Core) raftAutoSnapshotConfig(ctx context.Context, name string) (*raftAutoSnapshotConfig, error) {
+	entry, err := c.barrier.Get(ctx, raftAutoSnapshotConfigPath+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var config raftAutoSnapshotConfig
+	if err := entry.DecodeJSON(&config); err != nil {
+		return nil, errwrap.Wrapf("failed to decode automated snapshot configuration: {{err}}", err)
+	}
+	return &config, nil
+}
+
+func (c *Core) raftAutoSnapshotStatus(ctx context.Context, name string) (*raftAutoSnapshotStatus, error) {
+	entry, err := c.barrier.Get(ctx, raftAutoSnapshotStatusPath+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var status raftAutoSnapshotStatus
+	if err := entry.DecodeJSON(&status); err != nil {
+		return nil, errwrap.Wrapf("failed to decode automated snapshot status: {{err}}", err)
+	}
+	return &status, nil
+}
+
+// configure starts taking snapshots with the given configuration, replacing
+// any previous configuration of the same name. A nil configuration stops the
+// snapshots.
+func (a *raftAutoSnapshots) configure(name string, config *raftAutoSnapshotConfig) error {
+	a.l.Lock()
+	defer a.l.Unlock()
+
+	var lastAttempt time.Time
+	if runner, ok := a.runners[name]; ok {
+		runner.stop()
+		lastAttempt = runner.lastAttempt
+		delete(a.runners, name)
+	}
+
+	if config == nil {
+		return nil
+	}
+
+	target, err := config.target()
+	if err != nil {
+		return err
+	}
+
+	if lastAttempt.IsZero() {
+		// Schedule from the last snapshot taken, possibly by a previous
+		// active node, so that a leadership change doesn't trigger one
+		status, err := a.core.raftAutoSnapshotStatus(a.ctx, name)
+		if err != nil {
+			return err
+		}
+		if status != nil {
+			lastAttempt = status.LastSnapshotStart
+		}
+	}
+
+	runner := &raftAutoSnapshotRunner{
+		name:        name,
+		config:      config,
+		target:      target,
+		lastAttempt: lastAttempt,
+		stopCh:      make(chan struct{}),
+		doneCh:      make(chan struct{}),
+	}
+	a.runners[name] = runner
+	go a.run(runner)
+
+	return nil
+}
+
+func (a *raftAutoSnapshots) stop() {
+	a.l.Lock()
+	defer a.l.Unlock()
+
+	for name, runner := range a.runners {
+		runner.stop()
+		delete(a.runners, name)
+	}
+}
+
+func (r *raftAutoSnapshotRunner) stop() {
+	close(r.stopCh)
+	<-r.doneCh
+}
+
+func (a *raftAutoSnapshots) run(runner *raftAutoSnapshotRunner) {
+	defer close(runner.doneCh)
+
+	for {
+		wait := time.Until(runner.lastAttempt.Add(runner.config.Interval))
+		if wait < 0 {
+			wait = 0
+		}
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-runner.stopCh:
+			timer.Stop()
+			return
+		case <-a.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		runner.lastAttempt = time.Now()
+		if err := a.snapshot(runner); err != nil {
+			a.logger.Error("failed to take automated snapshot", "name", runner.name, "error", err)
+		}
+	}
+}
+
+// snapshot takes a snapshot, writes it to the target of the runner, deletes
+// the snapshots beyond the retention and records the outcome in the status.
+func (a *raftAutoSnapshots) snapshot(runner *raftAutoSnapshotRunner) error {
+	defer metrics.MeasureSince([]string{"raft", "snapshot-auto", "duration"}, time.Now())
+
+	status, err := a.core.raftAutoSnapshotStatus(a.ctx, runner.name)
+	if err != nil {
+		return err
+	}
+	if status == nil {
+		status = &raftAutoSnapshotStatus{}
+	}
+
+	start := runner.lastAttempt
+	name := fmt.Sprintf("%s-%d.snap", runner.config.FilePrefix, start.UnixNano())
+
+	url, size, snapErr := a.writeSnapshot(runner, name)
+
+	status.LastSnapshotStart = start
+	status.LastSnapshotEnd = time.Now()
+	status.LastSnapshotURL = url
+	status.LastSnapshotError = ""
+	switch snapErr {
+	case nil:
+		status.SnapshotStart = start
+		status.SnapshotURL = url
+		status.SnapshotSize = size
+		status.ConsecutiveErrors = 0
+
+		a.logger.Info("took automated snapshot", "name", runner.name, "url", url, "size", size)
+		metrics.IncrCounterWithLabels([]string{"raft", "snapshot-auto", "success"}, 1, []metrics.Label{{"name", runner.name}})
+
+		if err := a.applyRetention(runner); err != nil {
+			a.logger.Error("failed to delete old automated snapshots", "name", runner.name, "error", err)
+		}
+	default:
+		status.LastSnapshotError = snapErr.Error()
+		status.ConsecutiveErrors++
+		metrics.IncrCounterWithLabels([]string{"raft", "snapshot-auto", "error"}, 1, []metrics.Label{{"name", runner.name}})
+	}
+
+	entry, err := logical.StorageEntryJSON(raftAutoSnapshotStatusPath+runner.name, status)
+	if err != nil {
+		return err
+	}
+	if err := a.core.barrier.Put(a.ctx, entry); err != nil {
+		return errwrap.Wrapf("failed to store automated snapshot status: {{err}}", err)
+	}
+
+	return snapErr
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (a *raftAutoSnapshots) writeSnapshot(runner *raftAutoSnapshotRunner, name string) (string, int64, error) {
+	raftBackend, ok := a.core.underlyingPhysical.(*raft.RaftBackend)
+	if !ok {
+		return "", 0, fmt.Errorf("raft storage is not in use")
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(raftBackend.Snapshot(pw, a.core.seal.GetAccess()))
+	}()
+
+	counter := &countingReader{r: pr}
+	url, err := runner.target.Put(a.ctx, name, counter)
+	// Unblock the snapshot if the target stopped reading early
+	pr.CloseWithError(io.ErrClosedPipe)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return url, counter.n, nil
+}
+
+// applyRetention deletes the oldest snapshots of the configuration beyond the
+// number to retain.
+func (a *raftAutoSnapshots) applyRetention(runner *raftAutoSnapshotRunner) error {
+	all, err := runner.target.List(a.ctx, runner.config.FilePrefix+"-")
+	if err != nil {
+		return err
+	}
+
+	// Leave alone other files sharing the prefix
+	var entries []*raft.SnapshotTargetEntry
+	for _, entry := range all {
+		if strings.HasSuffix(entry.Name, ".snap") {
+			entries = append(entries, entry)
+		}
+	}
+
+	for len(entries) > runner.config.Retain {
+		if err := runner.target.Delete(a.ctx, entries[0].Name); err != nil {
+			return err
+		}
+		entries = entries[1:]
+	}
+	return nil
+}
@@ -0,0 +1,433 @@
+package vault
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/physical/raft"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+)
+
+var (
+	// raftLocalReadsCheckInterval is how often a standby configured with
+	// local read mounts checks whether it needs to set up the local reads,
+	// e.g. after a failed attempt or after stepping down from active duty.
+	raftLocalReadsCheckInterval = 2 * time.Second
+
+	// raftLocalReadsMaxPending is the number of keys pending invalidation
+	// past which the local reads are reloaded instead.
+	raftLocalReadsMaxPending = 10000
+
+	// raftLocalReadsReloadKeys are the keys whose modification requires the
+	// local reads to be reloaded.
+	raftLocalReadsReloadKeys = []string{
+		coreMountConfigPath,
+		coreLocalMountConfigPath,
+		coreAuthConfigPath,
+		coreLocalAuthConfigPath,
+		coreAuditConfigPath,
+		coreLocalAuditConfigPath,
+	}
+)
+
+// raftLocalReads serves the reads of selected mounts on a standby from its
+// raft FSM. While the local reads are set up, the node only has the selected
+// mounts, along with the policies, tokens and identities needed to serve them,
+// loaded from the FSM, and their in-memory state is invalidated as the FSM
+// applies logs. Requests which can't be served locally are forwarded to the
+// active node.
+type raftLocalReads struct {
+	core    *Core
+	backend *raft.RaftBackend
+	logger  hclog.Logger
+
+	// enabled is set while the local reads are set up. Keys are only queued
+	// for invalidation while it is.
+	enabled uint32
+
+	// l protects pending and reload, which are filled by the FSM as it
+	// applies logs, and notifyCh signals that they were.
+	l        sync.Mutex
+	pending  []string
+	reload   bool
+	notifyCh chan struct{}
+
+	// cancel cancels the context of the requests served locally. It's only
+	// accessed with the state lock held.
+	cancel context.CancelFunc
+}
+
+// newRaftLocalReads returns the local reads of this node, or nil if it
+// doesn't use raft storage or has no local read mounts configured.
+func (c *Core) newRaftLocalReads() *raftLocalReads {
+	raftBackend, ok := c.underlyingPhysical.(*raft.RaftBackend)
+	if !ok || len(raftBackend.LocalReadMounts()) == 0 {
+		return nil
+	}
+
+	return &raftLocalReads{
+		core:     c,
+		backend:  raftBackend,
+		logger:   c.logger.Named("raft-local-reads"),
+		notifyCh: make(chan struct{}, 1),
+	}
+}
+
+// run sets up the local reads whenever this node is a standby, and
+// invalidates their state as logs are applied, until stopCh is closed.
+func (l *raftLocalReads) run(stopCh chan struct{}) {
+	l.backend.SetInvalidateCallback(l.invalidated)
+	defer l.backend.SetInvalidateCallback(nil)
+
+	ticker := time.NewTicker(raftLocalReadsCheckInterval)
+	defer ticker.Stop()
+
+	l.check(stopCh)
+	for {
+		select {
+		case <-stopCh:
+			// The standby is stopped while sealing, which holds the state
+			// lock until we're done, so tear down without grabbing it.
+			if l.core.raftLocalReads == l {
+				l.teardown()
+			}
+			return
+		case <-ticker.C:
+			l.check(stopCh)
+		case <-l.notifyCh:
+			l.invalidatePending(stopCh)
+		}
+	}
+}
+
+// check sets up the local reads if this node is an unsealed standby which
+// isn't serving them yet.
+func (l *raftLocalReads) check(stopCh chan struct{}) {
+	c := l.core
+
+	// Avoid grabbing the state lock for writing unless needed, since it
+	// blocks requests
+	if stopped := grabLockOrStop(c.stateLock.RLock, c.stateLock.RUnlock, stopCh); stopped {
+		return
+	}
+	needed := c.standby && c.raftLocalReads == nil && !c.Sealed()
+	c.stateLock.RUnlock()
+	if !needed {
+		return
+	}
+
+	if stopped := grabLockOrStop(c.stateLock.Lock, c.stateLock.Unlock, stopCh); stopped {
+		return
+	}
+	defer c.stateLock.Unlock()
+
+	if !c.standby || c.raftLocalReads != nil || c.Sealed() {
+		return
+	}
+	if err := l.setup(); err != nil {
+		l.logger.Error("failed to set up local reads, forwarding requests to the active node", "error", err)
+	}
+}
+
+// setup loads the state needed to serve requests from the FSM. It must be
+// called with the state lock held.
+func (l *raftLocalReads) setup() (retErr error) {
+	c := l.core
+
+	ctx, cancel := context.WithCancel(namespace.RootContext(nil))
+	c.activeContext = ctx
+	c.activeContextCancelFunc.Store(cancel)
+	l.cancel = cancel
+
+	c.raftLocalReadStandby = true
+	c.postUnsealFuncs = nil
+	c.raftLocalReads = l
+
+	// Queue the keys applied from now on, so that none of the changes made
+	// while loading are missed
+	atomic.StoreUint32(&l.enabled, 1)
+
+	defer func() {
+		if retErr != nil {
+			l.teardown()
+		}
+	}()
+
+	if err := c.setupPluginCatalog(ctx); err != nil {
+		return err
+	}
+	if err := c.loadMounts(ctx); err != nil {
+		return err
+	}
+	if err := c.loadCredentials(ctx); err != nil {
+		return err
+	}
+	l.filterMounts()
+	if err := c.setupMounts(ctx); err != nil {
+		return err
+	}
+	if err := c.setupPolicyStore(ctx); err != nil {
+		return err
+	}
+	if err := c.setupCredentials(ctx); err != nil {
+		return err
+	}
+
+	// Leases are looked up to validate tokens, but only the active node
+	// restores and expires them
+	c.metricsMutex.Lock()
+	expLogger := c.baseLogger.Named("expiration")
+	c.AddLogger(expLogger)
+	c.expiration = NewExpirationManager(c, c.systemBarrierView.SubView(expirationSubPath), expireLeaseStrategyRevoke, expLogger)
+	atomic.StoreInt32(c.expiration.restoreMode, 0)
+	c.metricsMutex.Unlock()
+	c.tokenStore.SetExpirationManager(c.expiration)
+
+	if err := c.loadAudits(ctx); err != nil {
+		return err
+	}
+	if err := c.setupAudits(ctx); err != nil {
+		return err
+	}
+	if err := c.loadIdentityStoreArtifacts(ctx); err != nil {
+		return err
+	}
+	if err := c.setupAuditedHeadersConfig(ctx); err != nil {
+		return err
+	}
+
+	for _, v := range c.postUnsealFuncs {
+		v()
+	}
+	c.postUnsealFuncs = nil
+
+	l.logger.Info("serving local reads", "mounts", l.backend.LocalReadMounts())
+	return nil
+}
+
+// filterMounts removes from the loaded mount tables all the mounts but the
+// local read mounts and the singleton mounts needed to serve them, such as
+// the token store and the identity store. No other backend is set up on the
+// standby, since their requests are always forwarded. It must be called with
+// the state lock held.
+func (l *raftLocalReads) filterMounts() {
+	c := l.core
+	localReadMounts := l.backend.LocalReadMounts()
+
+	c.mountsLock.Lock()
+	mounts := c.mounts.shallowClone()
+	mounts.Entries = mounts.Entries[:0]
+	for _, entry := range c.mounts.Entries {
+		if strutil.StrListContains(singletonMounts, entry.Type) || strutil.StrListContains(localReadMounts, entry.Path) {
+			mounts.Entries = append(mounts.Entries, entry)
+		}
+	}
+	c.mounts = mounts
+	c.mountsLock.Unlock()
+
+	c.authLock.Lock()
+	auth := c.auth.shallowClone()
+	auth.Entries = auth.Entries[:0]
+	for _, entry := range c.auth.Entries {
+		if strutil.StrListContains(singletonMounts, entry.Type) || strutil.StrListContains(localReadMounts, credentialRoutePrefix+entry.Path) {
+			auth.Entries = append(auth.Entries, entry)
+		}
+	}
+	c.auth = auth
+	c.authLock.Unlock()
+}
+
+// teardown reverses setup. It must be called with the state lock held.
+func (l *raftLocalReads) teardown() {
+	c := l.core
+
+	atomic.StoreUint32(&l.enabled, 0)
+	l.l.Lock()
+	l.pending = nil
+	l.reload = false
+	l.l.Unlock()
+
+	if l.cancel != nil {
+		l.cancel()
+		l.cancel = nil
+	}
+	c.raftLocalReadStandby = false
+	c.postUnsealFuncs = nil
+
+	var result error
+	if err := c.teardownAudits(); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("error tearing down audits: {{err}}", err))
+	}
+	if err := c.stopExpiration(); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("error stopping expiration: {{err}}", err))
+	}
+	if err := c.teardownCredentials(context.Background()); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("error tearing down credentials: {{err}}", err))
+	}
+	if err := c.teardownPolicyStore(); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("error tearing down policy store: {{err}}", err))
+	}
+	if err := c.unloadMounts(context.Background()); err != nil {
+		result = multierror.Append(result, errwrap.Wrapf("error unloading mounts: {{err}}", err))
+	}
+	if result != nil {
+		l.logger.Error("failed to tear down local reads", "error", result)
+	}
+
+	c.raftLocalReads = nil
+	l.logger.Info("stopped serving local reads")
+}
+
+// invalidated is the FSM callback queueing the keys modified by the applied
+// logs, or a reload when the FSM was replaced by a snapshot. It doesn't block.
+func (l *raftLocalReads) invalidated(keys []string) {
+	if atomic.LoadUint32(&l.enabled) == 0 {
+		return
+	}
+
+	l.l.Lock()
+	switch {
+	case keys == nil, len(l.pending)+len(keys) > raftLocalReadsMaxPending:
+		l.pending = nil
+		l.reload = true
+	case !l.reload:
+		l.pending = append(l.pending, keys...)
+	}
+	l.l.Unlock()
+
+	select {
+	case l.notifyCh <- struct{}{}:
+	default:
+	}
+}
+
+// invalidatePending invalidates the state of the pending keys, or reloads
+// the local reads if needed.
+func (l *raftLocalReads) invalidatePending(stopCh chan struct{}) {
+	c := l.core
+
+	l.l.Lock()
+	keys, reload := l.pending, l.reload
+	l.pending, l.reload = nil, false
+	l.l.Unlock()
+
+	for _, key := range keys {
+		if strutil.StrListContains(raftLocalReadsReloadKeys, key) {
+			reload = true
+			break
+		}
+	}
+
+	if reload {
+		if stopped := grabLockOrStop(c.stateLock.Lock, c.stateLock.Unlock, stopCh); stopped {
+			return
+		}
+		defer c.stateLock.Unlock()
+
+		if c.raftLocalReads != l {
+			return
+		}
+
+		l.logger.Debug("reloading local reads")
+		l.teardown()
+		if err := l.setup(); err != nil {
+			l.logger.Error("failed to reload local reads, forwarding requests to the active node", "error", err)
+		}
+		return
+	}
+
+	if stopped := grabLockOrStop(c.stateLock.RLock, c.stateLock.RUnlock, stopCh); stopped {
+		return
+	}
+	defer c.stateLock.RUnlock()
+
+	if c.raftLocalReads != l {
+		return
+	}
+	for _, key := range keys {
+		l.invalidate(c.activeContext, key)
+	}
+}
+
+// invalidate invalidates the in-memory state derived from the given key. It
+// must be called with the state lock held.
+func (l *raftLocalReads) invalidate(ctx context.Context, key string) {
+	c := l.core
+
+	switch {
+	case strings.HasPrefix(key, systemBarrierPrefix+policyACLSubPath):
+		c.policyStore.invalidate(ctx, strings.TrimPrefix(key, systemBarrierPrefix+policyACLSubPath), PolicyTypeACL)
+
+	case strings.HasPrefix(key, systemBarrierPrefix+tokenSubPath):
+		c.tokenStore.Invalidate(ctx, strings.TrimPrefix(key, systemBarrierPrefix))
+
+	case strings.HasPrefix(key, systemBarrierPrefix):
+		// Nothing else under sys/ is cached by standbys
+
+	default:
+		ns, mountPath, prefix, found := c.router.MatchingAPIPrefixByStoragePath(ctx, key)
+		if !found {
+			return
+		}
+		nsCtx := namespace.ContextWithNamespace(ctx, ns)
+		if backend := c.router.MatchingBackend(nsCtx, mountPath); backend != nil {
+			backend.InvalidateKey(nsCtx, strings.TrimPrefix(key, prefix))
+		}
+	}
+}
+
+// stopRaftLocalReads tears down the local reads, which must be done before
+// the node becomes active. It must be called with the state lock held.
+func (c *Core) stopRaftLocalReads() {
+	if c.raftLocalReads != nil {
+		c.raftLocalReads.teardown()
+	}
+}
+
+// RaftLocalReadPath returns whether the given path belongs to one of the
+// mounts whose reads this node serves from its raft FSM while it is a
+// standby.
+func (c *Core) RaftLocalReadPath(ctx context.Context, path string) bool {
+	raftBackend, ok := c.underlyingPhysical.(*raft.RaftBackend)
+	if !ok || len(raftBackend.LocalReadMounts()) == 0 {
+		return false
+	}
+
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return false
+	}
+	mount := strings.TrimPrefix(c.router.MatchingMount(ctx, path), ns.Path)
+	if mount == "" {
+		return false
+	}
+	return strutil.StrListContains(raftBackend.LocalReadMounts(), mount)
+}
+
+// RaftAppliedIndex returns the last index applied by the raft FSM of this
+// node, or 0 if raft storage is not in use.
+func (c *Core) RaftAppliedIndex() uint64 {
+	raftBackend, ok := c.underlyingPhysical.(*raft.RaftBackend)
+	if !ok {
+		return 0
+	}
+	return raftBackend.AppliedIndex()
+}
+
+// WaitForRaftAppliedIndex blocks until the raft FSM of this node has applied
+// the given index, and returns false if the context is done first or raft
+// storage is not in use.
+func (c *Core) WaitForRaftAppliedIndex(ctx context.Context, index uint64) bool {
+	raftBackend, ok := c.underlyingPhysical.(*raft.RaftBackend)
+	if !ok {
+		return false
+	}
+	return raftBackend.WaitForAppliedIndex(ctx, index)
+}
@@ -0,0 +1,226 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/physical/raft"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/sdk/plugin/pb"
+)
+
+// raftSnapshotMountTables maps the storage paths of the mount tables to the
+// type of the table, to find the mounts in a snapshot.
+var raftSnapshotMountTables = map[string]string{
+	coreMountConfigPath:      mountTableType,
+	coreLocalMountConfigPath: mountTableType,
+	coreAuthConfigPath:       credentialTableType,
+	coreLocalAuthConfigPath:  credentialTableType,
+}
+
+// raftSnapshotMountRestore tracks the restore of a mount from a snapshot.
+type raftSnapshotMountRestore struct {
+	path  string
+	entry *MountEntry
+
+	// fromPrefix is the storage prefix of the mount in the snapshot, and
+	// toPrefix the one of the running mount.
+	fromPrefix string
+	toPrefix   string
+
+	// snapshotKeys holds the keys of the mount in the snapshot, relative to
+	// its prefix.
+	snapshotKeys map[string]struct{}
+}
+
+// restoreRaftSnapshotMounts replaces the data of the given mounts with their
+// data in the state of a snapshot, leaving the rest of the storage
+// untouched. The mounts must exist in both the snapshot and the cluster, with
+// the same type. Since the data is re-encrypted, the mounts don't need to
+// have the same UUID, but the snapshot must have been taken with the keyring
+// of this cluster. The data of the snapshot is checked before anything is
+// written, and keys missing from the snapshot are only removed once the
+// others have been written. It returns the number of keys restored for each
+// mount.
+func (c *Core) restoreRaftSnapshotMounts(ctx context.Context, state io.ReadSeeker, paths []string) (map[string]int, error) {
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mountsLock.RLock()
+	defer c.mountsLock.RUnlock()
+	c.authLock.RLock()
+	defer c.authLock.RUnlock()
+
+	// Read the mount tables of the snapshot
+	var snapshotEntries []*MountEntry
+	err = raft.ReadSnapshotState(state, func(entry *pb.StorageEntry) error {
+		table, ok := raftSnapshotMountTables[entry.Key]
+		if !ok {
+			return nil
+		}
+
+		value, err := c.barrier.Decrypt(ctx, entry.Key, entry.Value)
+		if err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("failed to decrypt %q from the snapshot: {{err}}", entry.Key), err)
+		}
+		mountTable, err := c.decodeMountTable(ctx, value)
+		if err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("failed to decode %q from the snapshot: {{err}}", entry.Key), err)
+		}
+		for _, me := range mountTable.Entries {
+			me.Table = table
+			snapshotEntries = append(snapshotEntries, me)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	restores := make(map[string]*raftSnapshotMountRestore, len(paths))
+	for _, path := range paths {
+		path = sanitizePath(path)
+
+		table, entryPath := mountTableType, path
+		if strings.HasPrefix(path, credentialRoutePrefix) {
+			table, entryPath = credentialTableType, strings.TrimPrefix(path, credentialRoutePrefix)
+		}
+
+		var snapshotEntry *MountEntry
+		for _, me := range snapshotEntries {
+			if me.Table == table && me.Path == entryPath && me.NamespaceID == ns.ID {
+				snapshotEntry = me
+				break
+			}
+		}
+		if snapshotEntry == nil {
+			return nil, logical.CodedError(http.StatusBadRequest, fmt.Sprintf("no mount found at %q in the snapshot", path))
+		}
+
+		entry := c.router.MatchingMountEntry(ctx, path)
+		if entry == nil || entry.Table != table || entry.Path != entryPath {
+			return nil, logical.CodedError(http.StatusBadRequest, fmt.Sprintf("no mount found at %q; it must be enabled before being restored", path))
+		}
+		if entry.Type != snapshotEntry.Type {
+			return nil, logical.CodedError(http.StatusBadRequest, fmt.Sprintf("mount at %q is of type %q in the snapshot but %q in the cluster", path, snapshotEntry.Type, entry.Type))
+		}
+		if strutil.StrListContains(singletonMounts, entry.Type) {
+			return nil, logical.CodedError(http.StatusBadRequest, fmt.Sprintf("mount at %q of type %q cannot be restored", path, entry.Type))
+		}
+
+		fromPrefix := snapshotEntry.ViewPath()
+		if _, ok := restores[fromPrefix]; ok {
+			continue
+		}
+		restores[fromPrefix] = &raftSnapshotMountRestore{
+			path:         path,
+			entry:        entry,
+			fromPrefix:   fromPrefix,
+			toPrefix:     entry.ViewPath(),
+			snapshotKeys: make(map[string]struct{}),
+		}
+	}
+
+	// Check that all the data of the mounts can be decrypted before
+	// changing anything, so that a bad snapshot leaves the mounts intact
+	if _, err := state.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	err = raft.ReadSnapshotState(state, func(entry *pb.StorageEntry) error {
+		// The prefixes of mounts are made of their UUID, so they don't nest
+		restore, ok := restores[raftSnapshotMountPrefix(entry.Key)]
+		if !ok {
+			return nil
+		}
+
+		if _, err := c.barrier.Decrypt(ctx, entry.Key, entry.Value); err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("failed to decrypt %q from the snapshot: {{err}}", entry.Key), err)
+		}
+		restore.snapshotKeys[strings.TrimPrefix(entry.Key, restore.fromPrefix)] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Write the data of the snapshot over the current data
+	for _, restore := range restores {
+		c.logger.Info("restoring mount from snapshot", "path", restore.path)
+	}
+	if _, err := state.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	err = raft.ReadSnapshotState(state, func(entry *pb.StorageEntry) error {
+		restore, ok := restores[raftSnapshotMountPrefix(entry.Key)]
+		if !ok {
+			return nil
+		}
+
+		value, err := c.barrier.Decrypt(ctx, entry.Key, entry.Value)
+		if err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("failed to decrypt %q from the snapshot: {{err}}", entry.Key), err)
+		}
+		return c.barrier.Put(ctx, &logical.StorageEntry{
+			Key:   restore.toPrefix + strings.TrimPrefix(entry.Key, restore.fromPrefix),
+			Value: value,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove the keys the mounts didn't have in the snapshot
+	for _, restore := range restores {
+		view := NewBarrierView(c.barrier, restore.toPrefix)
+		currentKeys, err := logical.CollectKeys(ctx, view)
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("failed to list the data of %q: {{err}}", restore.path), err)
+		}
+		for _, key := range currentKeys {
+			if _, ok := restore.snapshotKeys[key]; ok {
+				continue
+			}
+			if err := view.Delete(ctx, key); err != nil {
+				return nil, errwrap.Wrapf(fmt.Sprintf("failed to remove %q from %q: {{err}}", key, restore.path), err)
+			}
+		}
+	}
+
+	// Reload the backends so they don't serve stale data
+	keys := make(map[string]int, len(restores))
+	for _, restore := range restores {
+		isAuth := restore.entry.Table == credentialTableType
+		if err := c.reloadBackendCommon(ctx, restore.entry, isAuth); err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("failed to reload %q: {{err}}", restore.path), err)
+		}
+		if backend := c.router.MatchingBackend(ctx, restore.path); backend != nil {
+			view := c.router.MatchingStorageByAPIPath(ctx, restore.path)
+			if err := backend.Initialize(c.activeContext, &logical.InitializationRequest{Storage: view}); err != nil {
+				return nil, errwrap.Wrapf(fmt.Sprintf("failed to initialize %q: {{err}}", restore.path), err)
+			}
+		}
+
+		c.logger.Info("restored mount from snapshot", "path", restore.path, "keys", len(restore.snapshotKeys))
+		keys[restore.path] = len(restore.snapshotKeys)
+	}
+
+	return keys, nil
+}
+
+// raftSnapshotMountPrefix returns the storage prefix of the mount a key
+// belongs to, such as "logical/<uuid>/".
+func raftSnapshotMountPrefix(key string) string {
+	parts := strings.SplitN(key, "/", 3)
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[0] + "/" + parts[1] + "/"
+}
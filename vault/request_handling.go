@@ -147,8 +147,12 @@ func (c *Core) fetchACLTokenEntryAndEntity(ctx context.Context, req *logical.Req
 		te = req.TokenEntry()
 	}
 
-	// Ensure the token is valid
+	// Ensure the token is valid. Tokens unknown to a standby serving local
+	// reads may not have been applied by it yet, so let the active node decide.
 	if te == nil {
+		if c.raftLocalReadStandby {
+			return nil, nil, nil, nil, logical.ErrPerfStandbyPleaseForward
+		}
 		return nil, nil, nil, nil, logical.ErrPermissionDenied
 	}
 
@@ -256,7 +260,7 @@ func (c *Core) checkToken(ctx context.Context, req *logical.Request, unauth bool
 		return nil, te, logical.ErrPermissionDenied
 	}
 	if te != nil && te.EntityID != "" && entity == nil {
-		if c.perfStandby {
+		if c.perfStandby || c.raftLocalReadStandby {
 			return nil, nil, logical.ErrPerfStandbyPleaseForward
 		}
 		c.logger.Warn("permission denied as the entity on the token is invalid")
@@ -365,13 +369,8 @@ func (c *Core) checkToken(ctx context.Context, req *logical.Request, unauth bool
 		// restore the client token information to the request so that we can
 		// forward this request properly to the active node.
 		if retErr.ErrorOrNil() != nil && checkErrControlGroupTokenNeedsCreated(retErr) &&
-			c.perfStandby && len(req.ClientToken) != 0 {
-			switch req.ClientTokenSource {
-			case logical.ClientTokenFromVaultHeader:
-				req.Headers[consts.AuthHeaderName] = []string{req.ClientToken}
-			case logical.ClientTokenFromAuthzHeader:
-				req.Headers["Authorization"] = append(req.Headers["Authorization"], fmt.Sprintf("Bearer %s", req.ClientToken))
-			}
+			(c.perfStandby || c.raftLocalReadStandby) && len(req.ClientToken) != 0 {
+			restoreClientTokenHeader(req)
 			// We also return the appropriate error so that the caller can forward the
 			// request to the active node
 			return auth, te, logical.ErrPerfStandbyPleaseForward
@@ -386,6 +385,18 @@ func (c *Core) checkToken(ctx context.Context, req *logical.Request, unauth bool
 	return auth, te, nil
 }
 
+// restoreClientTokenHeader adds the client token of the request back to the
+// header it was removed from once checked, so that the request can be
+// forwarded to the active node.
+func restoreClientTokenHeader(req *logical.Request) {
+	switch req.ClientTokenSource {
+	case logical.ClientTokenFromVaultHeader:
+		req.Headers[consts.AuthHeaderName] = []string{req.ClientToken}
+	case logical.ClientTokenFromAuthzHeader:
+		req.Headers["Authorization"] = append(req.Headers["Authorization"], fmt.Sprintf("Bearer %s", req.ClientToken))
+	}
+}
+
 // HandleRequest is used to handle a new incoming request
 func (c *Core) HandleRequest(httpCtx context.Context, req *logical.Request) (resp *logical.Response, err error) {
 	return c.switchedLockHandleRequest(httpCtx, req, true)
@@ -399,7 +410,7 @@ func (c *Core) switchedLockHandleRequest(httpCtx context.Context, req *logical.R
 	if c.Sealed() {
 		return nil, consts.ErrSealed
 	}
-	if c.standby && !c.perfStandby {
+	if c.standby && !c.perfStandby && !c.raftLocalReadStandby {
 		return nil, consts.ErrStandby
 	}
 
@@ -425,6 +436,13 @@ func (c *Core) switchedLockHandleRequest(httpCtx context.Context, req *logical.R
 
 	resp, err = c.handleCancelableRequest(ctx, ns, req)
 
+	// Standbys serving local reads only find out that a request must be
+	// forwarded once its token was checked, which removed it from the
+	// headers the request is forwarded with
+	if err != nil && c.raftLocalReadStandby && len(req.ClientToken) != 0 && errwrap.Contains(err, logical.ErrPerfStandbyPleaseForward.Error()) {
+		restoreClientTokenHeader(req)
+	}
+
 	req.SetTokenEntry(nil)
 	cancel()
 	return resp, err
@@ -608,6 +626,12 @@ func (c *Core) handleRequest(ctx context.Context, req *logical.Request) (retResp
 		return nil, nil, ctErr
 	}
 
+	// Standbys serving local reads can't decrement the use count of a token,
+	// so the active node handles the requests made with limited use tokens
+	if te != nil && te.NumUses != 0 && c.raftLocalReadStandby {
+		return nil, nil, logical.ErrPerfStandbyPleaseForward
+	}
+
 	// We run this logic first because we want to decrement the use count even
 	// in the case of an error (assuming we can successfully look up; if we
 	// need to forward, we exit before now)
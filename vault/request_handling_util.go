@@ -4,6 +4,7 @@ package vault
 
 import (
 	"context"
+	"strings"
 	"sync"
 
 	"github.com/hashicorp/vault/helper/identity"
@@ -22,8 +23,15 @@ func checkErrControlGroupTokenNeedsCreated(err error) bool {
 	return false
 }
 
+// shouldForward returns whether a request routed on a standby serving raft
+// local reads needs to be forwarded, because the backend attempted to write
+// to storage.
 func shouldForward(c *Core, resp *logical.Response, err error) bool {
-	return false
+	if !c.raftLocalReadStandby {
+		return false
+	}
+	return (err != nil && strings.Contains(err.Error(), logical.ErrReadOnly.Error())) ||
+		(resp.IsError() && strings.Contains(resp.Error().Error(), logical.ErrReadOnly.Error()))
 }
 
 func syncCounter(c *Core) {
@@ -33,15 +41,24 @@ func couldForward(c *Core) bool {
 	return false
 }
 
+// forward has the HTTP layer forward the request to the active node.
 func forward(ctx context.Context, c *Core, req *logical.Request) (*logical.Response, error) {
-	panic("forward called in OSS Vault")
+	return nil, logical.ErrPerfStandbyPleaseForward
 }
 
+// getLeaseRegisterFunc returns the function registering leases. Standbys
+// can't register leases, so the request is forwarded instead.
 func getLeaseRegisterFunc(c *Core) (func(context.Context, *logical.Request, *logical.Response) (string, error), error) {
+	if c.raftLocalReadStandby {
+		return nil, logical.ErrPerfStandbyPleaseForward
+	}
 	return c.expiration.Register, nil
 }
 
 func getAuthRegisterFunc(c *Core) (RegisterAuthFunc, error) {
+	if c.raftLocalReadStandby {
+		return nil, logical.ErrPerfStandbyPleaseForward
+	}
 	return c.RegisterAuth, nil
 }
 
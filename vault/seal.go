@@ -319,6 +319,10 @@ type SealConfig struct {
 	// How many keys to store, for seals that support storage.  Always 0 or 1.
 	StoredShares int `json:"stored_shares" mapstructure:"stored_shares"`
 
+	// MultiSealTypes are the types of the seals of a multi-seal barrier, one
+	// of which can be used alone without a seal migration
+	MultiSealTypes []string `json:"multi_seal_types,omitempty" mapstructure:"multi_seal_types"`
+
 	// Stores the progress of the rekey operation (key shares)
 	RekeyProgress [][]byte `json:"-"`
 
@@ -395,6 +399,10 @@ func (s *SealConfig) Clone() *SealConfig {
 		ret.PGPKeys = make([]string, len(s.PGPKeys))
 		copy(ret.PGPKeys, s.PGPKeys)
 	}
+	if len(s.MultiSealTypes) > 0 {
+		ret.MultiSealTypes = make([]string, len(s.MultiSealTypes))
+		copy(ret.MultiSealTypes, s.MultiSealTypes)
+	}
 	if len(s.VerificationKey) > 0 {
 		ret.VerificationKey = make([]byte, len(s.VerificationKey))
 		copy(ret.VerificationKey, s.VerificationKey)
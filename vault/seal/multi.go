@@ -0,0 +1,348 @@
+package seal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	proto "github.com/golang/protobuf/proto"
+	"github.com/hashicorp/errwrap"
+	log "github.com/hashicorp/go-hclog"
+	wrapping "github.com/hashicorp/go-kms-wrapping"
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+)
+
+// MultiWrapperBlobFlag is set in the KeyInfo flags of values encrypted by a
+// MultiWrapper, to tell them apart from values encrypted by a single wrapper
+// before the MultiWrapper was configured.
+const MultiWrapperBlobFlag uint64 = 1 << 32
+
+// MultiWrapperEntry is one of the seals of a MultiWrapper.
+type MultiWrapperEntry struct {
+	// Name identifies the seal in encrypted values, so it must not change
+	// while values encrypted by the seal are stored.
+	Name string
+
+	// Priority orders the seals when decrypting; seals with a lower
+	// priority are tried first.
+	Priority int
+
+	Wrapper wrapping.Wrapper
+}
+
+// MultiWrapper is a wrapping.Wrapper that encrypts values under each of its
+// seals, and decrypts them with the first seal, by priority, that is able to.
+// This allows unsealing as long as any of the seals is reachable.
+type MultiWrapper struct {
+	logger  log.Logger
+	entries []*MultiWrapperEntry
+}
+
+var _ wrapping.Wrapper = (*MultiWrapper)(nil)
+
+type multiWrapperBlob struct {
+	Entries []*multiWrapperBlobEntry `json:"entries"`
+}
+
+type multiWrapperBlobEntry struct {
+	Name string `json:"name"`
+	// Blob is the proto encoded wrapping.EncryptedBlobInfo
+	Blob []byte `json:"blob"`
+}
+
+// NewMultiWrapper returns a MultiWrapper for the given seals, which must have
+// distinct names.
+func NewMultiWrapper(logger log.Logger, entries ...*MultiWrapperEntry) (*MultiWrapper, error) {
+	if len(entries) == 0 {
+		return nil, errors.New("at least one seal is required")
+	}
+
+	names := make(map[string]struct{}, len(entries))
+	for _, entry := range entries {
+		if entry.Name == "" {
+			return nil, errors.New("seal name is required")
+		}
+		if strings.ContainsAny(entry.Name, ",=") {
+			return nil, fmt.Errorf("seal name %q must not contain ',' or '='", entry.Name)
+		}
+		if entry.Wrapper == nil {
+			return nil, fmt.Errorf("seal %q has no wrapper", entry.Name)
+		}
+		if _, ok := names[entry.Name]; ok {
+			return nil, fmt.Errorf("duplicate seal name %q", entry.Name)
+		}
+		names[entry.Name] = struct{}{}
+	}
+
+	sorted := make([]*MultiWrapperEntry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority < sorted[j].Priority
+	})
+
+	if logger == nil {
+		logger = log.NewNullLogger()
+	}
+
+	return &MultiWrapper{
+		logger:  logger,
+		entries: sorted,
+	}, nil
+}
+
+// Entries returns the seals of the wrapper, ordered by priority.
+func (m *MultiWrapper) Entries() []*MultiWrapperEntry {
+	return m.entries
+}
+
+// HasType returns whether one of the seals of the wrapper is of the given
+// type.
+func (m *MultiWrapper) HasType(t string) bool {
+	for _, entry := range m.entries {
+		if entry.Wrapper.Type() == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Types returns the sorted types of the seals of the wrapper.
+func (m *MultiWrapper) Types() []string {
+	types := make([]string, 0, len(m.entries))
+	for _, entry := range m.entries {
+		if !strutil.StrListContains(types, entry.Wrapper.Type()) {
+			types = append(types, entry.Wrapper.Type())
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+func (m *MultiWrapper) Type() string {
+	return wrapping.MultiWrapper
+}
+
+// KeyID identifies the current key of each of the seals, so that it changes
+// when a seal is added, removed or has its key rotated.
+func (m *MultiWrapper) KeyID() string {
+	keyIDs := make([]string, 0, len(m.entries))
+	for _, entry := range m.entries {
+		keyIDs = append(keyIDs, entry.Name+"="+entry.Wrapper.KeyID())
+	}
+	return strings.Join(keyIDs, ",")
+}
+
+func (m *MultiWrapper) HMACKeyID() string {
+	return ""
+}
+
+func (m *MultiWrapper) Init(ctx context.Context) error {
+	var result error
+	for _, entry := range m.entries {
+		if err := entry.Wrapper.Init(ctx); err != nil {
+			result = multierror.Append(result, errwrap.Wrapf(fmt.Sprintf("failed to initialize seal %q: {{err}}", entry.Name), err))
+		}
+	}
+	return result
+}
+
+func (m *MultiWrapper) Finalize(ctx context.Context) error {
+	var result error
+	for _, entry := range m.entries {
+		if err := entry.Wrapper.Finalize(ctx); err != nil {
+			result = multierror.Append(result, errwrap.Wrapf(fmt.Sprintf("failed to finalize seal %q: {{err}}", entry.Name), err))
+		}
+	}
+	return result
+}
+
+// Encrypt encrypts the plaintext under each of the seals. It fails if any of
+// the seals fails to encrypt, since a value missing from one of the seals
+// could not be decrypted once only this seal is reachable.
+func (m *MultiWrapper) Encrypt(ctx context.Context, plaintext, aad []byte) (*wrapping.EncryptedBlobInfo, error) {
+	var blob multiWrapperBlob
+	var keyIDs []string
+	var result error
+	for _, entry := range m.entries {
+		blobInfo, err := entry.Wrapper.Encrypt(ctx, plaintext, aad)
+		if err != nil {
+			m.logger.Warn("failed to encrypt with seal", "seal", entry.Name, "error", err)
+			result = multierror.Append(result, errwrap.Wrapf(fmt.Sprintf("seal %q: {{err}}", entry.Name), err))
+			continue
+		}
+
+		value, err := proto.Marshal(blobInfo)
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("failed to marshal value encrypted by seal %q: {{err}}", entry.Name), err)
+		}
+
+		blob.Entries = append(blob.Entries, &multiWrapperBlobEntry{
+			Name: entry.Name,
+			Blob: value,
+		})
+		keyIDs = append(keyIDs, entry.Name+"="+entry.Wrapper.KeyID())
+	}
+
+	if result != nil {
+		return nil, errwrap.Wrapf("failed to encrypt with every seal: {{err}}", result)
+	}
+
+	ciphertext, err := json.Marshal(blob)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to encode encrypted value: {{err}}", err)
+	}
+
+	return &wrapping.EncryptedBlobInfo{
+		Ciphertext: ciphertext,
+		KeyInfo: &wrapping.KeyInfo{
+			KeyID: strings.Join(keyIDs, ","),
+			Flags: MultiWrapperBlobFlag,
+		},
+	}, nil
+}
+
+// Decrypt decrypts the value with the first seal, by priority, that is able
+// to. Values encrypted by a single seal before the MultiWrapper was
+// configured are tried with the seals whose key ID matches first.
+func (m *MultiWrapper) Decrypt(ctx context.Context, in *wrapping.EncryptedBlobInfo, aad []byte) ([]byte, error) {
+	if in == nil {
+		return nil, errors.New("given input for decryption is nil")
+	}
+
+	if !IsMultiWrapperBlob(in) {
+		return m.decryptSingle(ctx, in, aad)
+	}
+
+	blobs, err := decodeMultiWrapperBlob(in)
+	if err != nil {
+		return nil, err
+	}
+
+	var result error
+	for _, entry := range m.entries {
+		value, ok := blobs[entry.Name]
+		if !ok {
+			continue
+		}
+
+		blobInfo := &wrapping.EncryptedBlobInfo{}
+		if err := proto.Unmarshal(value, blobInfo); err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("failed to proto decode value encrypted by seal %q: {{err}}", entry.Name), err)
+		}
+
+		pt, err := entry.Wrapper.Decrypt(ctx, blobInfo, aad)
+		if err != nil {
+			m.logger.Warn("failed to decrypt with seal, trying the next one", "seal", entry.Name, "error", err)
+			result = multierror.Append(result, errwrap.Wrapf(fmt.Sprintf("seal %q: {{err}}", entry.Name), err))
+			continue
+		}
+		return pt, nil
+	}
+
+	if result == nil {
+		return nil, errors.New("value is not encrypted under any of the configured seals")
+	}
+	return nil, errwrap.Wrapf("failed to decrypt with any seal: {{err}}", result)
+}
+
+func (m *MultiWrapper) decryptSingle(ctx context.Context, in *wrapping.EncryptedBlobInfo, aad []byte) ([]byte, error) {
+	var keyID string
+	if in.KeyInfo != nil {
+		keyID = in.KeyInfo.KeyID
+	}
+
+	candidates := make([]*MultiWrapperEntry, 0, len(m.entries))
+	for _, entry := range m.entries {
+		if keyID != "" && entry.Wrapper.KeyID() == keyID {
+			candidates = append(candidates, entry)
+		}
+	}
+	for _, entry := range m.entries {
+		if keyID == "" || entry.Wrapper.KeyID() != keyID {
+			candidates = append(candidates, entry)
+		}
+	}
+
+	var result error
+	for _, entry := range candidates {
+		pt, err := entry.Wrapper.Decrypt(ctx, in, aad)
+		if err != nil {
+			result = multierror.Append(result, errwrap.Wrapf(fmt.Sprintf("seal %q: {{err}}", entry.Name), err))
+			continue
+		}
+		return pt, nil
+	}
+
+	return nil, errwrap.Wrapf("failed to decrypt with any seal: {{err}}", result)
+}
+
+// IsMultiWrapperBlob returns whether the value was encrypted by a
+// MultiWrapper.
+func IsMultiWrapperBlob(in *wrapping.EncryptedBlobInfo) bool {
+	return in != nil && in.KeyInfo != nil && in.KeyInfo.Flags&MultiWrapperBlobFlag != 0
+}
+
+// decodeMultiWrapperBlob returns the values encrypted by each seal of a
+// MultiWrapper, by seal name.
+func decodeMultiWrapperBlob(in *wrapping.EncryptedBlobInfo) (map[string][]byte, error) {
+	var blob multiWrapperBlob
+	if err := json.Unmarshal(in.Ciphertext, &blob); err != nil {
+		return nil, errwrap.Wrapf("failed to decode encrypted value: {{err}}", err)
+	}
+	blobs := make(map[string][]byte, len(blob.Entries))
+	for _, entry := range blob.Entries {
+		blobs[entry.Name] = entry.Blob
+	}
+	return blobs, nil
+}
+
+// decryptMultiWrapperBlob decrypts a value encrypted by a MultiWrapper with
+// a single wrapper, which must have been one of its seals. This allows going
+// back from a multi-seal to one of its seals. The values encrypted under the
+// current key of the wrapper are tried first.
+func decryptMultiWrapperBlob(ctx context.Context, w wrapping.Wrapper, in *wrapping.EncryptedBlobInfo, aad []byte) ([]byte, error) {
+	blobs, err := decodeMultiWrapperBlob(in)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(blobs))
+	for name := range blobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	candidates := make([]*wrapping.EncryptedBlobInfo, 0, len(names))
+	var others []*wrapping.EncryptedBlobInfo
+	for _, name := range names {
+		blobInfo := &wrapping.EncryptedBlobInfo{}
+		if err := proto.Unmarshal(blobs[name], blobInfo); err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("failed to proto decode value encrypted by seal %q: {{err}}", name), err)
+		}
+		if blobInfo.KeyInfo != nil && blobInfo.KeyInfo.KeyID == w.KeyID() {
+			candidates = append(candidates, blobInfo)
+		} else {
+			others = append(others, blobInfo)
+		}
+	}
+	candidates = append(candidates, others...)
+
+	var result error
+	for _, blobInfo := range candidates {
+		pt, err := w.Decrypt(ctx, blobInfo, aad)
+		if err != nil {
+			result = multierror.Append(result, err)
+			continue
+		}
+		return pt, nil
+	}
+
+	if result == nil {
+		return nil, errors.New("value encrypted by a multi-seal has no seal values")
+	}
+	return nil, errwrap.Wrapf("failed to decrypt value encrypted by a multi-seal: {{err}}", result)
+}
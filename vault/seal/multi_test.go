@@ -0,0 +1,135 @@
+package seal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	wrapping "github.com/hashicorp/go-kms-wrapping"
+)
+
+// unreachableWrapper is a test wrapper that can be made to fail, as when its
+// KMS is unreachable.
+type unreachableWrapper struct {
+	*wrapping.TestWrapper
+	unreachable bool
+}
+
+func (w *unreachableWrapper) Encrypt(ctx context.Context, plaintext, aad []byte) (*wrapping.EncryptedBlobInfo, error) {
+	if w.unreachable {
+		return nil, errors.New("unreachable")
+	}
+	return w.TestWrapper.Encrypt(ctx, plaintext, aad)
+}
+
+func (w *unreachableWrapper) Decrypt(ctx context.Context, in *wrapping.EncryptedBlobInfo, aad []byte) ([]byte, error) {
+	if w.unreachable {
+		return nil, errors.New("unreachable")
+	}
+	return w.TestWrapper.Decrypt(ctx, in, aad)
+}
+
+func newUnreachableWrapper(secret, keyID string) *unreachableWrapper {
+	w := &unreachableWrapper{TestWrapper: wrapping.NewTestWrapper([]byte(secret))}
+	w.SetKeyID(keyID)
+	return w
+}
+
+func TestMultiWrapper(t *testing.T) {
+	ctx := context.Background()
+	primary := newUnreachableWrapper("primary-secret", "primary-key")
+	secondary := newUnreachableWrapper("secondary-secret", "secondary-key")
+
+	// Entries are ordered by priority regardless of the order given
+	m, err := NewMultiWrapper(nil,
+		&MultiWrapperEntry{Name: "secondary", Priority: 2, Wrapper: secondary},
+		&MultiWrapperEntry{Name: "primary", Priority: 1, Wrapper: primary},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "primary=primary-key,secondary=secondary-key", m.KeyID(); want != got {
+		t.Fatalf("bad key ID: want %q, got %q", want, got)
+	}
+	if types := m.Types(); len(types) != 1 || types[0] != wrapping.Test {
+		t.Fatalf("bad types: %v", types)
+	}
+
+	input := []byte("barrier keys")
+	blob, err := m.Encrypt(ctx, input, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blob.KeyInfo.KeyID != m.KeyID() || blob.KeyInfo.Flags&MultiWrapperBlobFlag == 0 {
+		t.Fatalf("bad key info: %#v", blob.KeyInfo)
+	}
+
+	decrypt := func(blob *wrapping.EncryptedBlobInfo) {
+		t.Helper()
+		output, err := m.Decrypt(ctx, blob, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(input, output) {
+			t.Fatalf("expected %q, got %q", input, output)
+		}
+	}
+	decrypt(blob)
+
+	// Either seal can decrypt on its own
+	primary.unreachable = true
+	decrypt(blob)
+	primary.unreachable, secondary.unreachable = false, true
+	decrypt(blob)
+
+	primary.unreachable = true
+	if _, err := m.Decrypt(ctx, blob, nil); err == nil {
+		t.Fatal("expected error decrypting with no reachable seal")
+	}
+	if _, err := m.Encrypt(ctx, input, nil); err == nil {
+		t.Fatal("expected error encrypting with no reachable seal")
+	}
+
+	// Values can't be encrypted while a seal is unreachable, since that seal
+	// alone could not decrypt them
+	primary.unreachable = false
+	if _, err := m.Encrypt(ctx, input, nil); err == nil {
+		t.Fatal("expected error encrypting with an unreachable seal")
+	}
+
+	// Values encrypted by a single seal before the multi-seal was configured
+	// are decrypted by the seal with the matching key ID
+	secondary.unreachable = false
+	single, err := secondary.Encrypt(ctx, input, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypt(single)
+
+	// Each of the seals can decrypt the values encrypted by the multi-seal
+	// on its own, once the multi-seal is removed
+	for _, w := range []*unreachableWrapper{primary, secondary} {
+		access := &Access{Wrapper: w}
+		output, err := access.Decrypt(ctx, blob, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(input, output) {
+			t.Fatalf("expected %q, got %q", input, output)
+		}
+	}
+	other := &Access{Wrapper: newUnreachableWrapper("other-secret", "other-key")}
+	if output, err := other.Decrypt(ctx, blob, nil); err == nil && bytes.Equal(input, output) {
+		t.Fatal("seal not part of the multi-seal decrypted the value")
+	}
+
+	// Seal names must be distinct
+	_, err = NewMultiWrapper(nil,
+		&MultiWrapperEntry{Name: "kms", Priority: 1, Wrapper: primary},
+		&MultiWrapperEntry{Name: "kms", Priority: 2, Wrapper: secondary},
+	)
+	if err == nil {
+		t.Fatal("expected error for duplicate seal names")
+	}
+}
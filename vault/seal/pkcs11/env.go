@@ -0,0 +1,12 @@
+package pkcs11
+
+// Environment variables that take precedence over the seal configuration
+const (
+	EnvHSMLib         = "VAULT_HSM_LIB"
+	EnvHSMSlot        = "VAULT_HSM_SLOT"
+	EnvHSMTokenLabel  = "VAULT_HSM_TOKEN_LABEL"
+	EnvHSMPin         = "VAULT_HSM_PIN"
+	EnvHSMKeyLabel    = "VAULT_HSM_KEY_LABEL"
+	EnvHSMMechanism   = "VAULT_HSM_MECHANISM"
+	EnvHSMGenerateKey = "VAULT_HSM_GENERATE_KEY"
+)
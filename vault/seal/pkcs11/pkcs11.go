@@ -0,0 +1,448 @@
+// +build cgo
+
+package pkcs11
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/hashicorp/errwrap"
+	log "github.com/hashicorp/go-hclog"
+	wrapping "github.com/hashicorp/go-kms-wrapping"
+	"github.com/hashicorp/vault/sdk/helper/parseutil"
+	p11 "github.com/miekg/pkcs11"
+)
+
+// Wrapper is a wrapping.Wrapper that encrypts the data encryption keys of
+// values with an AES key held by a PKCS#11 module, such as an HSM or SoftHSM.
+type Wrapper struct {
+	logger log.Logger
+
+	lib         string
+	slot        uint
+	tokenLabel  string
+	pin         string
+	keyLabel    string
+	mechanism   uint
+	generateKey bool
+
+	// l serializes the use of the session, which PKCS#11 does not allow
+	// to be used concurrently
+	l       sync.Mutex
+	ctx     *p11.Ctx
+	session p11.SessionHandle
+	open    bool
+	keys    map[string]p11.ObjectHandle
+}
+
+var _ wrapping.Wrapper = (*Wrapper)(nil)
+
+// NewWrapper creates a new PKCS#11 wrapper. SetConfig must be called before
+// it is used.
+func NewWrapper(opts *wrapping.WrapperOptions) *Wrapper {
+	if opts == nil {
+		opts = new(wrapping.WrapperOptions)
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = log.NewNullLogger()
+	}
+	return &Wrapper{
+		logger: logger,
+		keys:   make(map[string]p11.ObjectHandle),
+	}
+}
+
+// SetConfig loads the PKCS#11 module, logs into the configured token and
+// finds, or generates if allowed, the configured key. Environment variables
+// take precedence over the values in the config map.
+func (w *Wrapper) SetConfig(config map[string]string) (map[string]string, error) {
+	if config == nil {
+		config = map[string]string{}
+	}
+	get := func(env, key string) string {
+		if v := os.Getenv(env); v != "" {
+			return v
+		}
+		return config[key]
+	}
+
+	w.lib = get(EnvHSMLib, "lib")
+	if w.lib == "" {
+		return nil, errors.New("'lib' not found for pkcs11 seal configuration")
+	}
+
+	slot := get(EnvHSMSlot, "slot")
+	w.tokenLabel = get(EnvHSMTokenLabel, "token_label")
+	switch {
+	case slot == "" && w.tokenLabel == "":
+		return nil, errors.New("'slot' or 'token_label' not found for pkcs11 seal configuration")
+	case slot != "":
+		slotID, err := strconv.ParseUint(slot, 0, 0)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to parse 'slot' for pkcs11 seal configuration: {{err}}", err)
+		}
+		w.slot = uint(slotID)
+	}
+
+	w.pin = get(EnvHSMPin, "pin")
+	if w.pin == "" {
+		return nil, errors.New("'pin' not found for pkcs11 seal configuration")
+	}
+
+	w.keyLabel = get(EnvHSMKeyLabel, "key_label")
+	if w.keyLabel == "" {
+		return nil, errors.New("'key_label' not found for pkcs11 seal configuration")
+	}
+
+	w.mechanism = p11.CKM_AES_GCM
+	if mechanism := get(EnvHSMMechanism, "mechanism"); mechanism != "" {
+		m, err := strconv.ParseUint(mechanism, 0, 0)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to parse 'mechanism' for pkcs11 seal configuration: {{err}}", err)
+		}
+		w.mechanism = uint(m)
+	}
+	if w.mechanism != p11.CKM_AES_GCM {
+		return nil, fmt.Errorf("unsupported mechanism 0x%04x for pkcs11 seal, only CKM_AES_GCM (0x%04x) is supported", w.mechanism, p11.CKM_AES_GCM)
+	}
+
+	if generateKey := get(EnvHSMGenerateKey, "generate_key"); generateKey != "" {
+		var err error
+		if w.generateKey, err = parseutil.ParseBool(generateKey); err != nil {
+			return nil, errwrap.Wrapf("failed to parse 'generate_key' for pkcs11 seal configuration: {{err}}", err)
+		}
+	}
+
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	if err := w.openSession(); err != nil {
+		return nil, err
+	}
+	if _, err := w.findKey(w.keyLabel, w.generateKey); err != nil {
+		return nil, err
+	}
+
+	info := map[string]string{
+		"lib":       w.lib,
+		"key_label": w.keyLabel,
+		"mechanism": fmt.Sprintf("0x%04x", w.mechanism),
+	}
+	if w.tokenLabel != "" {
+		info["token_label"] = w.tokenLabel
+	} else {
+		info["slot"] = strconv.FormatUint(uint64(w.slot), 10)
+	}
+	return info, nil
+}
+
+// openSession loads the module if needed and opens a logged in session on
+// the configured token. It must be called with the lock held.
+func (w *Wrapper) openSession() error {
+	if w.ctx == nil {
+		ctx := p11.New(w.lib)
+		if ctx == nil {
+			return fmt.Errorf("failed to load pkcs11 library %q", w.lib)
+		}
+		if err := ctx.Initialize(); err != nil && !isError(err, p11.CKR_CRYPTOKI_ALREADY_INITIALIZED) {
+			ctx.Destroy()
+			return errwrap.Wrapf("failed to initialize pkcs11 library: {{err}}", err)
+		}
+		w.ctx = ctx
+	}
+
+	if w.tokenLabel != "" {
+		slots, err := w.ctx.GetSlotList(true)
+		if err != nil {
+			return errwrap.Wrapf("failed to list pkcs11 slots: {{err}}", err)
+		}
+		found := false
+		for _, slot := range slots {
+			tokenInfo, err := w.ctx.GetTokenInfo(slot)
+			if err != nil {
+				return errwrap.Wrapf("failed to read pkcs11 token info: {{err}}", err)
+			}
+			if tokenInfo.Label == w.tokenLabel {
+				w.slot = slot
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("pkcs11 token with label %q not found", w.tokenLabel)
+		}
+	}
+
+	session, err := w.ctx.OpenSession(w.slot, p11.CKF_SERIAL_SESSION|p11.CKF_RW_SESSION)
+	if err != nil {
+		return errwrap.Wrapf("failed to open pkcs11 session: {{err}}", err)
+	}
+	if err := w.ctx.Login(session, p11.CKU_USER, w.pin); err != nil && !isError(err, p11.CKR_USER_ALREADY_LOGGED_IN) {
+		w.ctx.CloseSession(session)
+		return errwrap.Wrapf("failed to log into pkcs11 token: {{err}}", err)
+	}
+
+	w.session = session
+	w.open = true
+	w.keys = make(map[string]p11.ObjectHandle)
+	return nil
+}
+
+// findKey returns the handle of the AES key with the given label, generating
+// it if it does not exist and generate is set. It must be called with the
+// lock held.
+func (w *Wrapper) findKey(label string, generate bool) (p11.ObjectHandle, error) {
+	if key, ok := w.keys[label]; ok {
+		return key, nil
+	}
+
+	template := []*p11.Attribute{
+		p11.NewAttribute(p11.CKA_CLASS, p11.CKO_SECRET_KEY),
+		p11.NewAttribute(p11.CKA_KEY_TYPE, p11.CKK_AES),
+		p11.NewAttribute(p11.CKA_LABEL, label),
+	}
+	if err := w.ctx.FindObjectsInit(w.session, template); err != nil {
+		return 0, errwrap.Wrapf("failed to search for pkcs11 key: {{err}}", err)
+	}
+	objects, _, err := w.ctx.FindObjects(w.session, 2)
+	if finalErr := w.ctx.FindObjectsFinal(w.session); err == nil {
+		err = finalErr
+	}
+	if err != nil {
+		return 0, errwrap.Wrapf("failed to search for pkcs11 key: {{err}}", err)
+	}
+
+	var key p11.ObjectHandle
+	switch {
+	case len(objects) > 1:
+		return 0, fmt.Errorf("found more than one pkcs11 key with label %q", label)
+	case len(objects) == 1:
+		key = objects[0]
+	case !generate:
+		return 0, fmt.Errorf("pkcs11 key with label %q not found", label)
+	default:
+		w.logger.Info("generating pkcs11 key", "key_label", label)
+		key, err = w.generate(label)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	w.keys[label] = key
+	return key, nil
+}
+
+// generate creates a non-extractable 256-bit AES key on the token
+func (w *Wrapper) generate(label string) (p11.ObjectHandle, error) {
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return 0, err
+	}
+
+	template := []*p11.Attribute{
+		p11.NewAttribute(p11.CKA_CLASS, p11.CKO_SECRET_KEY),
+		p11.NewAttribute(p11.CKA_KEY_TYPE, p11.CKK_AES),
+		p11.NewAttribute(p11.CKA_VALUE_LEN, 32),
+		p11.NewAttribute(p11.CKA_LABEL, label),
+		p11.NewAttribute(p11.CKA_ID, id),
+		p11.NewAttribute(p11.CKA_PRIVATE, true),
+		p11.NewAttribute(p11.CKA_TOKEN, true),
+		p11.NewAttribute(p11.CKA_SENSITIVE, true),
+		p11.NewAttribute(p11.CKA_ENCRYPT, true),
+		p11.NewAttribute(p11.CKA_DECRYPT, true),
+		p11.NewAttribute(p11.CKA_WRAP, true),
+		p11.NewAttribute(p11.CKA_UNWRAP, true),
+		p11.NewAttribute(p11.CKA_EXTRACTABLE, false),
+	}
+	key, err := w.ctx.GenerateKey(w.session, []*p11.Mechanism{p11.NewMechanism(p11.CKM_AES_KEY_GEN, nil)}, template)
+	if err != nil {
+		return 0, errwrap.Wrapf("failed to generate pkcs11 key: {{err}}", err)
+	}
+	return key, nil
+}
+
+// withSession runs fn with the lock held, reopening the session and retrying
+// once if the session was closed, as when the token was reconnected.
+func (w *Wrapper) withSession(fn func() error) error {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	if !w.open {
+		if w.ctx == nil {
+			return errors.New("pkcs11 seal is not configured")
+		}
+		if err := w.openSession(); err != nil {
+			return err
+		}
+	}
+
+	err := fn()
+	if isError(err, p11.CKR_SESSION_HANDLE_INVALID) || isError(err, p11.CKR_SESSION_CLOSED) || isError(err, p11.CKR_DEVICE_REMOVED) {
+		w.logger.Warn("pkcs11 session lost, reopening", "error", err)
+		w.open = false
+		if err := w.openSession(); err != nil {
+			return err
+		}
+		err = fn()
+	}
+	return err
+}
+
+// Init is a no-op, the session is opened by SetConfig
+func (w *Wrapper) Init(_ context.Context) error {
+	return nil
+}
+
+// Finalize logs out of the token and unloads the module
+func (w *Wrapper) Finalize(_ context.Context) error {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	if w.ctx == nil {
+		return nil
+	}
+	if w.open {
+		w.ctx.Logout(w.session)
+		w.ctx.CloseSession(w.session)
+		w.open = false
+	}
+	err := w.ctx.Finalize()
+	w.ctx.Destroy()
+	w.ctx = nil
+	return err
+}
+
+// Type returns the type of the wrapper
+func (w *Wrapper) Type() string {
+	return wrapping.PKCS11
+}
+
+// KeyID returns the label of the key currently used for encryption
+func (w *Wrapper) KeyID() string {
+	return w.keyLabel
+}
+
+// HMACKeyID is not used by the wrapper
+func (w *Wrapper) HMACKeyID() string {
+	return ""
+}
+
+// Encrypt encrypts the plaintext with a new data encryption key, which is
+// itself encrypted by the PKCS#11 key using AES-GCM.
+func (w *Wrapper) Encrypt(_ context.Context, plaintext, aad []byte) (*wrapping.EncryptedBlobInfo, error) {
+	env, err := wrapping.NewEnvelope(nil).Encrypt(plaintext, aad)
+	if err != nil {
+		return nil, errwrap.Wrapf("error wrapping data: {{err}}", err)
+	}
+
+	var wrappedKey []byte
+	err = w.withSession(func() error {
+		key, err := w.findKey(w.keyLabel, false)
+		if err != nil {
+			return err
+		}
+
+		iv := make([]byte, 12)
+		if _, err := rand.Read(iv); err != nil {
+			return err
+		}
+		params := p11.NewGCMParams(iv, nil, 128)
+		defer params.Free()
+
+		if err := w.ctx.EncryptInit(w.session, []*p11.Mechanism{p11.NewMechanism(w.mechanism, params)}, key); err != nil {
+			return err
+		}
+		ciphertext, err := w.ctx.Encrypt(w.session, env.Key)
+		if err != nil {
+			return err
+		}
+
+		// Some modules generate their own IV, so read it back
+		if actual := params.IV(); len(actual) != 0 {
+			iv = actual
+		}
+		wrappedKey = append(iv, ciphertext...)
+		return nil
+	})
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to encrypt data encryption key with pkcs11 key: {{err}}", err)
+	}
+
+	return &wrapping.EncryptedBlobInfo{
+		Ciphertext: env.Ciphertext,
+		IV:         env.IV,
+		KeyInfo: &wrapping.KeyInfo{
+			Mechanism:  uint64(w.mechanism),
+			KeyID:      w.keyLabel,
+			WrappedKey: wrappedKey,
+		},
+	}, nil
+}
+
+// Decrypt decrypts the data encryption key of the value with the PKCS#11 key
+// it was encrypted with, identified by its label, then the value.
+func (w *Wrapper) Decrypt(_ context.Context, in *wrapping.EncryptedBlobInfo, aad []byte) ([]byte, error) {
+	if in == nil {
+		return nil, errors.New("given input for decryption is nil")
+	}
+	if in.KeyInfo == nil {
+		return nil, errors.New("key info is nil")
+	}
+	if in.KeyInfo.Mechanism != uint64(p11.CKM_AES_GCM) {
+		return nil, fmt.Errorf("unsupported mechanism 0x%04x", in.KeyInfo.Mechanism)
+	}
+	if len(in.KeyInfo.WrappedKey) <= 12 {
+		return nil, errors.New("wrapped key is too short")
+	}
+
+	label := in.KeyInfo.KeyID
+	if label == "" {
+		label = w.keyLabel
+	}
+	iv, ciphertext := in.KeyInfo.WrappedKey[:12], in.KeyInfo.WrappedKey[12:]
+
+	var dataKey []byte
+	err := w.withSession(func() error {
+		key, err := w.findKey(label, false)
+		if err != nil {
+			return err
+		}
+
+		params := p11.NewGCMParams(iv, nil, 128)
+		defer params.Free()
+
+		if err := w.ctx.DecryptInit(w.session, []*p11.Mechanism{p11.NewMechanism(p11.CKM_AES_GCM, params)}, key); err != nil {
+			return err
+		}
+		dataKey, err = w.ctx.Decrypt(w.session, ciphertext)
+		return err
+	})
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to decrypt data encryption key with pkcs11 key: {{err}}", err)
+	}
+
+	plaintext, err := wrapping.NewEnvelope(nil).Decrypt(&wrapping.EnvelopeInfo{
+		Key:        dataKey,
+		IV:         in.IV,
+		Ciphertext: in.Ciphertext,
+	}, aad)
+	if err != nil {
+		return nil, errwrap.Wrapf("error decrypting data: {{err}}", err)
+	}
+	return plaintext, nil
+}
+
+// isError returns whether err is, or wraps, the given PKCS#11 error
+func isError(err error, rv uint) bool {
+	if err == nil {
+		return false
+	}
+	p11Err, ok := errwrap.GetType(err, p11.Error(0)).(p11.Error)
+	return ok && uint(p11Err) == rv
+}
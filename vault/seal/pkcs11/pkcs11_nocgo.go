@@ -0,0 +1,54 @@
+// +build !cgo
+
+package pkcs11
+
+import (
+	"context"
+	"errors"
+
+	wrapping "github.com/hashicorp/go-kms-wrapping"
+)
+
+var errNoCgo = errors.New("the pkcs11 seal requires a Vault binary built with cgo")
+
+// Wrapper is a placeholder for the PKCS#11 wrapper, which requires cgo to
+// load the PKCS#11 module. It fails to be configured.
+type Wrapper struct{}
+
+var _ wrapping.Wrapper = (*Wrapper)(nil)
+
+func NewWrapper(_ *wrapping.WrapperOptions) *Wrapper {
+	return &Wrapper{}
+}
+
+func (w *Wrapper) SetConfig(_ map[string]string) (map[string]string, error) {
+	return nil, errNoCgo
+}
+
+func (w *Wrapper) Init(_ context.Context) error {
+	return nil
+}
+
+func (w *Wrapper) Finalize(_ context.Context) error {
+	return nil
+}
+
+func (w *Wrapper) Type() string {
+	return wrapping.PKCS11
+}
+
+func (w *Wrapper) KeyID() string {
+	return ""
+}
+
+func (w *Wrapper) HMACKeyID() string {
+	return ""
+}
+
+func (w *Wrapper) Encrypt(_ context.Context, _, _ []byte) (*wrapping.EncryptedBlobInfo, error) {
+	return nil, errNoCgo
+}
+
+func (w *Wrapper) Decrypt(_ context.Context, _ *wrapping.EncryptedBlobInfo, _ []byte) ([]byte, error) {
+	return nil, errNoCgo
+}
@@ -0,0 +1,89 @@
+// +build cgo
+
+package pkcs11
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+)
+
+func TestWrapper_SetConfig(t *testing.T) {
+	for name, config := range map[string]map[string]string{
+		"missing lib":     {"slot": "0", "pin": "1234", "key_label": "vault"},
+		"missing slot":    {"lib": "/nonexistent.so", "pin": "1234", "key_label": "vault"},
+		"missing pin":     {"lib": "/nonexistent.so", "slot": "0", "key_label": "vault"},
+		"missing label":   {"lib": "/nonexistent.so", "slot": "0", "pin": "1234"},
+		"bad slot":        {"lib": "/nonexistent.so", "slot": "first", "pin": "1234", "key_label": "vault"},
+		"bad mechanism":   {"lib": "/nonexistent.so", "slot": "0", "pin": "1234", "key_label": "vault", "mechanism": "0x1082"},
+		"missing library": {"lib": "/nonexistent.so", "slot": "0", "pin": "1234", "key_label": "vault"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if os.Getenv(EnvHSMLib) != "" {
+				t.Skip("configuration is overridden by the environment")
+			}
+			if _, err := NewWrapper(nil).SetConfig(config); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+// TestWrapper_Lifecycle runs against a PKCS#11 module such as SoftHSM, for
+// instance after initializing a token with:
+//
+//	softhsm2-util --init-token --free --label vault --so-pin 1234 --pin 1234
+//
+// and setting VAULT_HSM_LIB to the path of libsofthsm2.so,
+// VAULT_HSM_TOKEN_LABEL to vault and VAULT_HSM_PIN to 1234.
+func TestWrapper_Lifecycle(t *testing.T) {
+	if os.Getenv(EnvHSMLib) == "" {
+		t.Skipf("%s not set, skipping", EnvHSMLib)
+	}
+	ctx := context.Background()
+
+	config := map[string]string{
+		"key_label":    "vault-test-key",
+		"generate_key": "true",
+	}
+	w := NewWrapper(nil)
+	if _, err := w.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Finalize(ctx)
+
+	input := []byte("foo")
+	blob, err := w.Encrypt(ctx, input, []byte("aad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blob.KeyInfo.KeyID != "vault-test-key" {
+		t.Fatalf("bad key ID: %q", blob.KeyInfo.KeyID)
+	}
+
+	output, err := w.Decrypt(ctx, blob, []byte("aad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(input, output) {
+		t.Fatalf("expected %q, got %q", input, output)
+	}
+	if _, err := w.Decrypt(ctx, blob, []byte("other")); err == nil {
+		t.Fatal("expected error decrypting with different additional data")
+	}
+
+	// A new wrapper finds the generated key
+	other := NewWrapper(nil)
+	if _, err := other.SetConfig(map[string]string{"key_label": "vault-test-key"}); err != nil {
+		t.Fatal(err)
+	}
+	defer other.Finalize(ctx)
+	output, err = other.Decrypt(ctx, blob, []byte("aad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(input, output) {
+		t.Fatalf("expected %q, got %q", input, output)
+	}
+}
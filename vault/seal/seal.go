@@ -84,5 +84,10 @@ func (a *Access) Decrypt(ctx context.Context, data *wrapping.EncryptedBlobInfo,
 	metrics.IncrCounter([]string{"seal", "decrypt"}, 1)
 	metrics.IncrCounter([]string{"seal", a.Wrapper.Type(), "decrypt"}, 1)
 
+	// Values encrypted by a multi-seal remain readable by each of its seals
+	if _, ok := a.Wrapper.(*MultiWrapper); !ok && IsMultiWrapperBlob(data) {
+		return decryptMultiWrapperBlob(ctx, a.Wrapper, data, aad)
+	}
+
 	return a.Wrapper.Decrypt(ctx, data, aad)
 }
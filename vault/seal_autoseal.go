@@ -11,6 +11,7 @@ import (
 	"github.com/hashicorp/errwrap"
 	log "github.com/hashicorp/go-hclog"
 	wrapping "github.com/hashicorp/go-kms-wrapping"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
 	"github.com/hashicorp/vault/sdk/physical"
 	"github.com/hashicorp/vault/vault/seal"
 )
@@ -150,9 +151,52 @@ func (d *autoSeal) UpgradeKeys(ctx context.Context) error {
 	if err := d.upgradeStoredKeys(ctx); err != nil {
 		return err
 	}
+	if err := d.upgradeBarrierType(ctx); err != nil {
+		return err
+	}
 	return nil
 }
 
+// upgradeBarrierType updates the stored barrier seal type after the seal
+// was changed to a compatible one, such as from a single seal to a
+// multi-seal containing it, or back. It must be called after the keys have been
+// upgraded, so that they are encrypted by the new seal.
+func (d *autoSeal) upgradeBarrierType(ctx context.Context) error {
+	conf, err := d.BarrierConfig(ctx)
+	if err != nil {
+		return errwrap.Wrapf("failed to read barrier seal configuration: {{err}}", err)
+	}
+	if conf == nil || conf.Type == d.BarrierType() {
+		return nil
+	}
+
+	d.logger.Info("upgrading barrier seal type", "from", conf.Type, "to", d.BarrierType())
+	if err := d.SetBarrierConfig(ctx, conf); err != nil {
+		return errwrap.Wrapf("failed to save upgraded barrier seal configuration: {{err}}", err)
+	}
+	return nil
+}
+
+// barrierTypeCompatible returns whether a barrier sealed as described by the
+// stored configuration can be unsealed by the given seal without a seal
+// migration, which is the case for a multi-seal containing a seal of the
+// stored type, and for a seal of one of the types of the stored multi-seal
+// since each of its seals can decrypt its values.
+func barrierTypeCompatible(s Seal, stored *SealConfig) bool {
+	if stored.Type == s.BarrierType() {
+		return true
+	}
+	if s.GetAccess() == nil {
+		return false
+	}
+	multi, ok := s.GetAccess().Wrapper.(*seal.MultiWrapper)
+	if !ok {
+		return stored.Type == wrapping.MultiWrapper && s.BarrierType() != wrapping.Shamir &&
+			strutil.StrListContains(stored.MultiSealTypes, s.BarrierType())
+	}
+	return multi.HasType(stored.Type)
+}
+
 func (d *autoSeal) BarrierConfig(ctx context.Context) (*SealConfig, error) {
 	if d.barrierConfig.Load().(*SealConfig) != nil {
 		return d.barrierConfig.Load().(*SealConfig).Clone(), nil
@@ -193,7 +237,7 @@ func (d *autoSeal) BarrierConfig(ctx context.Context) (*SealConfig, error) {
 
 	barrierTypeUpgradeCheck(d.BarrierType(), conf)
 
-	if conf.Type != d.BarrierType() {
+	if !barrierTypeCompatible(d, conf) {
 		d.logger.Error("barrier seal type does not match loaded type", "seal_type", conf.Type, "loaded_type", d.BarrierType())
 		return nil, fmt.Errorf("barrier seal type of %q does not match loaded type of %q", conf.Type, d.BarrierType())
 	}
@@ -213,6 +257,10 @@ func (d *autoSeal) SetBarrierConfig(ctx context.Context, conf *SealConfig) error
 	}
 
 	conf.Type = d.BarrierType()
+	conf.MultiSealTypes = nil
+	if multi, ok := d.GetAccess().Wrapper.(*seal.MultiWrapper); ok {
+		conf.MultiSealTypes = multi.Types()
+	}
 
 	// Encode the seal configuration
 	buf, err := json.Marshal(conf)
@@ -8,6 +8,7 @@ import (
 
 	proto "github.com/golang/protobuf/proto"
 	wrapping "github.com/hashicorp/go-kms-wrapping"
+	"github.com/hashicorp/go-kms-wrapping/wrappers/aead"
 	"github.com/hashicorp/vault/sdk/physical"
 	"github.com/hashicorp/vault/vault/seal"
 )
@@ -157,3 +158,146 @@ func TestAutoSeal_UpgradeKeys(t *testing.T) {
 	}
 	check()
 }
+
+func TestAutoSeal_UpgradeToMultiSeal(t *testing.T) {
+	core, _, _ := TestCoreUnsealed(t)
+	pBackend := newTestBackend(t)
+	core.physical = pBackend
+	ctx := context.Background()
+
+	// Initialize with a single seal
+	primary := seal.NewTestSeal(&seal.TestSealOpts{Secret: []byte("primary")})
+	primary.Wrapper.(*wrapping.TestWrapper).SetKeyID("primary-key")
+	single := NewAutoSeal(primary)
+	single.SetCore(core)
+
+	inkeys := [][]byte{[]byte("grist"), []byte("house")}
+	if err := single.SetStoredKeys(ctx, inkeys); err != nil {
+		t.Fatal(err)
+	}
+	if err := single.SetRecoveryKey(ctx, []byte("falernum")); err != nil {
+		t.Fatal(err)
+	}
+	if err := single.SetBarrierConfig(ctx, &SealConfig{SecretShares: 1, SecretThreshold: 1, StoredShares: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a second seal
+	secondary := wrapping.NewTestWrapper([]byte("secondary"))
+	secondary.SetKeyID("secondary-key")
+	multiWrapper, err := seal.NewMultiWrapper(nil,
+		&seal.MultiWrapperEntry{Name: "primary", Priority: 1, Wrapper: primary.Wrapper},
+		&seal.MultiWrapperEntry{Name: "secondary", Priority: 2, Wrapper: secondary},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	multi := NewAutoSeal(&seal.Access{Wrapper: multiWrapper})
+	multi.SetCore(core)
+
+	// The barrier sealed by the single seal is compatible with the
+	// multi-seal, so no seal migration is needed
+	if conf, err := multi.BarrierConfig(ctx); err != nil || conf == nil {
+		t.Fatalf("BarrierConfig: want config, got %v %v", conf, err)
+	}
+	outkeys, err := multi.GetStoredKeys(ctx)
+	if err != nil || !reflect.DeepEqual(inkeys, outkeys) {
+		t.Fatalf("GetStoredKeys: want %v, got %v %v", inkeys, outkeys, err)
+	}
+
+	if err := multi.UpgradeKeys(ctx); err != nil {
+		t.Fatalf("UpgradeKeys: want no error, got %v", err)
+	}
+
+	// The keys are now encrypted under both seals, and the stored barrier
+	// type is updated
+	for _, key := range []string{StoredBarrierKeysPath, recoveryKeyPath} {
+		entry, _ := pBackend.Get(ctx, key)
+		blobInfo := &wrapping.EncryptedBlobInfo{}
+		if err := proto.Unmarshal(entry.Value, blobInfo); err != nil {
+			t.Fatal(err)
+		}
+		if want, got := multiWrapper.KeyID(), blobInfo.KeyInfo.KeyID; want != got {
+			t.Errorf("%s: incorrect encryption key: want %s, got %s", key, want, got)
+		}
+	}
+	multi.SetCachedBarrierConfig(nil)
+	conf, err := multi.BarrierConfig(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Type != wrapping.MultiWrapper {
+		t.Fatalf("barrier type not upgraded: %q", conf.Type)
+	}
+	if !reflect.DeepEqual(conf.MultiSealTypes, []string{wrapping.Test}) {
+		t.Fatalf("seal types of the multi-seal not stored: %v", conf.MultiSealTypes)
+	}
+
+	// A seal of a type that isn't part of the multi-seal needs a seal
+	// migration
+	other := NewAutoSeal(&seal.Access{Wrapper: aead.NewWrapper(nil)})
+	other.SetCore(core)
+	if _, err := other.BarrierConfig(ctx); err == nil {
+		t.Fatal("BarrierConfig: want error for a seal outside of the multi-seal")
+	}
+
+	// The secondary seal alone can now unseal
+	secondaryOnly, err := seal.NewMultiWrapper(nil,
+		&seal.MultiWrapperEntry{Name: "secondary", Priority: 1, Wrapper: secondary},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fallback := NewAutoSeal(&seal.Access{Wrapper: secondaryOnly})
+	fallback.SetCore(core)
+	outkeys, err = fallback.GetStoredKeys(ctx)
+	if err != nil || !reflect.DeepEqual(inkeys, outkeys) {
+		t.Fatalf("GetStoredKeys: want %v, got %v %v", inkeys, outkeys, err)
+	}
+
+	// Going back to the secondary seal alone doesn't need a seal migration
+	back := NewAutoSeal(&seal.Access{Wrapper: secondary})
+	back.SetCore(core)
+	core.seal = back
+	if err := core.adjustForSealMigration(nil); err != nil {
+		t.Fatal(err)
+	}
+	if core.migrationInfo != nil {
+		t.Fatal("unexpected seal migration")
+	}
+	if conf, err := back.BarrierConfig(ctx); err != nil || conf == nil {
+		t.Fatalf("BarrierConfig: want config, got %v %v", conf, err)
+	}
+	outkeys, err = back.GetStoredKeys(ctx)
+	if err != nil || !reflect.DeepEqual(inkeys, outkeys) {
+		t.Fatalf("GetStoredKeys: want %v, got %v %v", inkeys, outkeys, err)
+	}
+
+	if err := back.UpgradeKeys(ctx); err != nil {
+		t.Fatalf("UpgradeKeys: want no error, got %v", err)
+	}
+
+	// The keys are now encrypted by the secondary seal alone, and the stored
+	// barrier type is updated
+	for _, key := range []string{StoredBarrierKeysPath, recoveryKeyPath} {
+		entry, _ := pBackend.Get(ctx, key)
+		blobInfo := &wrapping.EncryptedBlobInfo{}
+		if err := proto.Unmarshal(entry.Value, blobInfo); err != nil {
+			t.Fatal(err)
+		}
+		if want, got := secondary.KeyID(), blobInfo.KeyInfo.KeyID; want != got {
+			t.Errorf("%s: incorrect encryption key: want %s, got %s", key, want, got)
+		}
+		if seal.IsMultiWrapperBlob(blobInfo) {
+			t.Errorf("%s: still encrypted by the multi-seal", key)
+		}
+	}
+	back.SetCachedBarrierConfig(nil)
+	conf, err = back.BarrierConfig(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Type != secondary.Type() {
+		t.Fatalf("barrier type not downgraded: %q", conf.Type)
+	}
+}
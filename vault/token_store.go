@@ -455,7 +455,7 @@ func (c *Core) LookupToken(ctx context.Context, token string) (*logical.TokenEnt
 	c.stateLock.RLock()
 	defer c.stateLock.RUnlock()
 
-	if c.standby && !c.perfStandby {
+	if c.standby && !c.perfStandby && !c.raftLocalReadStandby {
 		return nil, consts.ErrStandby
 	}
 
@@ -1282,7 +1282,7 @@ func (ts *TokenStore) lookupInternal(ctx context.Context, id string, salted, tai
 	// allowed. Fast-path this.
 	if len(entry.Policies) == 1 && entry.Policies[0] == "root" && entry.TTL == 0 {
 		// If fields are getting upgraded, store the changes
-		if persistNeeded {
+		if persistNeeded && !ts.core.raftLocalReadStandby {
 			if err := ts.store(ctx, entry); err != nil {
 				return nil, errwrap.Wrapf("failed to persist token upgrade: {{err}}", err)
 			}
@@ -1305,6 +1305,10 @@ func (ts *TokenStore) lookupInternal(ctx context.Context, id string, salted, tai
 	var ret *logical.TokenEntry
 
 	switch {
+	// It's any kind of expiring token with no lease, leave it to the active
+	// node to delete it
+	case le == nil && ts.core.raftLocalReadStandby:
+
 	// It's any kind of expiring token with no lease, immediately delete it
 	case le == nil:
 		tokenNS, err := NamespaceByID(ctx, entry.NamespaceID, ts.core)
@@ -1334,8 +1338,9 @@ func (ts *TokenStore) lookupInternal(ctx context.Context, id string, salted, tai
 		}
 	}
 
-	// If fields are getting upgraded, store the changes
-	if persistNeeded {
+	// If fields are getting upgraded, store the changes. Standbys serving
+	// local reads can't write, and leave it to the active node.
+	if persistNeeded && !ts.core.raftLocalReadStandby {
 		if err := ts.store(ctx, entry); err != nil {
 			return nil, errwrap.Wrapf("failed to persist token upgrade: {{err}}", err)
 		}
@@ -80,6 +80,9 @@ func (c *Core) wrapInCubbyhole(ctx context.Context, req *logical.Request, resp *
 	if c.perfStandby {
 		return forwardWrapRequest(ctx, c, req, resp, auth)
 	}
+	if c.raftLocalReadStandby {
+		return nil, logical.ErrPerfStandbyPleaseForward
+	}
 
 	// Before wrapping, obey special rules for listing: if no entries are
 	// found, 404. This prevents unwrapping only to find empty data.
@@ -344,7 +347,7 @@ func (c *Core) ValidateWrappingToken(ctx context.Context, req *logical.Request)
 	c.stateLock.RLock()
 	defer c.stateLock.RUnlock()
 
-	if c.standby && !c.perfStandby {
+	if c.standby && !c.perfStandby && !c.raftLocalReadStandby {
 		return false, consts.ErrStandby
 	}
 